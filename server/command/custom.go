@@ -0,0 +1,249 @@
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProjectDir is where a project keeps its custom commands, relative to the project root.
+var ProjectDir = filepath.Join(".claude", "commands")
+
+// Source tells where a command comes from.
+type Source string
+
+const (
+	SourceBuiltin Source = "builtin"
+	SourceProject Source = "project"
+	SourceUser    Source = "user"
+)
+
+// Definition describes a custom command read from a Markdown file.
+// A file at frontend/component.md is named "frontend:component"; deeper
+// directories are joined with "-" ("frontend-forms:validate").
+type Definition struct {
+	Name         string
+	Source       Source
+	Description  string
+	ArgumentHint string
+	AllowedTools []string
+	Path         string
+}
+
+// loader reads custom command files, reparsing only those that changed since
+// the last scan, so every listing reflects the files on disk.
+type loader struct {
+	mu    sync.Mutex
+	cache map[string]cachedDefinition // path -> parsed file
+}
+
+type cachedDefinition struct {
+	modTime time.Time
+	size    int64
+	def     Definition
+}
+
+func newLoader() *loader {
+	return &loader{cache: make(map[string]cachedDefinition)}
+}
+
+// load returns the commands found under dir, sorted by name.
+func (l *loader) load(dir string, source Source) []Definition {
+	if dir == "" {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var defs []Definition
+	seen := make(map[string]bool)
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || filepath.Ext(path) != ".md" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		seen[path] = true
+
+		cached, ok := l.cache[path]
+		if !ok || !cached.modTime.Equal(info.ModTime()) || cached.size != info.Size() {
+			def, ok := parseDefinition(dir, path)
+			if !ok {
+				return nil
+			}
+			cached = cachedDefinition{modTime: info.ModTime(), size: info.Size(), def: def}
+			l.cache[path] = cached
+		}
+		def := cached.def
+		def.Source = source
+		defs = append(defs, def)
+		return nil
+	})
+
+	// Forget deleted files
+	prefix := dir + string(filepath.Separator)
+	for path := range l.cache {
+		if strings.HasPrefix(path, prefix) && !seen[path] {
+			delete(l.cache, path)
+		}
+	}
+
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+func parseDefinition(dir, path string) (Definition, bool) {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return Definition{}, false
+	}
+	name := commandName(rel)
+	if !IsValidName(name) {
+		slog.Debug("skipping custom command with invalid name", "path", path, "name", name)
+		return Definition{}, false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		slog.Warn("failed to read custom command", "path", path, "error", err)
+		return Definition{}, false
+	}
+
+	def := Definition{Name: name, Path: path}
+	meta, body := splitFrontmatter(data)
+	def.Description = meta["description"]
+	def.ArgumentHint = meta["argument-hint"]
+	if tools := meta["allowed-tools"]; tools != "" {
+		def.AllowedTools = splitList(tools)
+	}
+	if def.Description == "" {
+		def.Description = firstLine(body)
+	}
+	return def, true
+}
+
+// commandName derives a command name from a path relative to the commands directory.
+func commandName(rel string) string {
+	rel = strings.TrimSuffix(filepath.ToSlash(rel), ".md")
+	namespace, base := "", rel
+	if i := strings.LastIndex(rel, "/"); i >= 0 {
+		namespace, base = strings.ReplaceAll(rel[:i], "/", "-"), rel[i+1:]
+	}
+	if namespace == "" {
+		return base
+	}
+	return namespace + ":" + base
+}
+
+// splitFrontmatter parses a leading "---" block of "key: value" lines. YAML block
+// lists ("- item") are joined into a comma-separated value.
+func splitFrontmatter(data []byte) (map[string]string, []byte) {
+	meta := make(map[string]string)
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	rest, ok := cutLine(data, "---")
+	if !ok {
+		return meta, data
+	}
+
+	var key string
+	for len(rest) > 0 {
+		var line []byte
+		line, rest, _ = bytes.Cut(rest, []byte("\n"))
+		text := strings.TrimRight(string(line), "\r")
+		if strings.TrimSpace(text) == "---" {
+			return meta, rest
+		}
+
+		trimmed := strings.TrimSpace(text)
+		if item, ok := strings.CutPrefix(trimmed, "- "); ok && key != "" {
+			if meta[key] != "" {
+				meta[key] += ", "
+			}
+			meta[key] += unquote(strings.TrimSpace(item))
+			continue
+		}
+		k, v, ok := strings.Cut(text, ":")
+		if !ok || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		key = strings.TrimSpace(k)
+		v = strings.TrimSpace(v)
+		if strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]") {
+			v = v[1 : len(v)-1]
+		}
+		meta[key] = unquote(v)
+	}
+	// Unterminated frontmatter: treat the whole file as the body
+	return make(map[string]string), data
+}
+
+// cutLine returns data after its first line if that line is want.
+func cutLine(data []byte, want string) ([]byte, bool) {
+	line, rest, _ := bytes.Cut(data, []byte("\n"))
+	if strings.TrimRight(string(line), "\r ") != want {
+		return nil, false
+	}
+	return rest, true
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// splitList splits a comma-separated list, ignoring commas inside parentheses
+// as in "Bash(git add:*), Bash(git commit:*)".
+func splitList(s string) []string {
+	var items []string
+	depth, start := 0, 0
+	add := func(item string) {
+		if item = unquote(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth <= 0 {
+				add(s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	add(s[start:])
+	return items
+}
+
+// firstLine returns the first non-empty line of a command body, without heading markers.
+func firstLine(body []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimLeft(scanner.Text(), "# "))
+		if line != "" {
+			return line
+		}
+	}
+	return ""
+}
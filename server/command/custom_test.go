@@ -0,0 +1,167 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeCommand(t *testing.T, dir, rel, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseDefinition_Frontmatter(t *testing.T) {
+	dir := t.TempDir()
+	path := writeCommand(t, dir, "commit.md", `---
+description: "Create a git commit"
+argument-hint: [message]
+allowed-tools: Bash(git add:*), Bash(git commit:*), Read
+model: claude-sonnet
+---
+Commit the staged changes with message: $ARGUMENTS
+`)
+
+	def, ok := parseDefinition(dir, path)
+	if !ok {
+		t.Fatal("expected definition to be parsed")
+	}
+	if def.Name != "commit" {
+		t.Errorf("Name = %q, want commit", def.Name)
+	}
+	if def.Description != "Create a git commit" {
+		t.Errorf("Description = %q", def.Description)
+	}
+	if def.ArgumentHint != "message" {
+		t.Errorf("ArgumentHint = %q", def.ArgumentHint)
+	}
+	want := []string{"Bash(git add:*)", "Bash(git commit:*)", "Read"}
+	if !reflect.DeepEqual(def.AllowedTools, want) {
+		t.Errorf("AllowedTools = %q, want %q", def.AllowedTools, want)
+	}
+}
+
+func TestParseDefinition_BlockListAndFallbackDescription(t *testing.T) {
+	dir := t.TempDir()
+	path := writeCommand(t, dir, "review.md", "---\r\nallowed-tools:\r\n  - Read\r\n  - \"Grep\"\r\n---\r\n\r\n# Review the current diff\r\nLook for bugs.\r\n")
+
+	def, ok := parseDefinition(dir, path)
+	if !ok {
+		t.Fatal("expected definition to be parsed")
+	}
+	if def.Description != "Review the current diff" {
+		t.Errorf("Description = %q", def.Description)
+	}
+	if want := []string{"Read", "Grep"}; !reflect.DeepEqual(def.AllowedTools, want) {
+		t.Errorf("AllowedTools = %q, want %q", def.AllowedTools, want)
+	}
+}
+
+func TestParseDefinition_NoFrontmatter(t *testing.T) {
+	dir := t.TempDir()
+	path := writeCommand(t, dir, "plain.md", "---not frontmatter\nExplain this code\n")
+
+	def, ok := parseDefinition(dir, path)
+	if !ok {
+		t.Fatal("expected definition to be parsed")
+	}
+	if def.Description != "---not frontmatter" {
+		t.Errorf("Description = %q", def.Description)
+	}
+}
+
+func TestCommandName(t *testing.T) {
+	tests := map[string]string{
+		"fix.md":                     "fix",
+		"frontend/component.md":      "frontend:component",
+		"frontend/forms/validate.md": "frontend-forms:validate",
+	}
+	for rel, want := range tests {
+		if got := commandName(filepath.FromSlash(rel)); got != want {
+			t.Errorf("commandName(%q) = %q, want %q", rel, got, want)
+		}
+	}
+}
+
+func TestListFor_CustomCommands(t *testing.T) {
+	projectDir := t.TempDir()
+	userDir := t.TempDir()
+	commandsDir := filepath.Join(projectDir, ProjectDir)
+	writeCommand(t, commandsDir, "deploy.md", "---\ndescription: Deploy (project)\n---\n")
+	writeCommand(t, commandsDir, "frontend/component.md", "Create a component\n")
+	writeCommand(t, commandsDir, "Invalid Name.md", "Ignored\n")
+	writeCommand(t, commandsDir, "notes.txt", "Ignored\n")
+	writeCommand(t, userDir, "deploy.md", "---\ndescription: Deploy (user)\n---\n")
+	writeCommand(t, userDir, "standup.md", "Summarize my work\n")
+
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetUserDir(userDir)
+	store.Use("standup")
+
+	commands := store.ListFor(projectDir)
+
+	var names []string
+	for _, cmd := range commands {
+		names = append(names, cmd.Name)
+	}
+	wantNames := append([]string{"standup", "deploy", "frontend:component"}, BuiltinCommands...)
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Fatalf("names = %v, want %v", names, wantNames)
+	}
+
+	if commands[0].Source != SourceUser || commands[0].Description != "Summarize my work" {
+		t.Errorf("standup = %+v", commands[0])
+	}
+	if commands[1].Source != SourceProject || commands[1].Description != "Deploy (project)" || commands[1].IsBuiltin {
+		t.Errorf("deploy = %+v, want the project definition", commands[1])
+	}
+	if last := commands[len(commands)-1]; last.Source != SourceBuiltin || !last.IsBuiltin {
+		t.Errorf("builtin = %+v", last)
+	}
+
+	// Without a project, only user commands are listed
+	for _, cmd := range store.List() {
+		if cmd.Source == SourceProject {
+			t.Errorf("unexpected project command %q", cmd.Name)
+		}
+	}
+}
+
+func TestListFor_RefreshesChangedFiles(t *testing.T) {
+	userDir := t.TempDir()
+	path := writeCommand(t, userDir, "hello.md", "First\n")
+
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetUserDir(userDir)
+
+	if got := store.List()[0].Description; got != "First" {
+		t.Fatalf("Description = %q, want First", got)
+	}
+
+	os.WriteFile(path, []byte("Second version\n"), 0644)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+	if got := store.List()[0].Description; got != "Second version" {
+		t.Errorf("Description = %q after change, want Second version", got)
+	}
+
+	os.Remove(path)
+	if commands := store.List(); len(commands) != len(BuiltinCommands) {
+		t.Errorf("expected deleted command to disappear, got %d commands", len(commands))
+	}
+}
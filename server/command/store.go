@@ -1,4 +1,5 @@
-// Package command manages slash command history and builtin command definitions.
+// Package command manages slash command history, builtin command definitions and
+// custom commands defined in Markdown files.
 package command
 
 import (
@@ -37,21 +38,28 @@ type RecentCommand struct {
 }
 
 // Command is the API response type with builtin flag.
+// Source is empty for commands only known from history.
 type Command struct {
-	Name      string `json:"name"`
-	IsBuiltin bool   `json:"isBuiltin"`
+	Name         string   `json:"name"`
+	IsBuiltin    bool     `json:"isBuiltin"`
+	Source       Source   `json:"source,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	ArgumentHint string   `json:"argumentHint,omitempty"`
+	AllowedTools []string `json:"allowedTools,omitempty"`
 }
 
-// Store manages slash command history.
+// Store manages slash command history and discovers custom commands.
 type Store struct {
 	dataDir string
+	userDir string // user custom commands, e.g. ~/.claude/commands
+	loader  *loader
 	mu      sync.RWMutex
 	recent  []RecentCommand // in-memory cache
 }
 
 // NewStore creates a new command store.
 func NewStore(dataDir string) (*Store, error) {
-	store := &Store{dataDir: dataDir}
+	store := &Store{dataDir: dataDir, loader: newLoader()}
 
 	recent, err := store.readFromDisk()
 	if err != nil {
@@ -107,8 +115,52 @@ func (s *Store) persist() error {
 	return os.WriteFile(s.filePath(), data, 0644)
 }
 
-// List returns commands sorted by most recently used, with unused builtins appended at the end.
+// SetUserDir sets the directory of the user's custom commands. Must be called before List.
+func (s *Store) SetUserDir(dir string) {
+	s.userDir = dir
+}
+
+// List returns the commands available outside of any project; see ListFor.
 func (s *Store) List() []Command {
+	return s.ListFor("")
+}
+
+// ListFor returns commands sorted by most recently used, followed by the unused
+// custom commands of projectDir and of the user, then the unused builtins.
+// A project command takes precedence over a user command of the same name.
+func (s *Store) ListFor(projectDir string) []Command {
+	custom := make(map[string]Definition)
+	var customOrder []string
+	for _, source := range []struct {
+		dir    string
+		source Source
+	}{
+		{s.projectCommandsDir(projectDir), SourceProject},
+		{s.userDir, SourceUser},
+	} {
+		for _, def := range s.loader.load(source.dir, source.source) {
+			if _, ok := custom[def.Name]; !ok {
+				custom[def.Name] = def
+				customOrder = append(customOrder, def.Name)
+			}
+		}
+	}
+	newCommand := func(name string) Command {
+		if def, ok := custom[name]; ok {
+			return Command{
+				Name:         name,
+				Source:       def.Source,
+				Description:  def.Description,
+				ArgumentHint: def.ArgumentHint,
+				AllowedTools: def.AllowedTools,
+			}
+		}
+		if slices.Contains(BuiltinCommands, name) {
+			return Command{Name: name, IsBuiltin: true, Source: SourceBuiltin}
+		}
+		return Command{Name: name}
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -119,28 +171,30 @@ func (s *Store) List() []Command {
 	})
 
 	seen := make(map[string]bool)
-	commands := make([]Command, 0, len(sorted)+len(BuiltinCommands))
+	commands := make([]Command, 0, len(sorted)+len(customOrder)+len(BuiltinCommands))
 	for _, rc := range sorted {
 		seen[rc.Name] = true
-		commands = append(commands, Command{
-			Name:      rc.Name,
-			IsBuiltin: slices.Contains(BuiltinCommands, rc.Name),
-		})
+		commands = append(commands, newCommand(rc.Name))
 	}
 
-	for _, name := range BuiltinCommands {
+	for _, name := range append(customOrder, BuiltinCommands...) {
 		if seen[name] {
 			continue
 		}
-		commands = append(commands, Command{
-			Name:      name,
-			IsBuiltin: true,
-		})
+		seen[name] = true
+		commands = append(commands, newCommand(name))
 	}
 
 	return commands
 }
 
+func (s *Store) projectCommandsDir(projectDir string) string {
+	if projectDir == "" {
+		return ""
+	}
+	return filepath.Join(projectDir, ProjectDir)
+}
+
 const maxRecentCommands = 1000
 
 // Use records a command usage. Returns false if name is invalid.
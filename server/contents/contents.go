@@ -2,13 +2,12 @@
 package contents
 
 import (
-	"encoding/base64"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
+	"time"
 )
 
 var (
@@ -36,6 +35,32 @@ func ValidatePath(workDir, path string) error {
 	return nil
 }
 
+// ResolvePath returns the real location of path within workDir, following symlinks.
+// Returns ErrNotFound if path doesn't exist and ErrInvalidPath if it resolves outside
+// workDir.
+func ResolvePath(workDir, path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(filepath.Join(workDir, path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("%w: %s", ErrNotFound, path)
+		}
+		return "", err
+	}
+	root, err := filepath.EvalSymlinks(workDir)
+	if err != nil {
+		return "", err
+	}
+	if !isWithin(root, resolved) {
+		return "", fmt.Errorf("%w: %s", ErrInvalidPath, path)
+	}
+	return resolved, nil
+}
+
+// isWithin reports whether path is root or below it. Both must be clean.
+func isWithin(root, path string) bool {
+	return path == root || strings.HasPrefix(path, root+string(filepath.Separator))
+}
+
 type EntryType string
 
 const (
@@ -51,9 +76,18 @@ const (
 )
 
 type Entry struct {
-	Name string    `json:"name"`
-	Type EntryType `json:"type"`
-	Path string    `json:"path"`
+	Name    string    `json:"name"`
+	Type    EntryType `json:"type"` // of the symlink target for symlinks
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Mode    string    `json:"mode"`              // e.g. "-rw-r--r--", "Lrwxrwxrwx"
+	Symlink string    `json:"symlink,omitempty"` // link target
+
+	GitStatus string `json:"git_status,omitempty"` // see git.PathStatuses; for directories, of their contents
+	Ignored   bool   `json:"ignored,omitempty"`    // excluded by .gitignore
+
+	Children []Entry `json:"children,omitempty"` // for directories within the requested depth
 }
 
 type FileContent struct {
@@ -62,13 +96,20 @@ type FileContent struct {
 	Path     string    `json:"path"`
 	Content  string    `json:"content"`
 	Encoding Encoding  `json:"encoding"`
+	Hash     string    `json:"hash,omitempty"` // precondition for file.write (see HashContent); whole-file reads only
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mtime"`
+
+	Range     *ContentRange `json:"range,omitempty"`     // set for ranged reads
+	Truncated bool          `json:"truncated,omitempty"` // a ranged read stopped at the max size
 }
 
 // ContentsResult holds the result of GetContents.
 // Either Entries (for directories) or File (for files) is set, never both.
 type ContentsResult struct {
-	Entries []Entry      // Directory listing (nil if file)
-	File    *FileContent // File content (nil if directory)
+	Entries   []Entry      // Directory listing (nil if file)
+	Truncated bool         // Directory listing stopped at MaxListEntries
+	File      *FileContent // File content (nil if directory)
 }
 
 // IsDir returns true if the result is a directory listing.
@@ -76,9 +117,11 @@ func (r ContentsResult) IsDir() bool {
 	return r.File == nil
 }
 
-// GetContents returns directory entries or file content.
-// Returns ErrNotFound if path doesn't exist, ErrInvalidPath for path traversal attempts.
-func GetContents(workDir, path string) (ContentsResult, error) {
+// GetContents returns directory entries (as configured by list) or file content
+// (the part selected by read).
+// Returns ErrNotFound if path doesn't exist, ErrInvalidPath for path traversal attempts,
+// ErrInvalidRange for invalid read options and a *TooLargeError if the file is too large to read whole.
+func GetContents(workDir, path string, list ListOptions, read ReadOptions) (ContentsResult, error) {
 	if err := ValidatePath(workDir, path); err != nil {
 		return ContentsResult{}, err
 	}
@@ -93,77 +136,21 @@ func GetContents(workDir, path string) (ContentsResult, error) {
 	}
 
 	if info.IsDir() {
-		entries, err := listDir(path, fullPath)
+		entries, truncated, err := listDir(workDir, path, list)
 		if err != nil {
-			return ContentsResult{}, err
+			return ContentsResult{}, fmt.Errorf("failed to read directory: %w", err)
 		}
-		return ContentsResult{Entries: entries}, nil
+		return ContentsResult{Entries: entries, Truncated: truncated}, nil
 	}
 
-	file, err := readFile(path, fullPath, info)
-	if err != nil {
+	if err := read.validate(); err != nil {
 		return ContentsResult{}, err
 	}
-	return ContentsResult{File: file}, nil
-}
-
-func listDir(relPath, fullPath string) ([]Entry, error) {
-	dirEntries, err := os.ReadDir(fullPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read directory: %w", err)
-	}
-
-	entries := make([]Entry, 0, len(dirEntries))
-	for _, de := range dirEntries {
-		entryPath := de.Name()
-		if relPath != "" {
-			entryPath = relPath + "/" + de.Name()
-		}
-		entry := Entry{
-			Name: de.Name(),
-			Path: entryPath,
-		}
-
-		if de.IsDir() {
-			entry.Type = TypeDir
-		} else {
-			entry.Type = TypeFile
-		}
-
-		entries = append(entries, entry)
-	}
-
-	sort.Slice(entries, func(i, j int) bool {
-		if entries[i].Type != entries[j].Type {
-			return entries[i].Type == TypeDir
-		}
-		return entries[i].Name < entries[j].Name
-	})
-
-	return entries, nil
-}
-
-func readFile(relPath, fullPath string, info os.FileInfo) (*FileContent, error) {
-	content, err := os.ReadFile(fullPath)
+	file, err := readFile(path, fullPath, info, read)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
-	}
-
-	encoding := EncodingText
-	contentStr := string(content)
-
-	if isBinary(content) {
-		encoding = EncodingBase64
-		contentStr = base64.StdEncoding.EncodeToString(content)
+		return ContentsResult{}, err
 	}
-
-	return &FileContent{
-		Name:     info.Name(),
-		Type:     TypeFile,
-		Path:     relPath,
-		Content:  contentStr,
-		Encoding: encoding,
-	}, nil
+	return ContentsResult{File: file}, nil
 }
 
 // isBinary detects binary content by checking for null bytes in the first 512 bytes.
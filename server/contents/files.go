@@ -0,0 +1,71 @@
+package contents
+
+import (
+	"context"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ListFiles returns the paths (relative, slash-separated) of all files in workDir
+// that are not ignored. In a git repository this is the tracked files plus untracked
+// files not excluded by .gitignore; otherwise every file outside .git directories.
+func ListFiles(ctx context.Context, workDir string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "git", "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	cmd.Dir = workDir
+	output, err := cmd.Output()
+	if err == nil {
+		return parseFileList(string(output)), nil
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return walkFiles(ctx, workDir)
+}
+
+func parseFileList(output string) []string {
+	seen := make(map[string]bool)
+	files := []string{}
+	for _, path := range strings.Split(output, "\x00") {
+		// Files with unmerged entries are listed once per stage
+		if path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		files = append(files, path)
+	}
+	return files
+}
+
+func walkFiles(ctx context.Context, workDir string) ([]string, error) {
+	files := []string{}
+	err := filepath.WalkDir(workDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Skip unreadable entries rather than failing the whole listing
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(workDir, path)
+		if err != nil {
+			return nil
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
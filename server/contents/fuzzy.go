@@ -0,0 +1,176 @@
+package contents
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// DefaultFindLimit caps results when FindOptions.Limit is not set.
+	DefaultFindLimit = 50
+	// maxFindLimit caps FindOptions.Limit.
+	maxFindLimit = 500
+)
+
+// Scoring weights for fuzzy matches. Matches on word boundaries and in the
+// file name rank higher; gaps and long paths rank lower.
+const (
+	scoreMatch       = 16
+	scoreConsecutive = 8
+	scoreBoundary    = 10 // after "/" or at the start of the path
+	scoreSeparator   = 8  // after "-", "_", "." or " "
+	scoreCamelCase   = 7
+	scoreBasename    = 12 // the whole query matches within the file name
+	penaltyGap       = 1
+	maxGapPenalty    = 16
+)
+
+// FindResult is a path matching a fuzzy query.
+type FindResult struct {
+	Path      string `json:"path"`
+	Score     int    `json:"score"`
+	Positions []int  `json:"positions"` // matched character indexes in Path, for highlighting
+}
+
+// FindOptions configures a fuzzy find.
+type FindOptions struct {
+	Query string
+	Limit int // default DefaultFindLimit, at most 500
+}
+
+// Find fuzzy-matches query against files and returns the best matches, highest
+// score first, along with the total number of matching files. Characters of the
+// query must appear in order in the path; matching is case-insensitive and spaces
+// in the query are ignored. An empty query matches every file.
+func Find(files []string, opts FindOptions) ([]FindResult, int) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultFindLimit
+	}
+	limit = min(limit, maxFindLimit)
+
+	query := []rune(strings.ToLower(strings.ReplaceAll(opts.Query, " ", "")))
+
+	results := []FindResult{}
+	for _, path := range files {
+		score, positions, ok := fuzzyMatch(query, path)
+		if !ok {
+			continue
+		}
+		results = append(results, FindResult{Path: path, Score: score, Positions: positions})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if len(a.Path) != len(b.Path) {
+			return len(a.Path) < len(b.Path)
+		}
+		return a.Path < b.Path
+	})
+
+	total := len(results)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, total
+}
+
+// fuzzyMatch reports whether query (lower-cased) matches path, preferring a match
+// within the file name. Positions are character indexes in path.
+func fuzzyMatch(query []rune, path string) (int, []int, bool) {
+	runes := []rune(path)
+	if len(query) == 0 {
+		return 0, []int{}, true
+	}
+	lower := []rune(strings.ToLower(path))
+	if len(lower) != len(runes) {
+		// Lower-casing changed the length (rare Unicode cases); match as-is
+		lower = runes
+	}
+
+	base := 0
+	for i, r := range runes {
+		if r == '/' {
+			base = i + 1
+		}
+	}
+
+	if positions := matchPositions(query, lower, base); positions != nil {
+		return score(runes, positions) + scoreBasename, positions, true
+	}
+	if positions := matchPositions(query, lower, 0); positions != nil {
+		return score(runes, positions), positions, true
+	}
+	return 0, nil, false
+}
+
+// matchPositions finds query in text starting at from. It scans forward for the
+// first complete match, then backward from its end to find the shortest match
+// ending there, which keeps highlighted characters close together.
+func matchPositions(query, text []rune, from int) []int {
+	qi := 0
+	end := -1
+	for i := from; i < len(text); i++ {
+		if text[i] == query[qi] {
+			qi++
+			if qi == len(query) {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return nil
+	}
+
+	positions := make([]int, len(query))
+	qi = len(query) - 1
+	for i := end; i >= from && qi >= 0; i-- {
+		if text[i] == query[qi] {
+			positions[qi] = i
+			qi--
+		}
+	}
+	return positions
+}
+
+func score(text []rune, positions []int) int {
+	total := 0
+	consecutive := 0
+	for i, pos := range positions {
+		s := scoreMatch
+		if i > 0 && pos == positions[i-1]+1 {
+			consecutive++
+			s += scoreConsecutive * consecutive
+		} else {
+			consecutive = 0
+			if i > 0 {
+				total -= min((pos-positions[i-1]-1)*penaltyGap, maxGapPenalty)
+			}
+		}
+		s += boundaryBonus(text, pos)
+		total += s
+	}
+	// Prefer shorter paths among otherwise equal matches
+	return total - len(text)/8
+}
+
+func boundaryBonus(text []rune, pos int) int {
+	if pos == 0 {
+		return scoreBoundary
+	}
+	prev, cur := text[pos-1], text[pos]
+	switch {
+	case prev == '/':
+		return scoreBoundary
+	case prev == '-' || prev == '_' || prev == '.' || prev == ' ':
+		return scoreSeparator
+	case unicode.IsLower(prev) && unicode.IsUpper(cur):
+		return scoreCamelCase
+	}
+	return 0
+}
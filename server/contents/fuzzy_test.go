@@ -0,0 +1,121 @@
+package contents
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestFind_Ranking(t *testing.T) {
+	files := []string{
+		"server/ws/rpc_test.go",
+		"server/rpc/types.go",
+		"server/ws/rpc.go",
+		"web/src/lib/rpcClient.ts",
+		"docs/README.md",
+	}
+
+	results, total := Find(files, FindOptions{Query: "rpc.go"})
+	if total != 3 {
+		t.Fatalf("total = %d, want 3: %+v", total, results)
+	}
+	if results[0].Path != "server/ws/rpc.go" {
+		t.Errorf("best match = %s, want server/ws/rpc.go", results[0].Path)
+	}
+	if want := []int{10, 11, 12, 13, 14, 15}; !reflect.DeepEqual(results[0].Positions, want) {
+		t.Errorf("positions = %v, want %v", results[0].Positions, want)
+	}
+
+	// Matches in the file name beat matches in directories
+	results, _ = Find([]string{"remain/util.go", "cmd/main.go"}, FindOptions{Query: "main"})
+	if results[0].Path != "cmd/main.go" {
+		t.Errorf("best match = %s, want cmd/main.go", results[0].Path)
+	}
+
+	// Word boundaries beat scattered characters
+	results, _ = Find([]string{"src/preact/client.ts", "src/rpcClient.ts"}, FindOptions{Query: "rcl"})
+	if results[0].Path != "src/rpcClient.ts" {
+		t.Errorf("best match = %s, want src/rpcClient.ts", results[0].Path)
+	}
+
+	// Queries spanning directories match the whole path
+	results, _ = Find(files, FindOptions{Query: "ws rpc"})
+	if len(results) == 0 || results[0].Path != "server/ws/rpc.go" {
+		t.Errorf("results = %+v", results)
+	}
+
+	if results, total := Find(files, FindOptions{Query: "xyz"}); total != 0 || len(results) != 0 {
+		t.Errorf("expected no matches, got %+v", results)
+	}
+}
+
+func TestFind_Limit(t *testing.T) {
+	files := []string{"a1", "a2", "a3"}
+
+	results, total := Find(files, FindOptions{Query: "", Limit: 2})
+	if total != 3 || len(results) != 2 {
+		t.Errorf("got %d results of %d", len(results), total)
+	}
+}
+
+func TestFileIndex_Invalidate(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644)
+	idx := NewFileIndex(dir)
+
+	files, err := idx.Files(context.Background())
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Files() = %v, %v", files, err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b"), 0644)
+	if files, _ := idx.Files(context.Background()); len(files) != 1 {
+		t.Errorf("index rebuilt without a change: %v", files)
+	}
+
+	// Outside a git repository changed paths cannot be listed alone
+	idx.Changed("b.txt")
+	if files, _ := idx.Files(context.Background()); len(files) != 2 {
+		t.Errorf("index not rebuilt after change: %v", files)
+	}
+}
+
+func TestFileIndex_Changed(t *testing.T) {
+	dir := t.TempDir()
+	cmd := exec.Command("git", "init", "-q")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*.log\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644)
+	idx := NewFileIndex(dir)
+	ctx := context.Background()
+
+	if files, err := idx.Files(ctx); err != nil || !slices.Equal(files, []string{".gitignore", "a.txt"}) {
+		t.Fatalf("Files() = %v, %v", files, err)
+	}
+
+	os.MkdirAll(filepath.Join(dir, "sub", "deep"), 0755)
+	os.WriteFile(filepath.Join(dir, "sub", "deep", "b.txt"), []byte("b"), 0644)
+	os.WriteFile(filepath.Join(dir, "debug.log"), []byte("log"), 0644)
+	os.WriteFile(filepath.Join(dir, "unreported.txt"), []byte("c"), 0644)
+	os.Remove(filepath.Join(dir, "a.txt"))
+	idx.Changed("sub", "debug.log", "a.txt")
+
+	// Only the reported paths are listed again
+	want := []string{".gitignore", "sub/deep/b.txt"}
+	if files, err := idx.Files(ctx); err != nil || !slices.Equal(files, want) {
+		t.Errorf("Files() after changes = %v, %v, want %v", files, err, want)
+	}
+
+	idx.Invalidate()
+	want = []string{".gitignore", "sub/deep/b.txt", "unreported.txt"}
+	if files, err := idx.Files(ctx); err != nil || !slices.Equal(files, want) {
+		t.Errorf("Files() after Invalidate = %v, %v, want %v", files, err, want)
+	}
+}
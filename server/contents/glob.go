@@ -0,0 +1,131 @@
+package contents
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Glob matches slash-separated relative paths against a glob pattern.
+//
+// Supported syntax: * (any characters except /), ** (any characters including /),
+// ? (one character except /), [abc] character classes and {a,b} alternatives.
+// A pattern without / matches the file name or any directory name in the path
+// (e.g. "*.go", "node_modules"). A pattern with / matches the path from the root,
+// or any of its parent directories (e.g. "src/**/*.ts", "docs").
+type Glob struct {
+	re *regexp.Regexp
+}
+
+// CompileGlob parses a glob pattern.
+func CompileGlob(pattern string) (*Glob, error) {
+	pattern = strings.TrimPrefix(strings.TrimSuffix(pattern, "/"), "./")
+	if pattern == "" {
+		return nil, fmt.Errorf("empty glob pattern")
+	}
+
+	var expr strings.Builder
+	if strings.Contains(pattern, "/") {
+		expr.WriteString("^")
+	} else {
+		expr.WriteString("(?:^|/)")
+	}
+
+	inClass := false
+	braceDepth := 0
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if inClass {
+			if c == ']' {
+				inClass = false
+			}
+			if c == '\\' {
+				expr.WriteString(`\\`)
+				continue
+			}
+			expr.WriteByte(c)
+			continue
+		}
+
+		switch c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					expr.WriteString("(?:.*/)?")
+				} else {
+					expr.WriteString(".*")
+				}
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			inClass = true
+			expr.WriteByte('[')
+			if i+1 < len(pattern) && pattern[i+1] == '!' {
+				i++
+				expr.WriteByte('^')
+			}
+		case '{':
+			braceDepth++
+			expr.WriteString("(?:")
+		case '}':
+			if braceDepth == 0 {
+				expr.WriteString(`\}`)
+				continue
+			}
+			braceDepth--
+			expr.WriteString(")")
+		case ',':
+			if braceDepth > 0 {
+				expr.WriteString("|")
+			} else {
+				expr.WriteString(",")
+			}
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if inClass || braceDepth > 0 {
+		return nil, fmt.Errorf("invalid glob pattern: %s", pattern)
+	}
+
+	// Matching a directory also matches everything below it
+	expr.WriteString("(?:/|$)")
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob pattern: %s", pattern)
+	}
+	return &Glob{re: re}, nil
+}
+
+// Match reports whether path, or one of its parent directories, matches the pattern.
+func (g *Glob) Match(path string) bool {
+	return g.re.MatchString(path)
+}
+
+// compileGlobs compiles all patterns, failing on the first invalid one.
+func compileGlobs(patterns []string) ([]*Glob, error) {
+	globs := make([]*Glob, 0, len(patterns))
+	for _, pattern := range patterns {
+		g, err := CompileGlob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		globs = append(globs, g)
+	}
+	return globs, nil
+}
+
+func matchAny(globs []*Glob, path string) bool {
+	for _, g := range globs {
+		if g.Match(path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package contents
+
+import (
+	"context"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxPendingPaths bounds the paths refreshed incrementally; beyond that a full
+// rebuild is cheaper.
+const maxPendingPaths = 256
+
+// FileIndex caches the file list of a worktree (see ListFiles) for fuzzy finding.
+// Changed paths are refreshed on the next Files call by listing only them;
+// Invalidate forces a full rebuild (e.g. after a checkout, or when changes were missed).
+type FileIndex struct {
+	workDir string
+
+	mu    sync.Mutex // serializes updates of files
+	files []string
+
+	changeMu sync.Mutex
+	stale    bool
+	pending  map[string]bool // paths to refresh
+}
+
+func NewFileIndex(workDir string) *FileIndex {
+	return &FileIndex{workDir: workDir, pending: make(map[string]bool)}
+}
+
+// Files returns the indexed paths, updating the index with the changes reported
+// since the last call. The returned slice must not be modified.
+func (idx *FileIndex) Files(ctx context.Context) ([]string, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	// Take changes before listing so changes during the update are kept for the next call
+	stale, paths := idx.takeChanges()
+	if idx.files != nil && !stale && len(paths) > 0 {
+		if files, err := idx.refresh(ctx, paths); err == nil {
+			idx.files = files
+			return files, nil
+		}
+		if ctx.Err() != nil {
+			idx.Changed(paths...)
+			return nil, ctx.Err()
+		}
+		// Not a git repository (any more): list everything
+		stale = true
+	}
+	if idx.files != nil && !stale {
+		return idx.files, nil
+	}
+
+	files, err := ListFiles(ctx, idx.workDir)
+	if err != nil {
+		idx.Invalidate()
+		return nil, err
+	}
+	idx.files = files
+	return files, nil
+}
+
+// Invalidate makes the next Files call rebuild the whole index.
+func (idx *FileIndex) Invalidate() {
+	idx.changeMu.Lock()
+	defer idx.changeMu.Unlock()
+	idx.stale = true
+	clear(idx.pending)
+}
+
+// Changed makes the next Files call refresh paths (files or directories, relative
+// to the worktree and slash-separated) that were created, removed or renamed.
+func (idx *FileIndex) Changed(paths ...string) {
+	idx.changeMu.Lock()
+	defer idx.changeMu.Unlock()
+	if idx.stale {
+		return
+	}
+	for _, path := range paths {
+		idx.pending[path] = true
+	}
+	if len(idx.pending) > maxPendingPaths {
+		idx.stale = true
+		clear(idx.pending)
+	}
+}
+
+func (idx *FileIndex) takeChanges() (bool, []string) {
+	idx.changeMu.Lock()
+	defer idx.changeMu.Unlock()
+
+	stale := idx.stale
+	idx.stale = false
+	paths := make([]string, 0, len(idx.pending))
+	for path := range idx.pending {
+		paths = append(paths, path)
+	}
+	clear(idx.pending)
+	return stale, paths
+}
+
+// refresh returns the index with the entries at or below paths listed again.
+func (idx *FileIndex) refresh(ctx context.Context, paths []string) ([]string, error) {
+	args := append([]string{"--literal-pathspecs", "ls-files", "-z", "--cached", "--others", "--exclude-standard", "--"}, paths...)
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = idx.workDir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		changed[path] = true
+	}
+	files := make([]string, 0, len(idx.files))
+	for _, file := range idx.files {
+		if !underAny(file, changed) {
+			files = append(files, file)
+		}
+	}
+	files = append(files, parseFileList(string(output))...)
+	sort.Strings(files)
+	return files, nil
+}
+
+// underAny reports whether path or one of its parent directories is in dirs.
+func underAny(path string, dirs map[string]bool) bool {
+	for {
+		if dirs[path] {
+			return true
+		}
+		i := strings.LastIndexByte(path, '/')
+		if i < 0 {
+			return false
+		}
+		path = path[:i]
+	}
+}
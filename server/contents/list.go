@@ -0,0 +1,188 @@
+package contents
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pockode/server/git"
+)
+
+const (
+	// MaxListDepth caps ListOptions.Depth.
+	MaxListDepth = 10
+	// MaxListEntries caps the entries of a single (recursive) listing.
+	MaxListEntries = 10000
+)
+
+// ListOptions configures a directory listing.
+type ListOptions struct {
+	Depth         int  // levels to list; 0 and 1 list direct children only, at most MaxListDepth
+	ExcludeHidden bool // skip entries whose name starts with "."
+}
+
+// lister builds a directory listing, annotating entries with their git status.
+type lister struct {
+	workDir   string
+	opts      ListOptions
+	statuses  map[string]string // see git.PathStatuses
+	dirStatus map[string]string // aggregated status of directories containing changes
+	remaining int
+	truncated bool
+}
+
+// listDir lists relPath, recursing into subdirectories up to opts.Depth. Ignored
+// directories, .git and symlinked directories are listed but not recursed into.
+// The bool result reports whether the listing stopped at MaxListEntries.
+func listDir(workDir, relPath string, opts ListOptions) ([]Entry, bool, error) {
+	l := &lister{
+		workDir:   workDir,
+		opts:      opts,
+		remaining: MaxListEntries,
+	}
+	l.loadGitStatus(relPath)
+
+	entries, err := l.list(relPath, max(1, min(opts.Depth, MaxListDepth)))
+	if err != nil {
+		return nil, false, err
+	}
+	return entries, l.truncated, nil
+}
+
+func (l *lister) loadGitStatus(relPath string) {
+	// Outside a git repository entries simply have no status
+	statuses, err := git.PathStatuses(l.workDir, relPath)
+	if err != nil {
+		return
+	}
+	l.statuses = statuses
+	l.dirStatus = make(map[string]string)
+	for p, status := range statuses {
+		if status == git.PathIgnored {
+			continue
+		}
+		for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+			switch l.dirStatus[dir] {
+			case "":
+				l.dirStatus[dir] = status
+			case status:
+			default:
+				// Mixed changes below a directory are shown as modified
+				l.dirStatus[dir] = "M"
+			}
+		}
+	}
+}
+
+func (l *lister) list(relPath string, depth int) ([]Entry, error) {
+	dirEntries, err := os.ReadDir(filepath.Join(l.workDir, relPath))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(dirEntries))
+	for _, de := range dirEntries {
+		if l.opts.ExcludeHidden && strings.HasPrefix(de.Name(), ".") {
+			continue
+		}
+		if l.remaining == 0 {
+			l.truncated = true
+			break
+		}
+
+		entryPath := de.Name()
+		if relPath != "" {
+			entryPath = relPath + "/" + de.Name()
+		}
+		entry, ok := l.entry(entryPath, de)
+		if !ok {
+			continue
+		}
+		l.remaining--
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Type != entries[j].Type {
+			return entries[i].Type == TypeDir
+		}
+		return entries[i].Name < entries[j].Name
+	})
+
+	if depth > 1 {
+		for i := range entries {
+			e := &entries[i]
+			if e.Type != TypeDir || e.Symlink != "" || e.Ignored || e.Name == ".git" {
+				continue
+			}
+			children, err := l.list(e.Path, depth-1)
+			if err != nil {
+				// Unreadable subdirectories are listed without children
+				continue
+			}
+			e.Children = children
+		}
+	}
+
+	return entries, nil
+}
+
+func (l *lister) entry(entryPath string, de os.DirEntry) (Entry, bool) {
+	info, err := de.Info()
+	if err != nil {
+		// Removed while listing
+		return Entry{}, false
+	}
+
+	entry := Entry{
+		Name:    de.Name(),
+		Type:    TypeFile,
+		Path:    entryPath,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Mode:    info.Mode().String(),
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		fullPath := filepath.Join(l.workDir, entryPath)
+		entry.Symlink, _ = os.Readlink(fullPath)
+		// Describe the target; broken links are listed as files
+		if target, err := os.Stat(fullPath); err == nil {
+			info = target
+			entry.Size = target.Size()
+		}
+	}
+	if info.IsDir() {
+		entry.Type = TypeDir
+		entry.Size = 0
+	}
+
+	key := filepath.ToSlash(filepath.Clean(entryPath))
+	entry.Ignored = l.isIgnored(key)
+	if !entry.Ignored {
+		if entry.Type == TypeDir && entry.Symlink == "" {
+			entry.GitStatus = l.dirStatus[key]
+		} else {
+			entry.GitStatus = l.statuses[key]
+		}
+	}
+	return entry, true
+}
+
+// isIgnored reports whether p or one of its parent directories is ignored.
+func (l *lister) isIgnored(p string) bool {
+	if l.statuses == nil {
+		return false
+	}
+	if l.statuses[p] == git.PathIgnored || l.statuses[p+"/"] == git.PathIgnored {
+		return true
+	}
+	for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+		if l.statuses[dir+"/"] == git.PathIgnored {
+			return true
+		}
+	}
+	return false
+}
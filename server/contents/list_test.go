@@ -0,0 +1,118 @@
+package contents
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func setupListRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init"},
+		{"config", "user.email", "test@test.com"},
+		{"config", "user.name", "Test"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %s", args, out)
+		}
+	}
+
+	files := map[string]string{
+		".gitignore":          "node_modules/\n",
+		".env":                "SECRET=1",
+		"src/main.go":         "package main",
+		"src/lib/util.go":     "package lib",
+		"node_modules/x/x.js": "",
+	}
+	for path, content := range files {
+		full := filepath.Join(dir, path)
+		os.MkdirAll(filepath.Dir(full), 0755)
+		os.WriteFile(full, []byte(content), 0644)
+	}
+	if out, err := exec.Command("git", "-C", dir, "add", ".gitignore", "src").CombinedOutput(); err != nil {
+		t.Fatalf("git add failed: %s", out)
+	}
+	if out, err := exec.Command("git", "-C", dir, "commit", "--no-gpg-sign", "-m", "init").CombinedOutput(); err != nil {
+		t.Fatalf("git commit failed: %s", out)
+	}
+	return dir
+}
+
+func findEntry(entries []Entry, name string) *Entry {
+	for i := range entries {
+		if entries[i].Name == name {
+			return &entries[i]
+		}
+	}
+	return nil
+}
+
+func TestGetContents_ListMetadata(t *testing.T) {
+	dir := setupListRepo(t)
+	os.WriteFile(filepath.Join(dir, "src", "lib", "util.go"), []byte("package lib // changed"), 0644)
+	os.Symlink("src/main.go", filepath.Join(dir, "main.go"))
+
+	result, err := GetContents(dir, "", ListOptions{}, ReadOptions{})
+	if err != nil {
+		t.Fatalf("GetContents() error: %v", err)
+	}
+
+	src := findEntry(result.Entries, "src")
+	if src == nil || src.Type != TypeDir || src.GitStatus != "M" || src.Children != nil {
+		t.Errorf("unexpected src entry: %+v", src)
+	}
+	if nm := findEntry(result.Entries, "node_modules"); nm == nil || !nm.Ignored {
+		t.Errorf("expected node_modules to be ignored: %+v", nm)
+	}
+
+	link := findEntry(result.Entries, "main.go")
+	if link == nil || link.Symlink != "src/main.go" || link.Type != TypeFile || link.Size != int64(len("package main")) {
+		t.Errorf("unexpected symlink entry: %+v", link)
+	}
+	if link != nil && (link.Mode[0] != 'L' || link.ModTime.IsZero() || link.GitStatus != "?") {
+		t.Errorf("unexpected symlink metadata: %+v", link)
+	}
+
+	if env := findEntry(result.Entries, ".env"); env == nil || env.GitStatus != "?" {
+		t.Errorf("expected untracked .env: %+v", env)
+	}
+
+	result, _ = GetContents(dir, "", ListOptions{ExcludeHidden: true}, ReadOptions{})
+	if findEntry(result.Entries, ".env") != nil || findEntry(result.Entries, ".gitignore") != nil {
+		t.Errorf("hidden entries not excluded: %+v", result.Entries)
+	}
+}
+
+func TestGetContents_ListRecursive(t *testing.T) {
+	dir := setupListRepo(t)
+
+	result, err := GetContents(dir, "", ListOptions{Depth: 3}, ReadOptions{})
+	if err != nil {
+		t.Fatalf("GetContents() error: %v", err)
+	}
+
+	src := findEntry(result.Entries, "src")
+	if src == nil {
+		t.Fatal("src not listed")
+	}
+	lib := findEntry(src.Children, "lib")
+	if lib == nil || lib.Path != "src/lib" || findEntry(lib.Children, "util.go") == nil {
+		t.Errorf("unexpected src children: %+v", src.Children)
+	}
+	if src.GitStatus != "" || lib.GitStatus != "" {
+		t.Errorf("clean directories have a status: %q %q", src.GitStatus, lib.GitStatus)
+	}
+
+	// Ignored directories are not descended into
+	if nm := findEntry(result.Entries, "node_modules"); nm == nil || nm.Children != nil {
+		t.Errorf("unexpected node_modules entry: %+v", nm)
+	}
+
+	result, _ = GetContents(dir, "src", ListOptions{Depth: 2}, ReadOptions{})
+	if lib := findEntry(result.Entries, "lib"); lib == nil || len(lib.Children) != 1 {
+		t.Errorf("unexpected listing of src: %+v", result.Entries)
+	}
+}
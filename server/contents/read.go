@@ -0,0 +1,286 @@
+package contents
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultMaxReadSize caps the content returned by a read when ReadOptions.MaxSize is not set.
+	DefaultMaxReadSize = 1024 * 1024
+	// MaxReadSizeLimit is the largest ReadOptions.MaxSize a client may request.
+	MaxReadSizeLimit = 16 * 1024 * 1024
+
+	tailChunkSize = 64 * 1024
+)
+
+var (
+	ErrFileTooLarge = errors.New("file is too large")
+	ErrInvalidRange = errors.New("invalid range")
+)
+
+// TooLargeError is returned when a whole file is requested but exceeds the maximum
+// read size. Clients can fall back to a ranged or tail read.
+type TooLargeError struct {
+	Size    int64 `json:"size"`
+	MaxSize int64 `json:"max_size"`
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("%s: %d bytes (max %d)", ErrFileTooLarge, e.Size, e.MaxSize)
+}
+
+func (e *TooLargeError) Unwrap() error {
+	return ErrFileTooLarge
+}
+
+// ReadOptions selects the part of a file to read. At most one of a byte range
+// (Offset/Length), a line range (StartLine/EndLine) or Tail may be set; none reads
+// the whole file, which fails with a *TooLargeError if it exceeds MaxSize.
+// Ranged reads stop at MaxSize and report Truncated instead.
+type ReadOptions struct {
+	Offset    int64 // first byte
+	Length    int64 // number of bytes; 0 reads to the end
+	StartLine int   // first line, 1-based
+	EndLine   int   // last line, inclusive; 0 reads to the end
+	Tail      int   // last N lines
+	MaxSize   int64 // default DefaultMaxReadSize, at most MaxReadSizeLimit
+}
+
+// ContentRange locates the content of a ranged read within the file.
+type ContentRange struct {
+	Offset    int64 `json:"offset"`
+	Length    int64 `json:"length"`
+	StartLine int   `json:"start_line,omitempty"` // set for line range reads
+	EndLine   int   `json:"end_line,omitempty"`
+}
+
+func (o ReadOptions) validate() error {
+	if o.Offset < 0 || o.Length < 0 || o.StartLine < 0 || o.EndLine < 0 || o.Tail < 0 || o.MaxSize < 0 {
+		return fmt.Errorf("%w: negative value", ErrInvalidRange)
+	}
+	if o.MaxSize > MaxReadSizeLimit {
+		return fmt.Errorf("%w: max size exceeds %d bytes", ErrInvalidRange, MaxReadSizeLimit)
+	}
+	if o.EndLine > 0 && o.EndLine < max(o.StartLine, 1) {
+		return fmt.Errorf("%w: end line before start line", ErrInvalidRange)
+	}
+
+	modes := 0
+	for _, set := range []bool{o.isByteRange(), o.isLineRange(), o.Tail > 0} {
+		if set {
+			modes++
+		}
+	}
+	if modes > 1 {
+		return fmt.Errorf("%w: byte range, line range and tail are exclusive", ErrInvalidRange)
+	}
+	return nil
+}
+
+func (o ReadOptions) isByteRange() bool { return o.Offset > 0 || o.Length > 0 }
+func (o ReadOptions) isLineRange() bool { return o.StartLine > 0 || o.EndLine > 0 }
+
+func (o ReadOptions) maxSize() int64 {
+	if o.MaxSize == 0 {
+		return DefaultMaxReadSize
+	}
+	return o.MaxSize
+}
+
+func readFile(relPath, fullPath string, info os.FileInfo, opts ReadOptions) (*FileContent, error) {
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer f.Close()
+
+	file := &FileContent{
+		Name:    info.Name(),
+		Type:    TypeFile,
+		Path:    relPath,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+
+	maxSize := opts.maxSize()
+	var data []byte
+	switch {
+	case opts.Tail > 0:
+		data, file.Range, file.Truncated, err = readTail(f, info.Size(), opts.Tail, maxSize)
+	case opts.isLineRange():
+		data, file.Range, file.Truncated, err = readLines(f, max(opts.StartLine, 1), opts.EndLine, maxSize)
+	case opts.isByteRange():
+		data, file.Range, file.Truncated, err = readBytes(f, info.Size(), opts.Offset, opts.Length, maxSize)
+	default:
+		if info.Size() > maxSize {
+			return nil, &TooLargeError{Size: info.Size(), MaxSize: maxSize}
+		}
+		// The file may have grown since it was stat'ed
+		data, err = io.ReadAll(io.LimitReader(f, maxSize+1))
+		if err == nil && int64(len(data)) > maxSize {
+			return nil, &TooLargeError{Size: int64(len(data)), MaxSize: maxSize}
+		}
+		file.Hash = HashContent(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	file.Content, file.Encoding = encodeContent(data, file.Range != nil)
+	return file, nil
+}
+
+// encodeContent returns data as text, or base64 for binary content. A range may
+// cut through a multi-byte character, so ranged content must also be valid UTF-8
+// to be sent as text.
+func encodeContent(data []byte, ranged bool) (string, Encoding) {
+	if isBinary(data) || (ranged && !utf8.Valid(data)) {
+		return base64.StdEncoding.EncodeToString(data), EncodingBase64
+	}
+	return string(data), EncodingText
+}
+
+func readBytes(f *os.File, size, offset, length, maxSize int64) ([]byte, *ContentRange, bool, error) {
+	n := max(size-offset, 0)
+	if length > 0 {
+		n = min(n, length)
+	}
+	truncated := false
+	if n > maxSize {
+		n = maxSize
+		truncated = true
+	}
+
+	data, err := io.ReadAll(io.NewSectionReader(f, offset, n))
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return data, &ContentRange{Offset: offset, Length: int64(len(data))}, truncated, nil
+}
+
+// readLines reads lines start to end (0 = to the end of the file) without loading
+// the lines before start.
+func readLines(f *os.File, start, end int, maxSize int64) ([]byte, *ContentRange, bool, error) {
+	r := bufio.NewReader(f)
+	line := 1
+	var offset int64
+
+	for line < start {
+		chunk, err := r.ReadSlice('\n')
+		offset += int64(len(chunk))
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF {
+			// Past the end of the file
+			return []byte{}, &ContentRange{Offset: offset, StartLine: start}, false, nil
+		}
+		if err != nil {
+			return nil, nil, false, err
+		}
+		line++
+	}
+
+	var data []byte
+	lastLine := 0
+	truncated := false
+	for end == 0 || line <= end {
+		chunk, err := r.ReadSlice('\n')
+		if len(chunk) > 0 {
+			lastLine = line
+			if int64(len(data)+len(chunk)) > maxSize {
+				data = append(data, chunk[:maxSize-int64(len(data))]...)
+				data = trimPartialRune(data)
+				truncated = true
+				break
+			}
+			data = append(data, chunk...)
+			if chunk[len(chunk)-1] == '\n' {
+				line++
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, false, err
+		}
+	}
+
+	rng := &ContentRange{Offset: offset, Length: int64(len(data)), StartLine: start, EndLine: lastLine}
+	if data == nil {
+		data = []byte{}
+	}
+	return data, rng, truncated, nil
+}
+
+// readTail reads the last n lines, scanning backwards from the end of the file.
+// If they exceed maxSize, leading lines are dropped.
+func readTail(f *os.File, size int64, n int, maxSize int64) ([]byte, *ContentRange, bool, error) {
+	pos := size
+	data := []byte{}
+	newlines := 0
+	found := false
+
+	for pos > 0 && !found && int64(len(data)) <= maxSize {
+		chunkSize := min(tailChunkSize, pos)
+		pos -= chunkSize
+		chunk := make([]byte, chunkSize)
+		if _, err := f.ReadAt(chunk, pos); err != nil && err != io.EOF {
+			return nil, nil, false, err
+		}
+
+		for i := len(chunk) - 1; i >= 0; i-- {
+			// The newline terminating the last line does not start a new one
+			if chunk[i] != '\n' || pos+int64(i) == size-1 {
+				continue
+			}
+			newlines++
+			if newlines == n {
+				chunk = chunk[i+1:]
+				pos += int64(i + 1)
+				found = true
+				break
+			}
+		}
+		data = append(chunk, data...)
+	}
+
+	truncated := false
+	if int64(len(data)) > maxSize {
+		drop := int64(len(data)) - maxSize
+		data = data[drop:]
+		pos += drop
+		// Start on a line boundary when possible
+		if i := bytes.IndexByte(data, '\n'); i >= 0 && i < len(data)-1 {
+			data = data[i+1:]
+			pos += int64(i + 1)
+		}
+		truncated = true
+	}
+
+	return data, &ContentRange{Offset: pos, Length: int64(len(data))}, truncated, nil
+}
+
+// trimPartialRune drops an incomplete UTF-8 sequence at the end of data.
+func trimPartialRune(data []byte) []byte {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return data[:i]
+			}
+			break
+		}
+	}
+	return data
+}
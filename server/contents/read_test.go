@@ -0,0 +1,135 @@
+package contents
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeLines(t *testing.T, dir string, n int) string {
+	t.Helper()
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "log.txt"), []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestGetContents_WholeFile(t *testing.T) {
+	dir := t.TempDir()
+	content := writeLines(t, dir, 10)
+
+	result, err := GetContents(dir, "log.txt", ListOptions{}, ReadOptions{})
+	if err != nil {
+		t.Fatalf("GetContents() error: %v", err)
+	}
+	file := result.File
+	if file.Content != content || file.Size != int64(len(content)) || file.Hash != HashContent([]byte(content)) {
+		t.Errorf("unexpected file: %+v", file)
+	}
+	if file.ModTime.IsZero() || file.Range != nil || file.Truncated {
+		t.Errorf("unexpected metadata: %+v", file)
+	}
+
+	_, err = GetContents(dir, "log.txt", ListOptions{}, ReadOptions{MaxSize: 10})
+	var tooLarge *TooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Size != int64(len(content)) || tooLarge.MaxSize != 10 {
+		t.Errorf("expected TooLargeError, got %v", err)
+	}
+}
+
+func TestGetContents_ByteRange(t *testing.T) {
+	dir := t.TempDir()
+	writeLines(t, dir, 10)
+
+	result, err := GetContents(dir, "log.txt", ListOptions{}, ReadOptions{Offset: 7, Length: 6})
+	if err != nil {
+		t.Fatalf("GetContents() error: %v", err)
+	}
+	file := result.File
+	if file.Content != "line 2" || file.Range.Offset != 7 || file.Range.Length != 6 || file.Truncated || file.Hash != "" {
+		t.Errorf("unexpected file: %+v %+v", file, file.Range)
+	}
+
+	result, _ = GetContents(dir, "log.txt", ListOptions{}, ReadOptions{Offset: 7, MaxSize: 4})
+	if result.File.Content != "line" || !result.File.Truncated {
+		t.Errorf("expected truncated content, got %+v", result.File)
+	}
+}
+
+func TestGetContents_LineRange(t *testing.T) {
+	dir := t.TempDir()
+	writeLines(t, dir, 10)
+
+	result, err := GetContents(dir, "log.txt", ListOptions{}, ReadOptions{StartLine: 3, EndLine: 4})
+	if err != nil {
+		t.Fatalf("GetContents() error: %v", err)
+	}
+	file := result.File
+	if file.Content != "line 3\nline 4\n" || file.Range.StartLine != 3 || file.Range.EndLine != 4 || file.Range.Offset != 14 {
+		t.Errorf("unexpected file: %+v %+v", file, file.Range)
+	}
+
+	result, _ = GetContents(dir, "log.txt", ListOptions{}, ReadOptions{StartLine: 9})
+	if result.File.Content != "line 9\nline 10\n" || result.File.Range.EndLine != 10 {
+		t.Errorf("unexpected open-ended range: %+v", result.File)
+	}
+
+	result, _ = GetContents(dir, "log.txt", ListOptions{}, ReadOptions{StartLine: 20})
+	if result.File.Content != "" {
+		t.Errorf("expected empty content past the end, got %q", result.File.Content)
+	}
+
+	result, _ = GetContents(dir, "log.txt", ListOptions{}, ReadOptions{StartLine: 1, MaxSize: 10})
+	if result.File.Content != "line 1\nlin" || !result.File.Truncated || result.File.Range.EndLine != 2 {
+		t.Errorf("expected truncated content, got %+v %+v", result.File, result.File.Range)
+	}
+}
+
+func TestGetContents_Tail(t *testing.T) {
+	dir := t.TempDir()
+	content := writeLines(t, dir, 10)
+
+	result, err := GetContents(dir, "log.txt", ListOptions{}, ReadOptions{Tail: 2})
+	if err != nil {
+		t.Fatalf("GetContents() error: %v", err)
+	}
+	file := result.File
+	if file.Content != "line 9\nline 10\n" || file.Range.Offset != int64(len(content)-15) || file.Truncated {
+		t.Errorf("unexpected file: %+v %+v", file, file.Range)
+	}
+
+	result, _ = GetContents(dir, "log.txt", ListOptions{}, ReadOptions{Tail: 100})
+	if result.File.Content != content || result.File.Range.Offset != 0 {
+		t.Errorf("expected the whole file, got %+v", result.File)
+	}
+
+	// Leading lines are dropped to fit, starting on a line boundary
+	result, _ = GetContents(dir, "log.txt", ListOptions{}, ReadOptions{Tail: 5, MaxSize: 18})
+	if result.File.Content != "line 9\nline 10\n" || !result.File.Truncated {
+		t.Errorf("expected truncated tail, got %+v", result.File)
+	}
+}
+
+func TestGetContents_InvalidRange(t *testing.T) {
+	dir := t.TempDir()
+	writeLines(t, dir, 3)
+
+	for _, opts := range []ReadOptions{
+		{Offset: -1},
+		{StartLine: 5, EndLine: 2},
+		{Tail: 2, StartLine: 1},
+		{Offset: 1, EndLine: 2},
+		{MaxSize: MaxReadSizeLimit + 1},
+	} {
+		if _, err := GetContents(dir, "log.txt", ListOptions{}, opts); !errors.Is(err, ErrInvalidRange) {
+			t.Errorf("GetContents(%+v) error = %v, want ErrInvalidRange", opts, err)
+		}
+	}
+}
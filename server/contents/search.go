@@ -0,0 +1,246 @@
+package contents
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultSearchMaxResults caps matches when SearchOptions.MaxResults is not set.
+	DefaultSearchMaxResults = 1000
+	// maxSearchContextLines caps SearchOptions.ContextLines.
+	maxSearchContextLines = 10
+	// maxSearchFileSize skips files too large to be source code (e.g. logs, dumps).
+	maxSearchFileSize = 4 * 1024 * 1024
+	// maxSearchLineLength truncates long lines (e.g. minified files) in results.
+	maxSearchLineLength = 500
+
+	searchBatchSize     = 100
+	searchBatchInterval = 100 * time.Millisecond
+)
+
+var ErrInvalidQuery = errors.New("invalid query")
+
+// SearchOptions configures a content search.
+type SearchOptions struct {
+	Query         string
+	Regex         bool // Query is a regular expression (RE2 syntax); otherwise a literal
+	CaseSensitive bool
+	Include       []string // globs; when set, only matching files are searched (see Glob)
+	Exclude       []string // globs; matching files are skipped
+	MaxResults    int      // default DefaultSearchMaxResults
+	ContextLines  int      // lines of context before and after each match, at most 10
+}
+
+// SearchMatch is one occurrence of the query. A line with several occurrences
+// produces one match per occurrence.
+type SearchMatch struct {
+	Path   string   `json:"path"`
+	Line   int      `json:"line"`   // 1-based
+	Column int      `json:"column"` // 1-based, in characters
+	Length int      `json:"length"` // in characters
+	Text   string   `json:"text"`   // the matching line (truncated if very long)
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+}
+
+// SearchSummary describes a finished search.
+type SearchSummary struct {
+	Matches       int  `json:"matches"`
+	FilesSearched int  `json:"files_searched"`
+	FilesMatched  int  `json:"files_matched"`
+	Truncated     bool `json:"truncated"` // stopped at MaxResults
+}
+
+// Searcher is a validated content search.
+type Searcher struct {
+	opts    SearchOptions
+	re      *regexp.Regexp
+	include []*Glob
+	exclude []*Glob
+}
+
+// NewSearcher validates opts. Returns ErrInvalidQuery for an empty query or
+// malformed regular expression or glob.
+func NewSearcher(opts SearchOptions) (*Searcher, error) {
+	if opts.Query == "" {
+		return nil, fmt.Errorf("%w: query is empty", ErrInvalidQuery)
+	}
+
+	expr := opts.Query
+	if !opts.Regex {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if !opts.CaseSensitive {
+		expr = "(?i)" + expr
+	}
+	// Multi-line mode so ^ and $ also anchor at line boundaries when checking a whole file
+	expr = "(?m)" + expr
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+
+	include, err := compileGlobs(opts.Include)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+	exclude, err := compileGlobs(opts.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+
+	if opts.MaxResults <= 0 {
+		opts.MaxResults = DefaultSearchMaxResults
+	}
+	opts.ContextLines = max(0, min(opts.ContextLines, maxSearchContextLines))
+
+	return &Searcher{opts: opts, re: re, include: include, exclude: exclude}, nil
+}
+
+// Run searches the non-ignored files of workDir (see ListFiles), passing matches to
+// emit in batches as they are found. Binary files and files over 4MB are skipped.
+// Returns ctx.Err() if cancelled; matches emitted so far remain valid.
+func (s *Searcher) Run(ctx context.Context, workDir string, emit func([]SearchMatch)) (SearchSummary, error) {
+	files, err := ListFiles(ctx, workDir)
+	if err != nil {
+		return SearchSummary{}, err
+	}
+
+	var summary SearchSummary
+	var batch []SearchMatch
+	lastFlush := time.Now()
+	flush := func() {
+		if len(batch) > 0 {
+			emit(batch)
+			batch = nil
+		}
+		lastFlush = time.Now()
+	}
+	defer flush()
+
+	for _, path := range files {
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+		if len(s.include) > 0 && !matchAny(s.include, path) {
+			continue
+		}
+		if matchAny(s.exclude, path) {
+			continue
+		}
+
+		data, ok := readSearchable(filepath.Join(workDir, filepath.FromSlash(path)))
+		if !ok {
+			continue
+		}
+		summary.FilesSearched++
+
+		remaining := s.opts.MaxResults - summary.Matches
+		matches := s.searchFile(path, data, remaining+1)
+		if len(matches) > remaining {
+			matches = matches[:remaining]
+			summary.Truncated = true
+		}
+		if len(matches) > 0 {
+			summary.FilesMatched++
+			summary.Matches += len(matches)
+			batch = append(batch, matches...)
+		}
+
+		if summary.Truncated {
+			break
+		}
+		if len(batch) >= searchBatchSize || time.Since(lastFlush) >= searchBatchInterval {
+			flush()
+		}
+	}
+	return summary, nil
+}
+
+// searchFile returns up to limit matches in data.
+func (s *Searcher) searchFile(path string, data []byte, limit int) []SearchMatch {
+	if !s.re.Match(data) {
+		return nil
+	}
+
+	lines := splitLines(data)
+	var matches []SearchMatch
+	for i, line := range lines {
+		for _, loc := range s.re.FindAllIndex(line, -1) {
+			// Empty matches (e.g. "^") carry no useful position
+			if loc[0] == loc[1] {
+				continue
+			}
+			match := SearchMatch{
+				Path:   path,
+				Line:   i + 1,
+				Column: utf8.RuneCount(line[:loc[0]]) + 1,
+				Length: utf8.RuneCount(line[loc[0]:loc[1]]),
+				Text:   truncateLine(line),
+			}
+			if n := s.opts.ContextLines; n > 0 {
+				match.Before = contextLines(lines, i-n, i)
+				match.After = contextLines(lines, i+1, i+1+n)
+			}
+			matches = append(matches, match)
+			if len(matches) >= limit {
+				return matches
+			}
+		}
+	}
+	return matches
+}
+
+// readSearchable reads a regular text file, skipping large and binary files.
+func readSearchable(fullPath string) ([]byte, bool) {
+	info, err := os.Stat(fullPath)
+	if err != nil || !info.Mode().IsRegular() || info.Size() > maxSearchFileSize {
+		return nil, false
+	}
+	data, err := os.ReadFile(fullPath)
+	if err != nil || isBinary(data) {
+		return nil, false
+	}
+	return data, true
+}
+
+func splitLines(data []byte) [][]byte {
+	lines := bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+	for i, line := range lines {
+		lines[i] = bytes.TrimSuffix(line, []byte("\r"))
+	}
+	return lines
+}
+
+func contextLines(lines [][]byte, from, to int) []string {
+	from = max(from, 0)
+	to = min(to, len(lines))
+	if from >= to {
+		return nil
+	}
+	result := make([]string, 0, to-from)
+	for _, line := range lines[from:to] {
+		result = append(result, truncateLine(line))
+	}
+	return result
+}
+
+func truncateLine(line []byte) string {
+	if len(line) <= maxSearchLineLength {
+		return string(line)
+	}
+	// Cut on a rune boundary
+	cut := maxSearchLineLength
+	for cut > 0 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	return string(line[:cut])
+}
@@ -0,0 +1,139 @@
+package contents
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "ws/rpc.go", true},
+		{"*.go", "main.ts", false},
+		{"node_modules", "web/node_modules/react/index.js", true},
+		{"src/**/*.ts", "src/a/b/c.ts", true},
+		{"src/**/*.ts", "src/c.ts", true},
+		{"src/**/*.ts", "lib/src/c.ts", false},
+		{"docs", "docs/readme.md", true},
+		{"docs/", "docs/readme.md", true},
+		{"*.{ts,tsx}", "app.tsx", true},
+		{"file?.txt", "file1.txt", true},
+		{"file?.txt", "file10.txt", false},
+		{"[ab].txt", "b.txt", true},
+		{"[!ab].txt", "b.txt", false},
+	}
+	for _, tt := range tests {
+		g, err := CompileGlob(tt.pattern)
+		if err != nil {
+			t.Fatalf("CompileGlob(%q) error: %v", tt.pattern, err)
+		}
+		if got := g.Match(tt.path); got != tt.want {
+			t.Errorf("Glob(%q).Match(%q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+
+	if _, err := CompileGlob("[abc"); err == nil {
+		t.Error("expected error for unterminated class")
+	}
+}
+
+func setupSearchDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		".gitignore":     "ignored/\n",
+		"main.go":        "package main\n\nfunc main() {\n\tprintln(\"Hello\")\n}\n",
+		"src/app.ts":     "const hello = 'hello';\nexport default hello;\n",
+		"ignored/gen.go": "hello from generated code\n",
+		"bin.dat":        "hello\x00binary",
+	}
+	for path, content := range files {
+		full := filepath.Join(dir, path)
+		os.MkdirAll(filepath.Dir(full), 0755)
+		os.WriteFile(full, []byte(content), 0644)
+	}
+	if out, err := exec.Command("git", "-C", dir, "init").CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %s", out)
+	}
+	return dir
+}
+
+func runSearch(t *testing.T, dir string, opts SearchOptions) ([]SearchMatch, SearchSummary) {
+	t.Helper()
+	s, err := NewSearcher(opts)
+	if err != nil {
+		t.Fatalf("NewSearcher() error: %v", err)
+	}
+	var matches []SearchMatch
+	summary, err := s.Run(context.Background(), dir, func(batch []SearchMatch) {
+		matches = append(matches, batch...)
+	})
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	return matches, summary
+}
+
+func TestSearch_LiteralCaseInsensitive(t *testing.T) {
+	dir := setupSearchDir(t)
+
+	matches, summary := runSearch(t, dir, SearchOptions{Query: "hello"})
+	// main.go once, src/app.ts three times; ignored and binary files skipped
+	if summary.Matches != 4 || len(matches) != 4 || summary.FilesMatched != 2 {
+		t.Fatalf("got %d matches in %d files: %+v", summary.Matches, summary.FilesMatched, matches)
+	}
+	for _, m := range matches {
+		if m.Path == "main.go" && (m.Line != 4 || m.Column != 11 || m.Length != 5) {
+			t.Errorf("unexpected position: %+v", m)
+		}
+	}
+
+	matches, _ = runSearch(t, dir, SearchOptions{Query: "Hello", CaseSensitive: true})
+	if len(matches) != 1 || matches[0].Path != "main.go" {
+		t.Errorf("case sensitive search = %+v", matches)
+	}
+}
+
+func TestSearch_RegexGlobsAndContext(t *testing.T) {
+	dir := setupSearchDir(t)
+
+	matches, _ := runSearch(t, dir, SearchOptions{Query: `^func \w+`, Regex: true, ContextLines: 1})
+	if len(matches) != 1 {
+		t.Fatalf("regex search = %+v", matches)
+	}
+	if len(matches[0].Before) != 1 || matches[0].Before[0] != "" || len(matches[0].After) != 1 {
+		t.Errorf("unexpected context: %+v", matches[0])
+	}
+
+	matches, _ = runSearch(t, dir, SearchOptions{Query: "hello", Include: []string{"src/**"}})
+	for _, m := range matches {
+		if m.Path != "src/app.ts" {
+			t.Errorf("include glob not honored: %+v", m)
+		}
+	}
+
+	matches, _ = runSearch(t, dir, SearchOptions{Query: "hello", Exclude: []string{"*.ts"}})
+	if len(matches) != 1 || matches[0].Path != "main.go" {
+		t.Errorf("exclude glob not honored: %+v", matches)
+	}
+
+	if _, err := NewSearcher(SearchOptions{Query: "(", Regex: true}); err == nil {
+		t.Error("expected error for invalid regex")
+	}
+}
+
+func TestSearch_MaxResults(t *testing.T) {
+	dir := setupSearchDir(t)
+
+	matches, summary := runSearch(t, dir, SearchOptions{Query: "hello", MaxResults: 2})
+	if len(matches) != 2 || !summary.Truncated {
+		t.Errorf("got %d matches, truncated=%v", len(matches), summary.Truncated)
+	}
+}
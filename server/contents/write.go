@@ -0,0 +1,394 @@
+package contents
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrAlreadyExists = errors.New("already exists")
+	ErrModified      = errors.New("file was modified since it was read")
+	ErrProtectedPath = errors.New("path is inside .git")
+	ErrNotEmpty      = errors.New("directory is not empty")
+)
+
+// ModifiedError is returned when a write precondition no longer holds.
+// It carries the file's current state so the client can reload or overwrite.
+type ModifiedError struct {
+	Hash    string    `json:"hash"`
+	ModTime time.Time `json:"mtime"`
+}
+
+func (e *ModifiedError) Error() string {
+	return ErrModified.Error()
+}
+
+func (e *ModifiedError) Unwrap() error {
+	return ErrModified
+}
+
+// Precondition guards a write against concurrent modification.
+// Zero fields are not checked; a zero Precondition overwrites unconditionally.
+type Precondition struct {
+	Hash    string    // hash of the content the client last read (see HashContent)
+	ModTime time.Time // modification time the client last saw
+}
+
+// WriteResult describes a file after it was written.
+type WriteResult struct {
+	Path    string    `json:"path"`
+	Hash    string    `json:"hash"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// HashContent returns the hash used for write preconditions (hex-encoded SHA-256).
+func HashContent(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// DecodeContent decodes content sent by a client in the given encoding (default text).
+func DecodeContent(content string, encoding Encoding) ([]byte, error) {
+	switch encoding {
+	case "", EncodingText:
+		return []byte(content), nil
+	case EncodingBase64:
+		data, err := base64.StdEncoding.DecodeString(content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 content: %w", err)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("unsupported encoding: %s", encoding)
+}
+
+// WriteFile replaces the content of an existing file, keeping its mode.
+// Returns ErrNotFound if the file does not exist and a *ModifiedError if pre no longer holds.
+// The file is replaced atomically, so readers never see partial content.
+func WriteFile(workDir, path string, data []byte, pre Precondition) (WriteResult, error) {
+	fullPath, err := resolveWritable(workDir, path)
+	if err != nil {
+		return WriteResult{}, err
+	}
+
+	// Write through symlinks, as editors do, as long as the target stays in workDir
+	target, err := filepath.EvalSymlinks(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return WriteResult{}, fmt.Errorf("%w: %s", ErrNotFound, path)
+		}
+		return WriteResult{}, err
+	}
+	if err := checkInside(workDir, target, path); err != nil {
+		return WriteResult{}, err
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return WriteResult{}, err
+	}
+	if info.IsDir() {
+		return WriteResult{}, fmt.Errorf("%w: %s is a directory", ErrInvalidPath, path)
+	}
+
+	if err := checkPrecondition(target, info, pre); err != nil {
+		return WriteResult{}, err
+	}
+
+	if err := writeAtomic(target, data, info.Mode().Perm()); err != nil {
+		return WriteResult{}, err
+	}
+	return statWritten(path, target, data)
+}
+
+// CreateFile creates a new file, along with missing parent directories.
+// Returns ErrAlreadyExists if path exists.
+func CreateFile(workDir, path string, data []byte) (WriteResult, error) {
+	fullPath, err := resolveWritable(workDir, path)
+	if err != nil {
+		return WriteResult{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return WriteResult{}, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	f, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return WriteResult{}, fmt.Errorf("%w: %s", ErrAlreadyExists, path)
+		}
+		return WriteResult{}, fmt.Errorf("failed to create file: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return WriteResult{}, fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return WriteResult{}, fmt.Errorf("failed to write file: %w", err)
+	}
+	return statWritten(path, fullPath, data)
+}
+
+// Upload streams r into path, creating the file and missing parent directories or
+// replacing an existing file (keeping its mode, and writing through symlinks as
+// WriteFile does). Returns a *ModifiedError if pre no longer holds for an existing file.
+// The second return value reports whether the file was created.
+func Upload(workDir, path string, r io.Reader, pre Precondition) (WriteResult, bool, error) {
+	fullPath, err := resolveWritable(workDir, path)
+	if err != nil {
+		return WriteResult{}, false, err
+	}
+
+	target := fullPath
+	perm := fs.FileMode(0644)
+	created := true
+	if resolved, err := filepath.EvalSymlinks(fullPath); err == nil {
+		if err := checkInside(workDir, resolved, path); err != nil {
+			return WriteResult{}, false, err
+		}
+		info, err := os.Stat(resolved)
+		if err != nil {
+			return WriteResult{}, false, err
+		}
+		if info.IsDir() {
+			return WriteResult{}, false, fmt.Errorf("%w: %s is a directory", ErrInvalidPath, path)
+		}
+		if err := checkPrecondition(resolved, info, pre); err != nil {
+			return WriteResult{}, false, err
+		}
+		target, perm, created = resolved, info.Mode().Perm(), false
+	} else if !os.IsNotExist(err) {
+		return WriteResult{}, false, err
+	} else if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return WriteResult{}, false, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	hash := sha256.New()
+	if err := writeAtomicFrom(target, io.TeeReader(r, hash), perm); err != nil {
+		return WriteResult{}, false, err
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return WriteResult{}, false, err
+	}
+	return WriteResult{
+		Path:    path,
+		Hash:    hex.EncodeToString(hash.Sum(nil)),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}, created, nil
+}
+
+// Mkdir creates a directory, along with missing parents.
+// Returns ErrAlreadyExists if path exists.
+func Mkdir(workDir, path string) error {
+	fullPath, err := resolveWritable(workDir, path)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(fullPath); err == nil {
+		return fmt.Errorf("%w: %s", ErrAlreadyExists, path)
+	}
+	if err := os.MkdirAll(fullPath, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	return nil
+}
+
+// Rename moves a file or directory. Missing parent directories of newPath are
+// created. Returns ErrAlreadyExists if newPath exists.
+func Rename(workDir, oldPath, newPath string) error {
+	oldFull, err := resolveWritable(workDir, oldPath)
+	if err != nil {
+		return err
+	}
+	newFull, err := resolveWritable(workDir, newPath)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Lstat(oldFull); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s", ErrNotFound, oldPath)
+		}
+		return err
+	}
+	if _, err := os.Lstat(newFull); err == nil {
+		return fmt.Errorf("%w: %s", ErrAlreadyExists, newPath)
+	}
+	if newFull == oldFull || strings.HasPrefix(newFull, oldFull+string(filepath.Separator)) {
+		return fmt.Errorf("%w: cannot move %s into itself", ErrInvalidPath, oldPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(newFull), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.Rename(oldFull, newFull); err != nil {
+		return fmt.Errorf("failed to rename: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a file, symlink or directory. Non-empty directories are only
+// removed when recursive is set; otherwise ErrNotEmpty is returned.
+func Delete(workDir, path string, recursive bool) error {
+	fullPath, err := resolveWritable(workDir, path)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s", ErrNotFound, path)
+		}
+		return err
+	}
+
+	if info.IsDir() && recursive {
+		err = os.RemoveAll(fullPath)
+	} else {
+		err = os.Remove(fullPath)
+	}
+	if err != nil {
+		if errors.Is(err, syscall.ENOTEMPTY) {
+			return fmt.Errorf("%w: %s", ErrNotEmpty, path)
+		}
+		return fmt.Errorf("failed to delete: %w", err)
+	}
+	return nil
+}
+
+// resolveWritable validates a path to be modified and returns its absolute path.
+// The workDir itself and anything inside a .git directory are rejected, as are
+// paths whose parent directory resolves outside workDir through a symlink.
+func resolveWritable(workDir, path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("%w: path required", ErrInvalidPath)
+	}
+	if err := ValidatePath(workDir, path); err != nil {
+		return "", err
+	}
+
+	cleanPath := filepath.Clean(path)
+	for _, part := range strings.Split(filepath.ToSlash(cleanPath), "/") {
+		if part == ".git" {
+			return "", fmt.Errorf("%w: %s", ErrProtectedPath, path)
+		}
+	}
+
+	fullPath := filepath.Join(workDir, cleanPath)
+	if err := checkParentInside(workDir, fullPath, path); err != nil {
+		return "", err
+	}
+	return fullPath, nil
+}
+
+// checkParentInside resolves the closest existing ancestor of fullPath and checks
+// it is inside workDir.
+func checkParentInside(workDir, fullPath, path string) error {
+	dir := filepath.Dir(fullPath)
+	for {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			return checkInside(workDir, resolved, path)
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return fmt.Errorf("%w: %s", ErrInvalidPath, path)
+		}
+		dir = parent
+	}
+}
+
+func checkInside(workDir, resolved, path string) error {
+	root, err := filepath.EvalSymlinks(workDir)
+	if err != nil {
+		return err
+	}
+	if !isWithin(root, resolved) {
+		return fmt.Errorf("%w: %s", ErrInvalidPath, path)
+	}
+	rel, _ := filepath.Rel(root, resolved)
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		if part == ".git" {
+			return fmt.Errorf("%w: %s", ErrProtectedPath, path)
+		}
+	}
+	return nil
+}
+
+// checkPrecondition returns a *ModifiedError if the file at path no longer matches pre.
+func checkPrecondition(path string, info os.FileInfo, pre Precondition) error {
+	if pre.Hash == "" && pre.ModTime.IsZero() {
+		return nil
+	}
+	current, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	hash := HashContent(current)
+	if (pre.Hash != "" && pre.Hash != hash) || (!pre.ModTime.IsZero() && !pre.ModTime.Equal(info.ModTime())) {
+		return &ModifiedError{Hash: hash, ModTime: info.ModTime()}
+	}
+	return nil
+}
+
+// writeAtomic writes data to a temporary file next to path and renames it over path.
+func writeAtomic(path string, data []byte, perm fs.FileMode) error {
+	return writeAtomicFrom(path, bytes.NewReader(data), perm)
+}
+
+// writeAtomicFrom streams r to a temporary file next to path and renames it over path.
+func writeAtomicFrom(path string, r io.Reader, perm fs.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+func statWritten(relPath, fullPath string, data []byte) (WriteResult, error) {
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return WriteResult{}, err
+	}
+	return WriteResult{
+		Path:    relPath,
+		Hash:    HashContent(data),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}, nil
+}
@@ -0,0 +1,257 @@
+// Package fileapi serves worktree files over HTTP, for transfers too large to fit
+// in JSON-RPC messages.
+package fileapi
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pockode/server/contents"
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/worktree"
+)
+
+// MaxUploadSize caps the body of an upload.
+const MaxUploadSize = 1 << 30
+
+// Handler serves GET and PUT /api/files/{path...}. The worktree is selected with the
+// "worktree" query parameter (empty = main worktree), as in the WebSocket auth params.
+// Authentication is left to the middleware wrapping the mux.
+type Handler struct {
+	manager *worktree.Manager
+}
+
+func NewHandler(manager *worktree.Manager) *Handler {
+	return &Handler{manager: manager}
+}
+
+// Register adds the file routes to mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/files/{path...}", h.handleGet)
+	mux.HandleFunc("PUT /api/files/{path...}", h.handlePut)
+}
+
+// handleGet serves a file, with Range and conditional request support, or a zip
+// archive of a directory.
+func (h *Handler) handleGet(w http.ResponseWriter, r *http.Request) {
+	workDir, relPath, ok := h.resolve(w, r)
+	if !ok {
+		return
+	}
+
+	// Follow symlinks, but only to files inside the worktree
+	fullPath, err := contents.ResolvePath(workDir, relPath)
+	if err != nil {
+		switch {
+		case errors.Is(err, contents.ErrNotFound):
+			http.Error(w, "not found", http.StatusNotFound)
+		case errors.Is(err, contents.ErrInvalidPath):
+			http.Error(w, "invalid path", http.StatusBadRequest)
+		default:
+			slog.Error("failed to resolve file", "path", relPath, "error", err)
+			http.Error(w, "failed to read file", http.StatusInternalServerError)
+		}
+		return
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to stat file", "path", relPath, "error", err)
+		http.Error(w, "failed to read file", http.StatusInternalServerError)
+		return
+	}
+
+	// Name downloads after the requested path rather than the symlink target
+	name := filepath.Base(filepath.Join(workDir, relPath))
+	if info.IsDir() {
+		serveZip(w, r, relPath, name, fullPath)
+		return
+	}
+
+	f, err := os.Open(fullPath)
+	if err != nil {
+		slog.Error("failed to open file", "path", relPath, "error", err)
+		http.Error(w, "failed to read file", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	// Worktree files are untrusted: never let the browser run them in our origin
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	if r.URL.Query().Get("download") != "" {
+		w.Header().Set("Content-Disposition", contentDisposition(name))
+	}
+
+	// ServeContent handles Range and If-Modified-Since, and sets Content-Type from
+	// the extension or, failing that, by sniffing the content
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
+
+// serveZip streams a zip archive of the non-ignored files in a directory (see
+// contents.ListFiles). Symlinks and other non-regular files are skipped, as are
+// files reached through a symlinked directory outside dir.
+func serveZip(w http.ResponseWriter, r *http.Request, relPath, name, dir string) {
+	files, err := contents.ListFiles(r.Context(), dir)
+	if err != nil {
+		slog.Error("failed to list files", "path", relPath, "error", err)
+		http.Error(w, "failed to list files", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", contentDisposition(name+".zip"))
+
+	zw := zip.NewWriter(w)
+	for _, file := range files {
+		if r.Context().Err() != nil {
+			return
+		}
+		if err := addToZip(zw, dir, file); err != nil {
+			// Headers are already sent; the truncated archive will fail to open
+			slog.Error("failed to write zip", "path", relPath, "file", file, "error", err)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		slog.Error("failed to finish zip", "path", relPath, "error", err)
+	}
+}
+
+func addToZip(zw *zip.Writer, dir, file string) error {
+	fullPath := filepath.Join(dir, filepath.FromSlash(file))
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		// Deleted since it was listed
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	// A tracked file's parent may since have been replaced by a symlink
+	if fullPath, err = contents.ResolvePath(dir, filepath.FromSlash(file)); err != nil {
+		if errors.Is(err, contents.ErrNotFound) || errors.Is(err, contents.ErrInvalidPath) {
+			return nil
+		}
+		return err
+	}
+
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = file
+	header.Method = zip.Deflate
+
+	dst, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(fullPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+// handlePut creates or replaces a file with the request body. An If-Match header
+// with the file's hash (see contents.HashContent) guards against overwriting
+// concurrent changes.
+func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request) {
+	workDir, relPath, ok := h.resolve(w, r)
+	if !ok {
+		return
+	}
+
+	pre := contents.Precondition{Hash: strings.Trim(r.Header.Get("If-Match"), `"`)}
+	body := http.MaxBytesReader(w, r.Body, MaxUploadSize)
+
+	result, created, err := contents.Upload(workDir, relPath, body, pre)
+	if err != nil {
+		writeUploadError(w, relPath, err)
+		return
+	}
+
+	op := rpc.FSOpWrite
+	if created {
+		op = rpc.FSOpCreate
+	}
+	h.notifyChanged(r.URL.Query().Get("worktree"), op, relPath)
+	slog.Info("file uploaded", "path", relPath, "size", result.Size, "created", created)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", `"`+result.Hash+`"`)
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+func writeUploadError(w http.ResponseWriter, relPath string, err error) {
+	var tooLarge *http.MaxBytesError
+	var modified *contents.ModifiedError
+	switch {
+	case errors.As(err, &tooLarge):
+		http.Error(w, fmt.Sprintf("upload exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+	case errors.As(err, &modified):
+		w.Header().Set("ETag", `"`+modified.Hash+`"`)
+		http.Error(w, modified.Error(), http.StatusPreconditionFailed)
+	case errors.Is(err, contents.ErrProtectedPath):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, contents.ErrInvalidPath):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		slog.Error("failed to upload file", "path", relPath, "error", err)
+		http.Error(w, "failed to write file", http.StatusInternalServerError)
+	}
+}
+
+// resolve returns the worktree directory and the validated relative path of the
+// request, or writes an error response.
+func (h *Handler) resolve(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	name := r.URL.Query().Get("worktree")
+	workDir, err := h.manager.Registry().Resolve(name)
+	if err != nil {
+		slog.Warn("worktree not found", "worktree", name, "error", err)
+		http.Error(w, "worktree not found", http.StatusNotFound)
+		return "", "", false
+	}
+
+	relPath := r.PathValue("path")
+	if err := contents.ValidatePath(workDir, relPath); err != nil {
+		http.Error(w, "invalid path", http.StatusBadRequest)
+		return "", "", false
+	}
+	if relPath != "" {
+		relPath = filepath.Clean(relPath)
+	}
+	return workDir, relPath, true
+}
+
+// notifyChanged reports an upload to FS subscribers of the worktree, if it is in use.
+func (h *Handler) notifyChanged(name string, op rpc.FSOp, relPath string) {
+	if wt, ok := h.manager.Active(name); ok {
+		wt.FSWatcher.NotifyChanged(op, relPath)
+	}
+}
+
+func contentDisposition(name string) string {
+	return fmt.Sprintf("attachment; filename=%q", path.Base(name))
+}
@@ -0,0 +1,210 @@
+package fileapi
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pockode/server/agent/claude"
+	"github.com/pockode/server/contents"
+	"github.com/pockode/server/worktree"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	workDir := t.TempDir()
+	manager := worktree.NewManager(worktree.NewRegistry(workDir), claude.New(), t.TempDir(), 10*time.Minute)
+	t.Cleanup(manager.Shutdown)
+
+	mux := http.NewServeMux()
+	NewHandler(manager).Register(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, workDir
+}
+
+func do(t *testing.T, method, url string, body io.Reader, header http.Header) (*http.Response, []byte) {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, body)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp, data
+}
+
+func TestGet_File(t *testing.T) {
+	srv, workDir := newTestServer(t)
+	os.MkdirAll(filepath.Join(workDir, "docs"), 0755)
+	os.WriteFile(filepath.Join(workDir, "docs", "notes.txt"), []byte("hello world"), 0644)
+	os.WriteFile(filepath.Join(workDir, "image"), []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), 0644)
+
+	resp, body := do(t, "GET", srv.URL+"/api/files/docs/notes.txt", nil, nil)
+	if resp.StatusCode != http.StatusOK || string(body) != "hello world" {
+		t.Fatalf("got %d %q", resp.StatusCode, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", ct)
+	}
+
+	resp, body = do(t, "GET", srv.URL+"/api/files/docs/notes.txt", nil, http.Header{"Range": {"bytes=6-"}})
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "world" {
+		t.Errorf("range: got %d %q", resp.StatusCode, body)
+	}
+
+	// No extension: the type is sniffed from the content
+	resp, _ = do(t, "GET", srv.URL+"/api/files/image", nil, nil)
+	if ct := resp.Header.Get("Content-Type"); ct != "image/png" {
+		t.Errorf("Content-Type = %q, want image/png", ct)
+	}
+
+	resp, _ = do(t, "GET", srv.URL+"/api/files/missing.txt", nil, nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing file: got %d", resp.StatusCode)
+	}
+
+	resp, _ = do(t, "GET", srv.URL+"/api/files/notes.txt?worktree=nope", nil, nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown worktree: got %d", resp.StatusCode)
+	}
+}
+
+func TestGet_DirectoryZip(t *testing.T) {
+	srv, workDir := newTestServer(t)
+	os.MkdirAll(filepath.Join(workDir, "src", "lib"), 0755)
+	os.WriteFile(filepath.Join(workDir, "src", "main.go"), []byte("package main"), 0644)
+	os.WriteFile(filepath.Join(workDir, "src", "lib", "util.go"), []byte("package lib"), 0644)
+
+	resp, body := do(t, "GET", srv.URL+"/api/files/src", nil, nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/zip" {
+		t.Fatalf("got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if cd := resp.Header.Get("Content-Disposition"); cd != `attachment; filename="src.zip"` {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("invalid zip: %v", err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "lib/util.go,main.go" {
+		t.Errorf("zip entries = %v", names)
+	}
+}
+
+func TestGet_Symlinks(t *testing.T) {
+	srv, workDir := newTestServer(t)
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644)
+	os.WriteFile(filepath.Join(workDir, "notes.txt"), []byte("notes"), 0644)
+	os.Symlink("notes.txt", filepath.Join(workDir, "link.txt"))
+	os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(workDir, "leak.txt"))
+	os.Symlink(outside, filepath.Join(workDir, "leakdir"))
+
+	resp, body := do(t, "GET", srv.URL+"/api/files/link.txt?download=1", nil, nil)
+	if resp.StatusCode != http.StatusOK || string(body) != "notes" {
+		t.Errorf("symlink inside: got %d %q", resp.StatusCode, body)
+	}
+	if cd := resp.Header.Get("Content-Disposition"); cd != `attachment; filename="link.txt"` {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+
+	for _, path := range []string{"leak.txt", "leakdir", "leakdir/secret.txt"} {
+		resp, body := do(t, "GET", srv.URL+"/api/files/"+path, nil, nil)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: got %d %q, want 400", path, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestGet_DirectoryZipSkipsOutsideSymlinks(t *testing.T) {
+	srv, workDir := newTestServer(t)
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644)
+
+	// sub/secret.txt stays in the index after sub is replaced by a symlink
+	os.MkdirAll(filepath.Join(workDir, "sub"), 0755)
+	os.WriteFile(filepath.Join(workDir, "sub", "secret.txt"), []byte("public"), 0644)
+	os.WriteFile(filepath.Join(workDir, "main.go"), []byte("package main"), 0644)
+	for _, args := range [][]string{{"init"}, {"add", "--all"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = workDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	os.RemoveAll(filepath.Join(workDir, "sub"))
+	os.Symlink(outside, filepath.Join(workDir, "sub"))
+	os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(workDir, "leak.txt"))
+
+	resp, body := do(t, "GET", srv.URL+"/api/files/", nil, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got %d %q", resp.StatusCode, body)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("invalid zip: %v", err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if strings.Join(names, ",") != "main.go" {
+		t.Errorf("zip entries = %v, want only main.go", names)
+	}
+}
+
+func TestPut(t *testing.T) {
+	srv, workDir := newTestServer(t)
+
+	resp, body := do(t, "PUT", srv.URL+"/api/files/uploads/a.bin", strings.NewReader("first"), nil)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create: got %d %s", resp.StatusCode, body)
+	}
+	var result contents.WriteResult
+	json.Unmarshal(body, &result)
+	if result.Hash != contents.HashContent([]byte("first")) || result.Size != 5 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	// Replacing with a stale hash fails
+	stale := http.Header{"If-Match": {`"` + contents.HashContent([]byte("other")) + `"`}}
+	resp, _ = do(t, "PUT", srv.URL+"/api/files/uploads/a.bin", strings.NewReader("second"), stale)
+	if resp.StatusCode != http.StatusPreconditionFailed || resp.Header.Get("ETag") != `"`+result.Hash+`"` {
+		t.Errorf("stale replace: got %d etag %s", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+
+	current := http.Header{"If-Match": {`"` + result.Hash + `"`}}
+	resp, _ = do(t, "PUT", srv.URL+"/api/files/uploads/a.bin", strings.NewReader("second"), current)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("replace: got %d", resp.StatusCode)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workDir, "uploads", "a.bin")); string(data) != "second" {
+		t.Errorf("content = %q", data)
+	}
+
+	resp, _ = do(t, "PUT", srv.URL+"/api/files/.git/config", strings.NewReader("x"), nil)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("write into .git: got %d", resp.StatusCode)
+	}
+}
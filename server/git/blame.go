@@ -0,0 +1,332 @@
+package git
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidRevision = errors.New("invalid revision")
+
+// notCommittedAuthor and notCommittedEmail are what git blame reports as the
+// author of lines not yet committed.
+const (
+	notCommittedAuthor = "Not Committed Yet"
+	notCommittedEmail  = "not.committed.yet"
+)
+
+// BlameLine is the blame information for a single line.
+type BlameLine struct {
+	Line        int       `json:"line"` // 1-based line number in the blamed revision
+	Hash        string    `json:"hash"`
+	Author      string    `json:"author"`
+	AuthorEmail string    `json:"author_email"`
+	Date        time.Time `json:"date"`
+	Summary     string    `json:"summary"`
+	Uncommitted bool      `json:"uncommitted"`
+	Content     string    `json:"content"`
+}
+
+// BlameOptions restricts a blame to a line range and/or revision.
+type BlameOptions struct {
+	Rev       string // empty = working tree (uncommitted lines are reported as such)
+	StartLine int    // 1-based, inclusive; 0 = from the first line
+	EndLine   int    // 1-based, inclusive; 0 = to the last line
+}
+
+// BlameCache caches full-file blames keyed by resolved commit, so repeated requests
+// for the same file (or different ranges of it) skip git until HEAD moves.
+// Working tree blames also key on the file's mtime and size.
+type BlameCache struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string][]BlameLine
+	order   []string // insertion order for eviction
+}
+
+func NewBlameCache(maxEntries int) *BlameCache {
+	return &BlameCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string][]BlameLine),
+	}
+}
+
+func (c *BlameCache) get(key string) ([]BlameLine, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lines, ok := c.entries[key]
+	return lines, ok
+}
+
+func (c *BlameCache) put(key string, lines []BlameLine) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	for len(c.order) >= c.maxEntries && len(c.order) > 0 {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.entries[key] = lines
+	c.order = append(c.order, key)
+}
+
+// Blame returns per-line blame information for path.
+// cache may be nil to disable caching.
+// Supports submodule paths (e.g., "submodule/path/to/file").
+func Blame(dir, path string, opts BlameOptions, cache *BlameCache) ([]BlameLine, error) {
+	if err := validatePath(path); err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(opts.Rev, "-") {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRevision, opts.Rev)
+	}
+	if opts.StartLine < 0 || opts.EndLine < 0 || (opts.EndLine > 0 && opts.EndLine < opts.StartLine) {
+		return nil, fmt.Errorf("invalid line range: %d-%d", opts.StartLine, opts.EndLine)
+	}
+
+	actualDir, relativePath := resolveSubmodulePath(dir, path)
+
+	key, unborn, err := blameCacheKey(actualDir, relativePath, opts.Rev)
+	if err != nil {
+		return nil, err
+	}
+
+	var all []BlameLine
+	var ok bool
+	if cache != nil {
+		all, ok = cache.get(key)
+	}
+	if !ok {
+		if unborn {
+			// git blame needs a commit; before the first one every line is uncommitted
+			all, err = blameNotCommitted(actualDir, relativePath)
+		} else {
+			all, err = runBlame(actualDir, relativePath, opts.Rev)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if cache != nil {
+			cache.put(key, all)
+		}
+	}
+
+	return sliceLines(all, opts.StartLine, opts.EndLine), nil
+}
+
+// blameCacheKey identifies a blame result: the resolved commit, plus the working tree
+// file state when blaming the working tree. unborn is set when blaming the working
+// tree of a repository without commits.
+func blameCacheKey(dir, path, rev string) (key string, unborn bool, err error) {
+	commitRev := rev
+	if commitRev == "" {
+		commitRev = "HEAD"
+	}
+
+	cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", commitRev+"^{commit}")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		if rev != "" || !isUnbornHead(dir) {
+			return "", false, fmt.Errorf("%w: %s", ErrInvalidRevision, commitRev)
+		}
+		unborn = true
+	}
+	key = dir + "\x00" + path + "\x00" + strings.TrimSpace(string(output))
+
+	if rev == "" {
+		info, err := os.Stat(filepath.Join(dir, path))
+		if err != nil {
+			return "", false, fmt.Errorf("failed to stat file: %w", err)
+		}
+		key += fmt.Sprintf("\x00%d\x00%d", info.ModTime().UnixNano(), info.Size())
+	}
+	return key, unborn, nil
+}
+
+// isUnbornHead reports whether HEAD points to a branch that has no commits yet.
+func isUnbornHead(dir string) bool {
+	cmd := exec.Command("git", "symbolic-ref", "--quiet", "HEAD")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return false
+	}
+	cmd = exec.Command("git", "rev-parse", "--verify", "--quiet", strings.TrimSpace(string(output)))
+	cmd.Dir = dir
+	return cmd.Run() != nil
+}
+
+// blameNotCommitted reports every line of a working tree file as uncommitted,
+// the way git blame reports lines changed since HEAD.
+func blameNotCommitted(dir, path string) ([]BlameLine, error) {
+	data, err := os.ReadFile(filepath.Join(dir, path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	hashLen := 40
+	cmd := exec.Command("git", "rev-parse", "--show-object-format")
+	cmd.Dir = dir
+	if output, err := cmd.Output(); err == nil && strings.TrimSpace(string(output)) == "sha256" {
+		hashLen = 64
+	}
+	hash := strings.Repeat("0", hashLen)
+
+	lines := []BlameLine{}
+	if len(data) == 0 {
+		return lines, nil
+	}
+	now := time.Now()
+	for i, text := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		lines = append(lines, BlameLine{
+			Line:        i + 1,
+			Hash:        hash,
+			Author:      notCommittedAuthor,
+			AuthorEmail: notCommittedEmail,
+			Date:        now,
+			Summary:     fmt.Sprintf("Version of %s from %s", path, path),
+			Uncommitted: true,
+			Content:     text,
+		})
+	}
+	return lines, nil
+}
+
+func runBlame(dir, path, rev string) ([]BlameLine, error) {
+	args := []string{"blame", "--porcelain"}
+	if rev != "" {
+		args = append(args, rev)
+	}
+	args = append(args, "--", path)
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		var stderr string
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			stderr = string(exitErr.Stderr)
+		}
+		return nil, fmt.Errorf("git blame failed: %w (output: %s)", err, stderr)
+	}
+
+	return parseBlamePorcelain(string(output)), nil
+}
+
+type blameCommit struct {
+	author      string
+	authorEmail string
+	date        time.Time
+	summary     string
+}
+
+// parseBlamePorcelain parses `git blame --porcelain` output.
+// Commit headers are only emitted the first time a commit appears.
+func parseBlamePorcelain(output string) []BlameLine {
+	commits := make(map[string]*blameCommit)
+	lines := []BlameLine{}
+
+	var current *BlameLine
+	var commit *blameCommit
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		text := scanner.Text()
+
+		if strings.HasPrefix(text, "\t") {
+			if current != nil {
+				current.Content = text[1:]
+				current.Author = commit.author
+				current.AuthorEmail = commit.authorEmail
+				current.Date = commit.date
+				current.Summary = commit.summary
+				lines = append(lines, *current)
+			}
+			current = nil
+			continue
+		}
+
+		if current == nil {
+			// "<hash> <orig-line> <final-line> [<num-lines>]"
+			fields := strings.Fields(text)
+			if len(fields) < 3 || !isObjectHash(fields[0]) {
+				continue
+			}
+			finalLine, err := strconv.Atoi(fields[2])
+			if err != nil {
+				continue
+			}
+			hash := fields[0]
+			commit = commits[hash]
+			if commit == nil {
+				commit = &blameCommit{}
+				commits[hash] = commit
+			}
+			// Lines not committed yet are reported with an all-zero hash
+			current = &BlameLine{Line: finalLine, Hash: hash, Uncommitted: strings.Trim(hash, "0") == ""}
+			continue
+		}
+
+		key, value, _ := strings.Cut(text, " ")
+		switch key {
+		case "author":
+			commit.author = value
+		case "author-mail":
+			commit.authorEmail = strings.Trim(value, "<>")
+		case "author-time":
+			if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+				commit.date = time.Unix(sec, 0)
+			}
+		case "summary":
+			commit.summary = value
+		}
+	}
+
+	sort.Slice(lines, func(i, j int) bool {
+		return lines[i].Line < lines[j].Line
+	})
+	return lines
+}
+
+// isObjectHash reports whether s is a full SHA-1 or SHA-256 object name.
+func isObjectHash(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func sliceLines(lines []BlameLine, start, end int) []BlameLine {
+	if start <= 1 && end == 0 {
+		return lines
+	}
+	if start < 1 {
+		start = 1
+	}
+	if end == 0 || end > len(lines) {
+		end = len(lines)
+	}
+	if start > end {
+		return []BlameLine{}
+	}
+	return lines[start-1 : end]
+}
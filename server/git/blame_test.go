@@ -0,0 +1,181 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setupBlameRepo(t *testing.T) string {
+	t.Helper()
+	dir, cleanup := setupTestRepo(t)
+	t.Cleanup(cleanup)
+
+	file := filepath.Join(dir, "file.txt")
+	os.WriteFile(file, []byte("one\ntwo\n"), 0644)
+	runGit(t, dir, "add", "file.txt")
+	runGit(t, dir, "commit", "--no-gpg-sign", "-m", "first")
+
+	os.WriteFile(file, []byte("one\ntwo\nthree\n"), 0644)
+	runGit(t, dir, "commit", "--no-gpg-sign", "-am", "second")
+	return dir
+}
+
+func TestBlame(t *testing.T) {
+	dir := setupBlameRepo(t)
+
+	lines, err := Blame(dir, "file.txt", BlameOptions{}, nil)
+	if err != nil {
+		t.Fatalf("Blame() error: %v", err)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d", len(lines))
+	}
+
+	for i, want := range []struct {
+		content string
+		summary string
+	}{
+		{"one", "first"},
+		{"two", "first"},
+		{"three", "second"},
+	} {
+		if lines[i].Line != i+1 || lines[i].Content != want.content || lines[i].Summary != want.summary {
+			t.Errorf("line %d: got %+v, want content=%q summary=%q", i+1, lines[i], want.content, want.summary)
+		}
+		if lines[i].Author != "Test" || lines[i].AuthorEmail != "test@test.com" || lines[i].Date.IsZero() {
+			t.Errorf("line %d: unexpected author info %+v", i+1, lines[i])
+		}
+	}
+	if lines[0].Hash != lines[1].Hash || lines[0].Hash == lines[2].Hash {
+		t.Errorf("unexpected hashes: %s %s %s", lines[0].Hash, lines[1].Hash, lines[2].Hash)
+	}
+}
+
+func TestBlame_RangeAndRevision(t *testing.T) {
+	dir := setupBlameRepo(t)
+
+	lines, err := Blame(dir, "file.txt", BlameOptions{StartLine: 2, EndLine: 3}, nil)
+	if err != nil {
+		t.Fatalf("Blame() error: %v", err)
+	}
+	if len(lines) != 2 || lines[0].Content != "two" || lines[1].Content != "three" {
+		t.Errorf("unexpected range result: %+v", lines)
+	}
+
+	lines, err = Blame(dir, "file.txt", BlameOptions{Rev: "HEAD~1"}, nil)
+	if err != nil {
+		t.Fatalf("Blame() error: %v", err)
+	}
+	if len(lines) != 2 {
+		t.Errorf("expected 2 lines at HEAD~1, got %d", len(lines))
+	}
+
+	if _, err := Blame(dir, "file.txt", BlameOptions{Rev: "--output=x"}, nil); !errors.Is(err, ErrInvalidRevision) {
+		t.Errorf("expected ErrInvalidRevision for option-like rev, got %v", err)
+	}
+	if _, err := Blame(dir, "file.txt", BlameOptions{Rev: "nonexistent"}, nil); !errors.Is(err, ErrInvalidRevision) {
+		t.Errorf("expected ErrInvalidRevision for unknown rev, got %v", err)
+	}
+}
+
+func TestBlame_Uncommitted(t *testing.T) {
+	dir := setupBlameRepo(t)
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("one\nchanged\nthree\n"), 0644)
+
+	lines, err := Blame(dir, "file.txt", BlameOptions{}, nil)
+	if err != nil {
+		t.Fatalf("Blame() error: %v", err)
+	}
+	if !lines[1].Uncommitted || lines[0].Uncommitted {
+		t.Errorf("expected only line 2 to be uncommitted: %+v", lines)
+	}
+}
+
+func TestBlame_NoCommits(t *testing.T) {
+	dir := t.TempDir()
+	runGit(t, dir, "init")
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("one\ntwo\n"), 0644)
+
+	lines, err := Blame(dir, "file.txt", BlameOptions{}, NewBlameCache(2))
+	if err != nil {
+		t.Fatalf("Blame() error: %v", err)
+	}
+	if len(lines) != 2 || lines[0].Content != "one" || lines[1].Content != "two" {
+		t.Fatalf("unexpected lines: %+v", lines)
+	}
+	for _, line := range lines {
+		if !line.Uncommitted || line.Hash != strings.Repeat("0", 40) {
+			t.Errorf("expected line %d to be uncommitted: %+v", line.Line, line)
+		}
+	}
+
+	if _, err := Blame(dir, "file.txt", BlameOptions{Rev: "HEAD"}, nil); !errors.Is(err, ErrInvalidRevision) {
+		t.Errorf("expected ErrInvalidRevision for HEAD without commits, got %v", err)
+	}
+}
+
+func TestBlame_SHA256(t *testing.T) {
+	dir := t.TempDir()
+	runGit(t, dir, "init", "--object-format=sha256")
+	runGit(t, dir, "config", "user.email", "test@test.com")
+	runGit(t, dir, "config", "user.name", "Test")
+	file := filepath.Join(dir, "file.txt")
+	os.WriteFile(file, []byte("one\n"), 0644)
+	runGit(t, dir, "add", "file.txt")
+	runGit(t, dir, "commit", "--no-gpg-sign", "-m", "first")
+	os.WriteFile(file, []byte("one\ntwo\n"), 0644)
+
+	lines, err := Blame(dir, "file.txt", BlameOptions{}, nil)
+	if err != nil {
+		t.Fatalf("Blame() error: %v", err)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %+v", lines)
+	}
+	if len(lines[0].Hash) != 64 || lines[0].Uncommitted || lines[0].Summary != "first" {
+		t.Errorf("unexpected committed line: %+v", lines[0])
+	}
+	if !lines[1].Uncommitted {
+		t.Errorf("expected line 2 to be uncommitted: %+v", lines[1])
+	}
+}
+
+func TestBlame_Cache(t *testing.T) {
+	dir := setupBlameRepo(t)
+	cache := NewBlameCache(2)
+
+	if _, err := Blame(dir, "file.txt", BlameOptions{}, cache); err != nil {
+		t.Fatalf("Blame() error: %v", err)
+	}
+	if len(cache.entries) != 1 {
+		t.Fatalf("expected 1 cache entry, got %d", len(cache.entries))
+	}
+
+	// Different range of the same file reuses the entry
+	if _, err := Blame(dir, "file.txt", BlameOptions{StartLine: 1, EndLine: 1}, cache); err != nil {
+		t.Fatalf("Blame() error: %v", err)
+	}
+	if len(cache.entries) != 1 {
+		t.Errorf("expected cache hit, got %d entries", len(cache.entries))
+	}
+
+	// A new commit changes HEAD and therefore the key
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("one\ntwo\nthree\nfour\n"), 0644)
+	runGit(t, dir, "commit", "--no-gpg-sign", "-am", "third")
+	lines, err := Blame(dir, "file.txt", BlameOptions{}, cache)
+	if err != nil {
+		t.Fatalf("Blame() error: %v", err)
+	}
+	if len(lines) != 4 {
+		t.Errorf("expected fresh blame with 4 lines, got %d", len(lines))
+	}
+
+	// Eviction keeps the cache bounded
+	Blame(dir, "file.txt", BlameOptions{Rev: "HEAD~1"}, cache)
+	if len(cache.entries) != 2 || len(cache.order) != 2 {
+		t.Errorf("expected cache bounded to 2 entries, got %d", len(cache.entries))
+	}
+}
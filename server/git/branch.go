@@ -0,0 +1,131 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxUnpushedCommits caps the number of commits returned by UnpushedCommits.
+const maxUnpushedCommits = 100
+
+// Commit is a summary of a single commit.
+type Commit struct {
+	Hash    string    `json:"hash"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+	Subject string    `json:"subject"`
+}
+
+// CurrentBranch returns the branch checked out in dir, or "" for a detached HEAD.
+func CurrentBranch(dir string) string {
+	cmd := exec.Command("git", "symbolic-ref", "--quiet", "--short", "HEAD")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// Upstream returns the upstream of the branch checked out in dir (e.g. "origin/main"),
+// or "" if it has none.
+func Upstream(dir string) string {
+	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// UnpushedCommits returns commits on HEAD that are not on its upstream (newest first,
+// at most maxUnpushedCommits). Without an upstream, commits reachable from any other
+// local branch or remote-tracking branch count as safe, so only commits that exist
+// solely on the current branch (or detached HEAD) are returned. The upstream used is
+// returned as well ("" if none).
+func UnpushedCommits(dir string) ([]Commit, string, error) {
+	upstream := Upstream(dir)
+
+	args := []string{"log", "--max-count=" + strconv.Itoa(maxUnpushedCommits), "--format=%H%x00%an%x00%ct%x00%s", "HEAD", "--not"}
+	if upstream != "" {
+		args = append(args, "@{upstream}")
+	} else {
+		if branch := CurrentBranch(dir); branch != "" {
+			// Patterns for --branches are matched without the refs/heads/ prefix
+			args = append(args, "--exclude="+branch)
+		}
+		args = append(args, "--branches", "--remotes")
+	}
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, "", fmt.Errorf("git log failed: %w", err)
+	}
+	return parseCommitLog(string(output)), upstream, nil
+}
+
+// parseCommitLog parses `git log --format=%H%x00%an%x00%ct%x00%s` output.
+func parseCommitLog(output string) []Commit {
+	commits := []Commit{}
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.SplitN(line, "\x00", 4)
+		if len(parts) != 4 {
+			continue
+		}
+		var date time.Time
+		if sec, err := strconv.ParseInt(parts[2], 10, 64); err == nil {
+			date = time.Unix(sec, 0)
+		}
+		commits = append(commits, Commit{
+			Hash:    parts[0],
+			Author:  parts[1],
+			Date:    date,
+			Subject: parts[3],
+		})
+	}
+	return commits
+}
+
+// AheadBehind counts commits on HEAD that are not on base (ahead) and commits on base
+// that are not on HEAD (behind).
+func AheadBehind(dir, base string) (ahead, behind int, err error) {
+	if err := validateRef(base); err != nil {
+		return 0, 0, err
+	}
+
+	cmd := exec.Command("git", "rev-list", "--left-right", "--count", base+"...HEAD")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("git rev-list failed: %w", err)
+	}
+
+	fields := strings.Fields(string(output))
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("unexpected git rev-list output: %q", output)
+	}
+	behind, _ = strconv.Atoi(fields[0])
+	ahead, _ = strconv.Atoi(fields[1])
+	return ahead, behind, nil
+}
+
+// LastCommitTime returns the committer date of HEAD, or zero time if there is none.
+func LastCommitTime(dir string) time.Time {
+	cmd := exec.Command("git", "log", "-1", "--format=%ct")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return time.Time{}
+	}
+	sec, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
@@ -0,0 +1,118 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setupBranchRepo(t *testing.T) string {
+	t.Helper()
+	dir, cleanup := setupTestRepo(t)
+	t.Cleanup(cleanup)
+
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("base\n"), 0644)
+	runGit(t, dir, "add", "file.txt")
+	runGit(t, dir, "commit", "--no-gpg-sign", "-m", "base")
+	runGit(t, dir, "branch", "-M", "main")
+	return dir
+}
+
+func TestCurrentBranch(t *testing.T) {
+	dir := setupBranchRepo(t)
+
+	if got := CurrentBranch(dir); got != "main" {
+		t.Errorf("CurrentBranch() = %q, want main", got)
+	}
+
+	runGit(t, dir, "checkout", "--detach")
+	if got := CurrentBranch(dir); got != "" {
+		t.Errorf("CurrentBranch() on detached HEAD = %q, want empty", got)
+	}
+}
+
+func TestUnpushedCommits_NoUpstream(t *testing.T) {
+	dir := setupBranchRepo(t)
+	runGit(t, dir, "checkout", "-b", "feature")
+
+	commits, upstream, err := UnpushedCommits(dir)
+	if err != nil {
+		t.Fatalf("UnpushedCommits() error: %v", err)
+	}
+	if upstream != "" || len(commits) != 0 {
+		t.Errorf("expected no unpushed commits on fresh branch, got %+v (upstream %q)", commits, upstream)
+	}
+
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("feature\n"), 0644)
+	runGit(t, dir, "commit", "--no-gpg-sign", "-am", "feature work")
+
+	commits, _, err = UnpushedCommits(dir)
+	if err != nil {
+		t.Fatalf("UnpushedCommits() error: %v", err)
+	}
+	if len(commits) != 1 || commits[0].Subject != "feature work" || commits[0].Author != "Test" || len(commits[0].Hash) != 40 {
+		t.Errorf("unexpected commits: %+v", commits)
+	}
+}
+
+func TestUnpushedCommits_Upstream(t *testing.T) {
+	dir := setupBranchRepo(t)
+	runGit(t, dir, "checkout", "-b", "feature")
+	runGit(t, dir, "branch", "--set-upstream-to=main")
+
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("one\n"), 0644)
+	runGit(t, dir, "commit", "--no-gpg-sign", "-am", "one")
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("two\n"), 0644)
+	runGit(t, dir, "commit", "--no-gpg-sign", "-am", "two")
+
+	commits, upstream, err := UnpushedCommits(dir)
+	if err != nil {
+		t.Fatalf("UnpushedCommits() error: %v", err)
+	}
+	if upstream != "main" {
+		t.Errorf("upstream = %q, want main", upstream)
+	}
+	if len(commits) != 2 || commits[0].Subject != "two" || commits[1].Subject != "one" {
+		t.Errorf("expected two commits newest first, got %+v", commits)
+	}
+}
+
+func TestAheadBehind(t *testing.T) {
+	dir := setupBranchRepo(t)
+	runGit(t, dir, "checkout", "-b", "feature")
+	runGit(t, dir, "commit", "--allow-empty", "--no-gpg-sign", "-m", "feature 1")
+	runGit(t, dir, "commit", "--allow-empty", "--no-gpg-sign", "-m", "feature 2")
+	runGit(t, dir, "checkout", "main")
+	runGit(t, dir, "commit", "--allow-empty", "--no-gpg-sign", "-m", "main 1")
+	runGit(t, dir, "checkout", "feature")
+
+	ahead, behind, err := AheadBehind(dir, "main")
+	if err != nil {
+		t.Fatalf("AheadBehind() error: %v", err)
+	}
+	if ahead != 2 || behind != 1 {
+		t.Errorf("AheadBehind() = %d, %d, want 2, 1", ahead, behind)
+	}
+
+	if _, _, err := AheadBehind(dir, "missing"); err == nil {
+		t.Error("expected error for unknown base")
+	}
+}
+
+func TestDiffSummary(t *testing.T) {
+	dir := setupBranchRepo(t)
+
+	if got, err := DiffSummary(dir); err != nil || got != "" {
+		t.Fatalf("DiffSummary() on clean tree = %q, %v", got, err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("base\nchanged\n"), 0644)
+	got, err := DiffSummary(dir)
+	if err != nil {
+		t.Fatalf("DiffSummary() error: %v", err)
+	}
+	if !strings.Contains(got, "file.txt | 1 +") || !strings.Contains(got, "1 file changed") {
+		t.Errorf("DiffSummary() = %q", got)
+	}
+}
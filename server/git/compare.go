@@ -0,0 +1,244 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// SnapshotTree writes a tree object for the working tree of dir as `git add -A` would
+// stage it: committed files plus staged, unstaged and untracked (not ignored) changes.
+// A temporary copy of the index is used, so the real index and refs are untouched,
+// and only paths that differ from the index are added, so unchanged files are
+// neither rehashed nor written. Trees are stored in the shared object database, so
+// a snapshot of one worktree can be diffed against another (see DiffWorkingTree).
+func SnapshotTree(dir string) (string, error) {
+	env, cleanup, err := tempIndex(dir)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	changed, err := listPaths(dir, "diff", "--name-only", "-z", "--no-renames")
+	if err != nil {
+		return "", err
+	}
+	untracked, err := listPaths(dir, "ls-files", "-z", "--others", "--exclude-standard")
+	if err != nil {
+		return "", err
+	}
+	if err := addPaths(dir, env, append(changed, untracked...), "--all"); err != nil {
+		return "", err
+	}
+
+	writeTree := exec.Command("git", "write-tree")
+	writeTree.Dir = dir
+	writeTree.Env = env
+	output, err := writeTree.Output()
+	if err != nil {
+		return "", fmt.Errorf("git write-tree failed: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// DiffWorkingTree lists files that differ between a tree-ish and the working tree
+// of dir, including staged, unstaged and untracked (not ignored) changes. Renames
+// are reported as a deletion plus an addition. No file contents are written to
+// the object database.
+func DiffWorkingTree(dir, from string) ([]FileStatus, error) {
+	if err := validateRef(from); err != nil {
+		return nil, err
+	}
+
+	env, cleanup, err := workingTreeIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	cmd := exec.Command("git", "diff", "--name-status", "--no-renames", "-z", from, "--")
+	cmd.Dir = dir
+	cmd.Env = env
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff failed: %w", err)
+	}
+	return parseNameStatus(string(output)), nil
+}
+
+// DiffWorkingTreeFile returns the unified diff of path between a tree-ish and the
+// working tree of dir, along with its content on each side (empty where the file
+// does not exist).
+func DiffWorkingTreeFile(dir, from, path string) (*DiffResult, error) {
+	if err := validateRef(from); err != nil {
+		return nil, err
+	}
+	if err := validatePath(path); err != nil {
+		return nil, err
+	}
+
+	env, cleanup, err := workingTreeIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	cmd := exec.Command("git", "diff", from, "--", path)
+	cmd.Dir = dir
+	cmd.Env = env
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("git diff failed: %w (output: %s)", err, string(output))
+	}
+	if len(output) == 0 {
+		return &DiffResult{}, nil
+	}
+
+	oldContent, _ := getFileFromRef(dir, from, path)
+	newContent, _ := getFileFromWorktree(dir, path)
+
+	return &DiffResult{
+		Diff:       string(output),
+		OldContent: oldContent,
+		NewContent: newContent,
+	}, nil
+}
+
+// tempIndex returns an environment using a temporary copy of the index of dir,
+// and a function removing it.
+func tempIndex(dir string) ([]string, func(), error) {
+	tmpDir, err := os.MkdirTemp("", "pockode-index-")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { os.RemoveAll(tmpDir) }
+	indexFile := filepath.Join(tmpDir, "index")
+
+	// Starting from the real index reuses its stat cache, so unchanged files are not rehashed
+	if index, err := gitPath(dir, "index"); err == nil {
+		if err := copyFile(index, indexFile); err != nil && !os.IsNotExist(err) {
+			cleanup()
+			return nil, nil, err
+		}
+	}
+	return append(os.Environ(), "GIT_INDEX_FILE="+indexFile), cleanup, nil
+}
+
+// workingTreeIndex is tempIndex with untracked (not ignored) files marked as
+// intent-to-add, so that `git diff <tree-ish>` compares against the whole working
+// tree without hashing untracked files into the object database.
+func workingTreeIndex(dir string) ([]string, func(), error) {
+	env, cleanup, err := tempIndex(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	untracked, err := listPaths(dir, "ls-files", "-z", "--others", "--exclude-standard")
+	if err == nil {
+		err = addPaths(dir, env, untracked, "--intent-to-add")
+	}
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return env, cleanup, nil
+}
+
+// listPaths runs a git command printing NUL-separated paths.
+func listPaths(dir string, args ...string) ([]string, error) {
+	cmd := exec.Command("git", append([]string{"--no-optional-locks"}, args...)...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %w", args[0], err)
+	}
+	var paths []string
+	for _, path := range strings.Split(string(output), "\x00") {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// addPaths runs `git add <flag>` for paths with the given environment.
+func addPaths(dir string, env, paths []string, flag string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	add := exec.Command("git", "add", flag, "--pathspec-from-file=-", "--pathspec-file-nul")
+	add.Dir = dir
+	add.Env = env
+	add.Stdin = strings.NewReader(strings.Join(paths, "\x00"))
+	if output, err := add.CombinedOutput(); err != nil {
+		return fmt.Errorf("git add failed: %w (output: %s)", err, output)
+	}
+	return nil
+}
+
+// MergeBase returns the best common ancestor of a and b.
+func MergeBase(dir, a, b string) (string, error) {
+	if err := validateRef(a); err != nil {
+		return "", err
+	}
+	if err := validateRef(b); err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command("git", "merge-base", a, b)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git merge-base failed: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// parseNameStatus parses `git diff --name-status --no-renames -z` output.
+func parseNameStatus(output string) []FileStatus {
+	// Output is "<status>\0<path>\0" repeated
+	files := []FileStatus{}
+	fields := strings.Split(output, "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		files = append(files, FileStatus{
+			Path:   fields[i+1],
+			Status: fields[i][:1],
+		})
+	}
+	return files
+}
+
+// gitPath resolves a path inside the git dir of dir (e.g. "index" of a linked worktree).
+func gitPath(dir, name string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--git-path", name)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	path := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	return path, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,129 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDiffWorkingTree(t *testing.T) {
+	dir := setupBranchRepo(t)
+	os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("ignored.txt\n"), 0644)
+	runGit(t, dir, "add", ".gitignore")
+	runGit(t, dir, "commit", "--no-gpg-sign", "-m", "ignore")
+
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("changed\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "ignored.txt"), []byte("secret\n"), 0644)
+
+	files, err := DiffWorkingTree(dir, "HEAD")
+	if err != nil {
+		t.Fatalf("DiffWorkingTree() error: %v", err)
+	}
+	want := map[string]string{"file.txt": "M", "new.txt": "A"}
+	if len(files) != len(want) {
+		t.Fatalf("DiffWorkingTree() = %+v, want %v", files, want)
+	}
+	for _, f := range files {
+		if want[f.Path] != f.Status {
+			t.Errorf("file %s status = %q, want %q", f.Path, f.Status, want[f.Path])
+		}
+	}
+
+	// Neither the real index nor the object database is written
+	if status, _ := Status(dir); len(status.Staged) != 0 {
+		t.Errorf("expected nothing staged, got %+v", status.Staged)
+	}
+	for _, name := range []string{"file.txt", "new.txt"} {
+		hash, _ := runGitOutput(dir, "hash-object", name)
+		hash = strings.TrimSpace(hash)
+		if exec.Command("git", "-C", dir, "cat-file", "-e", hash).Run() == nil {
+			t.Errorf("content of %s was written to the object database", name)
+		}
+	}
+}
+
+func TestDiffWorkingTreeFile(t *testing.T) {
+	dir := setupBranchRepo(t)
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("changed\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new\n"), 0644)
+
+	result, err := DiffWorkingTreeFile(dir, "HEAD", "file.txt")
+	if err != nil {
+		t.Fatalf("DiffWorkingTreeFile() error: %v", err)
+	}
+	if result.OldContent != "base\n" || result.NewContent != "changed\n" || result.Diff == "" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	result, err = DiffWorkingTreeFile(dir, "HEAD", "new.txt")
+	if err != nil {
+		t.Fatalf("DiffWorkingTreeFile() error: %v", err)
+	}
+	if result.OldContent != "" || result.NewContent != "new\n" || !strings.Contains(result.Diff, "+new") {
+		t.Errorf("unexpected result for untracked file: %+v", result)
+	}
+
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("base\n"), 0644)
+	result, err = DiffWorkingTreeFile(dir, "HEAD", "file.txt")
+	if err != nil {
+		t.Fatalf("DiffWorkingTreeFile() error: %v", err)
+	}
+	if result.Diff != "" || result.OldContent != "" {
+		t.Errorf("expected empty result for unchanged file, got %+v", result)
+	}
+
+	if _, err := DiffWorkingTreeFile(dir, "HEAD", "../outside"); err == nil {
+		t.Error("expected error for path traversal")
+	}
+}
+
+func TestSnapshotTree_IncludesUncommittedChanges(t *testing.T) {
+	dir := setupBranchRepo(t)
+	os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("ignored.txt\n"), 0644)
+	runGit(t, dir, "add", ".gitignore")
+	runGit(t, dir, "commit", "--no-gpg-sign", "-m", "ignore")
+
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("changed\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "ignored.txt"), []byte("secret\n"), 0644)
+
+	tree, err := SnapshotTree(dir)
+	if err != nil {
+		t.Fatalf("SnapshotTree() error: %v", err)
+	}
+
+	output, _ := runGitOutput(dir, "ls-tree", "--name-only", tree)
+	names := strings.Fields(output)
+	if !slices.Equal(names, []string{".gitignore", "file.txt", "new.txt"}) {
+		t.Errorf("snapshot contains %v", names)
+	}
+	if content, _ := runGitOutput(dir, "show", tree+":file.txt"); content != "changed\n" {
+		t.Errorf("snapshot of file.txt = %q", content)
+	}
+
+	// The real index is untouched
+	if status, _ := Status(dir); len(status.Staged) != 0 {
+		t.Errorf("expected nothing staged, got %+v", status.Staged)
+	}
+}
+
+func TestMergeBase(t *testing.T) {
+	dir := setupMergeRepo(t)
+	base, err := HeadCommit(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "commit", "--allow-empty", "--no-gpg-sign", "-m", "main moves on")
+
+	got, err := MergeBase(dir, "main", "feature")
+	if err != nil {
+		t.Fatalf("MergeBase() error: %v", err)
+	}
+	if got != base {
+		t.Errorf("MergeBase() = %s, want %s", got, base)
+	}
+}
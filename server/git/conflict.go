@@ -0,0 +1,252 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrNoOperationInProgress = errors.New("no operation in progress")
+	ErrNotConflicted         = errors.New("file is not conflicted")
+	ErrInvalidResolution     = errors.New("invalid resolution")
+)
+
+// ConflictError is returned when an operation stops because of merge conflicts.
+// The working tree is left in the conflicted state so the caller can resolve it.
+type ConflictError struct {
+	Operation string   `json:"operation"` // e.g. "stash apply", "merge"
+	Paths     []string `json:"paths"`
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s stopped with conflicts in %d file(s)", e.Operation, len(e.Paths))
+}
+
+// Operation is a multi-step git operation that can be in progress in a worktree.
+type Operation string
+
+const (
+	OperationNone       Operation = ""
+	OperationMerge      Operation = "merge"
+	OperationRebase     Operation = "rebase"
+	OperationCherryPick Operation = "cherry-pick"
+	OperationRevert     Operation = "revert"
+)
+
+// OperationInProgress detects an interrupted merge, rebase, cherry-pick or revert.
+func OperationInProgress(dir string) Operation {
+	gitDir := gitDirOf(dir)
+	if gitDir == "" {
+		return OperationNone
+	}
+
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(gitDir, name))
+		return err == nil
+	}
+
+	switch {
+	case exists("rebase-merge"), exists("rebase-apply"):
+		return OperationRebase
+	case exists("MERGE_HEAD"):
+		return OperationMerge
+	case exists("CHERRY_PICK_HEAD"):
+		return OperationCherryPick
+	case exists("REVERT_HEAD"):
+		return OperationRevert
+	}
+	return OperationNone
+}
+
+// gitDirOf returns the absolute git directory for dir (per-worktree for linked worktrees).
+func gitDirOf(dir string) string {
+	cmd := exec.Command("git", "rev-parse", "--absolute-git-dir")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// ConflictContent holds the three sides of a conflicted file plus the working tree
+// version (which contains conflict markers for text files).
+type ConflictContent struct {
+	Path         string `json:"path"`
+	Status       string `json:"status"` // porcelain code, e.g. "UU", "DU"
+	Base         string `json:"base"`
+	Ours         string `json:"ours"`
+	Theirs       string `json:"theirs"`
+	Merged       string `json:"merged"`
+	BaseExists   bool   `json:"base_exists"`
+	OursExists   bool   `json:"ours_exists"`
+	TheirsExists bool   `json:"theirs_exists"`
+}
+
+// GetConflict returns base/ours/theirs content for a conflicted file.
+// Returns ErrNotConflicted if the file has no unmerged entries.
+// Supports submodule paths (e.g., "submodule/path/to/file").
+func GetConflict(dir, path string) (*ConflictContent, error) {
+	if err := validatePath(path); err != nil {
+		return nil, err
+	}
+
+	actualDir, relativePath := resolveSubmodulePath(dir, path)
+
+	status, err := conflictStatus(actualDir, relativePath)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ConflictContent{Path: path, Status: status}
+	result.Base, result.BaseExists = getFileFromIndex(actualDir, "1:"+relativePath)
+	result.Ours, result.OursExists = getFileFromIndex(actualDir, "2:"+relativePath)
+	result.Theirs, result.TheirsExists = getFileFromIndex(actualDir, "3:"+relativePath)
+	result.Merged, _ = getFileFromWorktree(actualDir, relativePath)
+
+	return result, nil
+}
+
+// Resolution selects how a conflicted file is resolved.
+type Resolution string
+
+const (
+	ResolutionOurs   Resolution = "ours"
+	ResolutionTheirs Resolution = "theirs"
+	ResolutionCustom Resolution = "custom"
+)
+
+// ResolveConflict resolves a conflicted file and marks it resolved in the index.
+// For ours/theirs, a side that deleted the file resolves to deletion.
+// For custom, content is written to the working tree as-is.
+func ResolveConflict(dir, path string, resolution Resolution, content string) error {
+	if err := validatePath(path); err != nil {
+		return err
+	}
+
+	actualDir, relativePath := resolveSubmodulePath(dir, path)
+
+	if _, err := conflictStatus(actualDir, relativePath); err != nil {
+		return err
+	}
+
+	switch resolution {
+	case ResolutionOurs, ResolutionTheirs:
+		stage := "2:"
+		if resolution == ResolutionTheirs {
+			stage = "3:"
+		}
+		if _, exists := getFileFromIndex(actualDir, stage+relativePath); !exists {
+			return runGitIn(actualDir, "rm", "--quiet", "--", relativePath)
+		}
+		if err := runGitIn(actualDir, "checkout", "--"+string(resolution), "--", relativePath); err != nil {
+			return err
+		}
+	case ResolutionCustom:
+		fullPath := filepath.Join(actualDir, relativePath)
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write file: %w", err)
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidResolution, resolution)
+	}
+
+	return runGitIn(actualDir, "add", "--", relativePath)
+}
+
+// conflictStatus returns the porcelain code of an unmerged path or ErrNotConflicted.
+func conflictStatus(dir, path string) (string, error) {
+	cmd := exec.Command("git", "--no-optional-locks", "--literal-pathspecs", "status", "--porcelain=v1", "-z", "--", path)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git status failed: %w", err)
+	}
+
+	// Entries are "XY <path>\0"; renames and copies are followed by the original path
+	entries := strings.Split(string(output), "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		x, y := entry[0], entry[1]
+		if x == 'R' || x == 'C' {
+			i++
+		}
+		if entry[3:] == path && isUnmerged(x, y) {
+			return entry[:2], nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrNotConflicted, path)
+}
+
+// ContinueOperation continues the in-progress operation after all conflicts are resolved.
+// Returns a *ConflictError if conflicts remain or the next step (e.g. the next rebase
+// commit) stops on new conflicts.
+func ContinueOperation(dir string, op Operation) error {
+	if err := requireOperation(dir, op); err != nil {
+		return err
+	}
+	if paths := unmergedPaths(dir); len(paths) > 0 {
+		return &ConflictError{Operation: string(op), Paths: paths}
+	}
+
+	// GIT_EDITOR=true accepts the prepared commit message without an editor
+	cmd := exec.Command("git", string(op), "--continue")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_EDITOR=true")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if paths := unmergedPaths(dir); len(paths) > 0 {
+			return &ConflictError{Operation: string(op), Paths: paths}
+		}
+		return fmt.Errorf("git %s --continue failed: %w (output: %s)", op, err, string(output))
+	}
+	return nil
+}
+
+// AbortOperation aborts the in-progress operation and restores the pre-operation state.
+func AbortOperation(dir string, op Operation) error {
+	if err := requireOperation(dir, op); err != nil {
+		return err
+	}
+	return runGitIn(dir, string(op), "--abort")
+}
+
+func requireOperation(dir string, op Operation) error {
+	if current := OperationInProgress(dir); current != op {
+		return fmt.Errorf("%w: %s", ErrNoOperationInProgress, op)
+	}
+	return nil
+}
+
+func runGitIn(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s failed: %w (output: %s)", args[0], err, string(output))
+	}
+	return nil
+}
+
+// unmergedPaths returns paths that currently have unresolved conflicts.
+func unmergedPaths(dir string) []string {
+	cmd := exec.Command("git", "diff", "--name-only", "--diff-filter=U", "-z")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	var paths []string
+	for _, path := range strings.Split(string(output), "\x00") {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,200 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// setupConflictRepo creates a repo where merging "feature" into the current branch
+// conflicts on file.txt. The merge is left in progress.
+func setupConflictRepo(t *testing.T) string {
+	return setupConflictRepoWithFiles(t, "file.txt")
+}
+
+// setupConflictRepoWithFiles is setupConflictRepo with a conflict on each of names.
+func setupConflictRepoWithFiles(t *testing.T, names ...string) string {
+	t.Helper()
+	dir, cleanup := setupTestRepo(t)
+	t.Cleanup(cleanup)
+
+	write := func(content string) {
+		for _, name := range names {
+			os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		}
+	}
+	write("base\n")
+	runGit(t, dir, "add", "--all")
+	runGit(t, dir, "commit", "--no-gpg-sign", "-m", "base")
+	runGit(t, dir, "branch", "feature")
+
+	write("ours\n")
+	runGit(t, dir, "commit", "--no-gpg-sign", "-am", "ours")
+
+	runGit(t, dir, "checkout", "feature")
+	write("theirs\n")
+	runGit(t, dir, "commit", "--no-gpg-sign", "-am", "theirs")
+	runGit(t, dir, "checkout", "-")
+
+	cmd := exec.Command("git", "merge", "feature")
+	cmd.Dir = dir
+	if err := cmd.Run(); err == nil {
+		t.Fatal("expected merge to conflict")
+	}
+	return dir
+}
+
+func TestStatus_Conflicted(t *testing.T) {
+	dir := setupConflictRepo(t)
+
+	status, err := Status(dir)
+	if err != nil {
+		t.Fatalf("Status() error: %v", err)
+	}
+
+	if len(status.Conflicted) != 1 {
+		t.Fatalf("expected 1 conflicted file, got %+v", status.Conflicted)
+	}
+	if status.Conflicted[0].Path != "file.txt" || status.Conflicted[0].Status != "UU" {
+		t.Errorf("unexpected conflict entry: %+v", status.Conflicted[0])
+	}
+	if len(status.Staged) != 0 || len(status.Unstaged) != 0 {
+		t.Errorf("conflicted file should not be in staged/unstaged: %+v", status)
+	}
+	if status.Operation != OperationMerge {
+		t.Errorf("expected operation %q, got %q", OperationMerge, status.Operation)
+	}
+}
+
+func TestGetConflict(t *testing.T) {
+	dir := setupConflictRepo(t)
+
+	c, err := GetConflict(dir, "file.txt")
+	if err != nil {
+		t.Fatalf("GetConflict() error: %v", err)
+	}
+	if c.Base != "base\n" || c.Ours != "ours\n" || c.Theirs != "theirs\n" {
+		t.Errorf("unexpected sides: base=%q ours=%q theirs=%q", c.Base, c.Ours, c.Theirs)
+	}
+	if !c.BaseExists || !c.OursExists || !c.TheirsExists {
+		t.Errorf("expected all sides to exist: %+v", c)
+	}
+	if !strings.Contains(c.Merged, "<<<<<<<") {
+		t.Errorf("expected conflict markers in merged content, got %q", c.Merged)
+	}
+}
+
+func TestGetConflict_NotConflicted(t *testing.T) {
+	dir := setupConflictRepo(t)
+	os.WriteFile(filepath.Join(dir, "other.txt"), []byte("x"), 0644)
+
+	if _, err := GetConflict(dir, "other.txt"); !errors.Is(err, ErrNotConflicted) {
+		t.Errorf("expected ErrNotConflicted, got %v", err)
+	}
+}
+
+func TestResolveConflict(t *testing.T) {
+	tests := []struct {
+		resolution Resolution
+		content    string
+		want       string
+	}{
+		{ResolutionOurs, "", "ours\n"},
+		{ResolutionTheirs, "", "theirs\n"},
+		{ResolutionCustom, "custom\n", "custom\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.resolution), func(t *testing.T) {
+			dir := setupConflictRepo(t)
+
+			if err := ResolveConflict(dir, "file.txt", tt.resolution, tt.content); err != nil {
+				t.Fatalf("ResolveConflict() error: %v", err)
+			}
+
+			content, _ := os.ReadFile(filepath.Join(dir, "file.txt"))
+			if string(content) != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, content)
+			}
+			status, _ := Status(dir)
+			if len(status.Conflicted) != 0 {
+				t.Errorf("expected no conflicts after resolve, got %+v", status.Conflicted)
+			}
+		})
+	}
+}
+
+func TestConflict_QuotedPaths(t *testing.T) {
+	// git status quotes these names unless -z is used
+	names := []string{"my file.txt", "naïve.txt"}
+	dir := setupConflictRepoWithFiles(t, names...)
+
+	err := ContinueOperation(dir, OperationMerge)
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) || !slices.Equal(conflictErr.Paths, names) {
+		t.Fatalf("ContinueOperation() error = %v, want conflicts on %q", err, names)
+	}
+
+	for _, name := range names {
+		c, err := GetConflict(dir, name)
+		if err != nil {
+			t.Fatalf("GetConflict(%q) error: %v", name, err)
+		}
+		if c.Ours != "ours\n" || c.Theirs != "theirs\n" {
+			t.Errorf("unexpected sides of %q: ours=%q theirs=%q", name, c.Ours, c.Theirs)
+		}
+		if err := ResolveConflict(dir, name, ResolutionTheirs, ""); err != nil {
+			t.Fatalf("ResolveConflict(%q) error: %v", name, err)
+		}
+	}
+	if status, _ := Status(dir); len(status.Conflicted) != 0 {
+		t.Errorf("expected no conflicts after resolve, got %+v", status.Conflicted)
+	}
+}
+
+func TestResolveConflict_InvalidResolution(t *testing.T) {
+	dir := setupConflictRepo(t)
+
+	if err := ResolveConflict(dir, "file.txt", "both", ""); !errors.Is(err, ErrInvalidResolution) {
+		t.Errorf("expected ErrInvalidResolution, got %v", err)
+	}
+}
+
+func TestContinueOperation_Merge(t *testing.T) {
+	dir := setupConflictRepo(t)
+
+	var conflict *ConflictError
+	if err := ContinueOperation(dir, OperationMerge); !errors.As(err, &conflict) {
+		t.Fatalf("expected ConflictError while unresolved, got %v", err)
+	}
+
+	if err := ResolveConflict(dir, "file.txt", ResolutionOurs, ""); err != nil {
+		t.Fatalf("ResolveConflict() error: %v", err)
+	}
+	if err := ContinueOperation(dir, OperationMerge); err != nil {
+		t.Fatalf("ContinueOperation() error: %v", err)
+	}
+	if op := OperationInProgress(dir); op != OperationNone {
+		t.Errorf("expected no operation in progress, got %q", op)
+	}
+}
+
+func TestAbortOperation(t *testing.T) {
+	dir := setupConflictRepo(t)
+
+	if err := AbortOperation(dir, OperationRebase); !errors.Is(err, ErrNoOperationInProgress) {
+		t.Errorf("expected ErrNoOperationInProgress for rebase, got %v", err)
+	}
+
+	if err := AbortOperation(dir, OperationMerge); err != nil {
+		t.Fatalf("AbortOperation() error: %v", err)
+	}
+	content, _ := os.ReadFile(filepath.Join(dir, "file.txt"))
+	if string(content) != "ours\n" {
+		t.Errorf("expected pre-merge content, got %q", content)
+	}
+}
@@ -0,0 +1,58 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// Discard throws away changes to a file or directory.
+// If staged is false, the working tree is restored from the index (unstaged changes only).
+// If staged is true, both the index and the working tree are restored from HEAD.
+// Untracked files under path are deleted in both cases; ignored files are left alone.
+// For submodule paths (e.g., "submodule/path/to/file"), it runs inside the submodule.
+func Discard(dir, path string, staged bool) error {
+	if err := validatePath(path); err != nil {
+		return err
+	}
+
+	actualDir, relativePath := resolveSubmodulePath(dir, path)
+
+	if isTracked(actualDir, relativePath, staged) {
+		args := []string{"restore", "--worktree"}
+		if staged {
+			args = append(args, "--source=HEAD", "--staged")
+		}
+		args = append(args, "--", relativePath)
+
+		cmd := exec.Command("git", args...)
+		cmd.Dir = actualDir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git restore failed: %w (output: %s)", err, string(output))
+		}
+	}
+
+	cmd := exec.Command("git", "clean", "-f", "-d", "--", relativePath)
+	cmd.Dir = actualDir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git clean failed: %w (output: %s)", err, string(output))
+	}
+	return nil
+}
+
+// isTracked reports whether path (or anything under it) is known to git.
+// With staged, files present only in HEAD also count, since restoring from HEAD brings them back.
+func isTracked(dir, path string, staged bool) bool {
+	cmd := exec.Command("git", "ls-files", "--error-unmatch", "--", path)
+	cmd.Dir = dir
+	if cmd.Run() == nil {
+		return true
+	}
+	if !staged {
+		return false
+	}
+
+	cmd = exec.Command("git", "ls-tree", "--name-only", "HEAD", "--", path)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	return err == nil && len(output) > 0
+}
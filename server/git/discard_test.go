@@ -0,0 +1,107 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiscard(t *testing.T) {
+	setup := func(t *testing.T) string {
+		dir, cleanup := setupTestRepo(t)
+		t.Cleanup(cleanup)
+
+		if err := os.WriteFile(filepath.Join(dir, "tracked.txt"), []byte("original"), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		runGit(t, dir, "add", "tracked.txt")
+		runGit(t, dir, "commit", "--no-gpg-sign", "-m", "initial")
+		return dir
+	}
+
+	t.Run("unstaged restores from index", func(t *testing.T) {
+		dir := setup(t)
+		file := filepath.Join(dir, "tracked.txt")
+		os.WriteFile(file, []byte("staged"), 0644)
+		runGit(t, dir, "add", "tracked.txt")
+		os.WriteFile(file, []byte("unstaged"), 0644)
+
+		if err := Discard(dir, "tracked.txt", false); err != nil {
+			t.Fatalf("Discard() error: %v", err)
+		}
+
+		content, _ := os.ReadFile(file)
+		if string(content) != "staged" {
+			t.Errorf("expected index content 'staged', got %q", content)
+		}
+	})
+
+	t.Run("staged restores from HEAD", func(t *testing.T) {
+		dir := setup(t)
+		file := filepath.Join(dir, "tracked.txt")
+		os.WriteFile(file, []byte("staged"), 0644)
+		runGit(t, dir, "add", "tracked.txt")
+
+		if err := Discard(dir, "tracked.txt", true); err != nil {
+			t.Fatalf("Discard() error: %v", err)
+		}
+
+		content, _ := os.ReadFile(file)
+		if string(content) != "original" {
+			t.Errorf("expected HEAD content 'original', got %q", content)
+		}
+		status, _ := Status(dir)
+		if len(status.Staged) != 0 || len(status.Unstaged) != 0 {
+			t.Errorf("expected clean status, got %+v", status)
+		}
+	})
+
+	t.Run("deleted file is restored", func(t *testing.T) {
+		dir := setup(t)
+		os.Remove(filepath.Join(dir, "tracked.txt"))
+
+		if err := Discard(dir, "tracked.txt", false); err != nil {
+			t.Fatalf("Discard() error: %v", err)
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "tracked.txt")); err != nil {
+			t.Errorf("expected file to be restored: %v", err)
+		}
+	})
+
+	t.Run("untracked file is removed", func(t *testing.T) {
+		dir := setup(t)
+		file := filepath.Join(dir, "new.txt")
+		os.WriteFile(file, []byte("new"), 0644)
+
+		if err := Discard(dir, "new.txt", false); err != nil {
+			t.Fatalf("Discard() error: %v", err)
+		}
+
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Error("expected untracked file to be removed")
+		}
+	})
+
+	t.Run("newly added file is removed when staged", func(t *testing.T) {
+		dir := setup(t)
+		file := filepath.Join(dir, "added.txt")
+		os.WriteFile(file, []byte("added"), 0644)
+		runGit(t, dir, "add", "added.txt")
+
+		if err := Discard(dir, "added.txt", true); err != nil {
+			t.Fatalf("Discard() error: %v", err)
+		}
+
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Error("expected added file to be removed")
+		}
+	})
+
+	t.Run("rejects path traversal", func(t *testing.T) {
+		dir := setup(t)
+		if err := Discard(dir, "../outside", false); err == nil {
+			t.Error("expected error for path traversal")
+		}
+	})
+}
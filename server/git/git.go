@@ -195,9 +195,13 @@ type FileStatus struct {
 }
 
 // GitStatus represents the overall git status.
+// Unmerged entries (UU, AA, DU, ...) are reported only in Conflicted, with the
+// two-letter porcelain code as Status.
 type GitStatus struct {
 	Staged     []FileStatus          `json:"staged"`
 	Unstaged   []FileStatus          `json:"unstaged"`
+	Conflicted []FileStatus          `json:"conflicted"`
+	Operation  Operation             `json:"operation,omitempty"` // in-progress merge/rebase/etc.
 	Submodules map[string]*GitStatus `json:"submodules,omitempty"`
 }
 
@@ -242,8 +246,10 @@ func Status(dir string) (*GitStatus, error) {
 	}
 
 	result := &GitStatus{
-		Staged:   []FileStatus{},
-		Unstaged: []FileStatus{},
+		Staged:     []FileStatus{},
+		Unstaged:   []FileStatus{},
+		Conflicted: []FileStatus{},
+		Operation:  OperationInProgress(dir),
 	}
 
 	submodules := getSubmodulePaths(dir)
@@ -254,13 +260,13 @@ func Status(dir string) (*GitStatus, error) {
 		for _, sub := range submodules {
 			subDir := filepath.Join(dir, sub)
 			if !isGitRepository(subDir) {
-				result.Submodules[sub] = &GitStatus{Staged: []FileStatus{}, Unstaged: []FileStatus{}}
+				result.Submodules[sub] = emptyStatus()
 				continue
 			}
 			subStatus, err := Status(subDir)
 			if err != nil {
 				slog.Warn("failed to get submodule status", "submodule", sub, "error", err)
-				result.Submodules[sub] = &GitStatus{Staged: []FileStatus{}, Unstaged: []FileStatus{}}
+				result.Submodules[sub] = emptyStatus()
 				continue
 			}
 			result.Submodules[sub] = subStatus
@@ -287,6 +293,11 @@ func Status(dir string) (*GitStatus, error) {
 			continue
 		}
 
+		if isUnmerged(stagedStatus, unstagedStatus) {
+			result.Conflicted = append(result.Conflicted, FileStatus{Path: path, Status: line[:2]})
+			continue
+		}
+
 		if stagedStatus != ' ' && stagedStatus != '?' {
 			result.Staged = append(result.Staged, FileStatus{Path: path, Status: string(stagedStatus)})
 		}
@@ -298,6 +309,16 @@ func Status(dir string) (*GitStatus, error) {
 	return result, nil
 }
 
+func emptyStatus() *GitStatus {
+	return &GitStatus{Staged: []FileStatus{}, Unstaged: []FileStatus{}, Conflicted: []FileStatus{}}
+}
+
+// isUnmerged reports whether a porcelain v1 XY code is an unmerged entry
+// (DD, AU, UD, UA, DU, AA, UU).
+func isUnmerged(x, y byte) bool {
+	return x == 'U' || y == 'U' || (x == 'A' && y == 'A') || (x == 'D' && y == 'D')
+}
+
 func contains(slice []string, item string) bool {
 	for _, s := range slice {
 		if s == item {
@@ -529,6 +550,23 @@ func extractHost(repoURL string) (string, error) {
 	return parsed.Host, nil
 }
 
+// DiffSummary returns the diffstat of the staged and unstaged changes to tracked
+// files in dir, or "" if there are none.
+func DiffSummary(dir string) (string, error) {
+	args := []string{"diff", "--stat", "HEAD"}
+	if _, err := HeadCommit(dir); err != nil {
+		// No commits yet: summarize what is staged
+		args = []string{"diff", "--stat", "--cached"}
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git diff --stat failed: %w", err)
+	}
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
 // Add stages a file to the git index.
 // For submodule paths (e.g., "submodule/path/to/file"), it runs git add inside the submodule.
 func Add(dir, path string) error {
@@ -0,0 +1,224 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var (
+	ErrUncommittedChanges = errors.New("uncommitted changes in tracked files")
+	ErrInvalidStrategy    = errors.New("invalid merge strategy")
+)
+
+// MergeStrategy selects how a branch is integrated into the target branch.
+type MergeStrategy string
+
+const (
+	MergeStrategyMerge  MergeStrategy = "merge"  // merge commit (--no-ff)
+	MergeStrategySquash MergeStrategy = "squash" // single commit with the combined changes
+	MergeStrategyRebase MergeStrategy = "rebase" // rebase branch onto target, then fast-forward
+)
+
+// HeadCommit returns the full hash of HEAD in dir.
+func HeadCommit(dir string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--verify", "HEAD")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse HEAD failed: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// IsAncestor reports whether commit is reachable from ref.
+func IsAncestor(dir, commit, ref string) bool {
+	cmd := exec.Command("git", "merge-base", "--is-ancestor", commit, ref)
+	cmd.Dir = dir
+	return cmd.Run() == nil
+}
+
+// IsSquashMerged reports whether the changes of branch since it forked from ref
+// were applied to ref as a single commit, as a squash merge does. The squashed
+// commit is recognized by its patch ID, so it is missed if conflicts were
+// resolved differently or ref changed the surrounding lines before the squash.
+func IsSquashMerged(dir, branch, ref string) bool {
+	cmd := exec.Command("git", "merge-base", ref, branch)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return false
+	}
+	forkPoint := strings.TrimSpace(string(output))
+
+	cmd = exec.Command("git", "diff", "--no-ext-diff", forkPoint, branch)
+	cmd.Dir = dir
+	diff, err := cmd.Output()
+	if err != nil || len(diff) == 0 {
+		return false
+	}
+	branchIDs, err := patchIDs(dir, diff)
+	if err != nil || len(branchIDs) == 0 {
+		return false
+	}
+
+	cmd = exec.Command("git", "log", "-p", "--no-merges", "--no-ext-diff", forkPoint+".."+ref)
+	cmd.Dir = dir
+	log, err := cmd.Output()
+	if err != nil {
+		return false
+	}
+	refIDs, err := patchIDs(dir, log)
+	if err != nil {
+		return false
+	}
+	for _, id := range refIDs {
+		if id == branchIDs[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// patchIDs returns the stable patch IDs of the patches in input, in order.
+func patchIDs(dir string, input []byte) ([]string, error) {
+	cmd := exec.Command("git", "patch-id", "--stable")
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(input)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git patch-id failed: %w", err)
+	}
+	var ids []string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if id, _, ok := strings.Cut(line, " "); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// HasTrackedChanges reports whether tracked files in dir have staged or unstaged changes.
+// Untracked files are ignored since they do not block checkout or merge unless overwritten.
+func HasTrackedChanges(dir string) (bool, error) {
+	cmd := exec.Command("git", "--no-optional-locks", "status", "--porcelain=v1", "--untracked-files=no")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return false, fmt.Errorf("git status failed: %w", err)
+	}
+	return strings.TrimSpace(string(output)) != "", nil
+}
+
+// HasChanges reports whether dir has any staged, unstaged or untracked changes.
+func HasChanges(dir string) (bool, error) {
+	cmd := exec.Command("git", "--no-optional-locks", "status", "--porcelain=v1")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return false, fmt.Errorf("git status failed: %w", err)
+	}
+	return strings.TrimSpace(string(output)) != "", nil
+}
+
+// MergeBranch integrates branch into the branch checked out at dir. The rebase strategy
+// only fast-forwards, so branch must first be rebased onto dir's branch (see RebaseBranch).
+//
+// On conflicts a *ConflictError is returned. A merge is left in progress so it can be
+// resolved and continued (see ContinueOperation). A squash has no merge state to
+// continue from, so it is rolled back before returning the conflicted paths.
+func MergeBranch(dir, branch string, strategy MergeStrategy, message string) error {
+	if err := validateRef(branch); err != nil {
+		return err
+	}
+	if dirty, err := HasTrackedChanges(dir); err != nil {
+		return err
+	} else if dirty {
+		return ErrUncommittedChanges
+	}
+
+	switch strategy {
+	case MergeStrategyMerge:
+		args := []string{"merge", "--no-ff", "--no-edit"}
+		if message != "" {
+			args = append(args, "-m", message)
+		}
+		args = append(args, branch)
+		if output, err := runGitOutput(dir, args...); err != nil {
+			if paths := unmergedPaths(dir); len(paths) > 0 {
+				return &ConflictError{Operation: string(OperationMerge), Paths: paths}
+			}
+			return fmt.Errorf("git merge failed: %w (output: %s)", err, output)
+		}
+		return nil
+
+	case MergeStrategySquash:
+		if output, err := runGitOutput(dir, "merge", "--squash", branch); err != nil {
+			if paths := unmergedPaths(dir); len(paths) > 0 {
+				runGitIn(dir, "reset", "--merge")
+				return &ConflictError{Operation: "squash", Paths: paths}
+			}
+			return fmt.Errorf("git merge --squash failed: %w (output: %s)", err, output)
+		}
+		args := []string{"commit"}
+		if message != "" {
+			args = append(args, "-m", message)
+		} else {
+			// Use the SQUASH_MSG git prepared, listing the squashed commits
+			args = append(args, "--no-edit")
+		}
+		if output, err := runGitOutput(dir, args...); err != nil {
+			runGitIn(dir, "reset", "--merge")
+			return fmt.Errorf("git commit failed: %w (output: %s)", err, output)
+		}
+		return nil
+
+	case MergeStrategyRebase:
+		if output, err := runGitOutput(dir, "merge", "--ff-only", branch); err != nil {
+			return fmt.Errorf("git merge --ff-only failed: %w (output: %s)", err, output)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidStrategy, strategy)
+}
+
+// RebaseBranch rebases the branch checked out at dir onto onto.
+// On conflicts the rebase is left in progress and a *ConflictError is returned.
+func RebaseBranch(dir, onto string) error {
+	if err := validateRef(onto); err != nil {
+		return err
+	}
+	if dirty, err := HasTrackedChanges(dir); err != nil {
+		return err
+	} else if dirty {
+		return ErrUncommittedChanges
+	}
+
+	if output, err := runGitOutput(dir, "rebase", onto); err != nil {
+		if paths := unmergedPaths(dir); len(paths) > 0 {
+			return &ConflictError{Operation: string(OperationRebase), Paths: paths}
+		}
+		return fmt.Errorf("git rebase failed: %w (output: %s)", err, output)
+	}
+	return nil
+}
+
+// runGitOutput runs git without an interactive editor and returns its combined output.
+func runGitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_EDITOR=true")
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
+// validateRef rejects empty refs and refs that git would parse as options.
+func validateRef(ref string) error {
+	if ref == "" || strings.HasPrefix(ref, "-") {
+		return fmt.Errorf("%w: %q", ErrInvalidRevision, ref)
+	}
+	return nil
+}
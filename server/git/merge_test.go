@@ -0,0 +1,154 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// setupMergeRepo creates a repo on main with a feature branch that changed file.txt.
+func setupMergeRepo(t *testing.T) string {
+	t.Helper()
+	dir := setupBranchRepo(t)
+	runGit(t, dir, "checkout", "-b", "feature")
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("feature\n"), 0644)
+	runGit(t, dir, "commit", "--no-gpg-sign", "-am", "feature one")
+	os.WriteFile(filepath.Join(dir, "other.txt"), []byte("other\n"), 0644)
+	runGit(t, dir, "add", "other.txt")
+	runGit(t, dir, "commit", "--no-gpg-sign", "-m", "feature two")
+	runGit(t, dir, "checkout", "main")
+	return dir
+}
+
+func commitCount(t *testing.T, dir string) int {
+	t.Helper()
+	cmd := exec.Command("git", "rev-list", "--count", "HEAD")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("rev-list failed: %v", err)
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(string(out)))
+	return n
+}
+
+func TestMergeBranch_Merge(t *testing.T) {
+	dir := setupMergeRepo(t)
+	runGit(t, dir, "config", "commit.gpgsign", "false")
+
+	if err := MergeBranch(dir, "feature", MergeStrategyMerge, "Merge feature"); err != nil {
+		t.Fatalf("MergeBranch() error: %v", err)
+	}
+	if !IsAncestor(dir, "feature", "HEAD") {
+		t.Error("expected feature to be merged")
+	}
+	// base + 2 feature commits + merge commit
+	if got := commitCount(t, dir); got != 4 {
+		t.Errorf("expected 4 commits, got %d", got)
+	}
+}
+
+func TestMergeBranch_Squash(t *testing.T) {
+	dir := setupMergeRepo(t)
+	runGit(t, dir, "config", "commit.gpgsign", "false")
+
+	if err := MergeBranch(dir, "feature", MergeStrategySquash, "Squash feature"); err != nil {
+		t.Fatalf("MergeBranch() error: %v", err)
+	}
+	if got := commitCount(t, dir); got != 2 {
+		t.Errorf("expected a single squashed commit on top of base, got %d commits", got)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "other.txt"))
+	if string(data) != "other\n" {
+		t.Errorf("expected squashed changes in working tree, got %q", data)
+	}
+}
+
+func TestMergeBranch_Conflicts(t *testing.T) {
+	dir := setupMergeRepo(t)
+	runGit(t, dir, "config", "commit.gpgsign", "false")
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("main\n"), 0644)
+	runGit(t, dir, "commit", "-am", "main change")
+
+	err := MergeBranch(dir, "feature", MergeStrategySquash, "")
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.Operation != "squash" || len(conflict.Paths) != 1 {
+		t.Fatalf("expected squash conflict on file.txt, got %v", err)
+	}
+	if dirty, _ := HasTrackedChanges(dir); dirty {
+		t.Error("expected squash conflict to be rolled back")
+	}
+
+	err = MergeBranch(dir, "feature", MergeStrategyMerge, "")
+	if !errors.As(err, &conflict) || conflict.Operation != "merge" {
+		t.Fatalf("expected merge conflict, got %v", err)
+	}
+	if op := OperationInProgress(dir); op != OperationMerge {
+		t.Errorf("expected merge to be left in progress, got %q", op)
+	}
+}
+
+func TestRebaseBranch_ThenFastForward(t *testing.T) {
+	dir := setupMergeRepo(t)
+	runGit(t, dir, "config", "commit.gpgsign", "false")
+	os.WriteFile(filepath.Join(dir, "main.txt"), []byte("main\n"), 0644)
+	runGit(t, dir, "add", "main.txt")
+	runGit(t, dir, "commit", "-m", "main change")
+
+	runGit(t, dir, "checkout", "feature")
+	if err := RebaseBranch(dir, "main"); err != nil {
+		t.Fatalf("RebaseBranch() error: %v", err)
+	}
+	runGit(t, dir, "checkout", "main")
+
+	if err := MergeBranch(dir, "feature", MergeStrategyRebase, ""); err != nil {
+		t.Fatalf("MergeBranch() error: %v", err)
+	}
+	// Linear history: base + main change + 2 rebased feature commits
+	if got := commitCount(t, dir); got != 4 {
+		t.Errorf("expected 4 commits, got %d", got)
+	}
+}
+
+func TestMergeBranch_Refusals(t *testing.T) {
+	dir := setupMergeRepo(t)
+
+	if err := MergeBranch(dir, "--upload-pack=x", MergeStrategyMerge, ""); !errors.Is(err, ErrInvalidRevision) {
+		t.Errorf("expected ErrInvalidRevision, got %v", err)
+	}
+	if err := MergeBranch(dir, "feature", "octopus", ""); !errors.Is(err, ErrInvalidStrategy) {
+		t.Errorf("expected ErrInvalidStrategy, got %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("dirty\n"), 0644)
+	if err := MergeBranch(dir, "feature", MergeStrategyMerge, ""); !errors.Is(err, ErrUncommittedChanges) {
+		t.Errorf("expected ErrUncommittedChanges, got %v", err)
+	}
+}
+
+func TestIsSquashMerged(t *testing.T) {
+	dir := setupMergeRepo(t)
+	runGit(t, dir, "config", "commit.gpgsign", "false")
+
+	if IsSquashMerged(dir, "feature", "main") {
+		t.Error("unmerged branch reported as squash-merged")
+	}
+
+	// Unrelated work on main before the squash does not hide it
+	os.WriteFile(filepath.Join(dir, "unrelated.txt"), []byte("unrelated\n"), 0644)
+	runGit(t, dir, "add", "unrelated.txt")
+	runGit(t, dir, "commit", "-m", "unrelated")
+	if err := MergeBranch(dir, "feature", MergeStrategySquash, "Squash feature"); err != nil {
+		t.Fatalf("MergeBranch() error: %v", err)
+	}
+	if IsAncestor(dir, "feature", "main") {
+		t.Fatal("squashed branch should not be an ancestor")
+	}
+	if !IsSquashMerged(dir, "feature", "main") {
+		t.Error("expected feature to be detected as squash-merged")
+	}
+}
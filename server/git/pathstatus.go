@@ -0,0 +1,60 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Path status codes returned by PathStatuses, in addition to the porcelain letters
+// (M, A, D, R, C, T).
+const (
+	PathUntracked  = "?"
+	PathIgnored    = "!"
+	PathConflicted = "U"
+)
+
+// PathStatuses returns the status of changed, untracked and ignored paths under
+// pathspec (relative to dir; empty = everything), keyed by repository-relative path.
+// Each path gets a single code: unstaged changes take precedence over staged ones.
+// Ignored directories are reported once, with a trailing "/", not file by file.
+func PathStatuses(dir, pathspec string) (map[string]string, error) {
+	args := []string{"--no-optional-locks", "status", "--porcelain=v1", "-z", "-uall", "--ignored=matching"}
+	if pathspec != "" {
+		args = append(args, "--", pathspec)
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git status failed: %w", err)
+	}
+
+	statuses := make(map[string]string)
+	entries := strings.Split(string(output), "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		x, y, path := entry[0], entry[1], entry[3:]
+		// Renames and copies are followed by the original path
+		if x == 'R' || x == 'C' {
+			i++
+		}
+
+		switch {
+		case x == '!':
+			statuses[path] = PathIgnored
+		case x == '?':
+			statuses[path] = PathUntracked
+		case isUnmerged(x, y):
+			statuses[path] = PathConflicted
+		case y != ' ':
+			statuses[path] = string(y)
+		default:
+			statuses[path] = string(x)
+		}
+	}
+	return statuses, nil
+}
@@ -0,0 +1,44 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPathStatuses(t *testing.T) {
+	dir := setupBranchRepo(t)
+	os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("build/\n*.log\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("changed\n"), 0644)
+	os.MkdirAll(filepath.Join(dir, "build", "out"), 0755)
+	os.WriteFile(filepath.Join(dir, "build", "out", "a.js"), []byte(""), 0644)
+	os.WriteFile(filepath.Join(dir, "debug.log"), []byte(""), 0644)
+	os.WriteFile(filepath.Join(dir, "staged.txt"), []byte(""), 0644)
+	runGit(t, dir, "add", "staged.txt")
+
+	statuses, err := PathStatuses(dir, "")
+	if err != nil {
+		t.Fatalf("PathStatuses() error: %v", err)
+	}
+
+	want := map[string]string{
+		"file.txt":   "M",
+		"staged.txt": "A",
+		".gitignore": PathUntracked,
+		"build/":     PathIgnored,
+		"debug.log":  PathIgnored,
+	}
+	for path, status := range want {
+		if statuses[path] != status {
+			t.Errorf("status of %s = %q, want %q", path, statuses[path], status)
+		}
+	}
+	if len(statuses) != len(want) {
+		t.Errorf("got %d statuses, want %d: %v", len(statuses), len(want), statuses)
+	}
+
+	statuses, _ = PathStatuses(dir, "build")
+	if len(statuses) != 1 || statuses["build/"] != PathIgnored {
+		t.Errorf("pathspec not honored: %v", statuses)
+	}
+}
@@ -0,0 +1,225 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrStashNotFound  = errors.New("stash not found")
+	ErrNothingToStash = errors.New("no local changes to save")
+)
+
+// StashEntry is a single entry of the stash list.
+type StashEntry struct {
+	Index     int       `json:"index"`
+	Ref       string    `json:"ref"`     // e.g. "stash@{0}"
+	Message   string    `json:"message"` // reflog subject, e.g. "On main: wip"
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// StashDetail holds the files and patch of a stash entry, including untracked files.
+type StashDetail struct {
+	StashEntry
+	Files []FileStatus `json:"files"`
+	Diff  string       `json:"diff"`
+}
+
+// StashPushOptions configures StashPush.
+type StashPushOptions struct {
+	Message          string
+	IncludeUntracked bool
+	Paths            []string // empty = everything
+}
+
+// StashPush saves local changes to a new stash entry.
+// Returns ErrNothingToStash if there was nothing to stash.
+func StashPush(dir string, opts StashPushOptions) error {
+	for _, path := range opts.Paths {
+		if err := validatePath(path); err != nil {
+			return err
+		}
+	}
+
+	before := stashCount(dir)
+
+	args := []string{"stash", "push"}
+	if opts.Message != "" {
+		args = append(args, "-m", opts.Message)
+	}
+	if opts.IncludeUntracked {
+		args = append(args, "--include-untracked")
+	}
+	if len(opts.Paths) > 0 {
+		args = append(args, "--")
+		args = append(args, opts.Paths...)
+	}
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git stash push failed: %w (output: %s)", err, string(output))
+	}
+
+	// "No local changes to save" exits 0 without creating an entry
+	if stashCount(dir) == before {
+		return ErrNothingToStash
+	}
+	return nil
+}
+
+// StashList returns all stash entries, newest first.
+func StashList(dir string) ([]StashEntry, error) {
+	cmd := exec.Command("git", "stash", "list", "--format=%gd%x00%gs%x00%ct")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git stash list failed: %w", err)
+	}
+
+	entries := []StashEntry{}
+	for _, line := range strings.Split(string(output), "\n") {
+		parts := strings.Split(line, "\x00")
+		if len(parts) != 3 {
+			continue
+		}
+		index, ok := parseStashRef(parts[0])
+		if !ok {
+			continue
+		}
+		var createdAt time.Time
+		if sec, err := strconv.ParseInt(parts[2], 10, 64); err == nil {
+			createdAt = time.Unix(sec, 0)
+		}
+		entries = append(entries, StashEntry{
+			Index:     index,
+			Ref:       parts[0],
+			Message:   parts[1],
+			CreatedAt: createdAt,
+		})
+	}
+	return entries, nil
+}
+
+// StashShow returns the changed files and patch of a stash entry.
+func StashShow(dir string, index int) (*StashDetail, error) {
+	entry, err := findStash(dir, index)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("git", "stash", "show", "--include-untracked", "--name-status", entry.Ref)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("git stash show failed: %w (output: %s)", err, string(output))
+	}
+
+	files := []FileStatus{}
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 || fields[0] == "" {
+			continue
+		}
+		// Renames are "R100\told\tnew"; report the new path
+		files = append(files, FileStatus{Path: fields[len(fields)-1], Status: fields[0][:1]})
+	}
+
+	cmd = exec.Command("git", "stash", "show", "--include-untracked", "-p", entry.Ref)
+	cmd.Dir = dir
+	diff, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("git stash show failed: %w (output: %s)", err, string(diff))
+	}
+
+	return &StashDetail{StashEntry: *entry, Files: files, Diff: string(diff)}, nil
+}
+
+// StashApply applies a stash entry to the working tree. If pop is true the entry is
+// dropped after a clean apply. On conflicts a *ConflictError is returned and the entry
+// is kept (git never drops a stash that failed to apply cleanly).
+func StashApply(dir string, index int, pop bool) error {
+	entry, err := findStash(dir, index)
+	if err != nil {
+		return err
+	}
+
+	op := "apply"
+	if pop {
+		op = "pop"
+	}
+
+	cmd := exec.Command("git", "stash", op, "--index", entry.Ref)
+	cmd.Dir = dir
+	if err := cmd.Run(); err == nil {
+		return nil
+	}
+
+	if paths := unmergedPaths(dir); len(paths) > 0 {
+		return &ConflictError{Operation: "stash " + op, Paths: paths}
+	}
+
+	// --index fails when the staged state cannot be restored; retry without it
+	cmd = exec.Command("git", "stash", op, entry.Ref)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if paths := unmergedPaths(dir); len(paths) > 0 {
+			return &ConflictError{Operation: "stash " + op, Paths: paths}
+		}
+		return fmt.Errorf("git stash %s failed: %w (output: %s)", op, err, string(output))
+	}
+	return nil
+}
+
+// StashDrop removes a stash entry.
+func StashDrop(dir string, index int) error {
+	entry, err := findStash(dir, index)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command("git", "stash", "drop", entry.Ref)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git stash drop failed: %w (output: %s)", err, string(output))
+	}
+	return nil
+}
+
+func findStash(dir string, index int) (*StashEntry, error) {
+	entries, err := StashList(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.Index == index {
+			return &e, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: stash@{%d}", ErrStashNotFound, index)
+}
+
+func stashCount(dir string) int {
+	entries, err := StashList(dir)
+	if err != nil {
+		return 0
+	}
+	return len(entries)
+}
+
+// parseStashRef parses "stash@{N}" into N.
+func parseStashRef(ref string) (int, bool) {
+	if !strings.HasPrefix(ref, "stash@{") || !strings.HasSuffix(ref, "}") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(ref[len("stash@{") : len(ref)-1])
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
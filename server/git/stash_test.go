@@ -0,0 +1,134 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setupStashRepo(t *testing.T) string {
+	t.Helper()
+	dir, cleanup := setupTestRepo(t)
+	t.Cleanup(cleanup)
+
+	if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte("original\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	runGit(t, dir, "add", "file.txt")
+	runGit(t, dir, "commit", "--no-gpg-sign", "-m", "initial")
+	return dir
+}
+
+func TestStash_PushListShowPop(t *testing.T) {
+	dir := setupStashRepo(t)
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("changed\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "untracked.txt"), []byte("new\n"), 0644)
+
+	if err := StashPush(dir, StashPushOptions{Message: "wip", IncludeUntracked: true}); err != nil {
+		t.Fatalf("StashPush() error: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "untracked.txt")); !os.IsNotExist(err) {
+		t.Error("expected untracked file to be stashed")
+	}
+
+	entries, err := StashList(dir)
+	if err != nil {
+		t.Fatalf("StashList() error: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 stash entry, got %d", len(entries))
+	}
+	if entries[0].Index != 0 || entries[0].Ref != "stash@{0}" {
+		t.Errorf("unexpected entry: %+v", entries[0])
+	}
+	if !strings.Contains(entries[0].Message, "wip") {
+		t.Errorf("expected message to contain 'wip', got %q", entries[0].Message)
+	}
+
+	detail, err := StashShow(dir, 0)
+	if err != nil {
+		t.Fatalf("StashShow() error: %v", err)
+	}
+	if len(detail.Files) != 2 {
+		t.Errorf("expected 2 files in stash, got %+v", detail.Files)
+	}
+	if !strings.Contains(detail.Diff, "+changed") {
+		t.Errorf("expected diff to contain change, got %q", detail.Diff)
+	}
+
+	if err := StashApply(dir, 0, true); err != nil {
+		t.Fatalf("StashApply(pop) error: %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "file.txt")); string(content) != "changed\n" {
+		t.Errorf("expected stashed content restored, got %q", content)
+	}
+	if entries, _ := StashList(dir); len(entries) != 0 {
+		t.Errorf("expected stash to be dropped after pop, got %d entries", len(entries))
+	}
+}
+
+func TestStashPush_PathSubset(t *testing.T) {
+	dir := setupStashRepo(t)
+	os.WriteFile(filepath.Join(dir, "other.txt"), []byte("other\n"), 0644)
+	runGit(t, dir, "add", "other.txt")
+	runGit(t, dir, "commit", "--no-gpg-sign", "-m", "other")
+
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("changed\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "other.txt"), []byte("changed other\n"), 0644)
+
+	if err := StashPush(dir, StashPushOptions{Paths: []string{"file.txt"}}); err != nil {
+		t.Fatalf("StashPush() error: %v", err)
+	}
+
+	if content, _ := os.ReadFile(filepath.Join(dir, "other.txt")); string(content) != "changed other\n" {
+		t.Errorf("expected other.txt to keep its change, got %q", content)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "file.txt")); string(content) != "original\n" {
+		t.Errorf("expected file.txt to be stashed, got %q", content)
+	}
+}
+
+func TestStashPush_NothingToStash(t *testing.T) {
+	dir := setupStashRepo(t)
+
+	err := StashPush(dir, StashPushOptions{})
+	if !errors.Is(err, ErrNothingToStash) {
+		t.Errorf("expected ErrNothingToStash, got %v", err)
+	}
+}
+
+func TestStashApply_Conflict(t *testing.T) {
+	dir := setupStashRepo(t)
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("stashed\n"), 0644)
+	if err := StashPush(dir, StashPushOptions{}); err != nil {
+		t.Fatalf("StashPush() error: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("committed\n"), 0644)
+	runGit(t, dir, "commit", "--no-gpg-sign", "-am", "conflicting")
+
+	err := StashApply(dir, 0, true)
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected ConflictError, got %v", err)
+	}
+	if len(conflict.Paths) != 1 || conflict.Paths[0] != "file.txt" {
+		t.Errorf("expected conflict in file.txt, got %v", conflict.Paths)
+	}
+
+	// A conflicted pop keeps the stash entry
+	if entries, _ := StashList(dir); len(entries) != 1 {
+		t.Errorf("expected stash to be kept after conflicted pop, got %d entries", len(entries))
+	}
+}
+
+func TestStashDrop_NotFound(t *testing.T) {
+	dir := setupStashRepo(t)
+
+	if err := StashDrop(dir, 3); !errors.Is(err, ErrStashNotFound) {
+		t.Errorf("expected ErrStashNotFound, got %v", err)
+	}
+}
@@ -10,7 +10,6 @@ require (
 require (
 	github.com/fsnotify/fsnotify v1.9.0
 	github.com/sourcegraph/jsonrpc2 v0.2.1
+	golang.org/x/sys v0.29.0
 	golang.org/x/term v0.13.0
 )
-
-require golang.org/x/sys v0.29.0 // indirect
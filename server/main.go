@@ -22,10 +22,14 @@ import (
 	"github.com/pockode/server/agent"
 	"github.com/pockode/server/agentfactory"
 	"github.com/pockode/server/command"
+	"github.com/pockode/server/fileapi"
 	"github.com/pockode/server/git"
 	"github.com/pockode/server/logger"
 	"github.com/pockode/server/middleware"
+	"github.com/pockode/server/preview"
+	"github.com/pockode/server/schedule"
 	"github.com/pockode/server/settings"
+	"github.com/pockode/server/snippet"
 	"github.com/pockode/server/startup"
 	"github.com/pockode/server/worktree"
 	"github.com/pockode/server/ws"
@@ -36,7 +40,7 @@ var version = "dev"
 //go:embed static/*
 var staticFS embed.FS
 
-func newHandler(token string, devMode bool, wsHandler *ws.RPCHandler) http.Handler {
+func newHandler(token string, devMode bool, wsHandler *ws.RPCHandler, fileHandler *fileapi.Handler, previewHandler *preview.Handler) http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
@@ -50,6 +54,10 @@ func newHandler(token string, devMode bool, wsHandler *ws.RPCHandler) http.Handl
 	})
 
 	mux.Handle("GET /ws", wsHandler)
+	fileHandler.Register(mux)
+	if previewHandler != nil {
+		previewHandler.Register(mux)
+	}
 
 	authedMux := middleware.Auth(token)(mux)
 
@@ -71,7 +79,7 @@ func newSPAHandler(apiHandler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 
-		if strings.HasPrefix(path, "/api") || path == "/ws" || path == "/health" {
+		if strings.HasPrefix(path, "/api") || strings.HasPrefix(path, "/preview/") || path == "/ws" || path == "/health" {
 			apiHandler.ServeHTTP(w, r)
 			return
 		}
@@ -240,6 +248,16 @@ func main() {
 		slog.Error("failed to initialize command store", "error", err)
 		os.Exit(1)
 	}
+	if home, err := os.UserHomeDir(); err == nil {
+		commandStore.SetUserDir(filepath.Join(home, ".claude", "commands"))
+	}
+
+	// Initialize snippet store (global snippets; worktree snippets live with each worktree)
+	snippetStore, err := snippet.NewStore(dataDir, snippet.ScopeGlobal)
+	if err != nil {
+		slog.Error("failed to initialize snippet store", "error", err)
+		os.Exit(1)
+	}
 
 	// Initialize process manager with idle timeout
 	idleTimeout := 10 * time.Minute
@@ -280,8 +298,49 @@ func main() {
 		slog.Warn("failed to start worktree manager", "error", err)
 	}
 
-	wsHandler := ws.NewRPCHandler(token, version, devMode, string(agentType), commandStore, worktreeManager, settingsStore)
-	handler := newHandler(token, devMode, wsHandler)
+	// Initialize scheduler for recurring agent prompts
+	scheduler, err := schedule.NewScheduler(dataDir, worktreeManager, func(name string) bool {
+		_, err := registry.Resolve(name)
+		return err == nil
+	})
+	if err != nil {
+		slog.Error("failed to initialize scheduler", "error", err)
+		os.Exit(1)
+	}
+	scheduler.Start()
+
+	// Ports that can always be previewed, in addition to those opened by tasks and terminals
+	previewPorts, err := preview.ParsePorts(os.Getenv("PREVIEW_PORTS"))
+	if err != nil {
+		slog.Error("invalid PREVIEW_PORTS", "error", err)
+		os.Exit(1)
+	}
+
+	wsHandler := ws.NewRPCHandler(token, version, devMode, string(agentType), commandStore, snippetStore, worktreeManager, settingsStore, scheduler)
+
+	// Previewed apps run worktree code, so they get their own origin where
+	// possible; on the UI's origin they are sandboxed, which breaks many apps
+	var previewSrv *http.Server
+	var sharedPreviewHandler *preview.Handler
+	if env := os.Getenv("PREVIEW_SERVER_PORT"); env != "" {
+		previewPort, err := strconv.Atoi(env)
+		if err != nil || previewPort < 1 || previewPort > 65535 || previewPort == port {
+			slog.Error("invalid PREVIEW_SERVER_PORT", "value", env)
+			os.Exit(1)
+		}
+		previewMux := http.NewServeMux()
+		preview.NewHandler(token, worktreeManager, previewPorts, false).Register(previewMux)
+		previewSrv = &http.Server{
+			Addr:    ":" + strconv.Itoa(previewPort),
+			Handler: previewMux,
+		}
+		wsHandler.SetPreviewOrigin(os.Getenv("PREVIEW_ORIGIN"), previewPort)
+	} else {
+		slog.Warn("PREVIEW_SERVER_PORT is not set, previews are served sandboxed on the UI's origin")
+		sharedPreviewHandler = preview.NewHandler(token, worktreeManager, previewPorts, true)
+	}
+
+	handler := newHandler(token, devMode, wsHandler, fileapi.NewHandler(worktreeManager), sharedPreviewHandler)
 
 	portStr := strconv.Itoa(port)
 	srv := &http.Server{
@@ -302,7 +361,13 @@ func main() {
 		if err := srv.Shutdown(ctx); err != nil {
 			slog.Error("server shutdown error", "error", err)
 		}
+		if previewSrv != nil {
+			if err := previewSrv.Shutdown(ctx); err != nil {
+				slog.Error("preview server shutdown error", "error", err)
+			}
+		}
 		wsHandler.Stop()
+		scheduler.Stop()
 		worktreeManager.Shutdown()
 		close(shutdownDone)
 	}()
@@ -315,6 +380,15 @@ func main() {
 
 	startup.PrintFooter()
 
+	if previewSrv != nil {
+		go func() {
+			slog.Info("preview server starting", "addr", previewSrv.Addr)
+			if err := previewSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("preview server error", "error", err)
+			}
+		}()
+	}
+
 	slog.Info("server starting", "port", port, "workDir", workDir, "dataDir", dataDir, "devMode", devMode, "idleTimeout", idleTimeout)
 	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		slog.Error("server error", "error", err)
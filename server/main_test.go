@@ -9,7 +9,11 @@ import (
 
 	"github.com/pockode/server/agent/claude"
 	"github.com/pockode/server/command"
+	"github.com/pockode/server/fileapi"
+	"github.com/pockode/server/preview"
+	"github.com/pockode/server/schedule"
 	"github.com/pockode/server/settings"
+	"github.com/pockode/server/snippet"
 	"github.com/pockode/server/worktree"
 	"github.com/pockode/server/ws"
 )
@@ -40,13 +44,15 @@ func TestHealthEndpoint(t *testing.T) {
 	dataDir := t.TempDir()
 	workDir := t.TempDir()
 	cmdStore, _ := command.NewStore(dataDir)
+	snippetStore, _ := snippet.NewStore(dataDir, snippet.ScopeGlobal)
 	settingsStore, _ := settings.NewStore(dataDir)
 	registry := worktree.NewRegistry(workDir)
 	scopeManager := worktree.NewManager(registry, claude.New(), dataDir, 10*time.Minute)
 	defer scopeManager.Shutdown()
 
-	wsHandler := ws.NewRPCHandler("test-token", "test", true, "claude", cmdStore, scopeManager, settingsStore)
-	handler := newHandler("test-token", true, wsHandler)
+	scheduler, _ := schedule.NewScheduler(dataDir, scopeManager, nil)
+	wsHandler := ws.NewRPCHandler("test-token", "test", true, "claude", cmdStore, snippetStore, scopeManager, settingsStore, scheduler)
+	handler := newHandler("test-token", true, wsHandler, fileapi.NewHandler(scopeManager), preview.NewHandler("test-token", scopeManager, nil, true))
 	req := httptest.NewRequest(http.MethodGet, "/health", nil)
 	rec := httptest.NewRecorder()
 
@@ -65,13 +71,15 @@ func TestPingEndpoint(t *testing.T) {
 	dataDir := t.TempDir()
 	workDir := t.TempDir()
 	cmdStore, _ := command.NewStore(dataDir)
+	snippetStore, _ := snippet.NewStore(dataDir, snippet.ScopeGlobal)
 	settingsStore, _ := settings.NewStore(dataDir)
 	registry := worktree.NewRegistry(workDir)
 	scopeManager := worktree.NewManager(registry, claude.New(), dataDir, 10*time.Minute)
 	defer scopeManager.Shutdown()
 
-	wsHandler := ws.NewRPCHandler(token, "test", true, "claude", cmdStore, scopeManager, settingsStore)
-	handler := newHandler(token, true, wsHandler)
+	scheduler, _ := schedule.NewScheduler(dataDir, scopeManager, nil)
+	wsHandler := ws.NewRPCHandler(token, "test", true, "claude", cmdStore, snippetStore, scopeManager, settingsStore, scheduler)
+	handler := newHandler(token, true, wsHandler, fileapi.NewHandler(scopeManager), preview.NewHandler(token, scopeManager, nil, true))
 
 	t.Run("returns pong with valid token", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/api/ping", nil)
@@ -9,8 +9,8 @@ import (
 func Auth(token string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Health check and WebSocket bypass auth (WebSocket handles its own auth)
-			if r.URL.Path == "/health" || r.URL.Path == "/ws" {
+			// Health check, WebSocket and previews bypass auth (they handle their own auth)
+			if r.URL.Path == "/health" || r.URL.Path == "/ws" || strings.HasPrefix(r.URL.Path, "/preview/") {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -26,6 +26,12 @@ func TestAuth(t *testing.T) {
 			authHeader: "",
 			wantStatus: http.StatusOK,
 		},
+		{
+			name:       "preview bypasses auth",
+			path:       "/preview/~/3000/",
+			authHeader: "",
+			wantStatus: http.StatusOK,
+		},
 		{
 			name:       "missing auth header",
 			path:       "/api/ping",
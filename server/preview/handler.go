@@ -0,0 +1,332 @@
+// Package preview proxies HTTP and WebSocket traffic to dev servers listening on
+// the server host, so that apps started in a worktree can be opened remotely.
+//
+// Previewed apps run code from the worktree, which is untrusted: it must not be
+// able to read the token the Pockode UI keeps in its origin's storage. Previews
+// are therefore meant to be served from a separate origin (a dedicated port,
+// see main). If they have to share the UI's origin, responses are sandboxed
+// into an opaque origin instead, which breaks apps relying on module scripts,
+// fetch or storage. Only ports opened by the user's own tasks and terminals, or
+// allowlisted by the operator, are reachable.
+package preview
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pockode/server/worktree"
+)
+
+const (
+	// MainWorktree is the {worktree} path segment selecting the main worktree.
+	MainWorktree = "~"
+
+	// TokenParam is the query parameter a browser can authenticate with, since it
+	// cannot set an Authorization header when navigating. The token is exchanged
+	// for a cookie scoped to the preview path.
+	TokenParam = "pockode_token"
+	cookieName = "pockode_preview"
+
+	// sandboxPolicy isolates previews served from the UI's origin: without
+	// allow-same-origin the page gets an opaque origin and cannot reach the UI's
+	// storage, cookies or authenticated endpoints.
+	sandboxPolicy = "sandbox allow-scripts allow-forms"
+
+	// portCacheTTL bounds how long detected ports are reused: a page load makes
+	// many requests, and detection scans all processes.
+	portCacheTTL = 3 * time.Second
+	// portRescanInterval bounds rescans for a port not detected yet, so a dev
+	// server that has just started is still found quickly.
+	portRescanInterval = 500 * time.Millisecond
+)
+
+// Source tells what opened a detected port.
+type Source string
+
+const (
+	SourceTask     Source = "task"
+	SourceTerminal Source = "terminal"
+)
+
+// Port is a port detected in a worktree.
+type Port struct {
+	Port   int
+	Source Source
+	Path   string // proxy path, e.g. "/preview/~/5173/"
+}
+
+// DetectPorts returns the ports listened on by processes started from the
+// worktree's tasks and terminals.
+func DetectPorts(wt *worktree.Worktree) []Port {
+	var ports []Port
+	seen := make(map[int]bool)
+	add := func(source Source, pids []int) {
+		for _, port := range listeningPorts(pids) {
+			if !seen[port] {
+				seen[port] = true
+				ports = append(ports, Port{Port: port, Source: source, Path: Path(wt.Name, port)})
+			}
+		}
+	}
+	add(SourceTask, wt.Tasks.PIDs())
+	add(SourceTerminal, wt.Terminals.PIDs())
+
+	sort.Slice(ports, func(i, j int) bool { return ports[i].Port < ports[j].Port })
+	return ports
+}
+
+// Path returns the proxy path of a port in a worktree (empty name = main worktree).
+func Path(worktreeName string, port int) string {
+	segment := MainWorktree
+	if worktreeName != "" {
+		segment = url.PathEscape(worktreeName)
+	}
+	return fmt.Sprintf("/preview/%s/%d/", segment, port)
+}
+
+// ParsePorts parses a comma-separated list of ports and port ranges, e.g. "3000,8000-8010".
+func ParsePorts(spec string) ([]int, error) {
+	var ports []int
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		first, err := parsePort(lo)
+		if err != nil {
+			return nil, err
+		}
+		last := first
+		if isRange {
+			if last, err = parsePort(hi); err != nil {
+				return nil, err
+			}
+			if last < first {
+				return nil, fmt.Errorf("invalid port range: %s", part)
+			}
+		}
+		for port := first; port <= last; port++ {
+			ports = append(ports, port)
+		}
+	}
+	return ports, nil
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port: %s", s)
+	}
+	return port, nil
+}
+
+// Handler serves /preview/{worktree}/{port}/{path...}, forwarding to
+// localhost:{port}/{path...}. A port is served if it is allowlisted or listened
+// on by a task or terminal of the worktree. The handler authenticates requests
+// itself (see TokenParam), so it must bypass the Bearer auth middleware.
+type Handler struct {
+	token   string
+	manager *worktree.Manager
+	allowed map[int]bool
+	// sandbox is set when previews share the UI's origin
+	sandbox bool
+
+	detectMu sync.Mutex // also serializes detection
+	detected map[string]detectedPorts
+}
+
+// detectedPorts are the ports detected in a worktree at a point in time.
+type detectedPorts struct {
+	ports map[int]bool
+	at    time.Time
+}
+
+// NewHandler creates a preview handler. sharedOrigin tells that it is mounted on
+// the same origin as the Pockode UI, in which case every proxied response is
+// sandboxed.
+func NewHandler(token string, manager *worktree.Manager, allowedPorts []int, sharedOrigin bool) *Handler {
+	allowed := make(map[int]bool, len(allowedPorts))
+	for _, port := range allowedPorts {
+		allowed[port] = true
+	}
+	return &Handler{
+		token:    token,
+		manager:  manager,
+		allowed:  allowed,
+		sandbox:  sharedOrigin,
+		detected: make(map[string]detectedPorts),
+	}
+}
+
+// Register adds the preview routes to mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/preview/{worktree}/{port}", h.handleRoot)
+	mux.HandleFunc("/preview/{worktree}/{port}/{path...}", h.handleProxy)
+}
+
+// handleRoot redirects to the trailing-slash URL so relative links resolve under the prefix.
+func (h *Handler) handleRoot(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.EscapedPath() + "/"
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusMovedPermanently)
+}
+
+func (h *Handler) handleProxy(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("worktree")
+	if name == MainWorktree {
+		name = ""
+	}
+	port, err := parsePort(r.PathValue("port"))
+	if err != nil {
+		http.Error(w, "invalid port", http.StatusBadRequest)
+		return
+	}
+	prefix := Path(name, port)
+
+	if !h.authenticate(w, r, prefix) {
+		return
+	}
+
+	if _, err := h.manager.Registry().Resolve(name); err != nil {
+		http.Error(w, "worktree not found", http.StatusNotFound)
+		return
+	}
+	if !h.isAllowed(name, port) {
+		http.Error(w, fmt.Sprintf("port %d is not allowed for preview", port), http.StatusForbidden)
+		return
+	}
+
+	target := &url.URL{Scheme: "http", Host: "127.0.0.1:" + strconv.Itoa(port)}
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.Out.URL.Path = "/" + r.PathValue("path")
+			pr.Out.URL.RawPath = ""
+			if rawPath, ok := strings.CutPrefix(r.URL.EscapedPath(), prefix); ok {
+				pr.Out.URL.RawPath = "/" + rawPath
+			}
+			pr.SetXForwarded()
+			pr.Out.Header.Set("X-Forwarded-Prefix", strings.TrimSuffix(prefix, "/"))
+			h.stripCredentials(pr.Out)
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			if h.sandbox {
+				// Added to the app's own policies, which still apply
+				resp.Header.Add("Content-Security-Policy", sandboxPolicy)
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			slog.Debug("preview proxy error", "port", port, "error", err)
+			http.Error(w, fmt.Sprintf("nothing is responding on port %d", port), http.StatusBadGateway)
+		},
+	}
+	// ReverseProxy also handles WebSocket upgrades
+	proxy.ServeHTTP(w, r)
+}
+
+func (h *Handler) isAllowed(name string, port int) bool {
+	if h.allowed[port] {
+		return true
+	}
+
+	h.detectMu.Lock()
+	defer h.detectMu.Unlock()
+	cached, ok := h.detected[name]
+	if age := time.Since(cached.at); ok && age < portCacheTTL && (cached.ports[port] || age < portRescanInterval) {
+		return cached.ports[port]
+	}
+
+	wt, ok := h.manager.Active(name)
+	if !ok {
+		delete(h.detected, name)
+		return false
+	}
+	ports := make(map[int]bool)
+	for _, p := range DetectPorts(wt) {
+		ports[p.Port] = true
+	}
+	h.detected[name] = detectedPorts{ports: ports, at: time.Now()}
+	return ports[port]
+}
+
+// authenticate accepts the Bearer token, the token query parameter (answered with
+// a cookie and a redirect dropping the parameter) or the preview cookie.
+func (h *Handler) authenticate(w http.ResponseWriter, r *http.Request, prefix string) bool {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && h.validToken(token) {
+		return true
+	}
+
+	if token := r.URL.Query().Get(TokenParam); token != "" {
+		if !h.validToken(token) {
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			return false
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     cookieName,
+			Value:    h.cookieValue(),
+			Path:     prefix,
+			HttpOnly: true,
+			Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+			SameSite: http.SameSiteLaxMode,
+		})
+		query := r.URL.Query()
+		query.Del(TokenParam)
+		target := r.URL.EscapedPath()
+		if len(query) > 0 {
+			target += "?" + query.Encode()
+		}
+		http.Redirect(w, r, target, http.StatusSeeOther)
+		return false
+	}
+
+	if cookie, err := r.Cookie(cookieName); err == nil &&
+		subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(h.cookieValue())) == 1 {
+		return true
+	}
+
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	return false
+}
+
+func (h *Handler) validToken(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
+
+// cookieValue derives the cookie from the token, so the token itself is never
+// stored in the browser's cookie jar.
+func (h *Handler) cookieValue() string {
+	mac := hmac.New(sha256.New, []byte(h.token))
+	mac.Write([]byte("pockode preview"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// stripCredentials removes Pockode's credentials from a request forwarded to a preview.
+func (h *Handler) stripCredentials(r *http.Request) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && h.validToken(token) {
+		r.Header.Del("Authorization")
+	}
+
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	for _, c := range cookies {
+		if c.Name != cookieName {
+			r.AddCookie(c)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package preview
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pockode/server/agent/claude"
+	"github.com/pockode/server/worktree"
+)
+
+const testToken = "test-token"
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []int
+		wantErr bool
+	}{
+		{spec: "", want: nil},
+		{spec: "3000", want: []int{3000}},
+		{spec: "3000, 8000-8002", want: []int{3000, 8000, 8001, 8002}},
+		{spec: "0", wantErr: true},
+		{spec: "70000", wantErr: true},
+		{spec: "abc", wantErr: true},
+		{spec: "8002-8000", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePorts(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePorts(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePorts(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	if got := Path("", 5173); got != "/preview/~/5173/" {
+		t.Errorf("got %q", got)
+	}
+	if got := Path("feature x", 3000); got != "/preview/feature%20x/3000/" {
+		t.Errorf("got %q", got)
+	}
+}
+
+// newTestProxy starts a dev server echoing what it receives, and a preview
+// handler allowing its port.
+func newTestProxy(t *testing.T, sharedOrigin bool) (proxy *httptest.Server, port int) {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Join([]string{
+			r.URL.RequestURI(),
+			r.Header.Get("Authorization"),
+			r.Header.Get("Cookie"),
+			r.Header.Get("X-Forwarded-Prefix"),
+		}, "|")))
+	}))
+	t.Cleanup(upstream.Close)
+	port = upstream.Listener.Addr().(*net.TCPAddr).Port
+
+	manager := worktree.NewManager(worktree.NewRegistry(t.TempDir()), claude.New(), t.TempDir(), 10*time.Minute)
+	t.Cleanup(manager.Shutdown)
+
+	mux := http.NewServeMux()
+	NewHandler(testToken, manager, []int{port}, sharedOrigin).Register(mux)
+	proxy = httptest.NewServer(mux)
+	t.Cleanup(proxy.Close)
+	return proxy, port
+}
+
+func noRedirectClient() *http.Client {
+	return &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+}
+
+func get(t *testing.T, client *http.Client, rawURL string, header http.Header) (*http.Response, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, rawURL, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", rawURL, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+func TestHandler_Proxy(t *testing.T) {
+	proxy, port := newTestProxy(t, false)
+	base := proxy.URL + Path("", port)
+	client := noRedirectClient()
+
+	t.Run("rejects unauthenticated requests", func(t *testing.T) {
+		resp, _ := get(t, client, base, nil)
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401", resp.StatusCode)
+		}
+		resp, _ = get(t, client, base+"?"+TokenParam+"=wrong", nil)
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("status = %d for wrong token, want 401", resp.StatusCode)
+		}
+	})
+
+	t.Run("forwards bearer requests without credentials", func(t *testing.T) {
+		header := http.Header{"Authorization": {"Bearer " + testToken}, "Cookie": {"app=1"}}
+		resp, body := get(t, client, base+"assets/a%2Fb.js?v=1", header)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, body %q", resp.StatusCode, body)
+		}
+		want := "/assets/a%2Fb.js?v=1||app=1|/preview/~/" + strconv.Itoa(port)
+		if body != want {
+			t.Errorf("upstream got %q, want %q", body, want)
+		}
+		if csp := resp.Header.Get("Content-Security-Policy"); csp != "" {
+			t.Errorf("Content-Security-Policy = %q on a dedicated origin", csp)
+		}
+	})
+
+	t.Run("exchanges the token for a cookie", func(t *testing.T) {
+		resp, _ := get(t, client, base+"page?"+TokenParam+"="+testToken+"&x=1", nil)
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("status = %d, want 303", resp.StatusCode)
+		}
+		if loc := resp.Header.Get("Location"); loc != Path("", port)+"page?x=1" {
+			t.Errorf("Location = %q", loc)
+		}
+		cookies := resp.Cookies()
+		if len(cookies) != 1 || cookies[0].Path != Path("", port) || !cookies[0].HttpOnly {
+			t.Fatalf("cookies = %v", cookies)
+		}
+		if strings.Contains(cookies[0].Value, testToken) {
+			t.Error("cookie must not contain the token")
+		}
+
+		header := http.Header{"Cookie": {cookies[0].Name + "=" + cookies[0].Value + "; app=1"}}
+		resp, body := get(t, client, base+"page?x=1", header)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d with cookie, want 200", resp.StatusCode)
+		}
+		if !strings.HasPrefix(body, "/page?x=1||app=1|") {
+			t.Errorf("upstream got %q", body)
+		}
+	})
+
+	t.Run("redirects to the trailing slash", func(t *testing.T) {
+		resp, _ := get(t, client, strings.TrimSuffix(base, "/"), nil)
+		if resp.StatusCode != http.StatusMovedPermanently || resp.Header.Get("Location") != Path("", port) {
+			t.Errorf("status = %d, Location = %q", resp.StatusCode, resp.Header.Get("Location"))
+		}
+	})
+
+	t.Run("rejects ports that are neither allowed nor detected", func(t *testing.T) {
+		auth := http.Header{"Authorization": {"Bearer " + testToken}}
+		resp, _ := get(t, client, proxy.URL+Path("", port+1), auth)
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("status = %d, want 403", resp.StatusCode)
+		}
+		resp, _ = get(t, client, proxy.URL+"/preview/"+url.PathEscape("missing")+"/"+strconv.Itoa(port)+"/", auth)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("status = %d for unknown worktree, want 404", resp.StatusCode)
+		}
+	})
+}
+
+func TestHandler_SharedOriginIsSandboxed(t *testing.T) {
+	proxy, port := newTestProxy(t, true)
+	auth := http.Header{"Authorization": {"Bearer " + testToken}}
+
+	resp, _ := get(t, noRedirectClient(), proxy.URL+Path("", port), auth)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	csp := resp.Header.Get("Content-Security-Policy")
+	if !strings.HasPrefix(csp, "sandbox ") || strings.Contains(csp, "allow-same-origin") {
+		t.Errorf("Content-Security-Policy = %q, want a sandbox without allow-same-origin", csp)
+	}
+}
+
+func TestHandler_DetectedPortsAreCached(t *testing.T) {
+	manager := worktree.NewManager(worktree.NewRegistry(t.TempDir()), claude.New(), t.TempDir(), 10*time.Minute)
+	t.Cleanup(manager.Shutdown)
+	h := NewHandler(testToken, manager, nil, false)
+
+	// No worktree is active, so only a cached detection can allow the port
+	h.detected[""] = detectedPorts{ports: map[int]bool{4000: true}, at: time.Now()}
+	if !h.isAllowed("", 4000) {
+		t.Error("recently detected port not allowed")
+	}
+
+	h.detected[""] = detectedPorts{ports: map[int]bool{4000: true}, at: time.Now().Add(-portCacheTTL)}
+	if h.isAllowed("", 4000) {
+		t.Error("expired detection still allows the port")
+	}
+}
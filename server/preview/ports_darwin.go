@@ -0,0 +1,77 @@
+package preview
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// listeningPorts returns the TCP ports listened on by the given processes or
+// their descendants, found through ps and lsof.
+func listeningPorts(roots []int) []int {
+	if len(roots) == 0 {
+		return nil
+	}
+	pids := descendants(roots)
+	list := make([]string, len(pids))
+	for i, pid := range pids {
+		list[i] = strconv.Itoa(pid)
+	}
+
+	// -a ANDs the selections: listening TCP sockets of these processes
+	output, err := exec.Command("lsof", "-nP", "-a", "-iTCP", "-sTCP:LISTEN", "-p", strings.Join(list, ","), "-Fn").Output()
+	if err != nil && len(output) == 0 {
+		// lsof exits with 1 when nothing matches
+		return nil
+	}
+	return parseLsofPorts(string(output))
+}
+
+// parseLsofPorts extracts the ports from lsof -Fn output, whose name lines look
+// like "n*:5173", "n127.0.0.1:3000" or "n[::1]:8080".
+func parseLsofPorts(output string) []int {
+	seen := make(map[int]bool)
+	var ports []int
+	for _, line := range strings.Split(output, "\n") {
+		name, ok := strings.CutPrefix(line, "n")
+		if !ok {
+			continue
+		}
+		i := strings.LastIndexByte(name, ':')
+		if i < 0 {
+			continue
+		}
+		port, err := strconv.Atoi(name[i+1:])
+		if err == nil && !seen[port] {
+			seen[port] = true
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+// descendants returns roots and all their descendant processes.
+func descendants(roots []int) []int {
+	output, err := exec.Command("ps", "-A", "-o", "pid=", "-o", "ppid=").Output()
+	if err != nil {
+		return roots
+	}
+	children := make(map[int][]int)
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		pid, err1 := strconv.Atoi(fields[0])
+		ppid, err2 := strconv.Atoi(fields[1])
+		if err1 == nil && err2 == nil {
+			children[ppid] = append(children[ppid], pid)
+		}
+	}
+
+	result := append([]int(nil), roots...)
+	for i := 0; i < len(result); i++ {
+		result = append(result, children[result[i]]...)
+	}
+	return result
+}
@@ -0,0 +1,13 @@
+package preview
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLsofPorts(t *testing.T) {
+	output := "p123\nf5\nn*:5173\nf6\nn[::1]:5173\np456\nf7\nn127.0.0.1:3000\n"
+	if got, want := parseLsofPorts(output), []int{5173, 3000}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseLsofPorts() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,114 @@
+package preview
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// listeningPorts returns the TCP ports listened on by the given processes or
+// their descendants, found through /proc.
+func listeningPorts(roots []int) []int {
+	if len(roots) == 0 {
+		return nil
+	}
+	inodes := listeningSockets()
+	if len(inodes) == 0 {
+		return nil
+	}
+
+	seen := make(map[int]bool)
+	var ports []int
+	for _, pid := range descendants(roots) {
+		fds, err := os.ReadDir(filepath.Join("/proc", strconv.Itoa(pid), "fd"))
+		if err != nil {
+			// Exited, or owned by another user
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join("/proc", strconv.Itoa(pid), "fd", fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			inode := strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")
+			if port, ok := inodes[inode]; ok && !seen[port] {
+				seen[port] = true
+				ports = append(ports, port)
+			}
+		}
+	}
+	return ports
+}
+
+// descendants returns roots and all their descendant processes.
+func descendants(roots []int) []int {
+	children := make(map[int][]int)
+	entries, _ := os.ReadDir("/proc")
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		if ppid, ok := parentPID(pid); ok {
+			children[ppid] = append(children[ppid], pid)
+		}
+	}
+
+	result := append([]int(nil), roots...)
+	for i := 0; i < len(result); i++ {
+		result = append(result, children[result[i]]...)
+	}
+	return result
+}
+
+func parentPID(pid int) (int, bool) {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, false
+	}
+	// The command name may contain spaces and parentheses; fields resume after the last ")"
+	i := strings.LastIndexByte(string(data), ')')
+	if i < 0 {
+		return 0, false
+	}
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 2 {
+		return 0, false
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	return ppid, err == nil
+}
+
+// listeningSockets maps the inodes of listening TCP sockets to their ports.
+func listeningSockets() map[string]int {
+	const stateListen = "0A"
+	inodes := make(map[string]int)
+	for _, path := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Scan() // header
+		for scanner.Scan() {
+			// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 10 || fields[3] != stateListen {
+				continue
+			}
+			_, portHex, ok := strings.Cut(fields[1], ":")
+			if !ok {
+				continue
+			}
+			port, err := strconv.ParseInt(portHex, 16, 32)
+			if err != nil {
+				continue
+			}
+			inodes[fields[9]] = int(port)
+		}
+		f.Close()
+	}
+	return inodes
+}
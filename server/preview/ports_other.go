@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package preview
+
+// listeningPorts is only implemented on Linux and macOS; elsewhere only
+// allowlisted ports can be previewed.
+func listeningPorts(roots []int) []int {
+	return nil
+}
@@ -0,0 +1,26 @@
+//go:build linux || darwin
+
+package preview
+
+import (
+	"net"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestListeningPorts(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	if ports := listeningPorts([]int{os.Getpid()}); !slices.Contains(ports, port) {
+		t.Errorf("ports = %v, want %d included", ports, port)
+	}
+	if ports := listeningPorts(nil); ports != nil {
+		t.Errorf("ports = %v for no processes, want none", ports)
+	}
+}
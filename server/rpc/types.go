@@ -4,13 +4,38 @@ package rpc
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/pockode/server/agent"
 	"github.com/pockode/server/command"
 	"github.com/pockode/server/contents"
 	"github.com/pockode/server/git"
+	"github.com/pockode/server/schedule"
 	"github.com/pockode/server/session"
 	"github.com/pockode/server/settings"
+	"github.com/pockode/server/snapshot"
+	"github.com/pockode/server/snippet"
+	"github.com/pockode/server/task"
+)
+
+// Application error codes (JSON-RPC reserves -32000 to -32099 for server errors)
+
+const (
+	// CodeConflict indicates an operation stopped on merge conflicts.
+	// The error data is a git.ConflictError listing the conflicted paths.
+	CodeConflict int64 = -32010
+
+	// CodeWorkWouldBeLost indicates a destructive operation was refused because it would
+	// discard work. The error data describes what would be lost; retry with force to proceed.
+	CodeWorkWouldBeLost int64 = -32011
+
+	// CodeFileModified indicates a file.write precondition failed because the file changed
+	// since the client read it. The error data is a contents.ModifiedError with its current state.
+	CodeFileModified int64 = -32012
+
+	// CodeFileTooLarge indicates file.get refused to read a whole file over the max size.
+	// The error data is a contents.TooLargeError; retry with a range, tail or larger max_size.
+	CodeFileTooLarge int64 = -32013
 )
 
 // Client → Server
@@ -31,6 +56,9 @@ type AuthResult struct {
 type MessageParams struct {
 	SessionID string `json:"session_id"`
 	Content   string `json:"content"`
+	// Snippet marks Content as a snippet template whose placeholders the server
+	// expands before sending. Empty Content sends the snippet as saved.
+	Snippet *SnippetUse `json:"snippet,omitempty"`
 }
 
 type InterruptParams struct {
@@ -55,6 +83,18 @@ type QuestionResponseParams struct {
 
 // Session management
 
+type SessionCreateParams struct {
+	Worktree   bool   `json:"worktree,omitempty"`    // create the session in a new dedicated worktree
+	BaseBranch string `json:"base_branch,omitempty"` // with worktree; empty = main worktree's branch
+}
+
+// SessionCreateResult is the created session. When a dedicated worktree was created,
+// Worktree is set and the client should switch to it to use the session.
+type SessionCreateResult struct {
+	session.SessionMeta
+	Worktree *WorktreeInfo `json:"worktree,omitempty"`
+}
+
 type SessionDeleteParams struct {
 	SessionID string `json:"session_id"`
 }
@@ -71,14 +111,107 @@ type SessionSetModeParams struct {
 
 // File namespace
 
+// FileGetParams reads a directory or file. For directories, depth and exclude_hidden
+// configure the listing. For files, at most one of a byte range (offset/length),
+// a line range (start_line/end_line) or tail may be set.
 type FileGetParams struct {
-	Path string `json:"path"`
+	Path          string `json:"path"`
+	Depth         int    `json:"depth,omitempty"` // levels of subdirectories to list, at most 10
+	ExcludeHidden bool   `json:"exclude_hidden,omitempty"`
+
+	Offset    int64 `json:"offset,omitempty"`
+	Length    int64 `json:"length,omitempty"`     // 0 = to the end
+	StartLine int   `json:"start_line,omitempty"` // 1-based
+	EndLine   int   `json:"end_line,omitempty"`   // inclusive, 0 = to the end
+	Tail      int   `json:"tail,omitempty"`       // last N lines
+	MaxSize   int64 `json:"max_size,omitempty"`   // default 1MB, at most 16MB
 }
 
 type FileGetResult struct {
-	Type    string                `json:"type"` // "directory" or "file"
-	Entries []contents.Entry      `json:"entries,omitempty"`
-	File    *contents.FileContent `json:"file,omitempty"`
+	Type      string                `json:"type"` // "directory" or "file"
+	Entries   []contents.Entry      `json:"entries,omitempty"`
+	Truncated bool                  `json:"truncated,omitempty"` // listing stopped at 10000 entries
+	File      *contents.FileContent `json:"file,omitempty"`
+}
+
+// FileWriteParams replaces an existing file. With expected_hash and/or expected_mtime,
+// the write fails with CodeFileModified if the file changed since it was read.
+type FileWriteParams struct {
+	Path          string            `json:"path"`
+	Content       string            `json:"content"`
+	Encoding      contents.Encoding `json:"encoding,omitempty"` // default text
+	ExpectedHash  string            `json:"expected_hash,omitempty"`
+	ExpectedMtime *time.Time        `json:"expected_mtime,omitempty"`
+}
+
+type FileCreateParams struct {
+	Path     string            `json:"path"`
+	Content  string            `json:"content"`
+	Encoding contents.Encoding `json:"encoding,omitempty"` // default text
+}
+
+type FileWriteResult struct {
+	File contents.WriteResult `json:"file"`
+}
+
+// FileSearchParams starts a content search. Results are streamed as
+// file.search.result notifications, followed by one file.search.done.
+type FileSearchParams struct {
+	Query         string   `json:"query"`
+	Regex         bool     `json:"regex,omitempty"`
+	CaseSensitive bool     `json:"case_sensitive,omitempty"`
+	Include       []string `json:"include,omitempty"` // globs, e.g. "src/**/*.ts"
+	Exclude       []string `json:"exclude,omitempty"`
+	MaxResults    int      `json:"max_results,omitempty"`   // default 1000
+	ContextLines  int      `json:"context_lines,omitempty"` // at most 10
+}
+
+type FileSearchResult struct {
+	ID string `json:"id"`
+}
+
+type FileSearchCancelParams struct {
+	ID string `json:"id"`
+}
+
+// FileSearchResultParams is a batch of matches for a running search.
+type FileSearchResultParams struct {
+	ID      string                 `json:"id"`
+	Matches []contents.SearchMatch `json:"matches"`
+}
+
+// FileSearchDoneParams ends a search. Error is set if it failed; Cancelled if it was
+// cancelled by file.search.cancel.
+type FileSearchDoneParams struct {
+	ID string `json:"id"`
+	contents.SearchSummary
+	Cancelled bool   `json:"cancelled,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// FileFindParams fuzzy-matches file paths. An empty query lists files.
+type FileFindParams struct {
+	Query string `json:"query"`
+	Limit int    `json:"limit,omitempty"` // default 50, at most 500
+}
+
+type FileFindResult struct {
+	Results []contents.FindResult `json:"results"`
+	Total   int                   `json:"total"` // number of matching files, before the limit
+}
+
+type FileMkdirParams struct {
+	Path string `json:"path"`
+}
+
+type FileRenameParams struct {
+	Path    string `json:"path"`
+	NewPath string `json:"new_path"`
+}
+
+type FileDeleteParams struct {
+	Path      string `json:"path"`
+	Recursive bool   `json:"recursive,omitempty"` // required for non-empty directories
 }
 
 // Git namespace
@@ -108,16 +241,174 @@ type GitPathsParams struct {
 	Paths []string `json:"paths"`
 }
 
+// GitDiscardParams is used for git.discard.
+// Staged=false restores the working tree from the index; Staged=true restores both from HEAD.
+type GitDiscardParams struct {
+	Paths  []string `json:"paths"`
+	Staged bool     `json:"staged"`
+}
+
+type GitDiscardResult struct {
+	SnapshotID string    `json:"snapshot_id"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type GitDiscardListResult struct {
+	Snapshots []snapshot.Snapshot `json:"snapshots"`
+}
+
+type GitDiscardRestoreParams struct {
+	SnapshotID string `json:"snapshot_id"`
+}
+
+type GitDiscardRestoreResult struct {
+	Paths []string `json:"paths"`
+}
+
+// Git stash
+
+type GitStashPushParams struct {
+	Message          string   `json:"message,omitempty"`
+	IncludeUntracked bool     `json:"include_untracked"`
+	Paths            []string `json:"paths,omitempty"` // empty = all changes
+}
+
+type GitStashListResult struct {
+	Stashes []git.StashEntry `json:"stashes"`
+}
+
+// GitStashParams identifies a stash entry for show/apply/pop/drop.
+type GitStashParams struct {
+	Index int `json:"index"`
+}
+
+type GitStashShowResult = git.StashDetail
+
+// Git conflicts
+
+type GitConflictGetParams struct {
+	Path string `json:"path"`
+}
+
+type GitConflictGetResult = git.ConflictContent
+
+type GitConflictResolveParams struct {
+	Path       string         `json:"path"`
+	Resolution git.Resolution `json:"resolution"`        // "ours", "theirs" or "custom"
+	Content    string         `json:"content,omitempty"` // required for "custom"
+}
+
+// Git blame
+
+type GitBlameParams struct {
+	Path      string `json:"path"`
+	Rev       string `json:"rev,omitempty"`        // empty = working tree
+	StartLine int    `json:"start_line,omitempty"` // 1-based, inclusive
+	EndLine   int    `json:"end_line,omitempty"`   // 1-based, inclusive
+}
+
+type GitBlameResult struct {
+	Path  string          `json:"path"`
+	Lines []git.BlameLine `json:"lines"`
+}
+
 // Command namespace
 
 type CommandListResult struct {
 	Commands []command.Command `json:"commands"`
 }
 
+// Snippet namespace
+
+// SnippetListResult lists the global snippets and those of the worktree, most used first.
+type SnippetListResult struct {
+	Snippets []snippet.Snippet `json:"snippets"`
+}
+
+type SnippetCreateParams struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Content     string        `json:"content"`
+	Scope       snippet.Scope `json:"scope,omitempty"` // default "global"
+}
+
+// SnippetUpdateParams changes the fields that are set.
+type SnippetUpdateParams struct {
+	ID          string  `json:"id"`
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Content     *string `json:"content,omitempty"`
+}
+
+type SnippetDeleteParams struct {
+	ID string `json:"id"`
+}
+
+// SnippetUse provides the values of a snippet's placeholders. Variables take
+// precedence over the builtin ones (branch, worktree, selected_file, diff_summary).
+type SnippetUse struct {
+	ID           string            `json:"id"`
+	Variables    map[string]string `json:"variables,omitempty"`
+	SelectedFile string            `json:"selected_file,omitempty"`
+}
+
+// SnippetExpandParams previews a snippet as it would be sent, without recording a use.
+type SnippetExpandParams struct {
+	SnippetUse
+	Content string `json:"content,omitempty"` // template to expand instead of the saved content
+}
+
+type SnippetExpandResult struct {
+	Content string   `json:"content"`
+	Missing []string `json:"missing"` // placeholders without a value, left as written
+}
+
+// Schedule namespace
+
+type ScheduleListResult struct {
+	Jobs []schedule.Job `json:"jobs"`
+}
+
+// ScheduleCreateParams defines a job; Spec is a five-field cron expression or a
+// descriptor such as "@daily", in the server's local time.
+type ScheduleCreateParams struct {
+	Name         string       `json:"name"`
+	Spec         string       `json:"spec"`
+	Worktree     string       `json:"worktree,omitempty"` // empty for the main worktree
+	Prompt       string       `json:"prompt"`
+	Mode         session.Mode `json:"mode,omitempty"` // default "default"
+	ReuseSession bool         `json:"reuse_session,omitempty"`
+}
+
+type SchedulePauseParams struct {
+	ID string `json:"id"`
+}
+
+type ScheduleResumeParams struct {
+	ID string `json:"id"`
+}
+
+type ScheduleDeleteParams struct {
+	ID string `json:"id"`
+}
+
+type ScheduleHistoryParams struct {
+	ID string `json:"id"`
+}
+
+// ScheduleHistoryResult lists the recent runs of a job, newest first.
+type ScheduleHistoryResult struct {
+	Runs []schedule.Run `json:"runs"`
+}
+
 // FS namespace
 
+// FSSubscribeParams watches a file or directory. A directory subscription reports
+// changes to its direct children; a recursive one reports changes anywhere below it,
+// except in .git and directories excluded by .gitignore.
 type FSSubscribeParams struct {
-	Path string `json:"path"`
+	Path      string `json:"path"`
+	Recursive bool   `json:"recursive,omitempty"`
 }
 
 type FSSubscribeResult struct {
@@ -128,6 +419,27 @@ type FSUnsubscribeParams struct {
 	ID string `json:"id"`
 }
 
+type FSOp string
+
+const (
+	FSOpCreate FSOp = "create"
+	FSOpWrite  FSOp = "write"
+	FSOpRemove FSOp = "remove"
+	FSOpRename FSOp = "rename" // the path was renamed away; the new path is reported as created
+)
+
+type FSChange struct {
+	Path string `json:"path"`
+	Op   FSOp   `json:"op"`
+}
+
+// FSChangedParams is sent as fs.changed with the changes of a burst, in order,
+// at most one per path.
+type FSChangedParams struct {
+	ID      string     `json:"id"`
+	Changes []FSChange `json:"changes"`
+}
+
 // Git namespace
 
 type GitSubscribeResult struct {
@@ -179,10 +491,55 @@ type ChatMessagesUnsubscribeParams struct {
 // Worktree namespace
 
 type WorktreeInfo struct {
+	Name   string               `json:"name"`
+	Path   string               `json:"path"`
+	Branch string               `json:"branch"`
+	IsMain bool                 `json:"is_main"`
+	Setup  *WorktreeSetupStatus `json:"setup,omitempty"` // nil = no setup run since server start
+
+	// Persisted metadata (empty for worktrees not created through Pockode)
+	Description string     `json:"description,omitempty"`
+	BaseBranch  string     `json:"base_branch,omitempty"`
+	CreatedBy   string     `json:"created_by,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	Session     string     `json:"session,omitempty"` // set for worktrees dedicated to a session
+
+	// Current state
+	SessionCount int        `json:"session_count"`
+	LastActivity *time.Time `json:"last_activity,omitempty"` // latest of creation, session update and commit
+	Ahead        int        `json:"ahead"`                   // commits not on the base branch (upstream for main)
+	Behind       int        `json:"behind"`                  // base branch commits not on this branch
+	Dirty        bool       `json:"dirty"`                   // uncommitted or untracked changes
+}
+
+// WorktreeChangedParams is sent to worktree subscribers when the list or any metadata changes.
+type WorktreeChangedParams struct {
+	ID        string         `json:"id"`
+	Worktrees []WorktreeInfo `json:"worktrees,omitempty"`
+}
+
+// WorktreeSetupStatus is the progress of the .pockode/worktree.json setup of a new worktree.
+type WorktreeSetupStatus struct {
+	State      string     `json:"state"`          // "running", "succeeded", "failed"
+	Step       string     `json:"step,omitempty"` // running or failed step
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// WorktreeSetupOutputParams is sent to worktree subscribers for each line of setup command output.
+type WorktreeSetupOutputParams struct {
+	ID     string `json:"id"`
 	Name   string `json:"name"`
-	Path   string `json:"path"`
-	Branch string `json:"branch"`
-	IsMain bool   `json:"is_main"`
+	Stream string `json:"stream"` // "stdout" or "stderr"
+	Data   string `json:"data"`
+}
+
+// WorktreeSetupStatusParams is sent to worktree subscribers when setup progresses.
+type WorktreeSetupStatusParams struct {
+	ID    string              `json:"id"`
+	Name  string              `json:"name"`
+	Setup WorktreeSetupStatus `json:"setup"`
 }
 
 type WorktreeListResult struct {
@@ -190,17 +547,69 @@ type WorktreeListResult struct {
 }
 
 type WorktreeCreateParams struct {
-	Name       string `json:"name"`
-	Branch     string `json:"branch"`
-	BaseBranch string `json:"base_branch,omitempty"`
+	Name        string `json:"name"`
+	Branch      string `json:"branch"`
+	BaseBranch  string `json:"base_branch,omitempty"`
+	Description string `json:"description,omitempty"`
+	CreatedBy   string `json:"created_by,omitempty"` // free-form; defaults to "user"
 }
 
 type WorktreeCreateResult struct {
 	Worktree WorktreeInfo `json:"worktree"`
 }
 
+type WorktreeUpdateParams struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
 type WorktreeDeleteParams struct {
-	Name string `json:"name"`
+	Name         string `json:"name"`
+	Force        bool   `json:"force,omitempty"`         // delete despite uncommitted/unpushed work or running processes
+	DeleteBranch bool   `json:"delete_branch,omitempty"` // also delete the worktree's branch
+}
+
+type WorktreeDeleteResult struct {
+	BranchDeleted bool   `json:"branch_deleted"`
+	BranchError   string `json:"branch_error,omitempty"`
+}
+
+type WorktreeMergeParams struct {
+	Name           string            `json:"name"`
+	Target         string            `json:"target,omitempty"`   // must be checked out in the main worktree, which is never switched; empty = that branch
+	Strategy       git.MergeStrategy `json:"strategy,omitempty"` // "merge" (default), "squash" or "rebase"
+	Message        string            `json:"message,omitempty"`
+	DeleteWorktree bool              `json:"delete_worktree,omitempty"`
+	DeleteBranch   bool              `json:"delete_branch,omitempty"` // with delete_worktree
+}
+
+type WorktreeMergeResult struct {
+	Target        string `json:"target"`
+	Commit        string `json:"commit"`
+	UpToDate      bool   `json:"up_to_date"`
+	Deleted       bool   `json:"deleted"`
+	BranchDeleted bool   `json:"branch_deleted"`
+	BranchError   string `json:"branch_error,omitempty"`
+}
+
+type WorktreeDiffParams struct {
+	Name    string  `json:"name"`
+	Against *string `json:"against,omitempty"` // other worktree ("" = main); omitted = base branch
+	Path    string  `json:"path,omitempty"`    // file to return the diff and contents of
+}
+
+type WorktreeDiffResult struct {
+	Base       string           `json:"base,omitempty"` // base branch when comparing against it
+	Files      []git.FileStatus `json:"files"`
+	Diff       string           `json:"diff,omitempty"`
+	OldContent string           `json:"old_content,omitempty"`
+	NewContent string           `json:"new_content,omitempty"`
+}
+
+// WorktreePruneParams is the params for worktree.prune, which also removes data
+// directories of worktrees that no longer exist (the background job only reports them).
+type WorktreePruneParams struct {
+	IdleDays int `json:"idle_days,omitempty"` // report merged worktrees idle this long; default 7
 }
 
 // WorktreeDeletedParams is sent to clients when a worktree they are connected to is deleted.
@@ -247,3 +656,146 @@ type SettingsSubscribeResult struct {
 type SettingsUpdateParams struct {
 	Settings settings.Settings `json:"settings"`
 }
+
+// Terminal namespace
+
+type TerminalInfo struct {
+	ID        string    `json:"id"`
+	Shell     string    `json:"shell"`
+	Cols      int       `json:"cols"`
+	Rows      int       `json:"rows"`
+	CreatedAt time.Time `json:"created_at"`
+	Exited    bool      `json:"exited"`
+	ExitCode  *int      `json:"exit_code,omitempty"` // set once exited; -1 if killed by a signal
+}
+
+// TerminalOpenParams starts a shell in the worktree directory. Zero sizes default to 80x24.
+type TerminalOpenParams struct {
+	Cols  int    `json:"cols,omitempty"`
+	Rows  int    `json:"rows,omitempty"`
+	Shell string `json:"shell,omitempty"` // default $SHELL
+}
+
+type TerminalAttachParams struct {
+	TerminalID string `json:"terminal_id"`
+}
+
+// TerminalAttachResult is the result of terminal.open and terminal.attach. Output
+// following Scrollback is sent as terminal.output notifications with ID, until
+// terminal.exited, or terminal.detached if the client fell too far behind and
+// must attach again. No ID is returned for a terminal that already exited.
+type TerminalAttachResult struct {
+	ID         string       `json:"id,omitempty"`
+	Terminal   TerminalInfo `json:"terminal"`
+	Scrollback string       `json:"scrollback"`
+}
+
+type TerminalDetachParams struct {
+	ID string `json:"id"`
+}
+
+type TerminalInputParams struct {
+	TerminalID string `json:"terminal_id"`
+	Data       string `json:"data"`
+}
+
+type TerminalResizeParams struct {
+	TerminalID string `json:"terminal_id"`
+	Cols       int    `json:"cols"`
+	Rows       int    `json:"rows"`
+}
+
+type TerminalCloseParams struct {
+	TerminalID string `json:"terminal_id"`
+}
+
+type TerminalListResult struct {
+	Terminals []TerminalInfo `json:"terminals"`
+}
+
+type TerminalOutputParams struct {
+	ID   string `json:"id"`
+	Data string `json:"data"`
+}
+
+type TerminalExitedParams struct {
+	ID       string `json:"id"`
+	ExitCode int    `json:"exit_code"`
+}
+
+type TerminalDetachedParams struct {
+	ID string `json:"id"`
+}
+
+// Task namespace
+
+type TaskListResult struct {
+	Tasks []task.Task `json:"tasks"`
+}
+
+type TaskRunParams struct {
+	Name string `json:"name"`
+}
+
+type TaskRunResult struct {
+	RunID string `json:"run_id"`
+}
+
+type TaskCancelParams struct {
+	RunID string `json:"run_id"`
+}
+
+type TaskGetParams struct {
+	RunID string `json:"run_id"`
+}
+
+// TaskGetResult is a run with its retained output (stdout and stderr interleaved).
+type TaskGetResult struct {
+	Run    task.Run `json:"run"`
+	Output string   `json:"output"`
+}
+
+// TaskHistoryResult lists the running and recent runs of the worktree, newest first.
+type TaskHistoryResult struct {
+	Runs []task.Run `json:"runs"`
+}
+
+type TaskSubscribeResult struct {
+	ID   string     `json:"id"`
+	Runs []task.Run `json:"runs"`
+}
+
+type TaskUnsubscribeParams struct {
+	ID string `json:"id"`
+}
+
+// TaskOutputParams is sent to task subscribers for each line of run output.
+type TaskOutputParams struct {
+	ID     string `json:"id"`
+	RunID  string `json:"run_id"`
+	Stream string `json:"stream"` // "stdout" or "stderr"
+	Data   string `json:"data"`
+}
+
+// TaskStatusParams is sent to task subscribers when a run starts or finishes.
+type TaskStatusParams struct {
+	ID  string   `json:"id"`
+	Run task.Run `json:"run"`
+}
+
+// Preview namespace
+
+// PreviewPort is a dev server port detected in the worktree, served under Path.
+type PreviewPort struct {
+	Port   int    `json:"port"`
+	Source string `json:"source"` // "task" or "terminal"
+	Path   string `json:"path"`
+}
+
+type PreviewListResult struct {
+	Ports []PreviewPort `json:"ports"`
+	// Previews are served from a separate origin: Origin if set, otherwise the
+	// UI's host on ServerPort. Both are empty when previews share the UI's origin.
+	Origin     string `json:"origin,omitempty"`
+	ServerPort int    `json:"server_port,omitempty"`
+}
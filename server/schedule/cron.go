@@ -0,0 +1,170 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSearchYears bounds the search for the next run, for specs such as
+// "0 0 30 2 *" that never match.
+const maxSearchYears = 5
+
+var ErrInvalidSpec = errors.New("invalid schedule spec")
+
+// Spec is a parsed cron expression: minute, hour, day of month, month and day
+// of week, evaluated in the server's local time. Fields accept "*", lists,
+// ranges and steps ("*/15", "1-5", "8,12"), and month and weekday names.
+// The descriptors @yearly, @monthly, @weekly, @daily (@midnight) and @hourly
+// are also accepted.
+type Spec struct {
+	minute, hour, dom, month, dow uint64 // bit sets
+	// Like cron, if both day fields are restricted a day matching either runs.
+	domStar, dowStar bool
+}
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+var dayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ParseSpec parses a five-field cron expression or a descriptor.
+func ParseSpec(expr string) (Spec, error) {
+	expr = strings.TrimSpace(expr)
+	if d, ok := descriptors[strings.ToLower(expr)]; ok {
+		expr = d
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return Spec{}, fmt.Errorf("%w: expected 5 fields, got %d", ErrInvalidSpec, len(fields))
+	}
+
+	var s Spec
+	var err error
+	if s.minute, err = parseField(fields[0], 0, 59, nil); err != nil {
+		return Spec{}, err
+	}
+	if s.hour, err = parseField(fields[1], 0, 23, nil); err != nil {
+		return Spec{}, err
+	}
+	if s.dom, err = parseField(fields[2], 1, 31, nil); err != nil {
+		return Spec{}, err
+	}
+	if s.month, err = parseField(fields[3], 1, 12, monthNames); err != nil {
+		return Spec{}, err
+	}
+	// 7 is also Sunday
+	if s.dow, err = parseField(fields[4], 0, 7, dayNames); err != nil {
+		return Spec{}, err
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = fields[2] == "*" || strings.HasPrefix(fields[2], "*/")
+	s.dowStar = fields[4] == "*" || strings.HasPrefix(fields[4], "*/")
+	return s, nil
+}
+
+// parseField parses a comma-separated list of values, ranges and steps into a bit set.
+// names, if given, are accepted for the values starting at min.
+func parseField(field string, min, max int, names []string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("%w: bad step in %q", ErrInvalidSpec, part)
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		if rangePart != "*" {
+			first, last, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = parseValue(first, min, max, names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = parseValue(last, min, max, names); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// "5/15" means from 5 to max every 15
+				hi = max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("%w: bad range %q", ErrInvalidSpec, rangePart)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(s string, min, max int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(s, name) {
+			return min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("%w: value %q out of range %d-%d", ErrInvalidSpec, s, min, max)
+	}
+	return v, nil
+}
+
+// Next returns the first time matching the spec strictly after t, or the zero
+// time if there is none within maxSearchYears.
+func (s Spec) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(maxSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			// Advance rather than rebuild the date, so DST transitions neither loop nor skip
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s Spec) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,84 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseSpec(t *testing.T) {
+	valid := []string{
+		"* * * * *",
+		"*/15 9-17 * * mon-fri",
+		"0 8,12 1 jan,jul *",
+		"5/10 * * * 7",
+		"@daily",
+		" @Hourly ",
+	}
+	for _, expr := range valid {
+		if _, err := ParseSpec(expr); err != nil {
+			t.Errorf("ParseSpec(%q) error: %v", expr, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+		"@reboot",
+	}
+	for _, expr := range invalid {
+		if _, err := ParseSpec(expr); !errors.Is(err, ErrInvalidSpec) {
+			t.Errorf("ParseSpec(%q) error = %v, want ErrInvalidSpec", expr, err)
+		}
+	}
+}
+
+func TestSpec_Next(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		spec string
+		from string
+		want string
+	}{
+		{"0 8 * * *", "2026-03-02 07:59", "2026-03-02 08:00"},
+		{"0 8 * * *", "2026-03-02 08:00", "2026-03-03 08:00"},
+		{"*/15 * * * *", "2026-03-02 10:16", "2026-03-02 10:30"},
+		{"30 9-17 * * *", "2026-03-02 17:30", "2026-03-03 09:30"},
+		{"0 9 * * mon-fri", "2026-03-06 10:00", "2026-03-09 09:00"}, // Friday -> Monday
+		{"0 0 * * 7", "2026-03-02 00:00", "2026-03-08 00:00"},       // 7 is Sunday
+		{"0 0 13 * fri", "2026-03-02 00:00", "2026-03-06 00:00"},    // either day field matches
+		{"@weekly", "2026-03-02 12:00", "2026-03-08 00:00"},
+		{"@yearly", "2026-03-02 12:00", "2027-01-01 00:00"},
+		{"0 0 29 feb *", "2026-03-02 00:00", "2028-02-29 00:00"},
+	}
+	for _, tt := range tests {
+		spec, err := ParseSpec(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseSpec(%q) error: %v", tt.spec, err)
+		}
+		if got := spec.Next(at(tt.from)); !got.Equal(at(tt.want)) {
+			t.Errorf("%q.Next(%s) = %s, want %s", tt.spec, tt.from, got.Format("2006-01-02 15:04"), tt.want)
+		}
+	}
+
+	spec, _ := ParseSpec("0 0 30 2 *")
+	if got := spec.Next(at("2026-03-02 00:00")); !got.IsZero() {
+		t.Errorf("Next() of a spec that never matches = %s, want zero time", got)
+	}
+}
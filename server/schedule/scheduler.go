@@ -0,0 +1,413 @@
+// Package schedule runs agent prompts on cron-style schedules.
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pockode/server/session"
+)
+
+const (
+	// MaxHistory is the number of runs kept per job.
+	MaxHistory = 20
+
+	jobsFile = "schedules.json"
+	// sendTimeout bounds starting the agent and sending the prompt.
+	sendTimeout = time.Minute
+)
+
+var (
+	ErrNotFound         = errors.New("job not found")
+	ErrNameRequired     = errors.New("job name is required")
+	ErrPromptRequired   = errors.New("job prompt is required")
+	ErrInvalidMode      = errors.New("invalid mode")
+	ErrWorktreeNotFound = errors.New("worktree not found")
+)
+
+type RunStatus string
+
+const (
+	RunSent   RunStatus = "sent"   // the prompt was sent to the agent
+	RunFailed RunStatus = "failed" // the prompt could not be sent
+)
+
+// Run is a single execution of a job.
+type Run struct {
+	StartedAt time.Time `json:"started_at"`
+	Status    RunStatus `json:"status"`
+	SessionID string    `json:"session_id,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Job sends a prompt to an agent session of a worktree on a schedule.
+type Job struct {
+	ID       string       `json:"id"`
+	Name     string       `json:"name"`
+	Spec     string       `json:"spec"`     // cron expression, see Spec
+	Worktree string       `json:"worktree"` // empty for the main worktree
+	Prompt   string       `json:"prompt"`
+	Mode     session.Mode `json:"mode"`
+	// ReuseSession sends every run to the session of the previous run, if it
+	// still exists, instead of starting a new session.
+	ReuseSession bool       `json:"reuse_session"`
+	SessionID    string     `json:"session_id,omitempty"` // session of the last successful run
+	Paused       bool       `json:"paused"`
+	NextRunAt    *time.Time `json:"next_run_at,omitempty"`
+	LastRun      *Run       `json:"last_run,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// NewJob is the definition of a job to create.
+type NewJob struct {
+	Name         string
+	Spec         string
+	Worktree     string
+	Prompt       string
+	Mode         session.Mode // default session.ModeDefault
+	ReuseSession bool
+}
+
+// PromptSender sends a prompt to a worktree session; implemented by worktree.Manager.
+type PromptSender interface {
+	SendPrompt(ctx context.Context, worktree, sessionID, title, prompt string, mode session.Mode) (string, error)
+}
+
+// WorktreeChecker reports whether a worktree exists, to validate new jobs.
+type WorktreeChecker func(name string) bool
+
+type fileData struct {
+	Jobs []Job            `json:"jobs"`
+	Runs map[string][]Run `json:"runs"` // job ID -> runs, newest first
+}
+
+// Scheduler runs jobs when they are due. Jobs and their run history are
+// persisted in the data directory. Runs missed while the server was down are
+// not caught up.
+type Scheduler struct {
+	path   string
+	sender PromptSender
+	exists WorktreeChecker
+	now    func() time.Time
+
+	saveMu sync.Mutex // serializes file writes
+
+	mu    sync.Mutex
+	jobs  []Job
+	specs map[string]Spec
+	runs  map[string][]Run
+
+	wake    chan struct{}
+	cancel  context.CancelFunc
+	done    chan struct{}
+	running sync.WaitGroup // jobs being sent
+}
+
+func NewScheduler(dataDir string, sender PromptSender, exists WorktreeChecker) (*Scheduler, error) {
+	s := &Scheduler{
+		path:   filepath.Join(dataDir, jobsFile),
+		sender: sender,
+		exists: exists,
+		now:    time.Now,
+		specs:  make(map[string]Spec),
+		runs:   make(map[string][]Run),
+		wake:   make(chan struct{}, 1),
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		var file fileData
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, err
+		}
+		for _, job := range file.Jobs {
+			spec, err := ParseSpec(job.Spec)
+			if err != nil {
+				slog.Warn("skipping scheduled job with invalid spec", "job", job.Name, "spec", job.Spec, "error", err)
+				continue
+			}
+			s.specs[job.ID] = spec
+			s.jobs = append(s.jobs, job)
+		}
+		if file.Runs != nil {
+			s.runs = file.Runs
+		}
+	}
+	return s, nil
+}
+
+// Start schedules the jobs from now on and runs them as they become due.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	now := s.now()
+	for i := range s.jobs {
+		s.scheduleLocked(&s.jobs[i], now)
+	}
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go s.loop(ctx)
+	slog.Info("scheduler started", "jobs", len(s.jobs))
+}
+
+// Stop stops scheduling and waits for jobs being sent. Prompts already sent keep running.
+func (s *Scheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+	s.running.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context) {
+	defer close(s.done)
+	for {
+		timer := time.NewTimer(s.untilNextRun())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+		s.runDue(ctx)
+	}
+}
+
+// untilNextRun returns how long to sleep before the next due job.
+func (s *Scheduler) untilNextRun() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := time.Duration(-1)
+	now := s.now()
+	for _, job := range s.jobs {
+		if job.Paused || job.NextRunAt == nil {
+			continue
+		}
+		d := job.NextRunAt.Sub(now)
+		if next < 0 || d < next {
+			next = d
+		}
+	}
+	if next < 0 {
+		// Nothing scheduled; woken up by changes
+		return 24 * time.Hour
+	}
+	return max(next, 0)
+}
+
+func (s *Scheduler) runDue(ctx context.Context) {
+	s.mu.Lock()
+	now := s.now()
+	var due []Job
+	for i := range s.jobs {
+		job := &s.jobs[i]
+		if job.Paused || job.NextRunAt == nil || job.NextRunAt.After(now) {
+			continue
+		}
+		due = append(due, *job)
+		s.scheduleLocked(job, now)
+	}
+	s.mu.Unlock()
+
+	// Jobs run concurrently so a slow agent start does not delay the others
+	for _, job := range due {
+		s.running.Add(1)
+		go func() {
+			defer s.running.Done()
+			s.execute(ctx, job)
+		}()
+	}
+}
+
+func (s *Scheduler) execute(ctx context.Context, job Job) {
+	sessionID := ""
+	if job.ReuseSession {
+		sessionID = job.SessionID
+	}
+
+	run := Run{StartedAt: s.now(), Status: RunSent}
+	sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+	sessionID, err := s.sender.SendPrompt(sendCtx, job.Worktree, sessionID, job.Name, job.Prompt, job.Mode)
+	cancel()
+	if err != nil {
+		run.Status = RunFailed
+		run.Error = err.Error()
+		slog.Warn("scheduled job failed", "job", job.Name, "jobId", job.ID, "error", err)
+	} else {
+		run.SessionID = sessionID
+		slog.Info("scheduled job sent", "job", job.Name, "jobId", job.ID, "sessionId", sessionID)
+	}
+
+	s.mu.Lock()
+	if i := s.indexLocked(job.ID); i >= 0 {
+		s.jobs[i].LastRun = &run
+		if err == nil {
+			s.jobs[i].SessionID = sessionID
+		}
+		runs := append([]Run{run}, s.runs[job.ID]...)
+		if len(runs) > MaxHistory {
+			runs = runs[:MaxHistory]
+		}
+		s.runs[job.ID] = runs
+	}
+	s.mu.Unlock()
+	s.save()
+}
+
+// scheduleLocked sets the next run of a job after now. Caller must hold mu.
+func (s *Scheduler) scheduleLocked(job *Job, now time.Time) {
+	job.NextRunAt = nil
+	if job.Paused {
+		return
+	}
+	if next := s.specs[job.ID].Next(now); !next.IsZero() {
+		job.NextRunAt = &next
+	}
+}
+
+// List returns the jobs in creation order.
+func (s *Scheduler) List() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.jobs)
+}
+
+// History returns the recent runs of a job, newest first.
+func (s *Scheduler) History(id string) ([]Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.indexLocked(id) < 0 {
+		return nil, ErrNotFound
+	}
+	runs := slices.Clone(s.runs[id])
+	if runs == nil {
+		runs = []Run{}
+	}
+	return runs, nil
+}
+
+func (s *Scheduler) Create(def NewJob) (Job, error) {
+	def.Name = strings.TrimSpace(def.Name)
+	if def.Name == "" {
+		return Job{}, ErrNameRequired
+	}
+	if strings.TrimSpace(def.Prompt) == "" {
+		return Job{}, ErrPromptRequired
+	}
+	if def.Mode == "" {
+		def.Mode = session.ModeDefault
+	}
+	if !def.Mode.IsValid() {
+		return Job{}, ErrInvalidMode
+	}
+	spec, err := ParseSpec(def.Spec)
+	if err != nil {
+		return Job{}, err
+	}
+	if s.exists != nil && !s.exists(def.Worktree) {
+		return Job{}, ErrWorktreeNotFound
+	}
+
+	job := Job{
+		ID:           uuid.Must(uuid.NewV7()).String(),
+		Name:         def.Name,
+		Spec:         strings.TrimSpace(def.Spec),
+		Worktree:     def.Worktree,
+		Prompt:       def.Prompt,
+		Mode:         def.Mode,
+		ReuseSession: def.ReuseSession,
+		CreatedAt:    s.now(),
+	}
+
+	s.mu.Lock()
+	s.specs[job.ID] = spec
+	s.scheduleLocked(&job, s.now())
+	s.jobs = append(s.jobs, job)
+	s.mu.Unlock()
+
+	s.save()
+	s.notify()
+	slog.Info("scheduled job created", "job", job.Name, "jobId", job.ID, "spec", job.Spec)
+	return job, nil
+}
+
+// SetPaused pauses or resumes a job. A resumed job runs at its next scheduled time.
+func (s *Scheduler) SetPaused(id string, paused bool) (Job, error) {
+	s.mu.Lock()
+	i := s.indexLocked(id)
+	if i < 0 {
+		s.mu.Unlock()
+		return Job{}, ErrNotFound
+	}
+	s.jobs[i].Paused = paused
+	s.scheduleLocked(&s.jobs[i], s.now())
+	job := s.jobs[i]
+	s.mu.Unlock()
+
+	s.save()
+	s.notify()
+	return job, nil
+}
+
+func (s *Scheduler) Delete(id string) error {
+	s.mu.Lock()
+	i := s.indexLocked(id)
+	if i < 0 {
+		s.mu.Unlock()
+		return ErrNotFound
+	}
+	s.jobs = slices.Delete(s.jobs, i, i+1)
+	delete(s.specs, id)
+	delete(s.runs, id)
+	s.mu.Unlock()
+
+	s.save()
+	s.notify()
+	return nil
+}
+
+func (s *Scheduler) indexLocked(id string) int {
+	return slices.IndexFunc(s.jobs, func(j Job) bool { return j.ID == id })
+}
+
+// notify wakes the loop up to reconsider the next run.
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) save() {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.Lock()
+	data, err := json.MarshalIndent(fileData{Jobs: s.jobs, Runs: s.runs}, "", "  ")
+	s.mu.Unlock()
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(s.path), 0755); err == nil {
+			err = os.WriteFile(s.path, data, 0644)
+		}
+	}
+	if err != nil {
+		slog.Warn("failed to save scheduled jobs", "path", s.path, "error", err)
+	}
+}
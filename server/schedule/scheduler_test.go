@@ -0,0 +1,218 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pockode/server/session"
+)
+
+type sentPrompt struct {
+	worktree, sessionID, title, prompt string
+	mode                               session.Mode
+}
+
+type fakeSender struct {
+	mu   sync.Mutex
+	sent []sentPrompt
+	err  error
+}
+
+func (f *fakeSender) SendPrompt(ctx context.Context, worktree, sessionID, title, prompt string, mode session.Mode) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, sentPrompt{worktree, sessionID, title, prompt, mode})
+	if f.err != nil {
+		return "", f.err
+	}
+	if sessionID == "" {
+		sessionID = "session-1"
+	}
+	return sessionID, nil
+}
+
+func newTestScheduler(t *testing.T, sender PromptSender) (*Scheduler, string) {
+	t.Helper()
+	dir := t.TempDir()
+	s, err := NewScheduler(dir, sender, func(name string) bool { return name == "" || name == "feature" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return time.Date(2026, 3, 2, 7, 30, 0, 0, time.Local) }
+	return s, dir
+}
+
+// runAt runs the jobs due at now and waits for them to be sent.
+func runAt(s *Scheduler, now time.Time) {
+	s.now = func() time.Time { return now }
+	s.runDue(context.Background())
+	s.running.Wait()
+}
+
+func TestScheduler_Create(t *testing.T) {
+	s, dir := newTestScheduler(t, &fakeSender{})
+
+	job, err := s.Create(NewJob{Name: " nightly ", Spec: "0 8 * * *", Prompt: "Run the tests"})
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if job.ID == "" || job.Name != "nightly" || job.Mode != session.ModeDefault {
+		t.Errorf("Create() = %+v", job)
+	}
+	if want := time.Date(2026, 3, 2, 8, 0, 0, 0, time.Local); job.NextRunAt == nil || !job.NextRunAt.Equal(want) {
+		t.Errorf("NextRunAt = %v, want %v", job.NextRunAt, want)
+	}
+
+	errorCases := []struct {
+		def  NewJob
+		want error
+	}{
+		{NewJob{Spec: "0 8 * * *", Prompt: "p"}, ErrNameRequired},
+		{NewJob{Name: "n", Spec: "0 8 * * *", Prompt: " "}, ErrPromptRequired},
+		{NewJob{Name: "n", Spec: "0 8 * *", Prompt: "p"}, ErrInvalidSpec},
+		{NewJob{Name: "n", Spec: "0 8 * * *", Prompt: "p", Mode: "plan"}, ErrInvalidMode},
+		{NewJob{Name: "n", Spec: "0 8 * * *", Prompt: "p", Worktree: "missing"}, ErrWorktreeNotFound},
+	}
+	for _, tc := range errorCases {
+		if _, err := s.Create(tc.def); !errors.Is(err, tc.want) {
+			t.Errorf("Create(%+v) error = %v, want %v", tc.def, err, tc.want)
+		}
+	}
+
+	// Reload from disk
+	reloaded, err := NewScheduler(dir, &fakeSender{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := reloaded.List()
+	if len(jobs) != 1 || jobs[0].ID != job.ID || jobs[0].Prompt != "Run the tests" {
+		t.Errorf("List() after reload = %+v", jobs)
+	}
+}
+
+func TestScheduler_Run(t *testing.T) {
+	sender := &fakeSender{}
+	s, _ := newTestScheduler(t, sender)
+
+	job, _ := s.Create(NewJob{Name: "review", Spec: "0 8 * * *", Worktree: "feature", Prompt: "Review the diff", Mode: session.ModeYolo, ReuseSession: true})
+	other, _ := s.Create(NewJob{Name: "other", Spec: "0 9 * * *", Prompt: "Later"})
+
+	runAt(s, time.Date(2026, 3, 2, 7, 59, 0, 0, time.Local))
+	if len(sender.sent) != 0 {
+		t.Fatalf("sent before due: %+v", sender.sent)
+	}
+
+	runAt(s, time.Date(2026, 3, 2, 8, 0, 0, 0, time.Local))
+	want := sentPrompt{"feature", "", "review", "Review the diff", session.ModeYolo}
+	if len(sender.sent) != 1 || sender.sent[0] != want {
+		t.Fatalf("sent = %+v, want %+v", sender.sent, want)
+	}
+
+	jobs := s.List()
+	if jobs[0].LastRun == nil || jobs[0].LastRun.Status != RunSent || jobs[0].SessionID != "session-1" {
+		t.Errorf("job after run = %+v", jobs[0])
+	}
+	if want := time.Date(2026, 3, 3, 8, 0, 0, 0, time.Local); !jobs[0].NextRunAt.Equal(want) {
+		t.Errorf("NextRunAt = %v, want %v", jobs[0].NextRunAt, want)
+	}
+	if jobs[1].LastRun != nil {
+		t.Errorf("job not due was run: %+v", jobs[1])
+	}
+
+	// The next run reuses the session; a failure is recorded in the history
+	sender.err = errors.New("agent unavailable")
+	runAt(s, time.Date(2026, 3, 3, 8, 0, 0, 0, time.Local))
+	for _, sent := range sender.sent[1:] {
+		if sent.title == "review" && sent.sessionID != "session-1" {
+			t.Errorf("reused session = %q, want session-1", sent.sessionID)
+		}
+	}
+
+	runs, err := s.History(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].Status != RunFailed || runs[0].Error != "agent unavailable" || runs[1].SessionID != "session-1" {
+		t.Errorf("History() = %+v", runs)
+	}
+	if s.List()[0].SessionID != "session-1" {
+		t.Error("failed run replaced the session of the last successful run")
+	}
+
+	if runs, err := s.History(other.ID); err != nil || len(runs) != 1 {
+		t.Errorf("History() of other job = %+v, %v", runs, err)
+	}
+	if _, err := s.History("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("History() of missing job error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestScheduler_History_Limit(t *testing.T) {
+	s, _ := newTestScheduler(t, &fakeSender{})
+	job, _ := s.Create(NewJob{Name: "hourly", Spec: "@hourly", Prompt: "p"})
+
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.Local)
+	for i := range MaxHistory + 5 {
+		runAt(s, now.Add(time.Duration(i)*time.Hour))
+	}
+	runs, _ := s.History(job.ID)
+	if len(runs) != MaxHistory {
+		t.Errorf("len(History()) = %d, want %d", len(runs), MaxHistory)
+	}
+}
+
+func TestScheduler_PauseAndDelete(t *testing.T) {
+	sender := &fakeSender{}
+	s, dir := newTestScheduler(t, sender)
+	job, _ := s.Create(NewJob{Name: "nightly", Spec: "0 8 * * *", Prompt: "p"})
+
+	paused, err := s.SetPaused(job.ID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !paused.Paused || paused.NextRunAt != nil {
+		t.Errorf("paused job = %+v", paused)
+	}
+	runAt(s, time.Date(2026, 3, 2, 8, 0, 0, 0, time.Local))
+	if len(sender.sent) != 0 {
+		t.Errorf("paused job was run: %+v", sender.sent)
+	}
+
+	resumed, err := s.SetPaused(job.ID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 3, 3, 8, 0, 0, 0, time.Local); resumed.Paused || resumed.NextRunAt == nil || !resumed.NextRunAt.Equal(want) {
+		t.Errorf("resumed job = %+v", resumed)
+	}
+
+	if _, err := s.SetPaused("missing", true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetPaused() of missing job error = %v, want ErrNotFound", err)
+	}
+
+	if err := s.Delete(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(job.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete() error = %v, want ErrNotFound", err)
+	}
+	reloaded, _ := NewScheduler(dir, sender, nil)
+	if jobs := reloaded.List(); len(jobs) != 0 {
+		t.Errorf("List() after delete and reload = %+v", jobs)
+	}
+}
+
+func TestScheduler_StartStop(t *testing.T) {
+	sender := &fakeSender{}
+	s, _ := newTestScheduler(t, sender)
+	s.now = time.Now
+	s.Create(NewJob{Name: "minutely", Spec: "* * * * *", Prompt: "p"})
+
+	s.Start()
+	s.Stop()
+	// Stopping twice is harmless
+	s.Stop()
+}
@@ -65,6 +65,17 @@ func NewFileStore(dataDir string) (*FileStore, error) {
 	return store, nil
 }
 
+// ReadSessions returns the sessions persisted in dataDir without creating a store,
+// e.g. to summarize a worktree that is not loaded. Missing data yields no sessions.
+func ReadSessions(dataDir string) ([]SessionMeta, error) {
+	store := &FileStore{dataDir: dataDir}
+	idx, err := store.readIndexFromDisk()
+	if err != nil {
+		return nil, err
+	}
+	return idx.Sessions, nil
+}
+
 func (s *FileStore) indexPath() string {
 	return filepath.Join(s.dataDir, "sessions", "index.json")
 }
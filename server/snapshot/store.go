@@ -0,0 +1,374 @@
+// Package snapshot keeps copies of working tree files before destructive operations
+// (such as git discard) so they can be restored within a retention window.
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultRetention is how long snapshots are kept before being pruned.
+const DefaultRetention = 24 * time.Hour
+
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// Entry is a single file captured in a snapshot.
+type Entry struct {
+	Path string      `json:"path"`
+	Mode fs.FileMode `json:"mode"`
+}
+
+// Snapshot describes a set of files captured before a destructive operation.
+type Snapshot struct {
+	ID        string    `json:"id"`
+	Reason    string    `json:"reason"`
+	Paths     []string  `json:"paths"`   // paths as requested by the client
+	Missing   []string  `json:"missing"` // requested paths that did not exist at capture time
+	Entries   []Entry   `json:"entries"` // files captured (paths under requested directories are expanded)
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Store persists snapshots under <dataDir>/snapshots/<id>/.
+type Store struct {
+	dir       string
+	retention time.Duration
+	mu        sync.Mutex
+}
+
+// NewStore creates a snapshot store with DefaultRetention.
+func NewStore(dataDir string) (*Store, error) {
+	return NewStoreWithRetention(dataDir, DefaultRetention)
+}
+
+// NewStoreWithRetention creates a snapshot store with a custom retention window.
+func NewStoreWithRetention(dataDir string, retention time.Duration) (*Store, error) {
+	dir := filepath.Join(dataDir, "snapshots")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, retention: retention}, nil
+}
+
+func (s *Store) snapshotDir(id string) string {
+	return filepath.Join(s.dir, id)
+}
+
+func (s *Store) metaPath(id string) string {
+	return filepath.Join(s.snapshotDir(id), "meta.json")
+}
+
+func (s *Store) filePath(id, path string) string {
+	return filepath.Join(s.snapshotDir(id), "files", filepath.FromSlash(path))
+}
+
+// Save copies the current content of paths (relative to workDir) into a new snapshot.
+// Directories are captured recursively, skipping .git and paths excluded by
+// .gitignore, which a discard leaves alone. Callers must validate paths before calling Save.
+func (s *Store) Save(workDir string, paths []string, reason string) (Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneLocked()
+
+	now := time.Now()
+	snap := Snapshot{
+		ID:        uuid.Must(uuid.NewV7()).String(),
+		Reason:    reason,
+		Paths:     paths,
+		Missing:   []string{},
+		Entries:   []Entry{},
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.retention),
+	}
+
+	for _, path := range paths {
+		fullPath := filepath.Join(workDir, path)
+		info, err := os.Lstat(fullPath)
+		if os.IsNotExist(err) {
+			snap.Missing = append(snap.Missing, path)
+			continue
+		}
+		if err != nil {
+			s.removeLocked(snap.ID)
+			return Snapshot{}, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+
+		if !info.IsDir() {
+			if err := s.captureFile(&snap, fullPath, path, info.Mode()); err != nil {
+				s.removeLocked(snap.ID)
+				return Snapshot{}, err
+			}
+			continue
+		}
+
+		// Outside a git repository nothing is ignored
+		ignored, _ := ignoredPaths(workDir, path)
+		err = filepath.WalkDir(fullPath, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(workDir, p)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if d.IsDir() {
+				if d.Name() == ".git" || ignored[rel] {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if ignored[rel] {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			return s.captureFile(&snap, p, rel, info.Mode())
+		})
+		if err != nil {
+			s.removeLocked(snap.ID)
+			return Snapshot{}, fmt.Errorf("failed to capture %s: %w", path, err)
+		}
+	}
+
+	if err := s.writeMeta(snap); err != nil {
+		s.removeLocked(snap.ID)
+		return Snapshot{}, err
+	}
+	return snap, nil
+}
+
+func (s *Store) captureFile(snap *Snapshot, fullPath, relPath string, mode fs.FileMode) error {
+	dst := s.filePath(snap.ID, relPath)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	if mode&fs.ModeSymlink != 0 {
+		target, err := os.Readlink(fullPath)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(dst, []byte(target), 0644); err != nil {
+			return err
+		}
+	} else if err := copyFile(dst, fullPath, 0644); err != nil {
+		return fmt.Errorf("failed to copy %s: %w", relPath, err)
+	}
+
+	snap.Entries = append(snap.Entries, Entry{Path: relPath, Mode: mode})
+	return nil
+}
+
+// ignoredPaths returns the untracked files and directories under pathspec
+// (relative to workDir, slash-separated) that are excluded by .gitignore.
+func ignoredPaths(workDir, pathspec string) (map[string]bool, error) {
+	cmd := exec.Command("git", "ls-files", "-z", "--others", "--ignored", "--exclude-standard", "--directory", "--", pathspec)
+	cmd.Dir = workDir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	ignored := make(map[string]bool)
+	for _, entry := range strings.Split(string(output), "\x00") {
+		if entry != "" {
+			ignored[strings.TrimSuffix(entry, "/")] = true
+		}
+	}
+	return ignored, nil
+}
+
+// copyFile streams src into dst, creating or truncating it with perm.
+func copyFile(dst, src string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (s *Store) writeMeta(snap Snapshot) error {
+	if err := os.MkdirAll(s.snapshotDir(snap.ID), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.metaPath(snap.ID), data, 0644)
+}
+
+func (s *Store) readMeta(id string) (Snapshot, error) {
+	data, err := os.ReadFile(s.metaPath(id))
+	if os.IsNotExist(err) {
+		return Snapshot{}, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return Snapshot{}, err
+	}
+	return snap, nil
+}
+
+// List returns unexpired snapshots, newest first.
+func (s *Store) List() ([]Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneLocked()
+	return s.listLocked()
+}
+
+func (s *Store) listLocked() ([]Snapshot, error) {
+	dirEntries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	snaps := make([]Snapshot, 0, len(dirEntries))
+	for _, de := range dirEntries {
+		if !de.IsDir() {
+			continue
+		}
+		snap, err := s.readMeta(de.Name())
+		if err != nil {
+			continue
+		}
+		snaps = append(snaps, snap)
+	}
+
+	sort.Slice(snaps, func(i, j int) bool {
+		return snaps[i].CreatedAt.After(snaps[j].CreatedAt)
+	})
+	return snaps, nil
+}
+
+// Restore writes the snapshot back into workDir and deletes the snapshot.
+// Paths that did not exist when the snapshot was taken are removed again.
+func (s *Store) Restore(workDir, id string) (Snapshot, error) {
+	if !isValidID(id) {
+		return Snapshot{}, ErrSnapshotNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap, err := s.readMeta(id)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if time.Now().After(snap.ExpiresAt) {
+		s.removeLocked(id)
+		return Snapshot{}, ErrSnapshotNotFound
+	}
+
+	for _, path := range snap.Missing {
+		if err := os.RemoveAll(filepath.Join(workDir, path)); err != nil {
+			return Snapshot{}, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+
+	for _, entry := range snap.Entries {
+		if err := s.restoreEntry(workDir, id, entry); err != nil {
+			return Snapshot{}, err
+		}
+	}
+
+	s.removeLocked(id)
+	return snap, nil
+}
+
+func (s *Store) restoreEntry(workDir, id string, entry Entry) error {
+	src := s.filePath(id, entry.Path)
+	dst := filepath.Join(workDir, filepath.FromSlash(entry.Path))
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	if entry.Mode&fs.ModeSymlink != 0 {
+		target, err := os.ReadFile(src)
+		if err != nil {
+			return fmt.Errorf("failed to read snapshot of %s: %w", entry.Path, err)
+		}
+		os.Remove(dst)
+		if err := os.Symlink(string(target), dst); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", entry.Path, err)
+		}
+		return nil
+	}
+
+	if err := copyFile(dst, src, entry.Mode.Perm()); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", entry.Path, err)
+	}
+	// OpenFile keeps the mode of an existing file; apply the captured mode explicitly
+	return os.Chmod(dst, entry.Mode.Perm())
+}
+
+// Delete removes a snapshot without restoring it.
+func (s *Store) Delete(id string) error {
+	if !isValidID(id) {
+		return ErrSnapshotNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(s.snapshotDir(id)); os.IsNotExist(err) {
+		return ErrSnapshotNotFound
+	}
+	return os.RemoveAll(s.snapshotDir(id))
+}
+
+// pruneLocked removes expired snapshots. Caller must hold mu.
+func (s *Store) pruneLocked() {
+	snaps, err := s.listLocked()
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	for _, snap := range snaps {
+		if now.After(snap.ExpiresAt) {
+			s.removeLocked(snap.ID)
+		}
+	}
+}
+
+func (s *Store) removeLocked(id string) {
+	os.RemoveAll(s.snapshotDir(id))
+}
+
+// isValidID rejects IDs that could escape the snapshots directory.
+func isValidID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, `/\`) && id != "." && id != ".."
+}
@@ -0,0 +1,133 @@
+package snapshot
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore_SaveAndRestore(t *testing.T) {
+	workDir := t.TempDir()
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(workDir, "a.txt"), []byte("a"), 0644)
+	os.MkdirAll(filepath.Join(workDir, "dir", "sub"), 0755)
+	os.WriteFile(filepath.Join(workDir, "dir", "sub", "b.txt"), []byte("b"), 0755)
+
+	snap, err := store.Save(workDir, []string{"a.txt", "dir", "gone.txt"}, "discard")
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if len(snap.Entries) != 2 {
+		t.Errorf("expected 2 entries, got %+v", snap.Entries)
+	}
+	if len(snap.Missing) != 1 || snap.Missing[0] != "gone.txt" {
+		t.Errorf("expected gone.txt to be missing, got %v", snap.Missing)
+	}
+
+	// Simulate a discard
+	os.WriteFile(filepath.Join(workDir, "a.txt"), []byte("changed"), 0644)
+	os.RemoveAll(filepath.Join(workDir, "dir"))
+	os.WriteFile(filepath.Join(workDir, "gone.txt"), []byte("restored by git"), 0644)
+
+	if _, err := store.Restore(workDir, snap.ID); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	if content, _ := os.ReadFile(filepath.Join(workDir, "a.txt")); string(content) != "a" {
+		t.Errorf("expected a.txt content 'a', got %q", content)
+	}
+	info, err := os.Stat(filepath.Join(workDir, "dir", "sub", "b.txt"))
+	if err != nil {
+		t.Fatalf("expected b.txt to be restored: %v", err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("expected mode 0755, got %v", info.Mode().Perm())
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "gone.txt")); !os.IsNotExist(err) {
+		t.Error("expected gone.txt to be removed again")
+	}
+
+	// Snapshot is consumed by Restore
+	if _, err := store.Restore(workDir, snap.ID); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("expected ErrSnapshotNotFound, got %v", err)
+	}
+}
+
+func TestStore_SaveSkipsIgnoredPaths(t *testing.T) {
+	workDir := t.TempDir()
+	if out, err := exec.Command("git", "-C", workDir, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(workDir, ".gitignore"), []byte("node_modules/\n*.log\n"), 0644)
+	os.MkdirAll(filepath.Join(workDir, "app", "node_modules", "pkg"), 0755)
+	os.WriteFile(filepath.Join(workDir, "app", "main.go"), []byte("package main"), 0644)
+	os.WriteFile(filepath.Join(workDir, "app", "debug.log"), []byte("log"), 0644)
+	os.WriteFile(filepath.Join(workDir, "app", "node_modules", "pkg", "index.js"), []byte("js"), 0644)
+
+	snap, err := store.Save(workDir, []string{"app"}, "discard")
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if len(snap.Entries) != 1 || snap.Entries[0].Path != "app/main.go" {
+		t.Errorf("expected only app/main.go, got %+v", snap.Entries)
+	}
+}
+
+func TestStore_List(t *testing.T) {
+	workDir := t.TempDir()
+	store, _ := NewStore(t.TempDir())
+	os.WriteFile(filepath.Join(workDir, "a.txt"), []byte("a"), 0644)
+
+	first, _ := store.Save(workDir, []string{"a.txt"}, "discard")
+	second, _ := store.Save(workDir, []string{"a.txt"}, "discard")
+
+	snaps, err := store.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(snaps) != 2 {
+		t.Fatalf("expected 2 snapshots, got %d", len(snaps))
+	}
+	if snaps[0].ID != second.ID || snaps[1].ID != first.ID {
+		t.Errorf("expected newest first, got %s, %s", snaps[0].ID, snaps[1].ID)
+	}
+}
+
+func TestStore_ExpiredSnapshotsArePruned(t *testing.T) {
+	workDir := t.TempDir()
+	store, _ := NewStoreWithRetention(t.TempDir(), time.Millisecond)
+	os.WriteFile(filepath.Join(workDir, "a.txt"), []byte("a"), 0644)
+
+	snap, _ := store.Save(workDir, []string{"a.txt"}, "discard")
+	time.Sleep(5 * time.Millisecond)
+
+	snaps, _ := store.List()
+	if len(snaps) != 0 {
+		t.Errorf("expected expired snapshot to be pruned, got %d", len(snaps))
+	}
+	if _, err := store.Restore(workDir, snap.ID); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("expected ErrSnapshotNotFound, got %v", err)
+	}
+}
+
+func TestStore_RejectsInvalidID(t *testing.T) {
+	store, _ := NewStore(t.TempDir())
+
+	for _, id := range []string{"", "..", "../x", "a/b"} {
+		if _, err := store.Restore(t.TempDir(), id); !errors.Is(err, ErrSnapshotNotFound) {
+			t.Errorf("Restore(%q) error = %v, want ErrSnapshotNotFound", id, err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package snippet
+
+import "regexp"
+
+// Builtin variables, resolved by the server when a snippet is sent.
+const (
+	VarBranch       = "branch"        // current branch of the worktree
+	VarWorktree     = "worktree"      // worktree name, empty for the main worktree
+	VarSelectedFile = "selected_file" // file selected in the client, if any
+	VarDiffSummary  = "diff_summary"  // git diff --stat of uncommitted changes
+)
+
+// placeholderPattern matches {{name}}, allowing spaces inside the braces.
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.-]*)\s*\}\}`)
+
+// Expand replaces the placeholders in content with the values returned by
+// resolve, called once per name. Unresolved placeholders are kept as written
+// and their names returned.
+func Expand(content string, resolve func(name string) (string, bool)) (string, []string) {
+	type value struct {
+		text string
+		ok   bool
+	}
+	values := make(map[string]value)
+	var missing []string
+
+	expanded := placeholderPattern.ReplaceAllStringFunc(content, func(match string) string {
+		name := placeholderPattern.FindStringSubmatch(match)[1]
+		v, seen := values[name]
+		if !seen {
+			v.text, v.ok = resolve(name)
+			values[name] = v
+			if !v.ok {
+				missing = append(missing, name)
+			}
+		}
+		if !v.ok {
+			return match
+		}
+		return v.text
+	})
+	return expanded, missing
+}
@@ -0,0 +1,33 @@
+package snippet
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	calls := make(map[string]int)
+	resolve := func(name string) (string, bool) {
+		calls[name]++
+		switch name {
+		case "branch":
+			return "main", true
+		case "empty":
+			return "", true
+		}
+		return "", false
+	}
+
+	got, missing := Expand("On {{branch}} ({{ branch }}), fix {{file}}{{empty}} and {{file}}. {{not a var}}", resolve)
+
+	want := "On main (main), fix {{file}} and {{file}}. {{not a var}}"
+	if got != want {
+		t.Errorf("Expand() = %q, want %q", got, want)
+	}
+	if !reflect.DeepEqual(missing, []string{"file"}) {
+		t.Errorf("missing = %v, want [file]", missing)
+	}
+	if calls["branch"] != 1 || calls["file"] != 1 {
+		t.Errorf("resolve calls = %v, want one per name", calls)
+	}
+}
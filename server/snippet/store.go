@@ -0,0 +1,252 @@
+// Package snippet stores reusable prompts and expands their {{variable}} placeholders.
+package snippet
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound        = errors.New("snippet not found")
+	ErrNameRequired    = errors.New("snippet name is required")
+	ErrContentRequired = errors.New("snippet content is required")
+	ErrDuplicateName   = errors.New("a snippet with this name already exists")
+	ErrInvalidScope    = errors.New("invalid snippet scope")
+)
+
+// Scope tells where a snippet is available.
+type Scope string
+
+const (
+	ScopeGlobal   Scope = "global"   // every worktree
+	ScopeWorktree Scope = "worktree" // the worktree it was created in
+)
+
+// ValidScope reports whether s is a known scope.
+func ValidScope(s Scope) bool {
+	return s == ScopeGlobal || s == ScopeWorktree
+}
+
+// Snippet is a reusable prompt.
+type Snippet struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Content     string     `json:"content"`
+	Scope       Scope      `json:"scope"`
+	UseCount    int        `json:"use_count"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Update changes the non-nil fields of a snippet.
+type Update struct {
+	Name        *string
+	Description *string
+	Content     *string
+}
+
+// Store persists the snippets of one scope: global snippets live in the data
+// directory, worktree snippets in the worktree's data directory.
+type Store struct {
+	path     string
+	scope    Scope
+	mu       sync.RWMutex
+	snippets []Snippet // in-memory cache
+}
+
+// NewStore loads the snippets of a scope from dataDir.
+func NewStore(dataDir string, scope Scope) (*Store, error) {
+	if !ValidScope(scope) {
+		return nil, ErrInvalidScope
+	}
+	file := "snippets.json"
+	if scope == ScopeWorktree {
+		// The main worktree shares the global data directory
+		file = "worktree_snippets.json"
+	}
+	s := &Store{path: filepath.Join(dataDir, file), scope: scope}
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.snippets); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// List returns the snippets, most used first.
+func (s *Store) List() []Snippet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snippets := slices.Clone(s.snippets)
+	SortByUsage(snippets)
+	return snippets
+}
+
+func (s *Store) Get(id string) (Snippet, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	idx := s.indexLocked(id)
+	if idx < 0 {
+		return Snippet{}, ErrNotFound
+	}
+	return s.snippets[idx], nil
+}
+
+// Create adds a snippet. Names are unique within a store.
+func (s *Store) Create(name, description, content string) (Snippet, error) {
+	name = strings.TrimSpace(name)
+	if err := validate(name, content); err != nil {
+		return Snippet{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.nameTakenLocked(name, "") {
+		return Snippet{}, ErrDuplicateName
+	}
+
+	now := time.Now()
+	snippet := Snippet{
+		ID:          uuid.Must(uuid.NewV7()).String(),
+		Name:        name,
+		Description: description,
+		Content:     content,
+		Scope:       s.scope,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.replaceLocked(append(slices.Clone(s.snippets), snippet)); err != nil {
+		return Snippet{}, err
+	}
+	return snippet, nil
+}
+
+func (s *Store) Update(id string, upd Update) (Snippet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx := s.indexLocked(id)
+	if idx < 0 {
+		return Snippet{}, ErrNotFound
+	}
+
+	snippet := s.snippets[idx]
+	if upd.Name != nil {
+		snippet.Name = strings.TrimSpace(*upd.Name)
+	}
+	if upd.Description != nil {
+		snippet.Description = *upd.Description
+	}
+	if upd.Content != nil {
+		snippet.Content = *upd.Content
+	}
+	if err := validate(snippet.Name, snippet.Content); err != nil {
+		return Snippet{}, err
+	}
+	if s.nameTakenLocked(snippet.Name, id) {
+		return Snippet{}, ErrDuplicateName
+	}
+	snippet.UpdatedAt = time.Now()
+
+	snippets := slices.Clone(s.snippets)
+	snippets[idx] = snippet
+	if err := s.replaceLocked(snippets); err != nil {
+		return Snippet{}, err
+	}
+	return snippet, nil
+}
+
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx := s.indexLocked(id)
+	if idx < 0 {
+		return ErrNotFound
+	}
+	return s.replaceLocked(slices.Delete(slices.Clone(s.snippets), idx, idx+1))
+}
+
+// Use records that a snippet was sent, for ordering by usage.
+func (s *Store) Use(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx := s.indexLocked(id)
+	if idx < 0 {
+		return ErrNotFound
+	}
+
+	now := time.Now()
+	snippets := slices.Clone(s.snippets)
+	snippets[idx].UseCount++
+	snippets[idx].LastUsedAt = &now
+	return s.replaceLocked(snippets)
+}
+
+func (s *Store) indexLocked(id string) int {
+	return slices.IndexFunc(s.snippets, func(sn Snippet) bool { return sn.ID == id })
+}
+
+func (s *Store) nameTakenLocked(name, exceptID string) bool {
+	return slices.ContainsFunc(s.snippets, func(sn Snippet) bool {
+		return sn.ID != exceptID && strings.EqualFold(sn.Name, name)
+	})
+}
+
+// replaceLocked persists snippets, keeping the previous state if writing fails.
+func (s *Store) replaceLocked(snippets []Snippet) error {
+	data, err := json.MarshalIndent(snippets, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.path, data, 0644); err != nil {
+		return err
+	}
+	s.snippets = snippets
+	return nil
+}
+
+func validate(name, content string) error {
+	if name == "" {
+		return ErrNameRequired
+	}
+	if strings.TrimSpace(content) == "" {
+		return ErrContentRequired
+	}
+	return nil
+}
+
+// SortByUsage orders snippets by use count, then most recently used, then name.
+func SortByUsage(snippets []Snippet) {
+	sort.SliceStable(snippets, func(i, j int) bool {
+		a, b := snippets[i], snippets[j]
+		if a.UseCount != b.UseCount {
+			return a.UseCount > b.UseCount
+		}
+		if (a.LastUsedAt == nil) != (b.LastUsedAt == nil) {
+			return a.LastUsedAt != nil
+		}
+		if a.LastUsedAt != nil && !a.LastUsedAt.Equal(*b.LastUsedAt) {
+			return a.LastUsedAt.After(*b.LastUsedAt)
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+}
@@ -0,0 +1,108 @@
+package snippet
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore_CRUD(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir, ScopeGlobal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := store.Create(" tests ", "Write tests", "Write tests for {{target}}")
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if created.ID == "" || created.Name != "tests" || created.Scope != ScopeGlobal {
+		t.Errorf("Create() = %+v", created)
+	}
+
+	if _, err := store.Create("Tests", "", "duplicate"); !errors.Is(err, ErrDuplicateName) {
+		t.Errorf("Create() with duplicate name error = %v, want ErrDuplicateName", err)
+	}
+	if _, err := store.Create("", "", "content"); !errors.Is(err, ErrNameRequired) {
+		t.Errorf("Create() without name error = %v, want ErrNameRequired", err)
+	}
+	if _, err := store.Create("empty", "", "  "); !errors.Is(err, ErrContentRequired) {
+		t.Errorf("Create() without content error = %v, want ErrContentRequired", err)
+	}
+
+	content := "Write table-driven tests for {{target}}"
+	updated, err := store.Update(created.ID, Update{Content: &content})
+	if err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+	if updated.Content != content || updated.Name != "tests" || updated.Description != "Write tests" {
+		t.Errorf("Update() = %+v", updated)
+	}
+
+	// Reload from disk
+	reloaded, err := NewStore(dir, ScopeGlobal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reloaded.Get(created.ID); err != nil || got.Content != content {
+		t.Errorf("Get() after reload = %+v, %v", got, err)
+	}
+
+	if err := store.Delete(created.ID); err != nil {
+		t.Fatalf("Delete() error: %v", err)
+	}
+	if _, err := store.Get(created.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after delete error = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(created.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete() twice error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStore_ListByUsage(t *testing.T) {
+	store, err := NewStore(t.TempDir(), ScopeWorktree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := store.Create("b", "", "b")
+	a, _ := store.Create("a", "", "a")
+	c, _ := store.Create("c", "", "c")
+	store.Use(c.ID)
+	store.Use(b.ID)
+	store.Use(b.ID)
+
+	list := store.List()
+	got := []string{list[0].Name, list[1].Name, list[2].Name}
+	want := []string{"b", "c", "a"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("List() order = %v, want %v", got, want)
+		}
+	}
+	if list[0].UseCount != 2 || list[0].LastUsedAt == nil {
+		t.Errorf("usage of b = %d, %v", list[0].UseCount, list[0].LastUsedAt)
+	}
+	if list[2].ID != a.ID || list[2].LastUsedAt != nil || list[2].Scope != ScopeWorktree {
+		t.Errorf("unused snippet = %+v", list[2])
+	}
+}
+
+func TestNewStore_ScopesUseSeparateFiles(t *testing.T) {
+	dir := t.TempDir()
+	global, _ := NewStore(dir, ScopeGlobal)
+	worktree, _ := NewStore(dir, ScopeWorktree)
+	global.Create("shared", "", "global")
+	worktree.Create("shared", "", "worktree")
+
+	for _, file := range []string{"snippets.json", "worktree_snippets.json"} {
+		if _, err := os.Stat(filepath.Join(dir, file)); err != nil {
+			t.Errorf("expected %s: %v", file, err)
+		}
+	}
+	if _, err := NewStore(dir, "team"); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("NewStore() with unknown scope error = %v", err)
+	}
+}
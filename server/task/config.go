@@ -0,0 +1,70 @@
+// Package task runs the named commands declared in a repository's task config
+// and keeps a history of their runs.
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ConfigFile is the task config, relative to the worktree (or, failing that, the main worktree).
+const ConfigFile = ".pockode/tasks.json"
+
+// Task is a named command.
+type Task struct {
+	Name        string            `json:"name"`
+	Command     string            `json:"command"`               // run with sh -c
+	Cwd         string            `json:"cwd,omitempty"`         // relative to the worktree
+	Env         map[string]string `json:"env,omitempty"`         // added to the server's environment
+	Description string            `json:"description,omitempty"` // shown in the task list
+}
+
+type config struct {
+	Tasks []Task `json:"tasks"`
+}
+
+// LoadTasks reads the task config from the first of dirs that has one.
+// Returns no tasks without error if none has a config.
+func LoadTasks(dirs ...string) ([]Task, error) {
+	for _, dir := range dirs {
+		data, err := os.ReadFile(filepath.Join(dir, ConfigFile))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return parseConfig(data)
+	}
+	return []Task{}, nil
+}
+
+func parseConfig(data []byte) ([]Task, error) {
+	var cfg config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ConfigFile, err)
+	}
+
+	seen := make(map[string]bool)
+	for _, t := range cfg.Tasks {
+		switch {
+		case t.Name == "":
+			return nil, fmt.Errorf("invalid %s: task without a name", ConfigFile)
+		case seen[t.Name]:
+			return nil, fmt.Errorf("invalid %s: duplicate task %q", ConfigFile, t.Name)
+		case t.Command == "":
+			return nil, fmt.Errorf("invalid %s: task %q has no command", ConfigFile, t.Name)
+		case t.Cwd != "" && !filepath.IsLocal(t.Cwd):
+			return nil, fmt.Errorf("invalid %s: cwd of task %q must be relative to the repository", ConfigFile, t.Name)
+		}
+		seen[t.Name] = true
+	}
+	if cfg.Tasks == nil {
+		cfg.Tasks = []Task{}
+	}
+	return cfg.Tasks, nil
+}
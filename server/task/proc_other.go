@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package task
+
+import "os/exec"
+
+// killOnCancel keeps the default behaviour of killing only the shell.
+func killOnCancel(cmd *exec.Cmd) {}
@@ -0,0 +1,17 @@
+//go:build linux || darwin
+
+package task
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killOnCancel runs cmd in its own process group and kills the whole group on
+// cancel, so that commands started by the shell (compilers, test binaries) stop too.
+func killOnCancel(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,489 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxHistory is the number of finished runs kept per worktree.
+	MaxHistory = 50
+	// MaxOutputSize is the output retained per run, for clients that attach late.
+	MaxOutputSize = 256 * 1024
+
+	historyFile = "task_runs.json"
+	// waitDelay is how long a cancelled task may keep its output open.
+	waitDelay = 5 * time.Second
+	// outputChunkSize is the longest piece of output sent at once; longer lines are split.
+	outputChunkSize = 64 * 1024
+	// partialLineDelay is how long output without a trailing newline, such as a
+	// progress bar, waits for the rest of its line.
+	partialLineDelay = 100 * time.Millisecond
+)
+
+var (
+	ErrTaskNotFound = errors.New("task not found")
+	ErrRunNotFound  = errors.New("run not found")
+	ErrRunFinished  = errors.New("run has already finished")
+)
+
+type State string
+
+const (
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+	StateCancelled State = "cancelled"
+)
+
+// Run is a single execution of a task.
+type Run struct {
+	ID         string     `json:"id"`
+	Task       string     `json:"task"`
+	Command    string     `json:"command"`
+	State      State      `json:"state"`
+	ExitCode   *int       `json:"exit_code,omitempty"` // set once the command exited
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	DurationMs int64      `json:"duration_ms,omitempty"`
+}
+
+// Listener receives run progress. Calls are made from the run's goroutines.
+type Listener interface {
+	OnTaskOutput(runID, stream, data string)
+	OnTaskStatus(run Run)
+}
+
+// Runner runs the tasks of a worktree. Finished runs are persisted in the
+// worktree's data directory; their output is only kept in memory.
+type Runner struct {
+	name        string // worktree name, empty for the main worktree
+	workDir     string
+	mainDir     string
+	historyPath string
+	listener    Listener
+	onFinish    func()
+
+	saveMu sync.Mutex // serializes history file writes
+
+	mu      sync.Mutex
+	active  map[string]*activeRun
+	history []Run              // finished runs, newest first
+	outputs map[string]*output // output of active and recent runs
+}
+
+type activeRun struct {
+	run       Run
+	cancel    context.CancelFunc
+	cancelled bool
+	pid       int           // shell process, once started
+	done      chan struct{} // closed once the run is recorded
+}
+
+func NewRunner(name, workDir, mainDir, dataDir string) *Runner {
+	r := &Runner{
+		name:        name,
+		workDir:     workDir,
+		mainDir:     mainDir,
+		historyPath: filepath.Join(dataDir, historyFile),
+		active:      make(map[string]*activeRun),
+		outputs:     make(map[string]*output),
+	}
+	r.loadHistory()
+	return r
+}
+
+// SetListener must be called before Run.
+func (r *Runner) SetListener(l Listener) {
+	r.listener = l
+}
+
+// SetOnFinish sets a callback invoked when a run finishes (for cleanup coordination).
+func (r *Runner) SetOnFinish(fn func()) {
+	r.onFinish = fn
+}
+
+// Tasks returns the tasks declared in the worktree, or in the main worktree if it has no config.
+func (r *Runner) Tasks() ([]Task, error) {
+	return LoadTasks(r.workDir, r.mainDir)
+}
+
+// Run starts the named task in the background.
+func (r *Runner) Run(name string) (Run, error) {
+	tasks, err := r.Tasks()
+	if err != nil {
+		return Run{}, err
+	}
+	var task *Task
+	for i := range tasks {
+		if tasks[i].Name == name {
+			task = &tasks[i]
+			break
+		}
+	}
+	if task == nil {
+		return Run{}, fmt.Errorf("%w: %s", ErrTaskNotFound, name)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	active := &activeRun{
+		run: Run{
+			ID:        uuid.Must(uuid.NewV7()).String(),
+			Task:      task.Name,
+			Command:   task.Command,
+			State:     StateRunning,
+			StartedAt: time.Now(),
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	r.mu.Lock()
+	r.active[active.run.ID] = active
+	r.outputs[active.run.ID] = newOutput(MaxOutputSize)
+	r.mu.Unlock()
+	r.notifyStatus(active.run)
+
+	go func() {
+		defer close(active.done)
+		defer cancel()
+		err := r.execute(ctx, active, task)
+		r.finish(active, err)
+	}()
+
+	slog.Info("task started", "task", task.Name, "runId", active.run.ID, "worktree", r.name)
+	return active.run, nil
+}
+
+func (r *Runner) execute(ctx context.Context, active *activeRun, task *Task) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", task.Command)
+	cmd.Dir = filepath.Join(r.workDir, task.Cwd)
+	cmd.Env = append(os.Environ(),
+		"POCKODE_MAIN_DIR="+r.mainDir,
+		"POCKODE_WORKTREE="+r.name,
+	)
+	keys := make([]string, 0, len(task.Env))
+	for key := range task.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		cmd.Env = append(cmd.Env, key+"="+task.Env[key])
+	}
+	killOnCancel(cmd)
+	cmd.WaitDelay = waitDelay
+
+	stdoutReader, stdoutWriter := io.Pipe()
+	stderrReader, stderrWriter := io.Pipe()
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderrWriter
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go r.streamOutput(&wg, active.run.ID, "stdout", stdoutReader)
+	go r.streamOutput(&wg, active.run.ID, "stderr", stderrReader)
+
+	err := cmd.Start()
+	if err == nil {
+		r.mu.Lock()
+		active.pid = cmd.Process.Pid
+		r.mu.Unlock()
+		err = cmd.Wait()
+	}
+	stdoutWriter.Close()
+	stderrWriter.Close()
+	wg.Wait()
+	return err
+}
+
+// streamOutput forwards output line by line. Lines longer than outputChunkSize
+// are split, and a partial line is flushed after partialLineDelay.
+func (r *Runner) streamOutput(wg *sync.WaitGroup, runID, stream string, reader io.Reader) {
+	defer wg.Done()
+
+	chunks := make(chan []byte)
+	go func() {
+		defer close(chunks)
+		for {
+			buf := make([]byte, outputChunkSize)
+			n, err := reader.Read(buf)
+			if n > 0 {
+				chunks <- buf[:n]
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	flush := time.NewTimer(partialLineDelay)
+	flush.Stop()
+	defer flush.Stop()
+	flushPending := false
+
+	var pending []byte
+	for {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				r.writeOutput(runID, stream, pending)
+				return
+			}
+			pending = append(pending, chunk...)
+			for {
+				end := bytes.IndexByte(pending, '\n') + 1
+				if end == 0 && len(pending) < outputChunkSize {
+					break
+				}
+				if end == 0 || end > outputChunkSize {
+					end = outputChunkSize
+				}
+				r.writeOutput(runID, stream, pending[:end])
+				pending = pending[end:]
+			}
+		case <-flush.C:
+			flushPending = false
+			r.writeOutput(runID, stream, pending)
+			pending = nil
+		}
+
+		// The delay counts from the first byte of the line, so output redrawn
+		// with \r keeps being shown
+		switch {
+		case len(pending) == 0 && flushPending:
+			flush.Stop()
+			flushPending = false
+		case len(pending) > 0 && !flushPending:
+			flush.Reset(partialLineDelay)
+			flushPending = true
+		}
+	}
+}
+
+func (r *Runner) writeOutput(runID, stream string, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	text := string(data)
+	r.mu.Lock()
+	if out, ok := r.outputs[runID]; ok {
+		out.write(text)
+	}
+	r.mu.Unlock()
+	if r.listener != nil {
+		r.listener.OnTaskOutput(runID, stream, text)
+	}
+}
+
+func (r *Runner) finish(active *activeRun, err error) {
+	r.mu.Lock()
+	run := active.run
+	now := time.Now()
+	run.FinishedAt = &now
+	run.DurationMs = now.Sub(run.StartedAt).Milliseconds()
+
+	var exitErr *exec.ExitError
+	switch {
+	case active.cancelled:
+		run.State = StateCancelled
+	case err == nil:
+		run.State = StateSucceeded
+	default:
+		run.State = StateFailed
+		if !errors.As(err, &exitErr) {
+			// The command could not be started
+			run.Error = err.Error()
+		}
+	}
+	if err == nil || exitErr != nil {
+		code := 0
+		if exitErr != nil {
+			code = exitErr.ExitCode()
+		}
+		run.ExitCode = &code
+	}
+
+	delete(r.active, run.ID)
+	r.history = append([]Run{run}, r.history...)
+	if len(r.history) > MaxHistory {
+		for _, old := range r.history[MaxHistory:] {
+			delete(r.outputs, old.ID)
+		}
+		r.history = r.history[:MaxHistory]
+	}
+	r.mu.Unlock()
+
+	r.saveHistory()
+	r.notifyStatus(run)
+	slog.Info("task finished", "task", run.Task, "runId", run.ID, "state", run.State, "duration", time.Duration(run.DurationMs)*time.Millisecond)
+
+	if r.onFinish != nil {
+		r.onFinish()
+	}
+}
+
+// Cancel stops a running task.
+func (r *Runner) Cancel(runID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	active, ok := r.active[runID]
+	if !ok {
+		for _, run := range r.history {
+			if run.ID == runID {
+				return ErrRunFinished
+			}
+		}
+		return ErrRunNotFound
+	}
+	active.cancelled = true
+	active.cancel()
+	return nil
+}
+
+// History returns the running and recent runs, newest first.
+func (r *Runner) History() []Run {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	runs := make([]Run, 0, len(r.active)+len(r.history))
+	for _, active := range r.active {
+		runs = append(runs, active.run)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartedAt.After(runs[j].StartedAt)
+	})
+	return append(runs, r.history...)
+}
+
+// Get returns a run and its retained output. Output is not kept across restarts.
+func (r *Runner) Get(runID string) (Run, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var out string
+	if o, ok := r.outputs[runID]; ok {
+		out = o.String()
+	}
+	if active, ok := r.active[runID]; ok {
+		return active.run, out, nil
+	}
+	for _, run := range r.history {
+		if run.ID == runID {
+			return run, out, nil
+		}
+	}
+	return Run{}, "", ErrRunNotFound
+}
+
+// PIDs returns the shell processes of the runs in progress.
+func (r *Runner) PIDs() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pids := make([]int, 0, len(r.active))
+	for _, active := range r.active {
+		if active.pid != 0 {
+			pids = append(pids, active.pid)
+		}
+	}
+	return pids
+}
+
+// RunningCount returns the number of runs in progress.
+func (r *Runner) RunningCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.active)
+}
+
+// Shutdown cancels all runs and waits until their results are recorded, so the
+// history file is not written after the worktree's data is removed.
+func (r *Runner) Shutdown() {
+	r.mu.Lock()
+	runs := make([]*activeRun, 0, len(r.active))
+	for _, active := range r.active {
+		active.cancelled = true
+		active.cancel()
+		runs = append(runs, active)
+	}
+	r.mu.Unlock()
+
+	for _, active := range runs {
+		<-active.done
+	}
+}
+
+func (r *Runner) notifyStatus(run Run) {
+	if r.listener != nil {
+		r.listener.OnTaskStatus(run)
+	}
+}
+
+func (r *Runner) loadHistory() {
+	data, err := os.ReadFile(r.historyPath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("failed to read task history", "path", r.historyPath, "error", err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &r.history); err != nil {
+		slog.Warn("failed to parse task history", "path", r.historyPath, "error", err)
+		r.history = nil
+	}
+}
+
+func (r *Runner) saveHistory() {
+	r.saveMu.Lock()
+	defer r.saveMu.Unlock()
+
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.history, "", "  ")
+	r.mu.Unlock()
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(r.historyPath), 0755); err == nil {
+			err = os.WriteFile(r.historyPath, data, 0644)
+		}
+	}
+	if err != nil {
+		slog.Warn("failed to save task history", "path", r.historyPath, "error", err)
+	}
+}
+
+// output keeps the most recent output of a run, up to max bytes, dropping whole lines.
+type output struct {
+	lines []string
+	size  int
+	max   int
+}
+
+func newOutput(max int) *output {
+	return &output{max: max}
+}
+
+func (o *output) write(line string) {
+	o.lines = append(o.lines, line)
+	o.size += len(line)
+	for o.size > o.max && len(o.lines) > 1 {
+		o.size -= len(o.lines[0])
+		o.lines = o.lines[1:]
+	}
+}
+
+func (o *output) String() string {
+	return strings.Join(o.lines, "")
+}
@@ -0,0 +1,273 @@
+package task
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, dir, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(dir, ".pockode"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ConfigFile), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type recordingListener struct {
+	mu       sync.Mutex
+	output   map[string][]string // runID -> "stream: data"
+	finished chan Run
+}
+
+func newRecordingListener() *recordingListener {
+	return &recordingListener{output: make(map[string][]string), finished: make(chan Run, 10)}
+}
+
+func (l *recordingListener) OnTaskOutput(runID, stream, data string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.output[runID] = append(l.output[runID], stream+": "+data)
+}
+
+func (l *recordingListener) OnTaskStatus(run Run) {
+	if run.State != StateRunning {
+		l.finished <- run
+	}
+}
+
+func (l *recordingListener) waitFinished(t *testing.T) Run {
+	t.Helper()
+	select {
+	case run := <-l.finished:
+		return run
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not finish")
+		return Run{}
+	}
+}
+
+func TestLoadTasks(t *testing.T) {
+	workDir := t.TempDir()
+	mainDir := t.TempDir()
+
+	tasks, err := LoadTasks(workDir, mainDir)
+	if err != nil || len(tasks) != 0 {
+		t.Fatalf("expected no tasks without config, got %v, %v", tasks, err)
+	}
+
+	writeConfig(t, mainDir, `{"tasks": [{"name": "test", "command": "go test ./..."}]}`)
+	tasks, err = LoadTasks(workDir, mainDir)
+	if err != nil || len(tasks) != 1 || tasks[0].Name != "test" {
+		t.Fatalf("expected the main worktree config, got %v, %v", tasks, err)
+	}
+
+	// The worktree's own config takes precedence
+	writeConfig(t, workDir, `{"tasks": [{"name": "lint", "command": "make lint"}]}`)
+	tasks, err = LoadTasks(workDir, mainDir)
+	if err != nil || len(tasks) != 1 || tasks[0].Name != "lint" {
+		t.Fatalf("expected the worktree config, got %v, %v", tasks, err)
+	}
+
+	invalid := map[string]string{
+		"no name":    `{"tasks": [{"command": "true"}]}`,
+		"duplicate":  `{"tasks": [{"name": "a", "command": "true"}, {"name": "a", "command": "false"}]}`,
+		"no command": `{"tasks": [{"name": "a"}]}`,
+		"escape cwd": `{"tasks": [{"name": "a", "command": "true", "cwd": "../other"}]}`,
+		"malformed":  `{"tasks": [`,
+	}
+	for name, content := range invalid {
+		writeConfig(t, workDir, content)
+		if _, err := LoadTasks(workDir); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestRunner_Run(t *testing.T) {
+	workDir := t.TempDir()
+	os.Mkdir(filepath.Join(workDir, "sub"), 0755)
+	writeConfig(t, workDir, `{"tasks": [
+		{"name": "ok", "command": "pwd; echo $GREETING; echo oops >&2", "cwd": "sub", "env": {"GREETING": "hello"}},
+		{"name": "fail", "command": "exit 3"}
+	]}`)
+
+	r := NewRunner("", workDir, workDir, t.TempDir())
+	listener := newRecordingListener()
+	r.SetListener(listener)
+
+	run, err := r.Run("ok")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if run.State != StateRunning || run.Task != "ok" {
+		t.Errorf("unexpected run: %+v", run)
+	}
+	finished := listener.waitFinished(t)
+	if finished.State != StateSucceeded || finished.ExitCode == nil || *finished.ExitCode != 0 || finished.FinishedAt == nil {
+		t.Errorf("unexpected finished run: %+v", finished)
+	}
+
+	listener.mu.Lock()
+	output := strings.Join(listener.output[run.ID], "")
+	listener.mu.Unlock()
+	for _, want := range []string{"stdout: " + filepath.Join(workDir, "sub") + "\n", "stdout: hello\n", "stderr: oops\n"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output %q does not contain %q", output, want)
+		}
+	}
+	_, retained, err := r.Get(run.ID)
+	if err != nil || !strings.Contains(retained, "hello\n") {
+		t.Errorf("expected retained output, got %q, %v", retained, err)
+	}
+
+	r.Run("fail")
+	finished = listener.waitFinished(t)
+	if finished.State != StateFailed || finished.ExitCode == nil || *finished.ExitCode != 3 {
+		t.Errorf("unexpected failed run: %+v", finished)
+	}
+
+	if _, err := r.Run("missing"); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("expected ErrTaskNotFound, got %v", err)
+	}
+}
+
+func TestRunner_Cancel(t *testing.T) {
+	workDir := t.TempDir()
+	writeConfig(t, workDir, `{"tasks": [{"name": "slow", "command": "sleep 30"}]}`)
+
+	r := NewRunner("", workDir, workDir, t.TempDir())
+	listener := newRecordingListener()
+	r.SetListener(listener)
+
+	run, err := r.Run("slow")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if r.RunningCount() != 1 {
+		t.Errorf("RunningCount = %d, want 1", r.RunningCount())
+	}
+	if err := r.Cancel(run.ID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+
+	finished := listener.waitFinished(t)
+	if finished.State != StateCancelled {
+		t.Errorf("expected cancelled, got %+v", finished)
+	}
+	if finished.DurationMs >= 30000 {
+		t.Errorf("cancel did not stop the command: %+v", finished)
+	}
+	if err := r.Cancel(run.ID); !errors.Is(err, ErrRunFinished) {
+		t.Errorf("expected ErrRunFinished, got %v", err)
+	}
+	if err := r.Cancel("unknown"); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("expected ErrRunNotFound, got %v", err)
+	}
+}
+
+func TestRunner_HistoryIsPersisted(t *testing.T) {
+	workDir := t.TempDir()
+	dataDir := t.TempDir()
+	writeConfig(t, workDir, `{"tasks": [{"name": "a", "command": "true"}, {"name": "b", "command": "true"}]}`)
+
+	r := NewRunner("", workDir, workDir, dataDir)
+	listener := newRecordingListener()
+	r.SetListener(listener)
+	r.Run("a")
+	listener.waitFinished(t)
+	r.Run("b")
+	listener.waitFinished(t)
+
+	reloaded := NewRunner("", workDir, workDir, dataDir)
+	history := reloaded.History()
+	if len(history) != 2 || history[0].Task != "b" || history[1].Task != "a" {
+		t.Fatalf("expected both runs newest first, got %+v", history)
+	}
+	if history[0].State != StateSucceeded {
+		t.Errorf("unexpected state: %+v", history[0])
+	}
+}
+
+func TestRunner_ShutdownWaitsForRuns(t *testing.T) {
+	workDir := t.TempDir()
+	dataDir := t.TempDir()
+	writeConfig(t, workDir, `{"tasks": [{"name": "slow", "command": "sleep 30"}]}`)
+
+	r := NewRunner("", workDir, workDir, dataDir)
+	if _, err := r.Run("slow"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	r.Shutdown()
+
+	if r.RunningCount() != 0 {
+		t.Errorf("RunningCount = %d after Shutdown, want 0", r.RunningCount())
+	}
+	// Nothing may write to the data directory once Shutdown returns
+	if err := os.RemoveAll(dataDir); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := os.Stat(dataDir); !os.IsNotExist(err) {
+		t.Errorf("data directory recreated after Shutdown: %v", err)
+	}
+}
+
+func TestRunner_OutputChunks(t *testing.T) {
+	workDir := t.TempDir()
+	// A 200KB line, then a partial line left open for a while
+	writeConfig(t, workDir, `{"tasks": [
+		{"name": "long", "command": "head -c 204800 /dev/zero | tr '\\0' x; echo; echo after"},
+		{"name": "progress", "command": "printf 'progress 50%%'; sleep 1; echo"}
+	]}`)
+
+	r := NewRunner("", workDir, workDir, t.TempDir())
+	listener := newRecordingListener()
+	r.SetListener(listener)
+
+	run, err := r.Run("long")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	listener.waitFinished(t)
+	listener.mu.Lock()
+	chunks := listener.output[run.ID]
+	listener.mu.Unlock()
+	output := strings.Join(chunks, "")
+	if n := strings.Count(output, "x"); n != 204800 {
+		t.Errorf("got %d bytes of the long line, want 204800", n)
+	}
+	if !strings.HasSuffix(output, "stdout: after\n") {
+		t.Errorf("output after the long line was lost: ...%q", output[max(len(output)-40, 0):])
+	}
+	for _, chunk := range chunks {
+		if len(chunk) > outputChunkSize+len("stdout: ") {
+			t.Errorf("chunk of %d bytes exceeds the chunk size", len(chunk))
+		}
+	}
+
+	run, err = r.Run("progress")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	deadline := time.Now().Add(700 * time.Millisecond)
+	for {
+		listener.mu.Lock()
+		got := strings.Join(listener.output[run.ID], "")
+		listener.mu.Unlock()
+		if got == "stdout: progress 50%" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("partial line not flushed before the newline, got %q", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	listener.waitFinished(t)
+}
@@ -0,0 +1,21 @@
+//go:build !linux && !darwin
+
+package terminal
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+func startPTY(cmd *exec.Cmd, cols, rows int) (*os.File, error) {
+	return nil, errUnsupported
+}
+
+func setSize(master *os.File, cols, rows int) error {
+	return errUnsupported
+}
+
+func signalGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	return errUnsupported
+}
@@ -0,0 +1,59 @@
+//go:build linux || darwin
+
+package terminal
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// startPTY starts cmd as a session leader with a new pseudo-terminal as its
+// controlling terminal, and returns the master side.
+func startPTY(cmd *exec.Cmd, cols, rows int) (*os.File, error) {
+	master, slavePath, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+	if err := setSize(master, cols, rows); err != nil {
+		master.Close()
+		return nil, err
+	}
+
+	slave, err := os.OpenFile(slavePath, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, err
+	}
+	// The child holds its own copy; the master sees EOF once every copy is closed
+	defer slave.Close()
+
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid:  true,
+		Setctty: true,
+		Ctty:    0, // stdin in the child
+	}
+	if err := cmd.Start(); err != nil {
+		master.Close()
+		return nil, err
+	}
+	return master, nil
+}
+
+func setSize(master *os.File, cols, rows int) error {
+	return unix.IoctlSetWinsize(int(master.Fd()), unix.TIOCSWINSZ, &unix.Winsize{
+		Col: uint16(cols),
+		Row: uint16(rows),
+	})
+}
+
+// signalGroup signals the process group led by the terminal's shell, so that
+// jobs started from it are signalled too.
+func signalGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	return syscall.Kill(-cmd.Process.Pid, sig)
+}
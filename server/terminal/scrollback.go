@@ -0,0 +1,35 @@
+package terminal
+
+import "unicode/utf8"
+
+// scrollback keeps the most recent output of a terminal, up to max bytes.
+type scrollback struct {
+	buf []byte
+	max int
+}
+
+func newScrollback(max int) *scrollback {
+	return &scrollback{max: max}
+}
+
+func (s *scrollback) Write(p []byte) {
+	if len(p) >= s.max {
+		s.buf = append(s.buf[:0], p[len(p)-s.max:]...)
+		return
+	}
+	if over := len(s.buf) + len(p) - s.max; over > 0 {
+		s.buf = append(s.buf[:0], s.buf[over:]...)
+	}
+	s.buf = append(s.buf, p...)
+}
+
+// String returns the retained output. Dropping old output may have cut a
+// character in half; the remains are skipped so the result starts on a
+// character boundary.
+func (s *scrollback) String() string {
+	start := 0
+	for start < len(s.buf) && start < utf8.UTFMax && !utf8.RuneStart(s.buf[start]) {
+		start++
+	}
+	return string(s.buf[start:])
+}
@@ -0,0 +1,433 @@
+// Package terminal runs interactive shells on pseudo-terminals and keeps their
+// recent output so clients can reattach after reconnecting.
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultCols = 80
+	DefaultRows = 24
+	// MaxDimension caps the columns and rows of a terminal.
+	MaxDimension = 1000
+	// ScrollbackSize is the output retained per terminal for reattaching.
+	ScrollbackSize = 256 * 1024
+	// MaxTerminals caps the terminals (running or exited) open per worktree.
+	MaxTerminals = 16
+
+	readBufferSize = 32 * 1024
+	// closeGracePeriod is how long a closed shell may take to exit on SIGHUP before it is killed.
+	closeGracePeriod = 2 * time.Second
+	// exitDrainTimeout is how long output is still read after the shell exits;
+	// background jobs may keep the terminal open indefinitely.
+	exitDrainTimeout = 500 * time.Millisecond
+)
+
+var (
+	ErrNotFound         = errors.New("terminal not found")
+	ErrExited           = errors.New("terminal has exited")
+	ErrShellNotFound    = errors.New("shell not found")
+	ErrTooManyTerminals = fmt.Errorf("too many terminals (max %d)", MaxTerminals)
+	ErrInvalidSize      = fmt.Errorf("terminal size must be between 1 and %d", MaxDimension)
+	errUnsupported      = errors.New("terminals are not supported on this platform")
+)
+
+// Listener receives terminal output and exits. Calls are made with the terminal
+// locked (see Terminal.Attach), in output order, and must not block: a blocked
+// listener stalls the shell and every caller of Info, Attach and Resize.
+type Listener interface {
+	OnTerminalOutput(id, data string)
+	OnTerminalExit(id string, exitCode int)
+}
+
+// Info describes a terminal.
+type Info struct {
+	ID        string
+	Shell     string
+	Cols      int
+	Rows      int
+	CreatedAt time.Time
+	Exited    bool
+	ExitCode  int // -1 if the shell was killed by a signal
+}
+
+// OpenOptions configures a new terminal. Zero sizes use DefaultCols and DefaultRows.
+type OpenOptions struct {
+	Cols  int
+	Rows  int
+	Shell string // default $SHELL, or /bin/sh
+}
+
+// Terminal is a shell running on a pseudo-terminal.
+type Terminal struct {
+	id        string
+	shell     string
+	createdAt time.Time
+	cmd       *exec.Cmd
+	pty       *os.File
+	listener  Listener
+	done      chan struct{}
+
+	mu         sync.Mutex
+	cols       int
+	rows       int
+	scrollback *scrollback
+	exited     bool
+	exitCode   int
+}
+
+func (t *Terminal) ID() string {
+	return t.id
+}
+
+func (t *Terminal) Info() Info {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.infoLocked()
+}
+
+func (t *Terminal) infoLocked() Info {
+	return Info{
+		ID:        t.id,
+		Shell:     t.shell,
+		Cols:      t.cols,
+		Rows:      t.rows,
+		CreatedAt: t.createdAt,
+		Exited:    t.exited,
+		ExitCode:  t.exitCode,
+	}
+}
+
+// Attach calls fn with the terminal's info and scrollback. No output is emitted
+// to the listener while fn runs, so a subscription registered in fn receives
+// exactly the output following the scrollback.
+func (t *Terminal) Attach(fn func(info Info, scrollback string)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fn(t.infoLocked(), t.scrollback.String())
+}
+
+// Write sends input to the shell.
+func (t *Terminal) Write(data string) error {
+	if t.isExited() {
+		return ErrExited
+	}
+	_, err := t.pty.WriteString(data)
+	return err
+}
+
+// Resize changes the terminal size; the shell receives SIGWINCH.
+func (t *Terminal) Resize(cols, rows int) error {
+	if !validSize(cols, rows) {
+		return ErrInvalidSize
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.exited {
+		return ErrExited
+	}
+	if err := setSize(t.pty, cols, rows); err != nil {
+		return err
+	}
+	t.cols, t.rows = cols, rows
+	return nil
+}
+
+func (t *Terminal) isExited() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.exited
+}
+
+func (t *Terminal) output(data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.scrollback.Write(data)
+	if t.listener != nil {
+		t.listener.OnTerminalOutput(t.id, string(data))
+	}
+}
+
+func (t *Terminal) readLoop(readDone chan<- struct{}) {
+	defer close(readDone)
+	buf := make([]byte, readBufferSize)
+	var pending []byte
+	for {
+		n, err := t.pty.Read(buf)
+		if n > 0 {
+			// Output is sent as text: hold back a character split across reads
+			data := append(pending, buf[:n]...)
+			end := completeRunes(data)
+			if end > 0 {
+				t.output(data[:end])
+			}
+			pending = append([]byte(nil), data[end:]...)
+		}
+		if err != nil {
+			// EIO once the shell and its jobs closed the terminal, or the master was closed
+			if len(pending) > 0 {
+				t.output(pending)
+			}
+			return
+		}
+	}
+}
+
+func (t *Terminal) wait(readDone <-chan struct{}, onExit func()) {
+	t.cmd.Wait()
+
+	select {
+	case <-readDone:
+	case <-time.After(exitDrainTimeout):
+	}
+	t.pty.Close()
+	<-readDone
+
+	exitCode := t.cmd.ProcessState.ExitCode()
+	t.mu.Lock()
+	t.exited = true
+	t.exitCode = exitCode
+	if t.listener != nil {
+		t.listener.OnTerminalExit(t.id, exitCode)
+	}
+	t.mu.Unlock()
+	close(t.done)
+
+	slog.Info("terminal exited", "id", t.id, "exitCode", exitCode)
+	if onExit != nil {
+		onExit()
+	}
+}
+
+// kill hangs up the shell and kills it if it is still running after closeGracePeriod.
+func (t *Terminal) kill() {
+	if t.isExited() {
+		return
+	}
+	if err := signalGroup(t.cmd, syscall.SIGHUP); err != nil {
+		slog.Debug("failed to hang up terminal", "id", t.id, "error", err)
+	}
+	go func() {
+		select {
+		case <-t.done:
+		case <-time.After(closeGracePeriod):
+			signalGroup(t.cmd, syscall.SIGKILL)
+		}
+	}()
+}
+
+// Manager manages the terminals of a worktree. Terminals outlive client
+// connections; an exited terminal stays listed, with its scrollback, until closed.
+type Manager struct {
+	workDir  string
+	listener Listener
+	onExit   func()
+
+	mu        sync.Mutex
+	terminals map[string]*Terminal
+}
+
+func NewManager(workDir string) *Manager {
+	return &Manager{
+		workDir:   workDir,
+		terminals: make(map[string]*Terminal),
+	}
+}
+
+// SetListener must be called before Open.
+func (m *Manager) SetListener(l Listener) {
+	m.listener = l
+}
+
+// SetOnExit sets a callback invoked when a terminal's shell exits (for cleanup coordination).
+func (m *Manager) SetOnExit(fn func()) {
+	m.onExit = fn
+}
+
+// Open starts a shell in the worktree directory.
+func (m *Manager) Open(opts OpenOptions) (*Terminal, error) {
+	cols, rows := opts.Cols, opts.Rows
+	if cols == 0 {
+		cols = DefaultCols
+	}
+	if rows == 0 {
+		rows = DefaultRows
+	}
+	if !validSize(cols, rows) {
+		return nil, ErrInvalidSize
+	}
+
+	shell := opts.Shell
+	if shell == "" {
+		shell = defaultShell()
+	}
+	shellPath, err := exec.LookPath(shell)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrShellNotFound, shell)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.terminals) >= MaxTerminals {
+		return nil, ErrTooManyTerminals
+	}
+
+	cmd := exec.Command(shellPath)
+	cmd.Dir = m.workDir
+	cmd.Env = append(os.Environ(), "TERM=xterm-256color", "COLORTERM=truecolor")
+
+	master, err := startPTY(cmd, cols, rows)
+	if err != nil {
+		return nil, fmt.Errorf("start terminal: %w", err)
+	}
+
+	t := &Terminal{
+		id:         uuid.Must(uuid.NewV7()).String(),
+		shell:      shell,
+		createdAt:  time.Now(),
+		cmd:        cmd,
+		pty:        master,
+		listener:   m.listener,
+		done:       make(chan struct{}),
+		cols:       cols,
+		rows:       rows,
+		scrollback: newScrollback(ScrollbackSize),
+	}
+	m.terminals[t.id] = t
+
+	readDone := make(chan struct{})
+	go t.readLoop(readDone)
+	go t.wait(readDone, m.onExit)
+
+	slog.Info("terminal opened", "id", t.id, "shell", shell, "pid", cmd.Process.Pid)
+	return t, nil
+}
+
+func (m *Manager) Get(id string) (*Terminal, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.terminals[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return t, nil
+}
+
+// List returns the terminals, oldest first.
+func (m *Manager) List() []Info {
+	m.mu.Lock()
+	terminals := make([]*Terminal, 0, len(m.terminals))
+	for _, t := range m.terminals {
+		terminals = append(terminals, t)
+	}
+	m.mu.Unlock()
+
+	infos := make([]Info, 0, len(terminals))
+	for _, t := range terminals {
+		infos = append(infos, t.Info())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if !infos[i].CreatedAt.Equal(infos[j].CreatedAt) {
+			return infos[i].CreatedAt.Before(infos[j].CreatedAt)
+		}
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
+
+// Close removes a terminal, hanging up its shell if it is still running.
+func (m *Manager) Close(id string) error {
+	m.mu.Lock()
+	t, ok := m.terminals[id]
+	delete(m.terminals, id)
+	m.mu.Unlock()
+
+	if !ok {
+		return ErrNotFound
+	}
+	t.kill()
+	slog.Info("terminal closed", "id", id)
+	return nil
+}
+
+// PIDs returns the shell processes of the running terminals.
+func (m *Manager) PIDs() []int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pids := make([]int, 0, len(m.terminals))
+	for _, t := range m.terminals {
+		if !t.isExited() {
+			pids = append(pids, t.cmd.Process.Pid)
+		}
+	}
+	return pids
+}
+
+// RunningCount returns the number of terminals whose shell is still running.
+func (m *Manager) RunningCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, t := range m.terminals {
+		if !t.isExited() {
+			count++
+		}
+	}
+	return count
+}
+
+// Shutdown closes all terminals and waits for their shells to exit.
+func (m *Manager) Shutdown() {
+	m.mu.Lock()
+	terminals := make([]*Terminal, 0, len(m.terminals))
+	for _, t := range m.terminals {
+		terminals = append(terminals, t)
+	}
+	m.terminals = make(map[string]*Terminal)
+	m.mu.Unlock()
+
+	for _, t := range terminals {
+		t.kill()
+	}
+	for _, t := range terminals {
+		<-t.done
+	}
+}
+
+func validSize(cols, rows int) bool {
+	return cols >= 1 && cols <= MaxDimension && rows >= 1 && rows <= MaxDimension
+}
+
+func defaultShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	return "/bin/sh"
+}
+
+// completeRunes returns the length of data without an incomplete UTF-8
+// sequence at its end.
+func completeRunes(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(data)
+}
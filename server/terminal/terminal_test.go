@@ -0,0 +1,195 @@
+package terminal
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingListener struct {
+	mu       sync.Mutex
+	output   strings.Builder
+	exitCode *int
+}
+
+func (l *recordingListener) OnTerminalOutput(id, data string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.output.WriteString(data)
+}
+
+func (l *recordingListener) OnTerminalExit(id string, exitCode int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.exitCode = &exitCode
+}
+
+func (l *recordingListener) waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		l.mu.Lock()
+		ok := cond()
+		l.mu.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	t.Fatalf("timed out; output so far: %q", l.output.String())
+}
+
+func openTestTerminal(t *testing.T, m *Manager) *Terminal {
+	t.Helper()
+	term, err := m.Open(OpenOptions{Cols: 100, Rows: 30, Shell: "/bin/sh"})
+	if err != nil {
+		t.Skipf("pseudo-terminals unavailable: %v", err)
+	}
+	return term
+}
+
+func TestManager_OpenWriteAndExit(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(dir)
+	listener := &recordingListener{}
+	m.SetListener(listener)
+	exited := make(chan struct{}, 1)
+	m.SetOnExit(func() { exited <- struct{}{} })
+	t.Cleanup(m.Shutdown)
+
+	term := openTestTerminal(t, m)
+	if m.RunningCount() != 1 {
+		t.Errorf("RunningCount = %d, want 1", m.RunningCount())
+	}
+
+	if err := term.Write("pwd; stty size\n"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	listener.waitFor(t, func() bool {
+		out := listener.output.String()
+		return strings.Contains(out, dir+"\r\n") && strings.Contains(out, "30 100")
+	})
+
+	if err := term.Write("exit 3\n"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shell did not exit")
+	}
+
+	listener.waitFor(t, func() bool { return listener.exitCode != nil && *listener.exitCode == 3 })
+	info := term.Info()
+	if !info.Exited || info.ExitCode != 3 {
+		t.Errorf("info = %+v, want exited with code 3", info)
+	}
+	if m.RunningCount() != 0 {
+		t.Errorf("RunningCount = %d, want 0", m.RunningCount())
+	}
+	// Exited terminals stay listed until closed
+	if list := m.List(); len(list) != 1 || list[0].ID != term.ID() {
+		t.Errorf("List = %+v, want the exited terminal", list)
+	}
+	if err := term.Write("echo\n"); err != ErrExited {
+		t.Errorf("Write after exit = %v, want ErrExited", err)
+	}
+}
+
+func TestManager_AttachReturnsScrollback(t *testing.T) {
+	m := NewManager(t.TempDir())
+	listener := &recordingListener{}
+	m.SetListener(listener)
+	t.Cleanup(m.Shutdown)
+
+	term := openTestTerminal(t, m)
+	term.Write("echo scroll$((1+1))back\n")
+	listener.waitFor(t, func() bool {
+		return strings.Contains(listener.output.String(), "scroll2back")
+	})
+
+	var scrollback string
+	term.Attach(func(info Info, s string) {
+		scrollback = s
+		if info.Cols != 100 || info.Rows != 30 {
+			t.Errorf("size = %dx%d, want 100x30", info.Cols, info.Rows)
+		}
+	})
+	if !strings.Contains(scrollback, "scroll2back") {
+		t.Errorf("scrollback = %q, want it to contain the output", scrollback)
+	}
+}
+
+func TestManager_ResizeAndClose(t *testing.T) {
+	m := NewManager(t.TempDir())
+	listener := &recordingListener{}
+	m.SetListener(listener)
+	t.Cleanup(m.Shutdown)
+
+	term := openTestTerminal(t, m)
+	if err := term.Resize(0, 10); err != ErrInvalidSize {
+		t.Errorf("Resize(0, 10) = %v, want ErrInvalidSize", err)
+	}
+	if err := term.Resize(120, 40); err != nil {
+		t.Fatalf("Resize: %v", err)
+	}
+	term.Write("stty size\n")
+	listener.waitFor(t, func() bool {
+		return strings.Contains(listener.output.String(), "40 120")
+	})
+
+	if err := m.Close(term.ID()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	listener.waitFor(t, func() bool { return listener.exitCode != nil })
+	if _, err := m.Get(term.ID()); err != ErrNotFound {
+		t.Errorf("Get after close = %v, want ErrNotFound", err)
+	}
+	if err := m.Close(term.ID()); err != ErrNotFound {
+		t.Errorf("second Close = %v, want ErrNotFound", err)
+	}
+}
+
+func TestScrollback(t *testing.T) {
+	s := newScrollback(8)
+	s.Write([]byte("abc"))
+	s.Write([]byte("defgh"))
+	if got := s.String(); got != "abcdefgh" {
+		t.Errorf("got %q", got)
+	}
+	s.Write([]byte("ij"))
+	if got := s.String(); got != "cdefghij" {
+		t.Errorf("got %q", got)
+	}
+	s.Write([]byte("0123456789"))
+	if got := s.String(); got != "23456789" {
+		t.Errorf("got %q", got)
+	}
+	// A dropped half of a multi-byte character is skipped
+	s.Write([]byte("→abcdef"))
+	if got := s.String(); got != "abcdef" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestCompleteRunes(t *testing.T) {
+	arrow := []byte("→")
+	tests := []struct {
+		data []byte
+		want int
+	}{
+		{[]byte("abc"), 3},
+		{append([]byte("ab"), arrow...), 5},
+		{append([]byte("ab"), arrow[:2]...), 2},
+		{arrow[:1], 0},
+		{[]byte{'a', 0xff}, 2},
+	}
+	for _, tt := range tests {
+		if got := completeRunes(tt.data); got != tt.want {
+			t.Errorf("completeRunes(%q) = %d, want %d", tt.data, got, tt.want)
+		}
+	}
+}
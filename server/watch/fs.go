@@ -2,41 +2,120 @@ package watch
 
 import (
 	"context"
+	"errors"
+	"io/fs"
 	"log/slog"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/pockode/server/rpc"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
-const debounceInterval = 100 * time.Millisecond
+const (
+	debounceInterval = 100 * time.Millisecond
+	// fsMaxDelay bounds the debounce so a continuous stream of writes still notifies.
+	fsMaxDelay = time.Second
+	// fsMaxWatches caps directory watches per worktree, shared by all subscriptions.
+	fsMaxWatches = 4096
+)
+
+var ErrWatchBudgetExceeded = errors.New("too many directories to watch")
+
+// FSChangeListener is notified of every change (paths relative to the workDir),
+// before debouncing.
+type FSChangeListener interface {
+	OnFSChange(change rpc.FSChange)
+}
+
+// fsSubscription is the watch state of one subscription. Changes are queued in
+// pending until the next flush.
+type fsSubscription struct {
+	id        string
+	path      string
+	recursive bool
+	dirs      map[string]bool // watched paths owned by this subscription
 
-// FSWatcher watches filesystem changes via fsnotify and notifies subscribers.
+	pending    []rpc.FSChange
+	pendingIdx map[string]int // path -> index in pending
+}
+
+// covers reports whether a change to path concerns the subscription.
+func (s *fsSubscription) covers(path string) bool {
+	if path == s.path || parentPath(path) == s.path {
+		return true
+	}
+	return s.recursive && (s.path == "" || strings.HasPrefix(path, s.path+"/"))
+}
+
+// queue records a change, merging it with an earlier change to the same path.
+func (s *fsSubscription) queue(change rpc.FSChange) {
+	i, ok := s.pendingIdx[change.Path]
+	if !ok {
+		s.pendingIdx[change.Path] = len(s.pending)
+		s.pending = append(s.pending, change)
+		return
+	}
+
+	prev := s.pending[i].Op
+	switch {
+	case prev == rpc.FSOpCreate && change.Op == rpc.FSOpWrite:
+		// Still a new file
+	case prev == rpc.FSOpCreate && (change.Op == rpc.FSOpRemove || change.Op == rpc.FSOpRename):
+		// Temporary file the client never saw; dropped on flush
+		s.pending[i].Op = ""
+	case (prev == rpc.FSOpRemove || prev == rpc.FSOpRename) && change.Op == rpc.FSOpCreate:
+		// Replaced, e.g. by an editor's atomic save
+		s.pending[i].Op = rpc.FSOpWrite
+	case prev == "" && change.Op == rpc.FSOpWrite:
+		s.pending[i].Op = rpc.FSOpCreate
+	default:
+		s.pending[i].Op = change.Op
+	}
+}
+
+// takePending returns the queued changes and resets the queue.
+func (s *fsSubscription) takePending() []rpc.FSChange {
+	changes := make([]rpc.FSChange, 0, len(s.pending))
+	for _, c := range s.pending {
+		if c.Op != "" {
+			changes = append(changes, c)
+		}
+	}
+	s.pending = nil
+	s.pendingIdx = make(map[string]int)
+	return changes
+}
+
+// FSWatcher watches filesystem changes via fsnotify and notifies subscribers with
+// the changed paths. Bursts of changes are batched into one notification.
 type FSWatcher struct {
 	*BaseWatcher
-	workDir string
-	watcher *fsnotify.Watcher
+	workDir    string
+	watcher    *fsnotify.Watcher
+	listener   FSChangeListener
+	maxWatches int
 
-	pathMu       sync.RWMutex
-	pathToIDs    map[string][]string // path -> subscription IDs
-	idToPath     map[string]string   // subscription ID -> path
-	pathRefCount map[string]int
+	mu        sync.Mutex
+	subs      map[string]*fsSubscription // subscription ID -> state
+	watchRefs map[string]int             // watched path -> number of owning subscriptions
 
-	timerMu  sync.Mutex
-	timerMap map[string]*time.Timer
+	flushTimer   *time.Timer
+	pendingSince time.Time
 }
 
 func NewFSWatcher(workDir string) *FSWatcher {
 	return &FSWatcher{
-		BaseWatcher:  NewBaseWatcher("f"),
-		workDir:      workDir,
-		pathToIDs:    make(map[string][]string),
-		idToPath:     make(map[string]string),
-		pathRefCount: make(map[string]int),
-		timerMap:     make(map[string]*time.Timer),
+		BaseWatcher: NewBaseWatcher("f"),
+		workDir:     workDir,
+		maxWatches:  fsMaxWatches,
+		subs:        make(map[string]*fsSubscription),
+		watchRefs:   make(map[string]int),
 	}
 }
 
@@ -52,117 +131,188 @@ func (w *FSWatcher) Start() error {
 	return nil
 }
 
+// SetChangeListener registers a listener for changed paths. Must be called before Start.
+func (w *FSWatcher) SetChangeListener(listener FSChangeListener) {
+	w.listener = listener
+}
+
 func (w *FSWatcher) Stop() {
 	w.Cancel()
 	if w.watcher != nil {
 		w.watcher.Close()
 	}
 
-	// Cancel any pending debounce timers
-	w.timerMu.Lock()
-	for _, timer := range w.timerMap {
-		timer.Stop()
+	w.mu.Lock()
+	if w.flushTimer != nil {
+		w.flushTimer.Stop()
 	}
-	w.timerMap = make(map[string]*time.Timer)
-	w.timerMu.Unlock()
+	w.mu.Unlock()
 
 	slog.Info("FSWatcher stopped")
 }
 
-func (w *FSWatcher) Subscribe(path string, conn *jsonrpc2.Conn, connID string) (string, error) {
+// Subscribe watches path, and with recursive all directories below it that are not
+// ignored. Returns ErrWatchBudgetExceeded if that would exceed the watch budget.
+func (w *FSWatcher) Subscribe(path string, recursive bool, conn *jsonrpc2.Conn, connID string) (string, error) {
 	id := w.GenerateID()
+	path = cleanRelPath(path)
 
-	fullPath := filepath.Join(w.workDir, path)
-	if _, err := os.Stat(fullPath); err != nil {
+	info, err := os.Stat(filepath.Join(w.workDir, path))
+	if err != nil {
 		return "", err
 	}
+	recursive = recursive && info.IsDir()
 
-	sub := &Subscription{ID: id, ConnID: connID, Conn: conn}
-
-	// Lock order: pathMu → subMu (consistent with Unsubscribe/CleanupConnection)
-	w.pathMu.Lock()
+	paths := []string{path}
+	if recursive {
+		paths = w.collectDirs(path)
+	}
 
-	// Start fsnotify watch if first subscriber for this path
-	if w.pathRefCount[path] == 0 {
-		if err := w.watcher.Add(fullPath); err != nil {
-			w.pathMu.Unlock()
-			return "", err
-		}
-		slog.Debug("started watching path", "path", path)
+	sub := &fsSubscription{
+		id:         id,
+		path:       path,
+		recursive:  recursive,
+		dirs:       make(map[string]bool),
+		pendingIdx: make(map[string]int),
 	}
 
-	w.pathToIDs[path] = append(w.pathToIDs[path], id)
-	w.idToPath[id] = path
-	w.pathRefCount[path]++
-	w.pathMu.Unlock()
+	// Lock order: mu → subMu (consistent with Unsubscribe/CleanupConnection)
+	w.mu.Lock()
+	if err := w.addWatches(sub, paths); err != nil {
+		w.releaseWatches(sub, nil)
+		w.mu.Unlock()
+		return "", err
+	}
+	w.subs[id] = sub
+	w.mu.Unlock()
 
 	// Add to BaseWatcher after path mapping is set up
-	w.AddSubscription(sub)
+	w.AddSubscription(&Subscription{ID: id, ConnID: connID, Conn: conn})
 
+	slog.Debug("started watching path", "path", path, "recursive", recursive, "dirs", len(sub.dirs))
 	return id, nil
 }
 
 // Unsubscribe overrides BaseWatcher.Unsubscribe to also clean up fsnotify watches.
 func (w *FSWatcher) Unsubscribe(id string) {
-	w.pathMu.Lock()
-	path, ok := w.idToPath[id]
-	if ok {
-		w.removePathMapping(id, path)
-	}
-	w.pathMu.Unlock()
+	w.mu.Lock()
+	w.removeSub(id)
+	w.mu.Unlock()
 
 	w.RemoveSubscription(id)
 }
 
 func (w *FSWatcher) CleanupConnection(connID string) {
-	// Get subscription IDs first (releases subMu before acquiring pathMu)
+	// Get subscription IDs first (releases subMu before acquiring mu)
 	subs := w.GetSubscriptionsByConnID(connID)
 	if len(subs) == 0 {
 		return
 	}
 
-	// Collect IDs to avoid holding lock during iteration
-	ids := make([]string, len(subs))
-	for i, sub := range subs {
-		ids[i] = sub.ID
+	// Lock order: mu → subMu (consistent with Subscribe/Unsubscribe)
+	w.mu.Lock()
+	for _, sub := range subs {
+		w.removeSub(sub.ID)
 	}
-
-	// Lock order: pathMu → subMu (consistent with Subscribe/Unsubscribe)
-	w.pathMu.Lock()
-	for _, id := range ids {
-		if path, ok := w.idToPath[id]; ok {
-			w.removePathMapping(id, path)
-		}
-	}
-	w.pathMu.Unlock()
+	w.mu.Unlock()
 
 	w.BaseWatcher.CleanupConnection(connID)
 }
 
-// removePathMapping removes path tracking. Caller must hold pathMu.
-func (w *FSWatcher) removePathMapping(id, path string) {
-	delete(w.idToPath, id)
+// removeSub drops a subscription and its watches. Caller must hold mu.
+func (w *FSWatcher) removeSub(id string) {
+	sub, ok := w.subs[id]
+	if !ok {
+		return
+	}
+	w.releaseWatches(sub, nil)
+	delete(w.subs, id)
+}
+
+// WatchCount returns the number of watched paths.
+func (w *FSWatcher) WatchCount() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.watchRefs)
+}
 
-	ids := w.pathToIDs[path]
-	for i, v := range ids {
-		if v == id {
-			w.pathToIDs[path] = append(ids[:i], ids[i+1:]...)
-			break
+// addWatches adds paths to the subscription, starting fsnotify watches for paths
+// not watched yet. Caller must hold mu.
+func (w *FSWatcher) addWatches(sub *fsSubscription, paths []string) error {
+	for _, path := range paths {
+		if sub.dirs[path] {
+			continue
 		}
+		if w.watchRefs[path] == 0 {
+			if len(w.watchRefs) >= w.maxWatches {
+				return ErrWatchBudgetExceeded
+			}
+			if err := w.watcher.Add(filepath.Join(w.workDir, path)); err != nil {
+				// Directories may disappear while being added
+				if os.IsNotExist(err) {
+					continue
+				}
+				return err
+			}
+		}
+		w.watchRefs[path]++
+		sub.dirs[path] = true
 	}
-	if len(w.pathToIDs[path]) == 0 {
-		delete(w.pathToIDs, path)
-	}
+	return nil
+}
 
-	w.pathRefCount[path]--
-	if w.pathRefCount[path] == 0 {
-		fullPath := filepath.Join(w.workDir, path)
-		w.watcher.Remove(fullPath)
-		delete(w.pathRefCount, path)
-		slog.Debug("stopped watching path", "path", path)
+// releaseWatches drops the watches of sub for which match returns true (all if nil).
+// Caller must hold mu.
+func (w *FSWatcher) releaseWatches(sub *fsSubscription, match func(string) bool) {
+	for path := range sub.dirs {
+		if match != nil && !match(path) {
+			continue
+		}
+		delete(sub.dirs, path)
+		w.watchRefs[path]--
+		if w.watchRefs[path] == 0 {
+			delete(w.watchRefs, path)
+			// Fails harmlessly if the directory is gone
+			w.watcher.Remove(filepath.Join(w.workDir, path))
+		}
 	}
 }
 
+// collectDirs returns root and the directories below it, skipping .git and
+// directories excluded by .gitignore.
+func (w *FSWatcher) collectDirs(root string) []string {
+	// Outside a git repository nothing is ignored
+	ignored, _ := ignoredDirs(w.workDir, root)
+
+	var dirs []string
+	rootPath := filepath.Join(w.workDir, root)
+	filepath.WalkDir(rootPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() && path != rootPath {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != rootPath && (d.Name() == ".git" || ignored[path]) {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(w.workDir, path)
+		if err != nil {
+			return nil
+		}
+		dirs = append(dirs, cleanRelPath(rel))
+		// Stop walking early rather than listing a huge tree only to refuse it
+		if len(dirs) > w.maxWatches {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return dirs
+}
+
 func (w *FSWatcher) eventLoop() {
 	for {
 		select {
@@ -183,54 +333,236 @@ func (w *FSWatcher) eventLoop() {
 }
 
 func (w *FSWatcher) handleEvent(event fsnotify.Event) {
+	var op rpc.FSOp
+	switch {
+	case event.Has(fsnotify.Create):
+		op = rpc.FSOpCreate
+	case event.Has(fsnotify.Remove):
+		op = rpc.FSOpRemove
+	case event.Has(fsnotify.Rename):
+		op = rpc.FSOpRename
+	case event.Has(fsnotify.Write):
+		op = rpc.FSOpWrite
+	default:
+		// Permission changes do not change content
+		return
+	}
+
 	relPath, err := filepath.Rel(w.workDir, event.Name)
 	if err != nil {
 		slog.Error("failed to get relative path", "path", event.Name, "error", err)
 		return
 	}
+	relPath = cleanRelPath(relPath)
 
-	w.timerMu.Lock()
-	if timer, exists := w.timerMap[relPath]; exists {
-		timer.Stop()
+	switch op {
+	case rpc.FSOpCreate:
+		if info, err := os.Lstat(event.Name); err == nil && info.IsDir() {
+			w.watchNewDir(relPath)
+		}
+	case rpc.FSOpRemove, rpc.FSOpRename:
+		w.unwatchGone(relPath)
 	}
-	w.timerMap[relPath] = time.AfterFunc(debounceInterval, func() {
-		w.notifyPath(relPath)
-		w.timerMu.Lock()
-		delete(w.timerMap, relPath)
-		w.timerMu.Unlock()
-	})
-	w.timerMu.Unlock()
+
+	w.notify(op, relPath)
 }
 
-func (w *FSWatcher) notifyPath(path string) {
-	// Skip if watcher is stopped (timer may fire after Stop)
-	if w.Context().Err() != nil {
+// watchNewDir extends recursive subscriptions covering a new directory to it.
+func (w *FSWatcher) watchNewDir(path string) {
+	if filepath.Base(path) == ".git" || isIgnored(w.workDir, path) {
+		return
+	}
+
+	w.mu.Lock()
+	var subs []*fsSubscription
+	for _, sub := range w.subs {
+		if sub.recursive && sub.covers(path) {
+			subs = append(subs, sub)
+		}
+	}
+	w.mu.Unlock()
+	if len(subs) == 0 {
+		return
+	}
+
+	dirs := w.collectDirs(path)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, sub := range subs {
+		if _, ok := w.subs[sub.id]; !ok {
+			continue
+		}
+		if err := w.addWatches(sub, dirs); err != nil {
+			slog.Warn("not watching new directory", "path", path, "watchId", sub.id, "error", err)
+		}
+	}
+}
+
+// unwatchGone drops watches of a removed or renamed directory and its subdirectories,
+// so they are set up again if the path is recreated.
+func (w *FSWatcher) unwatchGone(path string) {
+	below := func(p string) bool {
+		return strings.HasPrefix(p, path+"/")
+	}
+	gone := func(p string) bool {
+		return p == path || below(p)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// Subdirectories are only watched along with their parent
+	if w.watchRefs[path] == 0 {
+		return
+	}
+	for _, sub := range w.subs {
+		// Keep the subscription's own root: it may be recreated, and fsnotify
+		// reports nothing more for it either way
+		if sub.path == path {
+			w.releaseWatches(sub, below)
+			continue
+		}
+		w.releaseWatches(sub, gone)
+	}
+}
+
+// NotifyChanged reports paths modified by the server without relying on fsnotify
+// delivering an event for them (e.g. when they are not watched). Changes are batched
+// together with fsnotify events for the same paths.
+func (w *FSWatcher) NotifyChanged(op rpc.FSOp, paths ...string) {
+	for _, path := range paths {
+		w.notify(op, cleanRelPath(path))
+	}
+}
+
+// notify queues a change for the subscriptions covering path and schedules a flush.
+func (w *FSWatcher) notify(op rpc.FSOp, path string) {
+	if w.listener != nil {
+		w.listener.OnFSChange(rpc.FSChange{Path: path, Op: op})
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	queued := false
+	for _, sub := range w.subs {
+		if sub.covers(path) {
+			sub.queue(rpc.FSChange{Path: path, Op: op})
+			queued = true
+		}
+	}
+	if !queued {
 		return
 	}
 
-	w.pathMu.RLock()
-	ids := make([]string, len(w.pathToIDs[path]))
-	copy(ids, w.pathToIDs[path])
-	w.pathMu.RUnlock()
+	now := time.Now()
+	if w.pendingSince.IsZero() {
+		w.pendingSince = now
+	}
+	delay := min(debounceInterval, max(fsMaxDelay-now.Sub(w.pendingSince), 0))
+	if w.flushTimer == nil {
+		w.flushTimer = time.AfterFunc(delay, w.flush)
+	} else {
+		w.flushTimer.Reset(delay)
+	}
+}
 
-	if len(ids) == 0 {
+// flush sends the queued changes of every subscription.
+func (w *FSWatcher) flush() {
+	// Skip if watcher is stopped (timer may fire after Stop)
+	if w.Context().Err() != nil {
 		return
 	}
 
-	var notified int
-	for _, id := range ids {
+	w.mu.Lock()
+	w.pendingSince = time.Time{}
+	batches := make(map[string][]rpc.FSChange)
+	var recursivePaths []string
+	for id, sub := range w.subs {
+		changes := sub.takePending()
+		if len(changes) == 0 {
+			continue
+		}
+		batches[id] = changes
+		if sub.recursive {
+			for _, c := range changes {
+				recursivePaths = append(recursivePaths, c.Path)
+			}
+		}
+	}
+	recursive := make(map[string]bool, len(w.subs))
+	for id, sub := range w.subs {
+		recursive[id] = sub.recursive
+	}
+	w.mu.Unlock()
+
+	// Ignored files in watched directories (e.g. *.log) are hidden from recursive
+	// subscriptions, as their ignored directories are
+	ignored := w.ignoredPaths(recursivePaths)
+
+	for id, changes := range batches {
+		if recursive[id] && len(ignored) > 0 {
+			filtered := changes[:0]
+			for _, c := range changes {
+				if !ignored[c.Path] {
+					filtered = append(filtered, c)
+				}
+			}
+			changes = filtered
+		}
+		if len(changes) == 0 {
+			continue
+		}
+
 		sub := w.GetSubscription(id)
 		if sub == nil {
 			continue
 		}
-		err := sub.Conn.Notify(context.Background(), "fs.changed", map[string]any{
-			"id": sub.ID,
-		})
+		err := sub.Conn.Notify(context.Background(), "fs.changed", rpc.FSChangedParams{ID: id, Changes: changes})
 		if err != nil {
-			slog.Debug("failed to notify subscriber", "watchId", sub.ID, "error", err)
+			slog.Debug("failed to notify subscriber", "watchId", id, "error", err)
+		}
+	}
+
+	slog.Debug("notified fs changes", "subscriptions", len(batches))
+}
+
+// ignoredPaths returns which of paths are excluded by .gitignore, in one git call.
+func (w *FSWatcher) ignoredPaths(paths []string) map[string]bool {
+	if len(paths) == 0 {
+		return nil
+	}
+	cmd := exec.Command("git", "check-ignore", "--stdin", "-z")
+	cmd.Dir = w.workDir
+	cmd.Stdin = strings.NewReader(strings.Join(paths, "\x00") + "\x00")
+	// Exits with 1 when no path is ignored
+	output, _ := cmd.Output()
+
+	ignored := make(map[string]bool)
+	for _, path := range strings.Split(string(output), "\x00") {
+		if path != "" {
+			ignored[path] = true
 		}
-		notified++
 	}
+	return ignored
+}
+
+// cleanRelPath normalizes a path relative to the workDir; the workDir itself is "".
+func cleanRelPath(path string) string {
+	path = filepath.ToSlash(filepath.Clean(path))
+	if path == "." {
+		return ""
+	}
+	return path
+}
 
-	slog.Debug("notified path change", "path", path, "subscribers", notified)
+func parentPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	parent := filepath.ToSlash(filepath.Dir(path))
+	if parent == "." {
+		return ""
+	}
+	return parent
 }
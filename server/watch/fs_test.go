@@ -0,0 +1,187 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pockode/server/rpc"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// newNotificationConn returns a server-side conn whose notifications are delivered
+// to the returned channel.
+func newNotificationConn[T any](t *testing.T) (*jsonrpc2.Conn, <-chan T) {
+	t.Helper()
+	serverPipe, clientPipe := net.Pipe()
+	ctx := context.Background()
+
+	notifications := make(chan T, 16)
+	handler := jsonrpc2.HandlerWithError(func(_ context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+		var params T
+		if req.Params != nil {
+			json.Unmarshal(*req.Params, &params)
+		}
+		notifications <- params
+		return nil, nil
+	})
+
+	server := jsonrpc2.NewConn(ctx, jsonrpc2.NewBufferedStream(serverPipe, jsonrpc2.VSCodeObjectCodec{}), nil)
+	client := jsonrpc2.NewConn(ctx, jsonrpc2.NewBufferedStream(clientPipe, jsonrpc2.VSCodeObjectCodec{}), jsonrpc2.AsyncHandler(handler))
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	return server, notifications
+}
+
+func startFSWatcher(t *testing.T, dir string) *FSWatcher {
+	t.Helper()
+	w := NewFSWatcher(dir)
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	t.Cleanup(w.Stop)
+	return w
+}
+
+func expectFSChanges(t *testing.T, notifications <-chan rpc.FSChangedParams) []rpc.FSChange {
+	t.Helper()
+	select {
+	case params := <-notifications:
+		return params.Changes
+	case <-time.After(3 * time.Second):
+		t.Fatal("expected fs.changed notification")
+		return nil
+	}
+}
+
+func hasChange(changes []rpc.FSChange, path string, op rpc.FSOp) bool {
+	for _, c := range changes {
+		if c.Path == path && c.Op == op {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFSWatcher_RecursiveSubscription(t *testing.T) {
+	dir := setupWatchGitRepo(t)
+	os.MkdirAll(filepath.Join(dir, "src", "lib"), 0755)
+	w := startFSWatcher(t, dir)
+	conn, notifications := newNotificationConn[rpc.FSChangedParams](t)
+
+	if _, err := w.Subscribe("", true, conn, "conn1"); err != nil {
+		t.Fatalf("Subscribe() error: %v", err)
+	}
+	// Root, src and src/lib; .git and ignored/ are skipped
+	if got := w.WatchCount(); got != 3 {
+		t.Errorf("WatchCount() = %d, want 3", got)
+	}
+
+	os.WriteFile(filepath.Join(dir, "src", "lib", "a.go"), []byte("package lib"), 0644)
+	os.WriteFile(filepath.Join(dir, "ignored", "out.js"), []byte(""), 0644)
+	changes := expectFSChanges(t, notifications)
+	if !hasChange(changes, "src/lib/a.go", rpc.FSOpCreate) {
+		t.Errorf("expected create of src/lib/a.go, got %+v", changes)
+	}
+	if hasChange(changes, "ignored/out.js", rpc.FSOpCreate) {
+		t.Errorf("ignored change reported: %+v", changes)
+	}
+
+	// New directories are watched as they appear
+	os.Mkdir(filepath.Join(dir, "src", "new"), 0755)
+	expectFSChanges(t, notifications)
+	os.WriteFile(filepath.Join(dir, "src", "new", "b.go"), []byte(""), 0644)
+	changes = expectFSChanges(t, notifications)
+	if !hasChange(changes, "src/new/b.go", rpc.FSOpCreate) {
+		t.Errorf("expected create in new directory, got %+v", changes)
+	}
+
+	os.Remove(filepath.Join(dir, "src", "lib", "a.go"))
+	changes = expectFSChanges(t, notifications)
+	if !hasChange(changes, "src/lib/a.go", rpc.FSOpRemove) {
+		t.Errorf("expected remove, got %+v", changes)
+	}
+}
+
+func TestFSWatcher_BatchesBursts(t *testing.T) {
+	dir := t.TempDir()
+	w := startFSWatcher(t, dir)
+	conn, notifications := newNotificationConn[rpc.FSChangedParams](t)
+
+	id, err := w.Subscribe("", false, conn, "conn1")
+	if err != nil {
+		t.Fatalf("Subscribe() error: %v", err)
+	}
+
+	w.NotifyChanged(rpc.FSOpCreate, "a.txt")
+	w.NotifyChanged(rpc.FSOpWrite, "a.txt")
+	w.NotifyChanged(rpc.FSOpCreate, "tmp.txt")
+	w.NotifyChanged(rpc.FSOpRemove, "tmp.txt")
+	w.NotifyChanged(rpc.FSOpWrite, "sub/deep.txt")
+
+	changes := expectFSChanges(t, notifications)
+	want := []rpc.FSChange{{Path: "a.txt", Op: rpc.FSOpCreate}}
+	if len(changes) != 1 || changes[0] != want[0] {
+		t.Errorf("changes = %+v, want %+v", changes, want)
+	}
+
+	w.Unsubscribe(id)
+	if got := w.WatchCount(); got != 0 {
+		t.Errorf("WatchCount() after unsubscribe = %d, want 0", got)
+	}
+}
+
+func TestFSWatcher_WatchBudget(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"a", "b", "c"} {
+		os.Mkdir(filepath.Join(dir, sub), 0755)
+	}
+	w := startFSWatcher(t, dir)
+	w.maxWatches = 3
+	conn, _ := newNotificationConn[rpc.FSChangedParams](t)
+
+	if _, err := w.Subscribe("", true, conn, "conn1"); !errors.Is(err, ErrWatchBudgetExceeded) {
+		t.Fatalf("Subscribe() error = %v, want ErrWatchBudgetExceeded", err)
+	}
+	if got := w.WatchCount(); got != 0 {
+		t.Errorf("WatchCount() after failed subscribe = %d, want 0", got)
+	}
+
+	if _, err := w.Subscribe("a", true, conn, "conn1"); err != nil {
+		t.Errorf("Subscribe() within budget error: %v", err)
+	}
+}
+
+func TestFSWatcher_RenamedRootReleasesSubdirectories(t *testing.T) {
+	dir := setupWatchGitRepo(t)
+	os.MkdirAll(filepath.Join(dir, "src", "lib", "deep"), 0755)
+	w := startFSWatcher(t, dir)
+	conn, _ := newNotificationConn[rpc.FSChangedParams](t)
+
+	if _, err := w.Subscribe("src", true, conn, "conn1"); err != nil {
+		t.Fatalf("Subscribe() error: %v", err)
+	}
+	if got := w.WatchCount(); got != 3 {
+		t.Fatalf("WatchCount() = %d, want 3", got)
+	}
+
+	// A rename reports nothing for the subdirectories that moved with src
+	if err := os.Rename(filepath.Join(dir, "src"), filepath.Join(dir, "moved")); err != nil {
+		t.Fatal(err)
+	}
+	// Only the subscription's own root is kept
+	deadline := time.Now().Add(3 * time.Second)
+	for w.WatchCount() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("WatchCount() after removal = %d, want 1", w.WatchCount())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -12,14 +12,19 @@ import (
 	"github.com/sourcegraph/jsonrpc2"
 )
 
+// gitPollInterval is only used when fsnotify-based watching is unavailable.
 const gitPollInterval = 3 * time.Second
 
-// GitWatcher polls git status and notifies subscribers when file list changes are detected.
+// GitWatcher watches the git dir and working tree via fsnotify and notifies subscribers
+// when the git status file list changes. Falls back to polling when fsnotify cannot be used.
 // For file-specific diff content changes, use GitDiffWatcher instead.
 type GitWatcher struct {
 	*BaseWatcher
 
-	workDir string
+	workDir      string
+	listeners    []GitChangeListener
+	maxWatches   int
+	pollInterval time.Duration
 
 	stateMu   sync.Mutex
 	lastState string // git status output
@@ -27,8 +32,10 @@ type GitWatcher struct {
 
 func NewGitWatcher(workDir string) *GitWatcher {
 	return &GitWatcher{
-		BaseWatcher: NewBaseWatcher("g"),
-		workDir:     workDir,
+		BaseWatcher:  NewBaseWatcher("g"),
+		workDir:      workDir,
+		maxWatches:   gitEventMaxWatches,
+		pollInterval: gitPollInterval,
 	}
 }
 
@@ -38,11 +45,29 @@ func (w *GitWatcher) Start() error {
 	w.lastState = state
 	w.stateMu.Unlock()
 
-	go w.pollLoop()
-	slog.Info("GitWatcher started", "workDir", w.workDir, "pollInterval", gitPollInterval)
+	events, err := newGitEventSource(w.workDir, w.maxWatches)
+	if err != nil {
+		go w.pollLoop()
+		slog.Warn("GitWatcher falling back to polling", "workDir", w.workDir, "pollInterval", w.pollInterval, "error", err)
+		return nil
+	}
+
+	go func() {
+		if err := events.run(w.Context(), w.handleChange); err != nil {
+			slog.Warn("GitWatcher falling back to polling", "workDir", w.workDir, "pollInterval", w.pollInterval, "error", err)
+			w.pollLoop()
+		}
+	}()
+	slog.Info("GitWatcher started", "workDir", w.workDir)
 	return nil
 }
 
+// AddChangeListener registers a listener called on every (debounced) change,
+// whether or not the status file list changed. Must be called before Start.
+func (w *GitWatcher) AddChangeListener(listener GitChangeListener) {
+	w.listeners = append(w.listeners, listener)
+}
+
 func (w *GitWatcher) Stop() {
 	w.Cancel()
 	slog.Info("GitWatcher stopped")
@@ -61,7 +86,7 @@ func (w *GitWatcher) Subscribe(conn *jsonrpc2.Conn, connID string) (string, erro
 }
 
 func (w *GitWatcher) pollLoop() {
-	ticker := time.NewTicker(gitPollInterval)
+	ticker := time.NewTicker(w.pollInterval)
 	defer ticker.Stop()
 
 	for {
@@ -69,15 +94,21 @@ func (w *GitWatcher) pollLoop() {
 		case <-w.Context().Done():
 			return
 		case <-ticker.C:
-			if !w.HasSubscriptions() {
-				continue
-			}
-
-			w.checkAndNotify()
+			// Polling cannot tell what changed
+			w.handleChange(GitChange{Rescan: true})
 		}
 	}
 }
 
+func (w *GitWatcher) handleChange(change GitChange) {
+	if w.HasSubscriptions() {
+		w.checkAndNotify()
+	}
+	for _, listener := range w.listeners {
+		listener.OnGitChange(change)
+	}
+}
+
 func (w *GitWatcher) checkAndNotify() {
 	newState := w.pollGitState()
 
@@ -6,14 +6,11 @@ import (
 	"encoding/hex"
 	"log/slog"
 	"sync"
-	"time"
 
 	"github.com/pockode/server/git"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
-const gitDiffPollInterval = 3 * time.Second
-
 // gitDiffSubscription holds additional data for a diff subscription.
 type gitDiffSubscription struct {
 	path     string
@@ -21,10 +18,12 @@ type gitDiffSubscription struct {
 	lastHash string
 }
 
-// GitDiffWatcher polls git diff for specific files and notifies subscribers when changes occur.
+// GitDiffWatcher re-checks git diff for subscribed files when GitWatcher reports a change
+// and notifies subscribers when the diff content differs.
 type GitDiffWatcher struct {
 	*BaseWatcher
-	workDir string
+	workDir  string
+	changeCh chan struct{}
 
 	dataMu  sync.RWMutex
 	subData map[string]*gitDiffSubscription // subscription ID -> extra data
@@ -34,13 +33,14 @@ func NewGitDiffWatcher(workDir string) *GitDiffWatcher {
 	return &GitDiffWatcher{
 		BaseWatcher: NewBaseWatcher("d"),
 		workDir:     workDir,
+		changeCh:    make(chan struct{}, 1),
 		subData:     make(map[string]*gitDiffSubscription),
 	}
 }
 
 func (w *GitDiffWatcher) Start() error {
-	go w.pollLoop()
-	slog.Info("GitDiffWatcher started", "workDir", w.workDir, "pollInterval", gitDiffPollInterval)
+	go w.eventLoop()
+	slog.Info("GitDiffWatcher started", "workDir", w.workDir)
 	return nil
 }
 
@@ -109,15 +109,21 @@ func (w *GitDiffWatcher) CleanupConnection(connID string) {
 	w.BaseWatcher.CleanupConnection(connID)
 }
 
-func (w *GitDiffWatcher) pollLoop() {
-	ticker := time.NewTicker(gitDiffPollInterval)
-	defer ticker.Stop()
+// OnGitChange implements GitChangeListener. Changes arriving while a check is
+// running are coalesced into a single follow-up check.
+func (w *GitDiffWatcher) OnGitChange(GitChange) {
+	select {
+	case w.changeCh <- struct{}{}:
+	default:
+	}
+}
 
+func (w *GitDiffWatcher) eventLoop() {
 	for {
 		select {
 		case <-w.Context().Done():
 			return
-		case <-ticker.C:
+		case <-w.changeCh:
 			if !w.HasSubscriptions() {
 				continue
 			}
@@ -0,0 +1,367 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// gitEventDebounce coalesces bursts of events (e.g. a checkout touching many files).
+	gitEventDebounce = 200 * time.Millisecond
+	// gitEventMaxDelay bounds the debounce so a continuous stream of writes still notifies.
+	gitEventMaxDelay = 2 * time.Second
+	// gitEventMaxWatches caps directory watches per worktree. Larger trees fall back to
+	// polling instead of exhausting the inotify budget shared by all worktrees.
+	// Watches of removed directories are released, so churn does not use it up.
+	gitEventMaxWatches = 8192
+	// gitChangeMaxPaths caps the paths collected per notification; larger bursts
+	// are reported as a rescan.
+	gitChangeMaxPaths = 1024
+)
+
+var errTooManyWatches = errors.New("too many directories to watch")
+
+// GitChange describes a batch of changes seen by GitWatcher.
+type GitChange struct {
+	// Paths lists working tree paths (relative to the worktree, slash-separated)
+	// that were created, removed or renamed. Plain writes are not listed.
+	Paths []string
+	// Refs is set when HEAD, the index or refs changed.
+	Refs bool
+	// Rescan is set when changes may have been missed (events lost, too many
+	// changes, polling), so Paths is incomplete.
+	Rescan bool
+}
+
+// GitChangeListener is notified when the working tree or git metadata may have changed.
+type GitChangeListener interface {
+	OnGitChange(change GitChange)
+}
+
+// gitEventSource watches everything that can affect git status via fsnotify:
+// the worktree's git dir (index, HEAD), refs in the common dir, and the working tree
+// directories not excluded by .gitignore.
+type gitEventSource struct {
+	workDir    string
+	gitDir     string
+	commonDir  string
+	watcher    *fsnotify.Watcher
+	maxWatches int
+	watched    map[string]bool // only accessed by run once started
+
+	// pending collects the change reported by the next notification
+	pending      GitChange
+	pendingPaths map[string]bool
+
+	// failed is set when a change can no longer be detected reliably
+	failed error
+}
+
+// newGitEventSource sets up all watches up front so callers can fall back to polling
+// when fsnotify is unavailable or the tree has more than maxWatches directories.
+func newGitEventSource(workDir string, maxWatches int) (*gitEventSource, error) {
+	gitDir, commonDir, err := resolveGitDirs(workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &gitEventSource{
+		workDir:      workDir,
+		gitDir:       gitDir,
+		commonDir:    commonDir,
+		watcher:      watcher,
+		maxWatches:   maxWatches,
+		watched:      make(map[string]bool),
+		pendingPaths: make(map[string]bool),
+	}
+	if err := s.addAll(); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func resolveGitDirs(workDir string) (gitDir, commonDir string, err error) {
+	cmd := exec.Command("git", "rev-parse", "--absolute-git-dir", "--git-common-dir")
+	cmd.Dir = workDir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", "", err
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) != 2 {
+		return "", "", errors.New("unexpected git rev-parse output")
+	}
+	gitDir = lines[0]
+	commonDir = lines[1]
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(workDir, commonDir)
+	}
+	return gitDir, filepath.Clean(commonDir), nil
+}
+
+func (s *gitEventSource) addAll() error {
+	// index and HEAD are replaced via rename, so watch their directory rather than the files
+	if err := s.add(s.gitDir); err != nil {
+		return err
+	}
+	if s.commonDir != s.gitDir {
+		if err := s.add(s.commonDir); err != nil {
+			return err
+		}
+	}
+	if err := s.addTree(filepath.Join(s.commonDir, "refs"), nil); err != nil {
+		return err
+	}
+
+	ignored, err := ignoredDirs(s.workDir, "")
+	if err != nil {
+		return err
+	}
+	return s.addTree(s.workDir, ignored)
+}
+
+func (s *gitEventSource) add(dir string) error {
+	if s.watched[dir] {
+		return nil
+	}
+	if len(s.watched) >= s.maxWatches {
+		return errTooManyWatches
+	}
+	if err := s.watcher.Add(dir); err != nil {
+		return err
+	}
+	s.watched[dir] = true
+	return nil
+}
+
+// release forgets the watches of a removed or renamed directory and of the
+// directories below it. Inotify drops the watch of a deleted directory by itself,
+// but a renamed one keeps it under its old path.
+func (s *gitEventSource) release(dir string) {
+	if !s.watched[dir] {
+		return
+	}
+	prefix := dir + string(filepath.Separator)
+	for path := range s.watched {
+		if path == dir || strings.HasPrefix(path, prefix) {
+			s.watcher.Remove(path)
+			delete(s.watched, path)
+		}
+	}
+}
+
+// addTree watches root and all directories below it, skipping .git and ignored directories.
+func (s *gitEventSource) addTree(root string, ignored map[string]bool) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Directories may disappear while walking
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && (d.Name() == ".git" || ignored[path]) {
+			return filepath.SkipDir
+		}
+		return s.add(path)
+	})
+}
+
+// ignoredDirs returns absolute paths of directories under pathspec (relative to
+// workDir; empty = everything) excluded by .gitignore.
+func ignoredDirs(workDir, pathspec string) (map[string]bool, error) {
+	args := []string{"ls-files", "-z", "--others", "--ignored", "--exclude-standard", "--directory"}
+	if pathspec != "" {
+		args = append(args, "--", pathspec)
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = workDir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	ignored := make(map[string]bool)
+	for _, entry := range strings.Split(string(output), "\x00") {
+		if strings.HasSuffix(entry, "/") {
+			ignored[filepath.Join(workDir, entry)] = true
+		}
+	}
+	return ignored, nil
+}
+
+// isIgnored reports whether path (relative to workDir) is excluded by .gitignore.
+func isIgnored(workDir, path string) bool {
+	cmd := exec.Command("git", "check-ignore", "-q", "--", path)
+	cmd.Dir = workDir
+	return cmd.Run() == nil
+}
+
+// run delivers debounced change notifications until ctx is cancelled. It returns
+// an error, after a last notification, when changes can no longer be detected
+// reliably (watch budget exhausted, events lost) and the caller should poll instead.
+func (s *gitEventSource) run(ctx context.Context, onChange func(GitChange)) error {
+	defer s.watcher.Close()
+
+	timer := time.NewTimer(gitEventMaxDelay)
+	timer.Stop()
+	defer timer.Stop()
+
+	var pendingSince time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-s.watcher.Events:
+			if !ok {
+				return nil
+			}
+			relevant := s.handleEvent(event)
+			if s.failed != nil {
+				s.pending.Rescan = true
+				onChange(s.takePending())
+				return s.failed
+			}
+			if !relevant {
+				continue
+			}
+
+			now := time.Now()
+			if pendingSince.IsZero() {
+				pendingSince = now
+			}
+			delay := min(gitEventDebounce, max(gitEventMaxDelay-now.Sub(pendingSince), 0))
+			timer.Reset(delay)
+		case <-timer.C:
+			pendingSince = time.Time{}
+			onChange(s.takePending())
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return nil
+			}
+			// Events may have been lost, so rescan
+			s.pending.Rescan = true
+			onChange(s.takePending())
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				return err
+			}
+			slog.Error("git fsnotify error", "workDir", s.workDir, "error", err)
+		}
+	}
+}
+
+// takePending returns the collected change and resets it.
+func (s *gitEventSource) takePending() GitChange {
+	change := s.pending
+	s.pending = GitChange{}
+	clear(s.pendingPaths)
+	return change
+}
+
+// addPendingPath records a created, removed or renamed working tree path.
+func (s *gitEventSource) addPendingPath(rel string) {
+	if s.pending.Rescan {
+		return
+	}
+	path := filepath.ToSlash(rel)
+	if s.pendingPaths[path] {
+		return
+	}
+	if len(s.pending.Paths) >= gitChangeMaxPaths {
+		s.pending.Paths = nil
+		s.pending.Rescan = true
+		return
+	}
+	s.pendingPaths[path] = true
+	s.pending.Paths = append(s.pending.Paths, path)
+}
+
+// handleEvent reports whether the event can affect git status, records it in
+// pending, and starts watching newly created directories.
+func (s *gitEventSource) handleEvent(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		s.release(event.Name)
+	}
+
+	for _, dir := range []string{s.gitDir, s.commonDir} {
+		if rel, ok := relativeTo(dir, event.Name); ok {
+			return s.handleGitDirEvent(event, rel)
+		}
+	}
+
+	rel, ok := relativeTo(s.workDir, event.Name)
+	if !ok {
+		return false
+	}
+	if rel == ".git" || strings.HasPrefix(rel, ".git"+string(filepath.Separator)) {
+		return false
+	}
+	if event.Has(fsnotify.Create) || event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		s.addPendingPath(rel)
+	}
+
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Lstat(event.Name); err == nil && info.IsDir() && !isIgnored(s.workDir, rel) {
+			if err := s.addTree(event.Name, nil); err != nil {
+				s.failed = fmt.Errorf("watch new directory %s: %w", event.Name, err)
+			}
+		}
+	}
+	return true
+}
+
+func (s *gitEventSource) handleGitDirEvent(event fsnotify.Event, rel string) bool {
+	// Lock files are transient; the rename that follows is what matters
+	if strings.HasSuffix(rel, ".lock") {
+		return false
+	}
+	first, _, _ := strings.Cut(filepath.ToSlash(rel), "/")
+	switch first {
+	case "objects", "logs", "worktrees", "modules":
+		return false
+	}
+	s.pending.Refs = true
+	switch first {
+	case "refs":
+		if event.Has(fsnotify.Create) {
+			if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+				if err := s.addTree(event.Name, nil); err != nil {
+					s.failed = fmt.Errorf("watch new refs directory %s: %w", event.Name, err)
+				}
+			}
+		}
+	}
+	return true
+}
+
+// relativeTo returns path relative to dir if path is inside dir.
+func relativeTo(dir, path string) (string, bool) {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
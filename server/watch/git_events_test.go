@@ -0,0 +1,218 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func setupWatchGitRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init"},
+		{"config", "user.email", "test@test.com"},
+		{"config", "user.name", "Test"},
+	} {
+		runWatchGit(t, dir, args...)
+	}
+	os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("ignored/\n"), 0644)
+	os.Mkdir(filepath.Join(dir, "ignored"), 0755)
+	return dir
+}
+
+func runWatchGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, output)
+	}
+}
+
+func startGitEventSource(t *testing.T, dir string) <-chan GitChange {
+	t.Helper()
+	source, err := newGitEventSource(dir, gitEventMaxWatches)
+	if err != nil {
+		t.Fatalf("newGitEventSource() error: %v", err)
+	}
+	changes, _ := runGitEventSource(t, source)
+	return changes
+}
+
+// runGitEventSource runs source, returning its notifications and the error it stops with.
+func runGitEventSource(t *testing.T, source *gitEventSource) (<-chan GitChange, <-chan error) {
+	t.Helper()
+	changes := make(chan GitChange, 16)
+	stopped := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { stopped <- source.run(ctx, func(change GitChange) { changes <- change }) }()
+	return changes, stopped
+}
+
+func expectChange(t *testing.T, changes <-chan GitChange, what string) GitChange {
+	t.Helper()
+	select {
+	case change := <-changes:
+		return change
+	case <-time.After(3 * time.Second):
+		t.Fatalf("expected change notification for %s", what)
+		return GitChange{}
+	}
+}
+
+func expectNoChange(t *testing.T, changes <-chan GitChange, what string) {
+	t.Helper()
+	select {
+	case <-changes:
+		t.Fatalf("unexpected change notification for %s", what)
+	case <-time.After(gitEventDebounce + 300*time.Millisecond):
+	}
+}
+
+func TestGitEventSource_WorkingTreeAndIndex(t *testing.T) {
+	dir := setupWatchGitRepo(t)
+	changes := startGitEventSource(t, dir)
+
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644)
+	if change := expectChange(t, changes, "new file"); !slices.Equal(change.Paths, []string{"a.txt"}) || change.Refs {
+		t.Errorf("new file reported as %+v", change)
+	}
+
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("b"), 0644)
+	if change := expectChange(t, changes, "write"); len(change.Paths) != 0 {
+		t.Errorf("write reported paths: %+v", change)
+	}
+
+	runWatchGit(t, dir, "add", "a.txt")
+	if change := expectChange(t, changes, "git add"); !change.Refs {
+		t.Errorf("git add reported as %+v", change)
+	}
+
+	runWatchGit(t, dir, "commit", "--no-gpg-sign", "-m", "initial")
+	expectChange(t, changes, "git commit")
+}
+
+func TestGitEventSource_Debounce(t *testing.T) {
+	dir := setupWatchGitRepo(t)
+	changes := startGitEventSource(t, dir)
+
+	for i := 0; i < 10; i++ {
+		os.WriteFile(filepath.Join(dir, "a.txt"), []byte{byte(i)}, 0644)
+	}
+	expectChange(t, changes, "burst of writes")
+	expectNoChange(t, changes, "coalesced writes")
+}
+
+func TestGitEventSource_IgnoredAndNewDirectories(t *testing.T) {
+	dir := setupWatchGitRepo(t)
+	changes := startGitEventSource(t, dir)
+
+	os.WriteFile(filepath.Join(dir, "ignored", "build.out"), []byte("x"), 0644)
+	expectNoChange(t, changes, "file in ignored directory")
+
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	expectChange(t, changes, "new directory")
+
+	os.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("b"), 0644)
+	expectChange(t, changes, "file in new directory")
+}
+
+func TestGitEventSource_ReleasesRemovedDirectories(t *testing.T) {
+	dir := setupWatchGitRepo(t)
+	probe, err := newGitEventSource(dir, gitEventMaxWatches)
+	if err != nil {
+		t.Fatalf("newGitEventSource() error: %v", err)
+	}
+	initial := len(probe.watched)
+	probe.watcher.Close()
+
+	// Room for a single extra directory at a time
+	source, err := newGitEventSource(dir, initial+1)
+	if err != nil {
+		t.Fatalf("newGitEventSource() error: %v", err)
+	}
+	changes, stopped := runGitEventSource(t, source)
+
+	sub := filepath.Join(dir, "build")
+	for i := 0; i < 3; i++ {
+		os.Mkdir(sub, 0755)
+		expectChange(t, changes, "new directory")
+		os.Remove(sub)
+		expectChange(t, changes, "removed directory")
+	}
+	os.Mkdir(sub, 0755)
+	os.Rename(sub, filepath.Join(dir, "renamed"))
+	expectChange(t, changes, "renamed directory")
+
+	select {
+	case err := <-stopped:
+		t.Fatalf("event source stopped after directory churn: %v", err)
+	default:
+	}
+
+	// Over budget: a last notification, then the caller has to poll
+	os.MkdirAll(filepath.Join(dir, "a", "b"), 0755)
+	if change := expectChange(t, changes, "directories over budget"); !change.Rescan {
+		t.Errorf("change over budget not reported as a rescan: %+v", change)
+	}
+	select {
+	case err := <-stopped:
+		if !errors.Is(err, errTooManyWatches) {
+			t.Errorf("run() error = %v, want errTooManyWatches", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("event source kept running over budget")
+	}
+}
+
+type gitChangeFunc func(GitChange)
+
+func (f gitChangeFunc) OnGitChange(change GitChange) { f(change) }
+
+func TestGitWatcher_FallsBackToPolling(t *testing.T) {
+	dir := setupWatchGitRepo(t)
+	w := NewGitWatcher(dir)
+	// Too small for the repository, so the event source cannot be used
+	w.maxWatches = 1
+	w.pollInterval = 50 * time.Millisecond
+
+	var mu sync.Mutex
+	var states []string
+	w.AddChangeListener(gitChangeFunc(func(change GitChange) {
+		if !change.Rescan {
+			t.Errorf("polled change without rescan: %+v", change)
+		}
+		mu.Lock()
+		states = append(states, w.pollGitState())
+		mu.Unlock()
+	}))
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	defer w.Stop()
+
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		mu.Lock()
+		polled := slices.ContainsFunc(states, func(state string) bool { return strings.Contains(state, "a.txt") })
+		mu.Unlock()
+		if polled {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("polling did not deliver the change")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
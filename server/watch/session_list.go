@@ -7,13 +7,20 @@ import (
 	"github.com/sourcegraph/jsonrpc2"
 )
 
+// SessionListChangeListener is notified after each change of a session list,
+// including activity in a session.
+type SessionListChangeListener interface {
+	OnSessionListChange()
+}
+
 // SessionListWatcher notifies subscribers when the session list changes.
 // Uses a channel-based async notification pattern to avoid blocking the session
 // store's mutex during network I/O.
 type SessionListWatcher struct {
 	*BaseWatcher
-	store   session.Store
-	eventCh chan session.SessionChangeEvent
+	store     session.Store
+	eventCh   chan session.SessionChangeEvent
+	listeners []SessionListChangeListener
 }
 
 func NewSessionListWatcher(store session.Store) *SessionListWatcher {
@@ -26,6 +33,12 @@ func NewSessionListWatcher(store session.Store) *SessionListWatcher {
 	return w
 }
 
+// AddChangeListener registers a listener called from the event loop on every
+// change, whether or not there are subscribers. Must be called before Start.
+func (w *SessionListWatcher) AddChangeListener(listener SessionListChangeListener) {
+	w.listeners = append(w.listeners, listener)
+}
+
 func (w *SessionListWatcher) Start() error {
 	go w.eventLoop()
 	slog.Info("SessionListWatcher started")
@@ -45,6 +58,9 @@ func (w *SessionListWatcher) eventLoop() {
 			return
 		case event := <-w.eventCh:
 			w.notifyChange(event)
+			for _, listener := range w.listeners {
+				listener.OnSessionListChange()
+			}
 		}
 	}
 }
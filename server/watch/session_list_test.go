@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/pockode/server/session"
 )
@@ -157,3 +158,27 @@ func TestSessionListWatcher_Subscribe_ListError(t *testing.T) {
 		t.Error("expected no subscriptions after error")
 	}
 }
+
+type sessionListChangeFunc func()
+
+func (f sessionListChangeFunc) OnSessionListChange() { f() }
+
+func TestSessionListWatcher_ChangeListener(t *testing.T) {
+	store := &mockSessionStore{}
+	w := NewSessionListWatcher(store)
+	changed := make(chan struct{}, 1)
+	w.AddChangeListener(sessionListChangeFunc(func() { changed <- struct{}{} }))
+	w.Start()
+	defer w.Stop()
+
+	// Listeners are called without subscribers
+	w.OnSessionChange(session.SessionChangeEvent{
+		Op:      session.OperationUpdate,
+		Session: session.SessionMeta{ID: "sess-1"},
+	})
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("expected change listener to be called")
+	}
+}
@@ -0,0 +1,64 @@
+package watch
+
+import (
+	"log/slog"
+
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/task"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// TaskWatcher streams task run output and status changes to subscribers.
+// Implements task.Listener to receive progress from task.Runner.
+type TaskWatcher struct {
+	*BaseWatcher
+	runner *task.Runner
+}
+
+var _ task.Listener = (*TaskWatcher)(nil)
+
+func NewTaskWatcher(runner *task.Runner) *TaskWatcher {
+	w := &TaskWatcher{
+		BaseWatcher: NewBaseWatcher("tk"),
+		runner:      runner,
+	}
+	runner.SetListener(w)
+	return w
+}
+
+func (w *TaskWatcher) Start() error {
+	slog.Info("TaskWatcher started")
+	return nil
+}
+
+func (w *TaskWatcher) Stop() {
+	w.Cancel()
+	slog.Info("TaskWatcher stopped")
+}
+
+// OnTaskOutput implements task.Listener.
+func (w *TaskWatcher) OnTaskOutput(runID, stream, data string) {
+	w.NotifyAll("task.output", func(sub *Subscription) any {
+		return rpc.TaskOutputParams{ID: sub.ID, RunID: runID, Stream: stream, Data: data}
+	})
+}
+
+// OnTaskStatus implements task.Listener.
+func (w *TaskWatcher) OnTaskStatus(run task.Run) {
+	w.NotifyAll("task.status", func(sub *Subscription) any {
+		return rpc.TaskStatusParams{ID: sub.ID, Run: run}
+	})
+}
+
+// Subscribe registers a subscriber and returns the subscription ID along with
+// the running and recent runs.
+func (w *TaskWatcher) Subscribe(conn *jsonrpc2.Conn, connID string) (string, []task.Run) {
+	id := w.GenerateID()
+	// Add subscription BEFORE getting the history to avoid missing status changes
+	w.AddSubscription(&Subscription{
+		ID:     id,
+		ConnID: connID,
+		Conn:   conn,
+	})
+	return id, w.runner.History()
+}
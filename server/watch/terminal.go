@@ -0,0 +1,198 @@
+package watch
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/terminal"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// terminalQueueSize is the number of output chunks buffered per subscription
+// before a subscriber is considered too slow and detached.
+const terminalQueueSize = 256
+
+// TerminalWatcher streams terminal output to attached clients.
+// Implements terminal.Listener to receive output from terminal.Manager.
+//
+// Each subscription is delivered by its own goroutine from a bounded queue, so
+// a slow client never holds back the terminal or other subscribers. A client
+// that falls behind is detached with terminal.detached and can re-attach to
+// resync from the scrollback.
+type TerminalWatcher struct {
+	*BaseWatcher
+	queueSize int
+
+	termMu    sync.Mutex
+	termToIDs map[string][]string     // terminal ID -> subscription IDs
+	idToTerm  map[string]string       // subscription ID -> terminal ID
+	queues    map[string]*terminalSub // subscription ID -> delivery queue
+}
+
+// terminalSub queues notifications for one subscription. final is set before
+// queue is closed and is sent once the queued output has been delivered.
+type terminalSub struct {
+	queue chan string
+	final *terminalEvent
+}
+
+type terminalEvent struct {
+	exited   bool
+	exitCode int
+}
+
+var _ terminal.Listener = (*TerminalWatcher)(nil)
+var _ Watcher = (*TerminalWatcher)(nil)
+
+func NewTerminalWatcher() *TerminalWatcher {
+	return &TerminalWatcher{
+		BaseWatcher: NewBaseWatcher("tm"),
+		queueSize:   terminalQueueSize,
+		termToIDs:   make(map[string][]string),
+		idToTerm:    make(map[string]string),
+		queues:      make(map[string]*terminalSub),
+	}
+}
+
+func (w *TerminalWatcher) Start() error {
+	slog.Info("TerminalWatcher started")
+	return nil
+}
+
+func (w *TerminalWatcher) Stop() {
+	w.Cancel()
+	slog.Info("TerminalWatcher stopped")
+}
+
+// OnTerminalOutput implements terminal.Listener. It never blocks: subscribers
+// whose queue is full are detached.
+func (w *TerminalWatcher) OnTerminalOutput(id, data string) {
+	w.termMu.Lock()
+	defer w.termMu.Unlock()
+
+	for _, subID := range append([]string(nil), w.termToIDs[id]...) {
+		select {
+		case w.queues[subID].queue <- data:
+		default:
+			slog.Warn("terminal subscriber too slow, detaching", "id", subID, "terminalId", id)
+			w.endSubscription(subID, &terminalEvent{})
+		}
+	}
+}
+
+// OnTerminalExit implements terminal.Listener. Subscriptions end with the terminal.
+func (w *TerminalWatcher) OnTerminalExit(id string, exitCode int) {
+	w.termMu.Lock()
+	defer w.termMu.Unlock()
+
+	for _, subID := range append([]string(nil), w.termToIDs[id]...) {
+		w.endSubscription(subID, &terminalEvent{exited: true, exitCode: exitCode})
+	}
+}
+
+// deliver sends a subscription's queued output in order, followed by its final
+// notification if any.
+func (w *TerminalWatcher) deliver(sub *Subscription, ts *terminalSub) {
+	for {
+		select {
+		case <-w.Context().Done():
+			return
+		case data, ok := <-ts.queue:
+			if !ok {
+				if ts.final != nil {
+					w.notifyFinal(sub, ts.final)
+				}
+				return
+			}
+			w.notify(sub, "terminal.output", rpc.TerminalOutputParams{ID: sub.ID, Data: data})
+		}
+	}
+}
+
+func (w *TerminalWatcher) notifyFinal(sub *Subscription, event *terminalEvent) {
+	if event.exited {
+		w.notify(sub, "terminal.exited", rpc.TerminalExitedParams{ID: sub.ID, ExitCode: event.exitCode})
+	} else {
+		w.notify(sub, "terminal.detached", rpc.TerminalDetachedParams{ID: sub.ID})
+	}
+}
+
+func (w *TerminalWatcher) notify(sub *Subscription, method string, params any) {
+	if err := sub.Conn.Notify(context.Background(), method, params); err != nil {
+		slog.Debug("failed to notify subscriber", "id", sub.ID, "error", err)
+	}
+}
+
+// Subscribe attaches a connection to a terminal's output. Call it from
+// terminal.Terminal.Attach so no output is missed or repeated after the scrollback.
+func (w *TerminalWatcher) Subscribe(conn *jsonrpc2.Conn, connID, terminalID string) string {
+	id := w.GenerateID()
+	sub := &Subscription{
+		ID:     id,
+		ConnID: connID,
+		Conn:   conn,
+	}
+	ts := &terminalSub{queue: make(chan string, w.queueSize)}
+
+	// Lock order: termMu → subMu (consistent with Unsubscribe/CleanupConnection)
+	w.termMu.Lock()
+	w.termToIDs[terminalID] = append(w.termToIDs[terminalID], id)
+	w.idToTerm[id] = terminalID
+	w.queues[id] = ts
+	w.AddSubscription(sub)
+	w.termMu.Unlock()
+
+	go w.deliver(sub, ts)
+	return id
+}
+
+// Unsubscribe removes a subscription.
+func (w *TerminalWatcher) Unsubscribe(id string) {
+	w.termMu.Lock()
+	defer w.termMu.Unlock()
+	w.endSubscription(id, nil)
+}
+
+// CleanupConnection removes all subscriptions for a connection.
+func (w *TerminalWatcher) CleanupConnection(connID string) {
+	subs := w.GetSubscriptionsByConnID(connID)
+	if len(subs) == 0 {
+		return
+	}
+
+	w.termMu.Lock()
+	defer w.termMu.Unlock()
+	for _, sub := range subs {
+		w.endSubscription(sub.ID, nil)
+	}
+}
+
+// endSubscription removes a subscription and closes its queue; its delivery
+// goroutine sends final, if set, after the output already queued. Caller must
+// hold termMu.
+func (w *TerminalWatcher) endSubscription(id string, final *terminalEvent) {
+	w.RemoveSubscription(id)
+
+	terminalID, ok := w.idToTerm[id]
+	if !ok {
+		return
+	}
+	delete(w.idToTerm, id)
+	ids := w.termToIDs[terminalID]
+	for i, v := range ids {
+		if v == id {
+			w.termToIDs[terminalID] = append(ids[:i], ids[i+1:]...)
+			break
+		}
+	}
+	if len(w.termToIDs[terminalID]) == 0 {
+		delete(w.termToIDs, terminalID)
+	}
+
+	ts := w.queues[id]
+	delete(w.queues, id)
+	ts.final = final
+	close(ts.queue)
+}
@@ -0,0 +1,65 @@
+package watch
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pockode/server/rpc"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// newStalledConn returns a connection whose peer never reads, so notifications block.
+func newStalledConn(t *testing.T) *jsonrpc2.Conn {
+	t.Helper()
+	serverPipe, clientPipe := net.Pipe()
+	conn := jsonrpc2.NewConn(context.Background(), jsonrpc2.NewBufferedStream(serverPipe, jsonrpc2.VSCodeObjectCodec{}), nil)
+	t.Cleanup(func() {
+		conn.Close()
+		clientPipe.Close()
+	})
+	return conn
+}
+
+func TestTerminalWatcher_DetachesSlowSubscriber(t *testing.T) {
+	w := NewTerminalWatcher()
+	w.queueSize = 2
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	t.Cleanup(w.Stop)
+
+	conn, notifications := newNotificationConn[rpc.TerminalOutputParams](t)
+	fast := w.Subscribe(conn, "fast", "t1")
+	slow := w.Subscribe(newStalledConn(t), "slow", "t1")
+
+	for i := 0; i < 10; i++ {
+		done := make(chan struct{})
+		go func() {
+			w.OnTerminalOutput("t1", "x")
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("OnTerminalOutput blocked on a slow subscriber")
+		}
+
+		select {
+		case params := <-notifications:
+			if params.ID != fast || params.Data != "x" {
+				t.Fatalf("unexpected notification %+v", params)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("output %d not delivered to the fast subscriber", i)
+		}
+	}
+
+	if w.GetSubscription(slow) != nil {
+		t.Error("slow subscriber was not detached")
+	}
+	if w.GetSubscription(fast) == nil {
+		t.Error("fast subscriber was detached")
+	}
+}
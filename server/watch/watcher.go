@@ -17,4 +17,6 @@ var (
 	_ Watcher = (*WorktreeWatcher)(nil)
 	_ Watcher = (*SessionListWatcher)(nil)
 	_ Watcher = (*SettingsWatcher)(nil)
+	_ Watcher = (*TerminalWatcher)(nil)
+	_ Watcher = (*TaskWatcher)(nil)
 )
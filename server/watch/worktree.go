@@ -2,31 +2,49 @@ package watch
 
 import (
 	"context"
+	"encoding/json"
 	"log/slog"
 	"os/exec"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/pockode/server/rpc"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
-const worktreePollInterval = 3 * time.Second
+const (
+	worktreePollInterval = 3 * time.Second
+	// worktreeRefreshDelay batches git and session activity of all worktrees into
+	// at most one list refresh per delay, since describing worktrees runs git.
+	worktreeRefreshDelay = time.Second
+)
 
 // WorktreeWatcher polls git worktree list and notifies subscribers when changes are detected.
+// Notifications carry the full worktree list when a list provider is set.
+//
+// It also listens to the git and session watchers of active worktrees, so that
+// changes of their state (dirty, ahead/behind, last activity) reach clients.
 type WorktreeWatcher struct {
 	*BaseWatcher
 
-	mainDir string
+	mainDir      string
+	listProvider func() []rpc.WorktreeInfo
+	refreshDelay time.Duration
 
 	stateMu   sync.Mutex
 	lastState string
+	lastList  string // JSON of the list last sent, to skip refreshes changing nothing
+
+	refreshMu      sync.Mutex
+	refreshPending bool
 }
 
 func NewWorktreeWatcher(mainDir string) *WorktreeWatcher {
 	return &WorktreeWatcher{
-		BaseWatcher: NewBaseWatcher("wt"),
-		mainDir:     mainDir,
+		BaseWatcher:  NewBaseWatcher("wt"),
+		mainDir:      mainDir,
+		refreshDelay: worktreeRefreshDelay,
 	}
 }
 
@@ -46,6 +64,53 @@ func (w *WorktreeWatcher) Stop() {
 	slog.Info("WorktreeWatcher stopped")
 }
 
+// SetListProvider sets the function used to build the worktree list sent with
+// notifications. Must be called before Start.
+func (w *WorktreeWatcher) SetListProvider(provider func() []rpc.WorktreeInfo) {
+	w.listProvider = provider
+}
+
+// NotifyChanged notifies subscribers of changes git worktree list cannot show,
+// such as metadata updates.
+func (w *WorktreeWatcher) NotifyChanged() {
+	if w.HasSubscriptions() {
+		w.notifySubscribers()
+	}
+}
+
+// OnGitChange implements GitChangeListener for the git watchers of active worktrees.
+func (w *WorktreeWatcher) OnGitChange(GitChange) {
+	w.scheduleRefresh()
+}
+
+// OnSessionListChange implements SessionListChangeListener for the session
+// watchers of active worktrees.
+func (w *WorktreeWatcher) OnSessionListChange() {
+	w.scheduleRefresh()
+}
+
+// scheduleRefresh notifies subscribers after refreshDelay if the worktree list
+// changed by then. Calls in the meantime are coalesced.
+func (w *WorktreeWatcher) scheduleRefresh() {
+	if !w.HasSubscriptions() || w.listProvider == nil {
+		return
+	}
+	w.refreshMu.Lock()
+	defer w.refreshMu.Unlock()
+	if w.refreshPending {
+		return
+	}
+	w.refreshPending = true
+	time.AfterFunc(w.refreshDelay, func() {
+		w.refreshMu.Lock()
+		w.refreshPending = false
+		w.refreshMu.Unlock()
+		if w.Context().Err() == nil && w.HasSubscriptions() {
+			w.notifyIfListChanged()
+		}
+	})
+}
+
 func (w *WorktreeWatcher) Subscribe(conn *jsonrpc2.Conn, connID string) (string, error) {
 	id := w.GenerateID()
 
@@ -104,10 +169,43 @@ func (w *WorktreeWatcher) pollWorktreeList() string {
 	return strings.TrimSpace(string(output))
 }
 
+// notifyIfListChanged notifies subscribers if the worktree list differs from the
+// one last sent.
+func (w *WorktreeWatcher) notifyIfListChanged() {
+	worktrees := w.listProvider()
+	data, err := json.Marshal(worktrees)
+	if err != nil {
+		return
+	}
+
+	w.stateMu.Lock()
+	changed := string(data) != w.lastList
+	w.lastList = string(data)
+	w.stateMu.Unlock()
+
+	if changed {
+		w.sendList(worktrees)
+	}
+}
+
 func (w *WorktreeWatcher) notifySubscribers() {
+	var worktrees []rpc.WorktreeInfo
+	if w.listProvider != nil {
+		worktrees = w.listProvider()
+		if data, err := json.Marshal(worktrees); err == nil {
+			w.stateMu.Lock()
+			w.lastList = string(data)
+			w.stateMu.Unlock()
+		}
+	}
+	w.sendList(worktrees)
+}
+
+func (w *WorktreeWatcher) sendList(worktrees []rpc.WorktreeInfo) {
 	count := w.NotifyAll("worktree.changed", func(sub *Subscription) any {
-		return map[string]any{
-			"id": sub.ID,
+		return rpc.WorktreeChangedParams{
+			ID:        sub.ID,
+			Worktrees: worktrees,
 		}
 	})
 	slog.Debug("notified worktree list change", "subscribers", count)
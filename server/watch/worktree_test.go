@@ -0,0 +1,69 @@
+package watch
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pockode/server/rpc"
+)
+
+func TestWorktreeWatcher_RefreshesOnActivity(t *testing.T) {
+	var mu sync.Mutex
+	worktrees := []rpc.WorktreeInfo{{Name: "feature", Branch: "feature"}}
+
+	w := NewWorktreeWatcher(t.TempDir())
+	w.refreshDelay = 50 * time.Millisecond
+	w.SetListProvider(func() []rpc.WorktreeInfo {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]rpc.WorktreeInfo(nil), worktrees...)
+	})
+	t.Cleanup(w.Cancel)
+
+	conn, notifications := newNotificationConn[rpc.WorktreeChangedParams](t)
+	if _, err := w.Subscribe(conn, "conn1"); err != nil {
+		t.Fatalf("Subscribe() error: %v", err)
+	}
+
+	expectList := func(what string) rpc.WorktreeChangedParams {
+		t.Helper()
+		select {
+		case params := <-notifications:
+			return params
+		case <-time.After(3 * time.Second):
+			t.Fatalf("expected worktree.changed for %s", what)
+			return rpc.WorktreeChangedParams{}
+		}
+	}
+	expectNone := func(what string) {
+		t.Helper()
+		select {
+		case params := <-notifications:
+			t.Fatalf("unexpected worktree.changed for %s: %+v", what, params)
+		case <-time.After(w.refreshDelay + 200*time.Millisecond):
+		}
+	}
+
+	// A burst of activity is coalesced
+	for i := 0; i < 5; i++ {
+		w.OnGitChange(GitChange{})
+		w.OnSessionListChange()
+	}
+	if got := expectList("first refresh"); len(got.Worktrees) != 1 || got.Worktrees[0].Dirty {
+		t.Errorf("unexpected list: %+v", got.Worktrees)
+	}
+	expectNone("coalesced activity")
+
+	// Activity leaving the list as it was sends nothing
+	w.OnGitChange(GitChange{})
+	expectNone("unchanged list")
+
+	mu.Lock()
+	worktrees[0].Dirty = true
+	mu.Unlock()
+	w.OnGitChange(GitChange{})
+	if got := expectList("dirty worktree"); len(got.Worktrees) != 1 || !got.Worktrees[0].Dirty {
+		t.Errorf("unexpected list: %+v", got.Worktrees)
+	}
+}
@@ -0,0 +1,146 @@
+package worktree
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/pockode/server/git"
+)
+
+// DeleteOptions controls Manager.Delete.
+type DeleteOptions struct {
+	Force        bool // delete even if work would be lost
+	DeleteBranch bool // also delete the worktree's branch
+}
+
+// DeleteReport describes work that would be lost by deleting a worktree.
+type DeleteReport struct {
+	Uncommitted      []git.FileStatus `json:"uncommitted"`
+	UnpushedCommits  []git.Commit     `json:"unpushed_commits"`
+	Upstream         string           `json:"upstream,omitempty"` // empty = compared against other branches and remotes
+	RunningProcesses int              `json:"running_processes"`
+}
+
+// Safe reports whether nothing would be lost.
+func (r *DeleteReport) Safe() bool {
+	return len(r.Uncommitted) == 0 && len(r.UnpushedCommits) == 0 && r.RunningProcesses == 0
+}
+
+// UnsafeDeleteError is returned by Manager.Delete when deleting without Force would lose work.
+type UnsafeDeleteError struct {
+	Report *DeleteReport
+}
+
+func (e *UnsafeDeleteError) Error() string {
+	var reasons []string
+	if n := len(e.Report.Uncommitted); n > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d uncommitted file(s)", n))
+	}
+	if n := len(e.Report.UnpushedCommits); n > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d unpushed commit(s)", n))
+	}
+	if n := e.Report.RunningProcesses; n > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d running agent process(es)", n))
+	}
+	return "worktree has " + strings.Join(reasons, ", ")
+}
+
+// DeleteResult is the outcome of a successful Manager.Delete.
+type DeleteResult struct {
+	BranchDeleted bool
+	BranchError   string // set when the worktree was deleted but its branch could not be
+}
+
+// Delete removes a worktree. Unless opts.Force is set, it refuses with an
+// *UnsafeDeleteError if the worktree has uncommitted changes, unpushed commits or
+// running agent processes. Subscribers are notified and the worktree's data
+// directory is removed (see ForceShutdown).
+func (m *Manager) Delete(name string, opts DeleteOptions) (*DeleteResult, error) {
+	if name == "" {
+		return nil, ErrMainWorktree
+	}
+
+	info, err := m.registry.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.Force {
+		report, err := m.DeleteReport(info)
+		if err != nil {
+			return nil, err
+		}
+		if !report.Safe() {
+			return nil, &UnsafeDeleteError{Report: report}
+		}
+	}
+
+	if err := m.registry.Delete(name); err != nil {
+		return nil, err
+	}
+	m.ForceShutdown(name)
+
+	result := &DeleteResult{}
+	if opts.DeleteBranch && info.Branch != "" {
+		if err := m.registry.DeleteBranch(info.Branch); err != nil {
+			slog.Warn("failed to delete worktree branch", "name", name, "branch", info.Branch, "error", err)
+			result.BranchError = err.Error()
+		} else {
+			result.BranchDeleted = true
+		}
+	}
+	return result, nil
+}
+
+// DeleteReport collects the work that would be lost by deleting the worktree.
+func (m *Manager) DeleteReport(info Info) (*DeleteReport, error) {
+	status, err := git.Status(info.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	commits, upstream, err := git.UnpushedCommits(info.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DeleteReport{
+		Uncommitted:     uncommittedFiles(status),
+		UnpushedCommits: commits,
+		Upstream:        upstream,
+	}
+
+	m.mu.Lock()
+	if wt, ok := m.worktrees[info.Name]; ok {
+		report.RunningProcesses = wt.ProcessManager.ProcessCount()
+	}
+	m.mu.Unlock()
+
+	return report, nil
+}
+
+// uncommittedFiles flattens a status into one entry per path, including submodule files.
+func uncommittedFiles(status *git.GitStatus) []git.FileStatus {
+	files := []git.FileStatus{}
+	seen := make(map[string]bool)
+
+	var collect func(prefix string, s *git.GitStatus)
+	collect = func(prefix string, s *git.GitStatus) {
+		for _, list := range [][]git.FileStatus{s.Conflicted, s.Staged, s.Unstaged} {
+			for _, f := range list {
+				path := prefix + f.Path
+				if seen[path] {
+					continue
+				}
+				seen[path] = true
+				files = append(files, git.FileStatus{Path: path, Status: f.Status})
+			}
+		}
+		for subPath, sub := range s.Submodules {
+			collect(prefix+subPath+"/", sub)
+		}
+	}
+	collect("", status)
+	return files
+}
@@ -0,0 +1,113 @@
+package worktree
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newDeleteTestManager(t *testing.T) (*Manager, *Registry) {
+	t.Helper()
+	dir := initGitRepo(t)
+	r := NewRegistry(dir)
+	return NewManager(r, nil, t.TempDir(), time.Minute), r
+}
+
+func runGitC(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %s", args, out)
+	}
+}
+
+func branchExists(dir, branch string) bool {
+	return exec.Command("git", "-C", dir, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch).Run() == nil
+}
+
+func TestManagerDelete_Clean(t *testing.T) {
+	m, r := newDeleteTestManager(t)
+	info, err := r.Create("clean", "clean-branch", "")
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	result, err := m.Delete("clean", DeleteOptions{DeleteBranch: true})
+	if err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if !result.BranchDeleted {
+		t.Errorf("expected branch to be deleted, got %+v", result)
+	}
+	if _, err := os.Stat(info.Path); !os.IsNotExist(err) {
+		t.Error("worktree directory still exists after Delete")
+	}
+	if branchExists(r.MainDir(), "clean-branch") {
+		t.Error("branch still exists")
+	}
+}
+
+func TestManagerDelete_RefusesUncommittedChanges(t *testing.T) {
+	m, r := newDeleteTestManager(t)
+	info, err := r.Create("dirty", "dirty-branch", "")
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	os.WriteFile(filepath.Join(info.Path, "new.txt"), []byte("work"), 0644)
+
+	_, err = m.Delete("dirty", DeleteOptions{})
+	var unsafe *UnsafeDeleteError
+	if !errors.As(err, &unsafe) {
+		t.Fatalf("expected UnsafeDeleteError, got %v", err)
+	}
+	if len(unsafe.Report.Uncommitted) != 1 || unsafe.Report.Uncommitted[0].Path != "new.txt" {
+		t.Errorf("unexpected report: %+v", unsafe.Report)
+	}
+	if _, err := os.Stat(info.Path); err != nil {
+		t.Fatal("worktree must not be removed when refused")
+	}
+
+	if _, err := m.Delete("dirty", DeleteOptions{Force: true}); err != nil {
+		t.Fatalf("forced Delete() failed: %v", err)
+	}
+	if _, err := os.Stat(info.Path); !os.IsNotExist(err) {
+		t.Error("worktree directory still exists after forced Delete")
+	}
+	if !branchExists(r.MainDir(), "dirty-branch") {
+		t.Error("branch should be kept without DeleteBranch")
+	}
+}
+
+func TestManagerDelete_RefusesUnpushedCommits(t *testing.T) {
+	m, r := newDeleteTestManager(t)
+	info, err := r.Create("ahead", "ahead-branch", "")
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	runGitC(t, info.Path, "commit", "--allow-empty", "-m", "only here")
+
+	_, err = m.Delete("ahead", DeleteOptions{DeleteBranch: true})
+	var unsafe *UnsafeDeleteError
+	if !errors.As(err, &unsafe) {
+		t.Fatalf("expected UnsafeDeleteError, got %v", err)
+	}
+	if len(unsafe.Report.UnpushedCommits) != 1 || unsafe.Report.UnpushedCommits[0].Subject != "only here" {
+		t.Errorf("unexpected report: %+v", unsafe.Report)
+	}
+
+	// Once the commit is reachable from another branch nothing would be lost
+	runGitC(t, r.MainDir(), "merge", "--ff-only", "ahead-branch")
+	if _, err := m.Delete("ahead", DeleteOptions{DeleteBranch: true}); err != nil {
+		t.Fatalf("Delete() after merge failed: %v", err)
+	}
+}
+
+func TestManagerDelete_MainWorktree(t *testing.T) {
+	m, _ := newDeleteTestManager(t)
+	if _, err := m.Delete("", DeleteOptions{Force: true}); err != ErrMainWorktree {
+		t.Errorf("Delete(\"\") error = %v, want ErrMainWorktree", err)
+	}
+}
@@ -0,0 +1,93 @@
+package worktree
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pockode/server/git"
+)
+
+var ErrNoBaseBranch = errors.New("worktree has no base branch to compare against")
+
+// DiffOptions controls Manager.Diff.
+type DiffOptions struct {
+	Against *string // worktree to compare against ("" = main); nil = the worktree's base branch
+	Path    string  // when set, also return the diff and contents of this file
+}
+
+// DiffResult compares a worktree (new side) against another worktree or its base
+// branch (old side). Both worktrees include their uncommitted and untracked changes.
+type DiffResult struct {
+	Base  string           // base branch compared against, empty when comparing worktrees
+	Files []git.FileStatus // changed files; Status is M, A, D or T
+	File  *git.DiffResult  // diff of DiffOptions.Path, if requested
+}
+
+// Diff compares the worktree name against opts.Against or, by default, against its
+// base branch. The base branch is the one recorded at creation (falling back to the
+// branch checked out in the main worktree); for the main worktree it is the upstream.
+// Against a base branch, the old side is the merge base so that commits made on the
+// base branch since the worktree forked are not shown as reverted.
+func (m *Manager) Diff(name string, opts DiffOptions) (*DiffResult, error) {
+	info, err := m.registry.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if !m.registry.IsGitRepo() {
+		return nil, ErrNotGitRepo
+	}
+
+	result := &DiffResult{}
+	var from string
+	if opts.Against != nil {
+		other, err := m.registry.Get(*opts.Against)
+		if err != nil {
+			return nil, err
+		}
+		// The other side needs a tree to diff against; this side is compared in place
+		if from, err = git.SnapshotTree(other.Path); err != nil {
+			return nil, err
+		}
+	} else {
+		base, err := m.baseBranch(info)
+		if err != nil {
+			return nil, err
+		}
+		if from, err = git.MergeBase(info.Path, base, "HEAD"); err != nil {
+			return nil, fmt.Errorf("compare with %s: %w", base, err)
+		}
+		result.Base = base
+	}
+
+	if result.Files, err = git.DiffWorkingTree(info.Path, from); err != nil {
+		return nil, err
+	}
+	if opts.Path != "" {
+		if result.File, err = git.DiffWorkingTreeFile(info.Path, from, opts.Path); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (m *Manager) baseBranch(info Info) (string, error) {
+	var base string
+	if info.IsMain {
+		base = git.Upstream(info.Path)
+	} else {
+		m.metaMu.Lock()
+		meta, err := m.readMetadata(info.Name)
+		m.metaMu.Unlock()
+		if err != nil {
+			return "", err
+		}
+		base = meta.BaseBranch
+		if base == "" {
+			base = git.CurrentBranch(m.registry.MainDir())
+		}
+	}
+	if base == "" || base == info.Branch {
+		return "", ErrNoBaseBranch
+	}
+	return base, nil
+}
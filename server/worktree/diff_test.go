@@ -0,0 +1,88 @@
+package worktree
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManagerDiff_AgainstBaseBranch(t *testing.T) {
+	m, r := newDeleteTestManager(t)
+	info, err := m.Create(CreateOptions{Name: "feature", Branch: "feature-branch"})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(info.Path, "committed.txt"), []byte("one\n"), 0644)
+	runGitC(t, info.Path, "add", "committed.txt")
+	runGitC(t, info.Path, "commit", "-m", "feature")
+	os.WriteFile(filepath.Join(info.Path, "untracked.txt"), []byte("two\n"), 0644)
+
+	// Commits on the base branch after forking must not show up as reverted
+	os.WriteFile(filepath.Join(r.MainDir(), "main.txt"), []byte("main\n"), 0644)
+	runGitC(t, r.MainDir(), "add", "main.txt")
+	runGitC(t, r.MainDir(), "commit", "-m", "main")
+
+	result, err := m.Diff("feature", DiffOptions{Path: "untracked.txt"})
+	if err != nil {
+		t.Fatalf("Diff() failed: %v", err)
+	}
+	if result.Base == "" {
+		t.Error("expected base branch in result")
+	}
+	got := map[string]string{}
+	for _, f := range result.Files {
+		got[f.Path] = f.Status
+	}
+	if len(got) != 2 || got["committed.txt"] != "A" || got["untracked.txt"] != "A" {
+		t.Errorf("Files = %+v, want committed.txt and untracked.txt added", result.Files)
+	}
+	if result.File == nil || result.File.NewContent != "two\n" || result.File.OldContent != "" {
+		t.Errorf("File = %+v, want new content of untracked.txt", result.File)
+	}
+}
+
+func TestManagerDiff_AgainstWorktree(t *testing.T) {
+	m, _ := newDeleteTestManager(t)
+	a, err := m.Create(CreateOptions{Name: "a", Branch: "a-branch"})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	b, err := m.Create(CreateOptions{Name: "b", Branch: "b-branch"})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(a.Path, "shared.txt"), []byte("from a\n"), 0644)
+	os.WriteFile(filepath.Join(b.Path, "shared.txt"), []byte("from b\n"), 0644)
+	os.WriteFile(filepath.Join(b.Path, "only-b.txt"), []byte("b\n"), 0644)
+
+	against := "a"
+	result, err := m.Diff("b", DiffOptions{Against: &against, Path: "shared.txt"})
+	if err != nil {
+		t.Fatalf("Diff() failed: %v", err)
+	}
+	got := map[string]string{}
+	for _, f := range result.Files {
+		got[f.Path] = f.Status
+	}
+	if len(got) != 2 || got["shared.txt"] != "M" || got["only-b.txt"] != "A" {
+		t.Errorf("Files = %+v", result.Files)
+	}
+	if result.File.OldContent != "from a\n" || result.File.NewContent != "from b\n" {
+		t.Errorf("File = %+v", result.File)
+	}
+
+	missing := "missing"
+	if _, err := m.Diff("b", DiffOptions{Against: &missing}); !errors.Is(err, ErrWorktreeNotFound) {
+		t.Errorf("expected ErrWorktreeNotFound, got %v", err)
+	}
+}
+
+func TestManagerDiff_MainWithoutUpstream(t *testing.T) {
+	m, _ := newDeleteTestManager(t)
+	if _, err := m.Diff("", DiffOptions{}); !errors.Is(err, ErrNoBaseBranch) {
+		t.Errorf("expected ErrNoBaseBranch, got %v", err)
+	}
+}
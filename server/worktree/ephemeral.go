@@ -0,0 +1,101 @@
+package worktree
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/pockode/server/git"
+	"github.com/pockode/server/session"
+)
+
+// sessionWorktreePrefix names worktrees (and their branches) created for a single session.
+const sessionWorktreePrefix = "session-"
+
+// CreateSessionWorktree creates a worktree dedicated to a new session: an auto-named
+// branch off baseBranch (empty = branch checked out in the main worktree) with the
+// session created in the worktree's store. The worktree is marked as bound to the
+// session so it can be cleaned up with it (see CleanupSessionWorktree).
+func (m *Manager) CreateSessionWorktree(ctx context.Context, sessionID, baseBranch string) (Info, session.SessionMeta, error) {
+	// UUIDv7 starts with a timestamp, so take the random tail for the name
+	name := sessionWorktreePrefix + sessionID[len(sessionID)-8:]
+
+	info, err := m.Create(CreateOptions{
+		Name:       name,
+		Branch:     name,
+		BaseBranch: baseBranch,
+		CreatedBy:  "session:" + sessionID,
+		Session:    sessionID,
+	})
+	if err != nil {
+		return Info{}, session.SessionMeta{}, err
+	}
+
+	wt, err := m.Get(name)
+	if err != nil {
+		return Info{}, session.SessionMeta{}, m.rollbackSessionWorktree(name, err)
+	}
+	defer m.Release(wt)
+
+	sess, err := wt.SessionStore.Create(ctx, sessionID)
+	if err != nil {
+		return Info{}, session.SessionMeta{}, m.rollbackSessionWorktree(name, err)
+	}
+	return info, sess, nil
+}
+
+func (m *Manager) rollbackSessionWorktree(name string, cause error) error {
+	if _, err := m.Delete(name, DeleteOptions{Force: true, DeleteBranch: true}); err != nil {
+		slog.Warn("failed to remove session worktree after error", "name", name, "error", err)
+	}
+	return fmt.Errorf("create session in worktree: %w", cause)
+}
+
+// CleanupSessionWorktree removes the worktree bound to a deleted session, along with
+// its branch, once the branch is merged into its base branch, including by a
+// squash merge (see git.IsSquashMerged for its limits). Worktrees with
+// uncommitted changes, unmerged commits or running processes are kept.
+// Returns true if the worktree was removed.
+func (m *Manager) CleanupSessionWorktree(name, sessionID string) bool {
+	if name == "" {
+		return false
+	}
+	info, err := m.registry.Get(name)
+	if err != nil {
+		return false
+	}
+
+	m.metaMu.Lock()
+	meta, err := m.readMetadata(name)
+	m.metaMu.Unlock()
+	if err != nil || meta.Session == "" || meta.Session != sessionID {
+		return false
+	}
+
+	base, err := m.baseBranch(info)
+	if err != nil || info.Branch == "" {
+		return false
+	}
+	opts := DeleteOptions{DeleteBranch: true}
+	if !git.IsAncestor(info.Path, info.Branch, base) {
+		if !git.IsSquashMerged(info.Path, info.Branch, base) {
+			slog.Info("keeping session worktree with unmerged branch", "name", name, "branch", info.Branch, "base", base)
+			return false
+		}
+		// The squashed commits look unpushed to Delete, so check the rest ourselves
+		report, err := m.DeleteReport(info)
+		if err != nil || len(report.Uncommitted) > 0 || report.RunningProcesses > 0 {
+			slog.Info("keeping squash-merged session worktree", "name", name, "branch", info.Branch)
+			return false
+		}
+		opts.Force = true
+	}
+
+	result, err := m.Delete(name, opts)
+	if err != nil {
+		slog.Info("keeping session worktree", "name", name, "reason", err)
+		return false
+	}
+	slog.Info("session worktree cleaned up", "name", name, "branchDeleted", result.BranchDeleted)
+	return true
+}
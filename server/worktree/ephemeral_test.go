@@ -0,0 +1,116 @@
+package worktree
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCreateSessionWorktree(t *testing.T) {
+	m, r := newDeleteTestManager(t)
+	sessionID := uuid.Must(uuid.NewV7()).String()
+
+	info, sess, err := m.CreateSessionWorktree(context.Background(), sessionID, "")
+	if err != nil {
+		t.Fatalf("CreateSessionWorktree() failed: %v", err)
+	}
+	if sess.ID != sessionID {
+		t.Errorf("session ID = %q, want %q", sess.ID, sessionID)
+	}
+	if info.Branch != info.Name || !branchExists(r.MainDir(), info.Branch) {
+		t.Errorf("expected auto-named branch, got %+v", info)
+	}
+
+	got := m.Describe(info)
+	if got.Session != sessionID || got.SessionCount != 1 {
+		t.Errorf("expected worktree bound to session, got %+v", got)
+	}
+}
+
+func TestCleanupSessionWorktree_Merged(t *testing.T) {
+	m, r := newDeleteTestManager(t)
+	sessionID := uuid.Must(uuid.NewV7()).String()
+	info, _, err := m.CreateSessionWorktree(context.Background(), sessionID, "")
+	if err != nil {
+		t.Fatalf("CreateSessionWorktree() failed: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(info.Path, "work.txt"), []byte("work"), 0644)
+	runGitC(t, info.Path, "add", "work.txt")
+	runGitC(t, info.Path, "commit", "-m", "work")
+
+	if m.CleanupSessionWorktree(info.Name, "other-session") {
+		t.Fatal("cleaned up worktree bound to another session")
+	}
+	if m.CleanupSessionWorktree(info.Name, sessionID) {
+		t.Fatal("cleaned up worktree with unmerged branch")
+	}
+
+	runGitC(t, r.MainDir(), "merge", "--no-ff", "--no-edit", info.Branch)
+
+	if !m.CleanupSessionWorktree(info.Name, sessionID) {
+		t.Fatal("expected merged session worktree to be cleaned up")
+	}
+	if _, err := os.Stat(info.Path); !os.IsNotExist(err) {
+		t.Error("worktree directory still exists")
+	}
+	if branchExists(r.MainDir(), info.Branch) {
+		t.Error("branch still exists")
+	}
+}
+
+func TestCleanupSessionWorktree_SquashMerged(t *testing.T) {
+	m, r := newDeleteTestManager(t)
+	sessionID := uuid.Must(uuid.NewV7()).String()
+	info, _, err := m.CreateSessionWorktree(context.Background(), sessionID, "")
+	if err != nil {
+		t.Fatalf("CreateSessionWorktree() failed: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(info.Path, "work.txt"), []byte("work"), 0644)
+	runGitC(t, info.Path, "add", "work.txt")
+	runGitC(t, info.Path, "commit", "-m", "work")
+	os.WriteFile(filepath.Join(info.Path, "more.txt"), []byte("more"), 0644)
+	runGitC(t, info.Path, "add", "more.txt")
+	runGitC(t, info.Path, "commit", "-m", "more work")
+
+	runGitC(t, r.MainDir(), "merge", "--squash", info.Branch)
+	runGitC(t, r.MainDir(), "commit", "-m", "squashed work")
+
+	if !m.CleanupSessionWorktree(info.Name, sessionID) {
+		t.Fatal("expected squash-merged session worktree to be cleaned up")
+	}
+	if branchExists(r.MainDir(), info.Branch) {
+		t.Error("branch still exists")
+	}
+}
+
+func TestCleanupSessionWorktree_KeepsUncommittedWork(t *testing.T) {
+	m, _ := newDeleteTestManager(t)
+	sessionID := uuid.Must(uuid.NewV7()).String()
+	info, _, err := m.CreateSessionWorktree(context.Background(), sessionID, "")
+	if err != nil {
+		t.Fatalf("CreateSessionWorktree() failed: %v", err)
+	}
+	os.WriteFile(filepath.Join(info.Path, "draft.txt"), []byte("draft"), 0644)
+
+	if m.CleanupSessionWorktree(info.Name, sessionID) {
+		t.Fatal("cleaned up worktree with uncommitted changes")
+	}
+	if _, err := os.Stat(info.Path); err != nil {
+		t.Errorf("worktree directory removed: %v", err)
+	}
+}
+
+func TestCleanupSessionWorktree_IgnoresRegularWorktree(t *testing.T) {
+	m, _ := newDeleteTestManager(t)
+	if _, err := m.Create(CreateOptions{Name: "feature", Branch: "feature-branch"}); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if m.CleanupSessionWorktree("feature", "") {
+		t.Fatal("cleaned up a worktree not bound to a session")
+	}
+}
@@ -0,0 +1,38 @@
+package worktree
+
+import (
+	"strings"
+
+	"github.com/pockode/server/contents"
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/watch"
+)
+
+// fileIndexUpdater feeds watcher changes to a worktree's file index: created,
+// removed and renamed paths are refreshed, while ref and index changes (e.g. a
+// checkout) and missed events rebuild it.
+type fileIndexUpdater struct {
+	index *contents.FileIndex
+}
+
+var _ watch.FSChangeListener = fileIndexUpdater{}
+var _ watch.GitChangeListener = fileIndexUpdater{}
+
+// OnFSChange implements watch.FSChangeListener.
+func (u fileIndexUpdater) OnFSChange(change rpc.FSChange) {
+	// Writes do not change the file list; changes inside .git are reported
+	// through OnGitChange
+	if change.Op == rpc.FSOpWrite || change.Path == ".git" || strings.HasPrefix(change.Path, ".git/") {
+		return
+	}
+	u.index.Changed(change.Path)
+}
+
+// OnGitChange implements watch.GitChangeListener.
+func (u fileIndexUpdater) OnGitChange(change watch.GitChange) {
+	if change.Refs || change.Rescan {
+		u.index.Invalidate()
+		return
+	}
+	u.index.Changed(change.Paths...)
+}
@@ -10,15 +10,24 @@ import (
 	"time"
 
 	"github.com/pockode/server/agent"
+	"github.com/pockode/server/contents"
+	"github.com/pockode/server/git"
 	"github.com/pockode/server/process"
 	"github.com/pockode/server/rpc"
 	"github.com/pockode/server/session"
+	"github.com/pockode/server/snapshot"
+	"github.com/pockode/server/snippet"
+	"github.com/pockode/server/task"
+	"github.com/pockode/server/terminal"
 	"github.com/pockode/server/watch"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
 const idleReleaseDelay = 30 * time.Second
 
+// blameCacheSize is the number of file blames cached per worktree.
+const blameCacheSize = 64
+
 // Manager manages the lifecycle of worktrees with lazy creation and reference-counted cleanup.
 type Manager struct {
 	registry        *Registry
@@ -26,20 +35,29 @@ type Manager struct {
 	dataDir         string
 	idleTimeout     time.Duration
 	WorktreeWatcher *watch.WorktreeWatcher
+	setup           *SetupRunner
+
+	metaMu sync.Mutex // serializes metadata file read-modify-write
+
+	stopPrune context.CancelFunc
 
 	mu        sync.Mutex
 	worktrees map[string]*Worktree
 }
 
 func NewManager(registry *Registry, ag agent.Agent, dataDir string, idleTimeout time.Duration) *Manager {
-	return &Manager{
+	m := &Manager{
 		registry:        registry,
 		agent:           ag,
 		dataDir:         dataDir,
 		idleTimeout:     idleTimeout,
 		WorktreeWatcher: watch.NewWorktreeWatcher(registry.MainDir()),
+		setup:           NewSetupRunner(registry.MainDir()),
 		worktrees:       make(map[string]*Worktree),
 	}
+	m.setup.SetListener(m)
+	m.WorktreeWatcher.SetListProvider(m.List)
+	return m
 }
 
 func (m *Manager) Registry() *Registry {
@@ -47,6 +65,10 @@ func (m *Manager) Registry() *Registry {
 }
 
 func (m *Manager) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.stopPrune = cancel
+	go m.runPruneLoop(ctx)
+
 	return m.WorktreeWatcher.Start()
 }
 
@@ -90,6 +112,15 @@ func (m *Manager) Get(name string) (*Worktree, error) {
 	return wt, nil
 }
 
+// Active returns the worktree if it is currently in use, without acquiring it.
+// Callers must not hold on to it, since it may be stopped at any time.
+func (m *Manager) Active(name string) (*Worktree, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	wt, ok := m.worktrees[name]
+	return wt, ok
+}
+
 // Release decrements the reference count and schedules cleanup after idleReleaseDelay.
 func (m *Manager) Release(wt *Worktree) {
 	m.mu.Lock()
@@ -106,9 +137,54 @@ func (m *Manager) Release(wt *Worktree) {
 	}
 }
 
+// SetupStatus returns the setup status of a worktree, or nil if no setup ran since server start.
+func (m *Manager) SetupStatus(name string) *rpc.WorktreeSetupStatus {
+	status, ok := m.setup.Status(name)
+	if !ok {
+		return nil
+	}
+	return setupStatusToRPC(status)
+}
+
+func (m *Manager) OnSetupOutput(name, stream, data string) {
+	m.WorktreeWatcher.NotifyAll("worktree.setup.output", func(sub *watch.Subscription) any {
+		return rpc.WorktreeSetupOutputParams{ID: sub.ID, Name: name, Stream: stream, Data: data}
+	})
+}
+
+func (m *Manager) OnSetupStatus(name string, status SetupStatus) {
+	m.WorktreeWatcher.NotifyAll("worktree.setup.status", func(sub *watch.Subscription) any {
+		return rpc.WorktreeSetupStatusParams{ID: sub.ID, Name: name, Setup: *setupStatusToRPC(status)}
+	})
+}
+
+func setupStatusToRPC(status SetupStatus) *rpc.WorktreeSetupStatus {
+	result := &rpc.WorktreeSetupStatus{
+		State:     string(status.State),
+		Step:      status.Step,
+		Error:     status.Error,
+		StartedAt: status.StartedAt,
+	}
+	if !status.FinishedAt.IsZero() {
+		result.FinishedAt = &status.FinishedAt
+	}
+	return result
+}
+
 // ForceShutdown immediately shuts down a worktree, notifies all subscribers,
 // and removes the worktree's data directory from .pockode.
 func (m *Manager) ForceShutdown(name string) {
+	m.unload(name)
+
+	wtDataDir := filepath.Join(m.dataDir, "worktrees", name)
+	if err := os.RemoveAll(wtDataDir); err != nil {
+		slog.Warn("failed to remove worktree data directory", "path", wtDataDir, "error", err)
+	}
+}
+
+// unload shuts down a worktree's in-memory state and notifies its subscribers,
+// keeping its data directory.
+func (m *Manager) unload(name string) {
 	m.mu.Lock()
 	wt, exists := m.worktrees[name]
 	if exists {
@@ -116,19 +192,19 @@ func (m *Manager) ForceShutdown(name string) {
 	}
 	m.mu.Unlock()
 
+	m.setup.Forget(name)
+
 	if exists {
 		wt.NotifyAll(context.Background(), "worktree.deleted", rpc.WorktreeDeletedParams{Name: name})
 		wt.Stop()
 		slog.Info("worktree force shutdown", "name", name)
 	}
-
-	wtDataDir := filepath.Join(m.dataDir, "worktrees", name)
-	if err := os.RemoveAll(wtDataDir); err != nil {
-		slog.Warn("failed to remove worktree data directory", "path", wtDataDir, "error", err)
-	}
 }
 
 func (m *Manager) Shutdown() {
+	if m.stopPrune != nil {
+		m.stopPrune()
+	}
 	m.WorktreeWatcher.Stop()
 
 	m.mu.Lock()
@@ -146,44 +222,83 @@ func (m *Manager) Shutdown() {
 	slog.Info("manager shutdown complete", "worktreesClosed", len(worktrees))
 }
 
-func (m *Manager) create(name, workDir string) (*Worktree, error) {
-	var wtDataDir string
+// worktreeDataDir returns where a worktree's sessions, snapshots and metadata are stored.
+func (m *Manager) worktreeDataDir(name string) string {
 	if name == "" {
-		wtDataDir = m.dataDir
-	} else {
-		wtDataDir = filepath.Join(m.dataDir, "worktrees", name)
+		return m.dataDir
 	}
+	return filepath.Join(m.dataDir, "worktrees", name)
+}
+
+func (m *Manager) create(name, workDir string) (*Worktree, error) {
+	wtDataDir := m.worktreeDataDir(name)
 
 	sessionStore, err := session.NewFileStore(wtDataDir)
 	if err != nil {
 		return nil, fmt.Errorf("create session store: %w", err)
 	}
 
+	snapshotStore, err := snapshot.NewStore(wtDataDir)
+	if err != nil {
+		return nil, fmt.Errorf("create snapshot store: %w", err)
+	}
+
+	snippetStore, err := snippet.NewStore(wtDataDir, snippet.ScopeWorktree)
+	if err != nil {
+		return nil, fmt.Errorf("create snippet store: %w", err)
+	}
+
 	fsWatcher := watch.NewFSWatcher(workDir)
 	gitWatcher := watch.NewGitWatcher(workDir)
 	gitDiffWatcher := watch.NewGitDiffWatcher(workDir)
+	gitWatcher.AddChangeListener(gitDiffWatcher)
+	fileIndex := contents.NewFileIndex(workDir)
+	fsWatcher.SetChangeListener(fileIndexUpdater{fileIndex})
+	gitWatcher.AddChangeListener(fileIndexUpdater{fileIndex})
+	gitWatcher.AddChangeListener(m.WorktreeWatcher)
 	sessionListWatcher := watch.NewSessionListWatcher(sessionStore)
+	sessionListWatcher.AddChangeListener(m.WorktreeWatcher)
 	chatMessagesWatcher := watch.NewChatMessagesWatcher(sessionStore)
 	processManager := process.NewManager(m.agent, workDir, sessionStore, m.idleTimeout)
 	processManager.SetMessageListener(chatMessagesWatcher)
+	terminalWatcher := watch.NewTerminalWatcher()
+	terminals := terminal.NewManager(workDir)
+	terminals.SetListener(terminalWatcher)
+	tasks := task.NewRunner(name, workDir, m.registry.MainDir(), wtDataDir)
+	taskWatcher := watch.NewTaskWatcher(tasks)
 
 	wt := &Worktree{
 		Name:                name,
 		WorkDir:             workDir,
 		SessionStore:        sessionStore,
+		SnapshotStore:       snapshotStore,
+		SnippetStore:        snippetStore,
+		BlameCache:          git.NewBlameCache(blameCacheSize),
+		FileIndex:           fileIndex,
 		FSWatcher:           fsWatcher,
 		GitWatcher:          gitWatcher,
 		GitDiffWatcher:      gitDiffWatcher,
 		SessionListWatcher:  sessionListWatcher,
 		ChatMessagesWatcher: chatMessagesWatcher,
+		TerminalWatcher:     terminalWatcher,
+		TaskWatcher:         taskWatcher,
 		ProcessManager:      processManager,
-		watchers:            []watch.Watcher{fsWatcher, gitWatcher, gitDiffWatcher, sessionListWatcher, chatMessagesWatcher},
+		Terminals:           terminals,
+		Tasks:               tasks,
+		watchers:            []watch.Watcher{fsWatcher, gitWatcher, gitDiffWatcher, sessionListWatcher, chatMessagesWatcher, terminalWatcher, taskWatcher},
 		subscribers:         make(map[*jsonrpc2.Conn]struct{}),
 	}
 
 	processManager.SetOnProcessEnd(func() {
 		m.maybeCleanup(wt)
 	})
+	// Running terminals and tasks keep the worktree alive so clients can reattach
+	terminals.SetOnExit(func() {
+		m.maybeCleanup(wt)
+	})
+	tasks.SetOnFinish(func() {
+		m.maybeCleanup(wt)
+	})
 
 	if err := wt.Start(); err != nil {
 		return nil, fmt.Errorf("start worktree: %w", err)
@@ -213,11 +328,13 @@ func (m *Manager) shouldCleanupLocked(wt *Worktree) bool {
 		return false
 	}
 
-	if wt.refCount > 0 || wt.ProcessManager.ProcessCount() > 0 {
+	if wt.refCount > 0 || wt.ProcessManager.ProcessCount() > 0 || wt.Terminals.RunningCount() > 0 || wt.Tasks.RunningCount() > 0 {
 		slog.Debug("worktree cleanup skipped",
 			"name", wt.Name,
 			"refCount", wt.refCount,
-			"processCount", wt.ProcessManager.ProcessCount())
+			"processCount", wt.ProcessManager.ProcessCount(),
+			"terminalCount", wt.Terminals.RunningCount(),
+			"taskCount", wt.Tasks.RunningCount())
 		return false
 	}
 
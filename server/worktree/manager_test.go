@@ -24,6 +24,7 @@ func TestForceShutdown_RemovesDataDirectory(t *testing.T) {
 
 	m := &Manager{
 		dataDir:   dataDir,
+		setup:     NewSetupRunner(dataDir),
 		worktrees: make(map[string]*Worktree),
 	}
 
@@ -0,0 +1,127 @@
+package worktree
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pockode/server/git"
+)
+
+var (
+	ErrNoBranch           = errors.New("worktree has no branch checked out")
+	ErrInvalidMergeTarget = errors.New("invalid merge target")
+)
+
+// MergeOptions controls Manager.Merge.
+type MergeOptions struct {
+	Target         string            // branch to merge into, checked out in the main worktree; empty = that branch
+	Strategy       git.MergeStrategy // empty = merge
+	Message        string            // commit message for merge/squash; empty = git default
+	DeleteWorktree bool              // delete the worktree after a successful merge
+	DeleteBranch   bool              // with DeleteWorktree, also delete the merged branch
+}
+
+// MergeResult is the outcome of a successful Manager.Merge.
+type MergeResult struct {
+	Target        string
+	Commit        string // HEAD of the target branch after the merge
+	UpToDate      bool   // branch was already merged or squash-merged; nothing changed
+	Deleted       bool
+	BranchDeleted bool
+	BranchError   string
+}
+
+// Merge integrates a worktree's branch into a target branch in the main worktree.
+// The target must be the branch checked out there: Merge never switches the
+// user's branch.
+//
+// Conflicts are returned as *git.ConflictError. For the merge strategy the merge is
+// left in progress in the main worktree; for rebase the rebase is left in progress in
+// the worktree being merged. Either can be resolved with the conflict RPCs and then
+// continued or aborted, after which Merge can be retried.
+//
+// With DeleteWorktree, the worktree must have no uncommitted changes or running agent
+// processes, since those would be lost; this is checked before merging.
+func (m *Manager) Merge(name string, opts MergeOptions) (*MergeResult, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%w: cannot merge the main worktree", ErrInvalidMergeTarget)
+	}
+	info, err := m.registry.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.Branch == "" {
+		return nil, ErrNoBranch
+	}
+
+	strategy := opts.Strategy
+	if strategy == "" {
+		strategy = git.MergeStrategyMerge
+	}
+	switch strategy {
+	case git.MergeStrategyMerge, git.MergeStrategySquash, git.MergeStrategyRebase:
+	default:
+		return nil, fmt.Errorf("%w: %s", git.ErrInvalidStrategy, strategy)
+	}
+
+	mainDir := m.registry.MainDir()
+	target := opts.Target
+	if target == "" {
+		target = git.CurrentBranch(mainDir)
+		if target == "" {
+			return nil, fmt.Errorf("%w: main worktree has no branch checked out", ErrInvalidMergeTarget)
+		}
+	}
+	if target == info.Branch {
+		return nil, fmt.Errorf("%w: target is the worktree's own branch", ErrInvalidMergeTarget)
+	}
+
+	if opts.DeleteWorktree {
+		report, err := m.DeleteReport(info)
+		if err != nil {
+			return nil, err
+		}
+		// Unpushed commits are what is being merged, so only local state matters here
+		report.UnpushedCommits = nil
+		if !report.Safe() {
+			return nil, &UnsafeDeleteError{Report: report}
+		}
+	}
+
+	if current := git.CurrentBranch(mainDir); current != target {
+		return nil, fmt.Errorf("%w: main worktree is on %q, not %q; check out the target branch first", ErrInvalidMergeTarget, current, target)
+	}
+
+	result := &MergeResult{Target: target}
+	// A squash merge leaves the branch unreachable from target, and merging it
+	// again would fail with nothing to commit
+	result.UpToDate = git.IsAncestor(mainDir, info.Branch, target) || git.IsSquashMerged(mainDir, info.Branch, target)
+
+	if !result.UpToDate {
+		if strategy == git.MergeStrategyRebase {
+			if err := git.RebaseBranch(info.Path, target); err != nil {
+				return nil, err
+			}
+		}
+		if err := git.MergeBranch(mainDir, info.Branch, strategy, opts.Message); err != nil {
+			return nil, err
+		}
+	}
+
+	if result.Commit, err = git.HeadCommit(mainDir); err != nil {
+		return nil, err
+	}
+
+	if opts.DeleteWorktree {
+		// Squashed commits are not reachable from target, so skip the unpushed check
+		deleted, err := m.Delete(name, DeleteOptions{Force: true, DeleteBranch: opts.DeleteBranch})
+		if err != nil {
+			return nil, fmt.Errorf("merged but failed to delete worktree: %w", err)
+		}
+		result.Deleted = true
+		result.BranchDeleted = deleted.BranchDeleted
+		result.BranchError = deleted.BranchError
+	}
+
+	return result, nil
+}
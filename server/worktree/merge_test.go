@@ -0,0 +1,135 @@
+package worktree
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pockode/server/git"
+)
+
+func TestManagerMerge_MergeAndDelete(t *testing.T) {
+	m, r := newDeleteTestManager(t)
+	info, err := r.Create("feature", "feature-branch", "")
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	os.WriteFile(filepath.Join(info.Path, "feature.txt"), []byte("work"), 0644)
+	runGitC(t, info.Path, "add", "feature.txt")
+	runGitC(t, info.Path, "commit", "-m", "feature work")
+
+	result, err := m.Merge("feature", MergeOptions{Strategy: git.MergeStrategySquash, Message: "Add feature", DeleteWorktree: true, DeleteBranch: true})
+	if err != nil {
+		t.Fatalf("Merge() failed: %v", err)
+	}
+	if result.UpToDate || !result.Deleted || !result.BranchDeleted || result.Commit == "" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(r.MainDir(), "feature.txt")); err != nil {
+		t.Error("expected merged file in main worktree")
+	}
+	if _, err := os.Stat(info.Path); !os.IsNotExist(err) {
+		t.Error("expected worktree to be deleted")
+	}
+}
+
+func TestManagerMerge_RefusesTargetNotCheckedOut(t *testing.T) {
+	m, r := newDeleteTestManager(t)
+	if _, err := r.Create("feature", "feature-branch", ""); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	runGitC(t, r.MainDir(), "branch", "release")
+	before := git.CurrentBranch(r.MainDir())
+
+	if _, err := m.Merge("feature", MergeOptions{Target: "release"}); !errors.Is(err, ErrInvalidMergeTarget) {
+		t.Errorf("expected ErrInvalidMergeTarget, got %v", err)
+	}
+	if got := git.CurrentBranch(r.MainDir()); got != before {
+		t.Errorf("main worktree switched to %q, want %q", got, before)
+	}
+}
+
+func TestManagerMerge_UpToDate(t *testing.T) {
+	m, r := newDeleteTestManager(t)
+	if _, err := r.Create("feature", "feature-branch", ""); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	result, err := m.Merge("feature", MergeOptions{})
+	if err != nil {
+		t.Fatalf("Merge() failed: %v", err)
+	}
+	if !result.UpToDate || result.Deleted {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestManagerMerge_SquashTwice(t *testing.T) {
+	m, r := newDeleteTestManager(t)
+	info, err := r.Create("feature", "feature-branch", "")
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	os.WriteFile(filepath.Join(info.Path, "feature.txt"), []byte("work"), 0644)
+	runGitC(t, info.Path, "add", "feature.txt")
+	runGitC(t, info.Path, "commit", "-m", "feature work")
+
+	first, err := m.Merge("feature", MergeOptions{Strategy: git.MergeStrategySquash, Message: "Add feature"})
+	if err != nil {
+		t.Fatalf("first Merge() failed: %v", err)
+	}
+	if first.UpToDate {
+		t.Fatalf("unexpected result: %+v", first)
+	}
+
+	second, err := m.Merge("feature", MergeOptions{Strategy: git.MergeStrategySquash, Message: "Add feature"})
+	if err != nil {
+		t.Fatalf("second Merge() failed: %v", err)
+	}
+	if !second.UpToDate || second.Commit != first.Commit {
+		t.Errorf("unexpected result: %+v, want up to date at %s", second, first.Commit)
+	}
+}
+
+func TestManagerMerge_RebaseConflictLeftInWorktree(t *testing.T) {
+	m, r := newDeleteTestManager(t)
+	info, err := r.Create("feature", "feature-branch", "")
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	os.WriteFile(filepath.Join(info.Path, "file.txt"), []byte("feature"), 0644)
+	runGitC(t, info.Path, "add", "file.txt")
+	runGitC(t, info.Path, "commit", "-m", "feature")
+	os.WriteFile(filepath.Join(r.MainDir(), "file.txt"), []byte("main"), 0644)
+	runGitC(t, r.MainDir(), "add", "file.txt")
+	runGitC(t, r.MainDir(), "commit", "-m", "main")
+
+	_, err = m.Merge("feature", MergeOptions{Strategy: git.MergeStrategyRebase})
+	var conflict *git.ConflictError
+	if !errors.As(err, &conflict) || conflict.Operation != "rebase" {
+		t.Fatalf("expected rebase conflict, got %v", err)
+	}
+	if op := git.OperationInProgress(info.Path); op != git.OperationRebase {
+		t.Errorf("expected rebase in progress in the worktree, got %q", op)
+	}
+}
+
+func TestManagerMerge_DeleteRefusedWithUncommittedWork(t *testing.T) {
+	m, r := newDeleteTestManager(t)
+	info, err := r.Create("feature", "feature-branch", "")
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	os.WriteFile(filepath.Join(info.Path, "wip.txt"), []byte("wip"), 0644)
+
+	_, err = m.Merge("feature", MergeOptions{DeleteWorktree: true})
+	var unsafe *UnsafeDeleteError
+	if !errors.As(err, &unsafe) {
+		t.Fatalf("expected UnsafeDeleteError, got %v", err)
+	}
+
+	if _, err := m.Merge("feature", MergeOptions{Target: "feature-branch"}); !errors.Is(err, ErrInvalidMergeTarget) {
+		t.Errorf("expected ErrInvalidMergeTarget, got %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+package worktree
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pockode/server/git"
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/session"
+)
+
+const metadataFile = "worktree.json"
+
+// Metadata is persisted per worktree in its data directory and removed with it.
+// Worktrees created outside Pockode (or before metadata existed) have none.
+type Metadata struct {
+	Description string    `json:"description"`
+	BaseBranch  string    `json:"base_branch"`
+	CreatedBy   string    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	Session     string    `json:"session,omitempty"` // session the worktree was created for and is cleaned up with
+}
+
+// CreateOptions configures Manager.Create.
+type CreateOptions struct {
+	Name        string
+	Branch      string
+	BaseBranch  string // empty = branch checked out in the main worktree
+	Description string
+	CreatedBy   string // free-form, e.g. "user" or "session:<id>"
+	Session     string // binds the worktree to a session (see CreateSessionWorktree)
+}
+
+// Create creates a worktree, records its metadata and starts the repository's
+// worktree setup (see SetupConfigFile).
+func (m *Manager) Create(opts CreateOptions) (Info, error) {
+	info, err := m.registry.Create(opts.Name, opts.Branch, opts.BaseBranch)
+	if err != nil {
+		return Info{}, err
+	}
+
+	baseBranch := opts.BaseBranch
+	if baseBranch == "" {
+		baseBranch = git.CurrentBranch(m.registry.MainDir())
+	}
+	meta := Metadata{
+		Description: opts.Description,
+		BaseBranch:  baseBranch,
+		CreatedBy:   opts.CreatedBy,
+		CreatedAt:   time.Now(),
+		Session:     opts.Session,
+	}
+	if err := m.saveMetadata(info.Name, meta); err != nil {
+		slog.Warn("failed to save worktree metadata", "name", info.Name, "error", err)
+	}
+
+	if m.setup.Run(info.Name, info.Path) {
+		slog.Info("worktree setup started", "name", info.Name)
+	}
+	m.WorktreeWatcher.NotifyChanged()
+
+	return info, nil
+}
+
+// UpdateDescription sets the description of a worktree.
+func (m *Manager) UpdateDescription(name, description string) error {
+	if _, err := m.registry.Get(name); err != nil {
+		return err
+	}
+
+	m.metaMu.Lock()
+	meta, err := m.readMetadata(name)
+	if err == nil {
+		meta.Description = description
+		err = m.writeMetadata(name, meta)
+	}
+	m.metaMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.WorktreeWatcher.NotifyChanged()
+	return nil
+}
+
+func (m *Manager) metadataPath(name string) string {
+	return filepath.Join(m.worktreeDataDir(name), metadataFile)
+}
+
+func (m *Manager) saveMetadata(name string, meta Metadata) error {
+	m.metaMu.Lock()
+	defer m.metaMu.Unlock()
+	return m.writeMetadata(name, meta)
+}
+
+// readMetadata returns zero Metadata if none is stored. Caller must hold metaMu.
+func (m *Manager) readMetadata(name string) (Metadata, error) {
+	data, err := os.ReadFile(m.metadataPath(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Metadata{}, nil
+	}
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	var meta Metadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return Metadata{}, fmt.Errorf("invalid worktree metadata: %w", err)
+	}
+	return meta, nil
+}
+
+// writeMetadata persists meta. Caller must hold metaMu.
+func (m *Manager) writeMetadata(name string, meta Metadata) error {
+	path := m.metadataPath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// List returns all worktrees with their metadata and current state.
+func (m *Manager) List() []rpc.WorktreeInfo {
+	worktrees := m.registry.List()
+	result := make([]rpc.WorktreeInfo, len(worktrees))
+	for i, info := range worktrees {
+		result[i] = m.Describe(info)
+	}
+	return result
+}
+
+// Describe returns info with its metadata, session count, last activity,
+// ahead/behind counts relative to its base branch, and dirty flag.
+// The main worktree is compared against its upstream, if any.
+func (m *Manager) Describe(info Info) rpc.WorktreeInfo {
+	result := rpc.WorktreeInfo{
+		Name:   info.Name,
+		Path:   info.Path,
+		Branch: info.Branch,
+		IsMain: info.IsMain,
+		Setup:  m.SetupStatus(info.Name),
+	}
+
+	var meta Metadata
+	if !info.IsMain {
+		m.metaMu.Lock()
+		var err error
+		meta, err = m.readMetadata(info.Name)
+		m.metaMu.Unlock()
+		if err != nil {
+			slog.Warn("failed to read worktree metadata", "name", info.Name, "error", err)
+		}
+	}
+	result.Description = meta.Description
+	result.BaseBranch = meta.BaseBranch
+	result.CreatedBy = meta.CreatedBy
+	result.Session = meta.Session
+	if !meta.CreatedAt.IsZero() {
+		result.CreatedAt = &meta.CreatedAt
+	}
+
+	lastActivity := meta.CreatedAt
+	if sessions, err := session.ReadSessions(m.worktreeDataDir(info.Name)); err == nil {
+		result.SessionCount = len(sessions)
+		for _, s := range sessions {
+			if s.UpdatedAt.After(lastActivity) {
+				lastActivity = s.UpdatedAt
+			}
+		}
+	}
+
+	if !m.registry.IsGitRepo() {
+		if !lastActivity.IsZero() {
+			result.LastActivity = &lastActivity
+		}
+		return result
+	}
+
+	if t := git.LastCommitTime(info.Path); t.After(lastActivity) {
+		lastActivity = t
+	}
+	if !lastActivity.IsZero() {
+		result.LastActivity = &lastActivity
+	}
+
+	if base, err := m.baseBranch(info); err == nil {
+		if ahead, behind, err := git.AheadBehind(info.Path, base); err == nil {
+			result.Ahead = ahead
+			result.Behind = behind
+		}
+	}
+
+	if dirty, err := git.HasChanges(info.Path); err == nil {
+		result.Dirty = dirty
+	}
+
+	return result
+}
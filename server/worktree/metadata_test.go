@@ -0,0 +1,105 @@
+package worktree
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pockode/server/git"
+	"github.com/pockode/server/session"
+)
+
+func TestManagerCreate_PersistsMetadata(t *testing.T) {
+	m, r := newDeleteTestManager(t)
+
+	info, err := m.Create(CreateOptions{
+		Name:        "feature",
+		Branch:      "feature-branch",
+		Description: "Add login form",
+		CreatedBy:   "user",
+	})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	got := m.Describe(info)
+	if got.Description != "Add login form" {
+		t.Errorf("Description = %q, want %q", got.Description, "Add login form")
+	}
+	if want := git.CurrentBranch(r.MainDir()); got.BaseBranch != want {
+		t.Errorf("BaseBranch = %q, want %q", got.BaseBranch, want)
+	}
+	if got.CreatedBy != "user" {
+		t.Errorf("CreatedBy = %q, want user", got.CreatedBy)
+	}
+	if got.CreatedAt == nil || got.LastActivity == nil {
+		t.Errorf("expected CreatedAt and LastActivity, got %+v", got)
+	}
+	if got.Ahead != 0 || got.Behind != 0 || got.Dirty {
+		t.Errorf("expected fresh worktree to be clean and even, got %+v", got)
+	}
+
+	// Metadata must survive a manager restart
+	m2 := NewManager(r, nil, m.dataDir, m.idleTimeout)
+	if got := m2.Describe(info); got.Description != "Add login form" {
+		t.Errorf("Description after restart = %q", got.Description)
+	}
+}
+
+func TestManagerDescribe_State(t *testing.T) {
+	m, r := newDeleteTestManager(t)
+	info, err := m.Create(CreateOptions{Name: "feature", Branch: "feature-branch"})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(info.Path, "a.txt"), []byte("a"), 0644)
+	runGitC(t, info.Path, "add", "a.txt")
+	runGitC(t, info.Path, "commit", "-m", "a")
+	os.WriteFile(filepath.Join(r.MainDir(), "b.txt"), []byte("b"), 0644)
+	runGitC(t, r.MainDir(), "add", "b.txt")
+	runGitC(t, r.MainDir(), "commit", "-m", "b")
+	os.WriteFile(filepath.Join(info.Path, "untracked.txt"), []byte("c"), 0644)
+
+	store, err := session.NewFileStore(m.worktreeDataDir("feature"))
+	if err != nil {
+		t.Fatalf("NewFileStore() failed: %v", err)
+	}
+	store.Create(context.Background(), "s1")
+	store.Create(context.Background(), "s2")
+
+	got := m.Describe(info)
+	if got.Ahead != 1 || got.Behind != 1 {
+		t.Errorf("Ahead/Behind = %d/%d, want 1/1", got.Ahead, got.Behind)
+	}
+	if !got.Dirty {
+		t.Error("expected Dirty with untracked file")
+	}
+	if got.SessionCount != 2 {
+		t.Errorf("SessionCount = %d, want 2", got.SessionCount)
+	}
+}
+
+func TestManagerUpdateDescription(t *testing.T) {
+	m, _ := newDeleteTestManager(t)
+	info, err := m.Create(CreateOptions{Name: "feature", Branch: "feature-branch", Description: "old"})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	if err := m.UpdateDescription("feature", "new"); err != nil {
+		t.Fatalf("UpdateDescription() failed: %v", err)
+	}
+	got := m.Describe(info)
+	if got.Description != "new" {
+		t.Errorf("Description = %q, want new", got.Description)
+	}
+	if got.BaseBranch == "" {
+		t.Error("expected BaseBranch to be preserved")
+	}
+
+	if err := m.UpdateDescription("missing", "x"); err == nil {
+		t.Error("expected error for unknown worktree")
+	}
+}
@@ -0,0 +1,53 @@
+package worktree
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/pockode/server/session"
+)
+
+// SendPrompt sends a prompt on behalf of the server (e.g. a scheduled job) to a
+// session of the named worktree, as if the user had typed it. A new session
+// titled title is created if sessionID is empty, no longer exists, or was
+// switched by the user to a mode other than mode; a session's running process
+// is never restarted. It returns the session the prompt was sent to.
+func (m *Manager) SendPrompt(ctx context.Context, name, sessionID, title, prompt string, mode session.Mode) (string, error) {
+	wt, err := m.Get(name)
+	if err != nil {
+		return "", err
+	}
+	// The agent process keeps the worktree alive until it goes idle
+	defer m.Release(wt)
+
+	reuse := false
+	if sessionID != "" {
+		meta, found, err := wt.SessionStore.Get(sessionID)
+		if err != nil {
+			return "", fmt.Errorf("get session: %w", err)
+		}
+		reuse = found && meta.Mode == mode
+		if found && !reuse {
+			slog.Info("session mode changed, starting a new session", "sessionId", sessionID, "mode", meta.Mode, "want", mode)
+		}
+	}
+	if !reuse {
+		sessionID = uuid.Must(uuid.NewV7()).String()
+		if _, err := wt.SessionStore.Create(ctx, sessionID); err != nil {
+			return "", fmt.Errorf("create session: %w", err)
+		}
+		if err := wt.SessionStore.Update(ctx, sessionID, title); err != nil {
+			slog.Warn("failed to set session title", "sessionId", sessionID, "error", err)
+		}
+		if err := wt.SessionStore.SetMode(ctx, sessionID, mode); err != nil {
+			return "", fmt.Errorf("set session mode: %w", err)
+		}
+	}
+
+	if err := wt.SendMessage(ctx, sessionID, prompt); err != nil {
+		return "", err
+	}
+	return sessionID, nil
+}
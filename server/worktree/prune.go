@@ -0,0 +1,339 @@
+package worktree
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pockode/server/git"
+	"github.com/pockode/server/logger"
+)
+
+const (
+	// pruneInterval is how often the background job prunes stale worktrees.
+	pruneInterval = time.Hour
+	// DefaultMergedIdleAfter is how long a merged worktree must be idle to be reported.
+	DefaultMergedIdleAfter = 7 * 24 * time.Hour
+)
+
+// Entry is a worktree as listed by `git worktree list`, including entries git
+// considers stale. External worktrees (outside the managed directory) have no Name.
+type Entry struct {
+	Name           string
+	Path           string
+	Branch         string
+	Managed        bool
+	Locked         bool
+	LockReason     string
+	Prunable       bool
+	PrunableReason string
+	Missing        bool // worktree directory does not exist
+}
+
+// Entries lists all linked worktrees known to git, bypassing the cache.
+// The main worktree is not included.
+func (r *Registry) Entries() ([]Entry, error) {
+	cmd := exec.Command("git", "-C", r.mainDir, "worktree", "list", "--porcelain")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, ErrNotGitRepo
+	}
+
+	worktreesDir := r.worktreesDir()
+	var entries []Entry
+	var current *Entry
+	flush := func() {
+		if current != nil && current.Path != r.mainDir {
+			if info := r.createInfo(current.Path, current.Branch, worktreesDir); info != nil {
+				current.Name = info.Name
+				current.Managed = true
+			}
+			if _, err := os.Stat(current.Path); os.IsNotExist(err) {
+				current.Missing = true
+			}
+			entries = append(entries, *current)
+		}
+		current = nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		key, value, _ := strings.Cut(line, " ")
+		switch {
+		case key == "worktree":
+			flush()
+			current = &Entry{Path: value}
+		case current == nil:
+		case key == "branch":
+			current.Branch = strings.TrimPrefix(value, "refs/heads/")
+		case key == "locked":
+			current.Locked = true
+			current.LockReason = value
+		case key == "prunable":
+			current.Prunable = true
+			current.PrunableReason = value
+		case line == "":
+			flush()
+		}
+	}
+	flush()
+	return entries, nil
+}
+
+// Prune runs `git worktree prune` and returns the entries it removed.
+// Locked entries are never pruned by git.
+func (r *Registry) Prune() ([]Entry, error) {
+	before, err := r.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("git", "-C", r.mainDir, "worktree", "prune")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("git worktree prune failed: %s", strings.TrimSpace(string(output)))
+	}
+	r.invalidateCache()
+
+	after, err := r.Entries()
+	if err != nil {
+		return nil, err
+	}
+	remaining := make(map[string]bool, len(after))
+	for _, e := range after {
+		remaining[e.Path] = true
+	}
+
+	pruned := []Entry{}
+	for _, e := range before {
+		if !remaining[e.Path] {
+			pruned = append(pruned, e)
+		}
+	}
+	return pruned, nil
+}
+
+// PrunedWorktree is a stale entry removed by Prune.
+type PrunedWorktree struct {
+	Name   string `json:"name,omitempty"` // empty for worktrees outside the managed directory
+	Path   string `json:"path"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// LockedWorktree is an entry git keeps because it is locked.
+type LockedWorktree struct {
+	Name    string `json:"name,omitempty"`
+	Path    string `json:"path"`
+	Reason  string `json:"reason,omitempty"`
+	Missing bool   `json:"missing"` // directory is gone; stays listed until unlocked
+}
+
+// IdleWorktree is a worktree whose branch is merged and that has been idle for a while.
+type IdleWorktree struct {
+	Name         string    `json:"name"`
+	Branch       string    `json:"branch"`
+	BaseBranch   string    `json:"base_branch"`
+	LastActivity time.Time `json:"last_activity"`
+}
+
+// PruneReport is the outcome of Manager.Prune.
+type PruneReport struct {
+	Pruned       []PrunedWorktree `json:"pruned"`
+	Locked       []LockedWorktree `json:"locked"`
+	OrphanedData []string         `json:"orphaned_data"` // data directories of worktrees that no longer exist
+	DataRemoved  bool             `json:"data_removed"`  // whether OrphanedData was removed
+	MergedIdle   []IdleWorktree   `json:"merged_idle"`   // candidates for deletion; not removed
+}
+
+// Prune removes worktree entries whose directories no longer exist, drops their
+// in-memory state, and reports data directories of worktrees that no longer exist,
+// locked entries, and worktrees whose branch is merged into its base branch and that
+// have been idle for longer than idleAfter. Orphaned data directories are removed
+// only if removeData is set, since they hold session history and snapshots.
+func (m *Manager) Prune(idleAfter time.Duration, removeData bool) (*PruneReport, error) {
+	pruned, err := m.registry.Prune()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PruneReport{
+		Pruned:       []PrunedWorktree{},
+		Locked:       []LockedWorktree{},
+		OrphanedData: []string{},
+		MergedIdle:   []IdleWorktree{},
+	}
+	for _, e := range pruned {
+		report.Pruned = append(report.Pruned, PrunedWorktree{Name: e.Name, Path: e.Path, Reason: e.PrunableReason})
+		if e.Managed {
+			m.unload(e.Name)
+		}
+	}
+
+	entries, err := m.registry.Entries()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.Locked {
+			report.Locked = append(report.Locked, LockedWorktree{Name: e.Name, Path: e.Path, Reason: e.LockReason, Missing: e.Missing})
+		}
+	}
+
+	if removeData {
+		report.OrphanedData, err = m.removeOrphanedData()
+		if err != nil {
+			return nil, err
+		}
+		report.DataRemoved = true
+	} else {
+		report.OrphanedData = m.orphanedData(entries)
+	}
+	report.MergedIdle = m.mergedIdle(idleAfter)
+
+	if len(report.Pruned) > 0 || len(report.OrphanedData) > 0 {
+		m.WorktreeWatcher.NotifyChanged()
+	}
+	return report, nil
+}
+
+// orphanedData returns the data directories left behind by worktrees that were
+// removed outside Pockode.
+func (m *Manager) orphanedData(entries []Entry) []string {
+	orphaned := []string{}
+	dirs, err := os.ReadDir(filepath.Join(m.dataDir, "worktrees"))
+	if err != nil {
+		return orphaned
+	}
+
+	for _, d := range dirs {
+		if d.IsDir() && !hasWorktreeUnder(entries, d.Name()) {
+			orphaned = append(orphaned, d.Name())
+		}
+	}
+	return orphaned
+}
+
+// removeOrphanedData removes the data directories of worktrees that no longer
+// exist and returns their names. Worktrees are listed again while holding mu, so
+// a worktree created or loaded meanwhile keeps its data: Create registers the
+// worktree with git before writing to its data directory.
+func (m *Manager) removeOrphanedData() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries, err := m.registry.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	removed := []string{}
+	for _, name := range m.orphanedData(entries) {
+		if _, loaded := m.worktrees[name]; loaded {
+			continue
+		}
+		dir := m.worktreeDataDir(name)
+		if err := os.RemoveAll(dir); err != nil {
+			slog.Warn("failed to remove worktree data directory", "path", dir, "error", err)
+			continue
+		}
+		removed = append(removed, name)
+	}
+	return removed, nil
+}
+
+// hasWorktreeUnder reports whether a managed worktree is named dir or nested below it.
+func hasWorktreeUnder(entries []Entry, dir string) bool {
+	for _, e := range entries {
+		if e.Managed && (e.Name == dir || strings.HasPrefix(e.Name, dir+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manager) mergedIdle(idleAfter time.Duration) []IdleWorktree {
+	idle := []IdleWorktree{}
+	for _, info := range m.registry.List() {
+		if info.IsMain || info.Branch == "" {
+			continue
+		}
+		base, err := m.baseBranch(info)
+		if err != nil || !git.IsAncestor(info.Path, info.Branch, base) {
+			continue
+		}
+
+		desc := m.Describe(info)
+		if desc.Dirty || desc.LastActivity == nil || time.Since(*desc.LastActivity) < idleAfter {
+			continue
+		}
+
+		m.mu.Lock()
+		wt, loaded := m.worktrees[info.Name]
+		running := loaded && wt.ProcessManager.ProcessCount() > 0
+		m.mu.Unlock()
+		if running {
+			continue
+		}
+
+		idle = append(idle, IdleWorktree{
+			Name:         info.Name,
+			Branch:       info.Branch,
+			BaseBranch:   base,
+			LastActivity: *desc.LastActivity,
+		})
+	}
+	return idle
+}
+
+// runPruneLoop prunes periodically until ctx is cancelled.
+func (m *Manager) runPruneLoop(ctx context.Context) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.LogPanic(r, "worktree prune job crashed")
+		}
+	}()
+
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.pruneInBackground()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *Manager) pruneInBackground() {
+	if !m.registry.IsGitRepo() {
+		return
+	}
+	// Data is only removed on request (worktree.prune)
+	report, err := m.Prune(DefaultMergedIdleAfter, false)
+	if err != nil {
+		slog.Warn("worktree prune failed", "error", err)
+		return
+	}
+	for _, p := range report.Pruned {
+		slog.Info("pruned stale worktree", "name", p.Name, "path", p.Path, "reason", p.Reason)
+	}
+	for _, l := range report.Locked {
+		if l.Missing {
+			slog.Warn("locked worktree directory is missing", "name", l.Name, "path", l.Path, "reason", l.Reason)
+		}
+	}
+	for _, name := range report.OrphanedData {
+		slog.Info("orphaned worktree data directory", "name", name, "path", m.worktreeDataDir(name))
+	}
+	for _, idle := range report.MergedIdle {
+		slog.Info("merged worktree is idle", "name", idle.Name, "branch", idle.Branch, "base", idle.BaseBranch, "lastActivity", idle.LastActivity)
+	}
+}
@@ -0,0 +1,143 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManagerPrune_MissingDirectory(t *testing.T) {
+	m, r := newDeleteTestManager(t)
+	info, err := m.Create(CreateOptions{Name: "gone", Branch: "gone-branch"})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	wt, err := m.Get("gone")
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	m.Release(wt)
+
+	os.RemoveAll(info.Path)
+
+	report, err := m.Prune(DefaultMergedIdleAfter, true)
+	if err != nil {
+		t.Fatalf("Prune() failed: %v", err)
+	}
+	if len(report.Pruned) != 1 || report.Pruned[0].Name != "gone" {
+		t.Errorf("Pruned = %+v, want gone", report.Pruned)
+	}
+	if _, err := r.Get("gone"); err == nil {
+		t.Error("pruned worktree still in registry")
+	}
+	m.mu.Lock()
+	_, loaded := m.worktrees["gone"]
+	m.mu.Unlock()
+	if loaded {
+		t.Error("pruned worktree still loaded in manager")
+	}
+	if _, err := os.Stat(m.worktreeDataDir("gone")); !os.IsNotExist(err) {
+		t.Error("data directory of pruned worktree still exists")
+	}
+}
+
+func TestManagerPrune_LockedAndOrphanedData(t *testing.T) {
+	m, r := newDeleteTestManager(t)
+	info, err := m.Create(CreateOptions{Name: "locked", Branch: "locked-branch"})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	runGitC(t, r.MainDir(), "worktree", "lock", "--reason", "on usb drive", info.Path)
+	os.RemoveAll(info.Path)
+
+	orphan := m.worktreeDataDir("orphan")
+	os.MkdirAll(filepath.Join(orphan, "sessions"), 0755)
+
+	report, err := m.Prune(DefaultMergedIdleAfter, true)
+	if err != nil {
+		t.Fatalf("Prune() failed: %v", err)
+	}
+	if len(report.Pruned) != 0 {
+		t.Errorf("locked worktree was pruned: %+v", report.Pruned)
+	}
+	if len(report.Locked) != 1 || report.Locked[0].Name != "locked" || !report.Locked[0].Missing || report.Locked[0].Reason != "on usb drive" {
+		t.Errorf("Locked = %+v", report.Locked)
+	}
+	if len(report.OrphanedData) != 1 || report.OrphanedData[0] != "orphan" || !report.DataRemoved {
+		t.Errorf("OrphanedData = %+v, want orphan", report.OrphanedData)
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Error("orphaned data directory still exists")
+	}
+	if _, err := os.Stat(m.worktreeDataDir("locked")); err != nil {
+		t.Errorf("data directory of locked worktree removed: %v", err)
+	}
+}
+
+func TestManagerPrune_KeepsOrphanedDataUnlessRequested(t *testing.T) {
+	m, _ := newDeleteTestManager(t)
+	orphan := m.worktreeDataDir("orphan")
+	os.MkdirAll(filepath.Join(orphan, "sessions"), 0755)
+
+	report, err := m.Prune(DefaultMergedIdleAfter, false)
+	if err != nil {
+		t.Fatalf("Prune() failed: %v", err)
+	}
+	if len(report.OrphanedData) != 1 || report.OrphanedData[0] != "orphan" || report.DataRemoved {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if _, err := os.Stat(orphan); err != nil {
+		t.Errorf("orphaned data directory removed without request: %v", err)
+	}
+
+	// A worktree registered after the orphans were listed keeps its data
+	if _, err := m.Create(CreateOptions{Name: "orphan", Branch: "orphan-branch"}); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	report, err = m.Prune(DefaultMergedIdleAfter, true)
+	if err != nil {
+		t.Fatalf("Prune() failed: %v", err)
+	}
+	if len(report.OrphanedData) != 0 {
+		t.Errorf("data of an existing worktree reported as orphaned: %+v", report.OrphanedData)
+	}
+	if _, err := os.Stat(orphan); err != nil {
+		t.Errorf("data directory of an existing worktree removed: %v", err)
+	}
+}
+
+func TestManagerPrune_ReportsMergedIdle(t *testing.T) {
+	m, r := newDeleteTestManager(t)
+	merged, err := m.Create(CreateOptions{Name: "merged", Branch: "merged-branch"})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	os.WriteFile(filepath.Join(merged.Path, "a.txt"), []byte("a"), 0644)
+	runGitC(t, merged.Path, "add", "a.txt")
+	runGitC(t, merged.Path, "commit", "-m", "a")
+	runGitC(t, r.MainDir(), "merge", "--no-ff", "--no-edit", "merged-branch")
+
+	unmerged, err := m.Create(CreateOptions{Name: "unmerged", Branch: "unmerged-branch"})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	os.WriteFile(filepath.Join(unmerged.Path, "b.txt"), []byte("b"), 0644)
+	runGitC(t, unmerged.Path, "add", "b.txt")
+	runGitC(t, unmerged.Path, "commit", "-m", "b")
+
+	report, err := m.Prune(0, true)
+	if err != nil {
+		t.Fatalf("Prune() failed: %v", err)
+	}
+	if len(report.MergedIdle) != 1 || report.MergedIdle[0].Name != "merged" {
+		t.Errorf("MergedIdle = %+v, want merged", report.MergedIdle)
+	}
+
+	report, err = m.Prune(DefaultMergedIdleAfter, true)
+	if err != nil {
+		t.Fatalf("Prune() failed: %v", err)
+	}
+	if len(report.MergedIdle) != 0 {
+		t.Errorf("recently active worktree reported as idle: %+v", report.MergedIdle)
+	}
+}
@@ -88,6 +88,24 @@ func (r *Registry) Resolve(name string) (string, error) {
 	return info.Path, nil
 }
 
+// Get returns the Info for a worktree name (empty string = main worktree).
+func (r *Registry) Get(name string) (Info, error) {
+	r.refreshIfNeeded()
+
+	r.cacheMu.RLock()
+	isGitRepo := r.isGitRepo
+	info, ok := r.cache[name]
+	r.cacheMu.RUnlock()
+
+	if !isGitRepo && name != "" {
+		return Info{}, ErrNotGitRepo
+	}
+	if !ok {
+		return Info{}, ErrWorktreeNotFound
+	}
+	return info, nil
+}
+
 func (r *Registry) List() []Info {
 	r.refreshIfNeeded()
 
@@ -196,6 +214,19 @@ func (r *Registry) Delete(name string) error {
 	return nil
 }
 
+// DeleteBranch force-deletes a local branch. The branch must not be checked out in any worktree.
+func (r *Registry) DeleteBranch(branch string) error {
+	if branch == "" || strings.HasPrefix(branch, "-") {
+		return fmt.Errorf("invalid branch: %q", branch)
+	}
+
+	cmd := exec.Command("git", "-C", r.mainDir, "branch", "-D", "--", branch)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git branch -D failed: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
 func (r *Registry) refreshIfNeeded() {
 	r.cacheMu.RLock()
 	needsRefresh := time.Since(r.cacheTime) > r.cacheTTL
@@ -0,0 +1,52 @@
+package worktree
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/pockode/server/agent"
+)
+
+// AgentSession returns the agent session of a stored session, starting its
+// process (resuming the conversation if it was activated before) if none is running.
+func (w *Worktree) AgentSession(ctx context.Context, sessionID string) (agent.Session, error) {
+	meta, found, err := w.SessionStore.Get(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if !found {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	resume := meta.Activated
+	proc, created, err := w.ProcessManager.GetOrCreateProcess(ctx, sessionID, resume, meta.Mode)
+	if err != nil {
+		return nil, err
+	}
+
+	// Mark as activated on first process creation
+	if created && !resume {
+		if err := w.SessionStore.Activate(ctx, sessionID); err != nil {
+			slog.Error("failed to activate session", "sessionId", sessionID, "error", err)
+		}
+	}
+
+	return proc.AgentSession(), nil
+}
+
+// SendMessage records a user message in the session history and sends it to
+// the agent, starting the session's process if needed.
+func (w *Worktree) SendMessage(ctx context.Context, sessionID, content string) error {
+	sess, err := w.AgentSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	event := agent.MessageEvent{Content: content}
+	if err := w.SessionStore.AppendToHistory(ctx, sessionID, agent.NewEventRecord(event)); err != nil {
+		slog.Error("failed to append to history", "sessionId", sessionID, "error", err)
+	}
+
+	return sess.SendMessage(content)
+}
@@ -0,0 +1,352 @@
+package worktree
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// setupWaitDelay is how long a cancelled setup command may keep its output open.
+const setupWaitDelay = 5 * time.Second
+
+// SetupConfigFile is the per-repo worktree setup config, relative to the main worktree.
+const SetupConfigFile = ".pockode/worktree.json"
+
+// SetupConfig declares how a newly created worktree is prepared.
+type SetupConfig struct {
+	Copy    []string `json:"copy"`    // paths copied from the main worktree (e.g. ".env")
+	Symlink []string `json:"symlink"` // paths symlinked to the main worktree (e.g. "node_modules")
+	Setup   []string `json:"setup"`   // shell commands run in the new worktree, in order
+}
+
+// LoadSetupConfig reads the setup config of the repository at mainDir.
+// Returns nil without error if the repository has no config.
+func LoadSetupConfig(mainDir string) (*SetupConfig, error) {
+	data, err := os.ReadFile(filepath.Join(mainDir, SetupConfigFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg SetupConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", SetupConfigFile, err)
+	}
+	for _, path := range append(append([]string{}, cfg.Copy...), cfg.Symlink...) {
+		if !filepath.IsLocal(path) {
+			return nil, fmt.Errorf("invalid %s: path must be relative to the repository: %s", SetupConfigFile, path)
+		}
+	}
+	return &cfg, nil
+}
+
+type SetupState string
+
+const (
+	SetupRunning   SetupState = "running"
+	SetupSucceeded SetupState = "succeeded"
+	SetupFailed    SetupState = "failed"
+)
+
+// SetupStatus is the progress of a worktree's setup.
+type SetupStatus struct {
+	State      SetupState
+	Step       string // step currently running, or the step that failed
+	Error      string
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// SetupListener receives setup progress. Calls are made from the setup goroutine.
+type SetupListener interface {
+	OnSetupOutput(name, stream, data string)
+	OnSetupStatus(name string, status SetupStatus)
+}
+
+// SetupRunner applies SetupConfig to new worktrees in the background and tracks
+// their status in memory.
+type SetupRunner struct {
+	mainDir  string
+	listener SetupListener
+
+	mu       sync.Mutex
+	statuses map[string]SetupStatus
+	runs     map[string]*setupRun // in-progress runs by worktree name
+}
+
+type setupRun struct {
+	name    string
+	workDir string
+	cancel  context.CancelFunc
+	status  SetupStatus
+}
+
+func NewSetupRunner(mainDir string) *SetupRunner {
+	return &SetupRunner{
+		mainDir:  mainDir,
+		statuses: make(map[string]SetupStatus),
+		runs:     make(map[string]*setupRun),
+	}
+}
+
+// SetListener must be called before Run.
+func (r *SetupRunner) SetListener(listener SetupListener) {
+	r.listener = listener
+}
+
+// Run starts setting up the worktree at workDir asynchronously.
+// Returns false if the repository has no setup config.
+func (r *SetupRunner) Run(name, workDir string) bool {
+	cfg, err := LoadSetupConfig(r.mainDir)
+	if err == nil && cfg == nil {
+		return false
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &setupRun{
+		name:    name,
+		workDir: workDir,
+		cancel:  cancel,
+		status:  SetupStatus{State: SetupRunning, StartedAt: time.Now()},
+	}
+
+	r.mu.Lock()
+	if prev, ok := r.runs[name]; ok {
+		prev.cancel()
+	}
+	r.runs[name] = run
+	r.mu.Unlock()
+	r.update(run)
+
+	go func() {
+		defer cancel()
+		if err == nil {
+			err = r.run(ctx, run, cfg)
+		}
+
+		run.status.FinishedAt = time.Now()
+		if err != nil {
+			run.status.State = SetupFailed
+			run.status.Error = err.Error()
+			slog.Warn("worktree setup failed", "name", name, "step", run.status.Step, "error", err)
+		} else {
+			run.status.State = SetupSucceeded
+			run.status.Step = ""
+			slog.Info("worktree setup completed", "name", name, "duration", run.status.FinishedAt.Sub(run.status.StartedAt))
+		}
+		r.update(run)
+
+		r.mu.Lock()
+		if r.runs[name] == run {
+			delete(r.runs, name)
+		}
+		r.mu.Unlock()
+	}()
+	return true
+}
+
+func (r *SetupRunner) run(ctx context.Context, run *setupRun, cfg *SetupConfig) error {
+	for _, path := range cfg.Copy {
+		r.setStep(run, "copy "+path)
+		if err := copyPath(filepath.Join(r.mainDir, path), filepath.Join(run.workDir, path)); err != nil {
+			return err
+		}
+	}
+
+	for _, path := range cfg.Symlink {
+		r.setStep(run, "symlink "+path)
+		if err := symlinkPath(filepath.Join(r.mainDir, path), filepath.Join(run.workDir, path)); err != nil {
+			return err
+		}
+	}
+
+	for _, command := range cfg.Setup {
+		r.setStep(run, command)
+		if err := r.runCommand(ctx, run, command); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *SetupRunner) runCommand(ctx context.Context, run *setupRun, command string) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = run.workDir
+	cmd.Env = append(os.Environ(),
+		"POCKODE_MAIN_DIR="+r.mainDir,
+		"POCKODE_WORKTREE="+run.name,
+	)
+
+	// Killing sh on cancel may leave children holding the output open; WaitDelay bounds that
+	cmd.WaitDelay = setupWaitDelay
+
+	stdoutReader, stdoutWriter := io.Pipe()
+	stderrReader, stderrWriter := io.Pipe()
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderrWriter
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go r.streamOutput(&wg, run, "stdout", stdoutReader)
+	go r.streamOutput(&wg, run, "stderr", stderrReader)
+
+	err := cmd.Run()
+	stdoutWriter.Close()
+	stderrWriter.Close()
+	wg.Wait()
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", command, err)
+	}
+	return nil
+}
+
+func (r *SetupRunner) streamOutput(wg *sync.WaitGroup, run *setupRun, stream string, reader io.Reader) {
+	defer wg.Done()
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if r.listener != nil && r.isCurrent(run) {
+			r.listener.OnSetupOutput(run.name, stream, scanner.Text()+"\n")
+		}
+	}
+	// Keep draining after an overlong line so the command never blocks on output
+	io.Copy(io.Discard, reader)
+}
+
+func (r *SetupRunner) setStep(run *setupRun, step string) {
+	run.status.Step = step
+	r.update(run)
+}
+
+// update publishes the run's status unless a newer run (or Forget) replaced it.
+func (r *SetupRunner) update(run *setupRun) {
+	r.mu.Lock()
+	if r.runs[run.name] != run {
+		r.mu.Unlock()
+		return
+	}
+	status := run.status
+	r.statuses[run.name] = status
+	r.mu.Unlock()
+
+	if r.listener != nil {
+		r.listener.OnSetupStatus(run.name, status)
+	}
+}
+
+func (r *SetupRunner) isCurrent(run *setupRun) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.runs[run.name] == run
+}
+
+// Status returns the setup status of a worktree, if setup was run since the server started.
+func (r *SetupRunner) Status(name string) (SetupStatus, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status, ok := r.statuses[name]
+	return status, ok
+}
+
+// Forget cancels a running setup and drops its status (e.g. when the worktree is deleted).
+func (r *SetupRunner) Forget(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if run, ok := r.runs[name]; ok {
+		run.cancel()
+		delete(r.runs, name)
+	}
+	delete(r.statuses, name)
+}
+
+// copyPath copies a file or directory tree. Missing sources are skipped.
+func copyPath(src, dst string) error {
+	info, err := os.Lstat(src)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return copyEntry(src, dst, info)
+	}
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+		return copyEntry(path, target, info)
+	})
+}
+
+func copyEntry(src, dst string, info fs.FileInfo) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	if info.Mode()&fs.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		os.Remove(dst)
+		return os.Symlink(target, dst)
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// symlinkPath links dst to src. Missing sources and existing destinations are skipped
+// so a checked-in file is never replaced.
+func symlinkPath(src, dst string) error {
+	if _, err := os.Lstat(src); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return os.Symlink(src, dst)
+}
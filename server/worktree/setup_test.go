@@ -0,0 +1,169 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingSetupListener struct {
+	mu       sync.Mutex
+	output   []string
+	statuses []SetupStatus
+}
+
+func (l *recordingSetupListener) OnSetupOutput(name, stream, data string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.output = append(l.output, stream+":"+data)
+}
+
+func (l *recordingSetupListener) OnSetupStatus(name string, status SetupStatus) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.statuses = append(l.statuses, status)
+}
+
+func writeSetupConfig(t *testing.T, mainDir, config string) {
+	t.Helper()
+	path := filepath.Join(mainDir, SetupConfigFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitForSetup(t *testing.T, r *SetupRunner, name string) SetupStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if status, ok := r.Status(name); ok && status.State != SetupRunning {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("setup did not finish")
+	return SetupStatus{}
+}
+
+func TestLoadSetupConfig(t *testing.T) {
+	mainDir := t.TempDir()
+
+	cfg, err := LoadSetupConfig(mainDir)
+	if err != nil || cfg != nil {
+		t.Fatalf("expected nil config without error, got %+v, %v", cfg, err)
+	}
+
+	writeSetupConfig(t, mainDir, `{"copy": ["../outside"]}`)
+	if _, err := LoadSetupConfig(mainDir); err == nil {
+		t.Error("expected error for path outside the repository")
+	}
+
+	writeSetupConfig(t, mainDir, `{"copy": [".env"], "symlink": ["node_modules"], "setup": ["true"]}`)
+	cfg, err = LoadSetupConfig(mainDir)
+	if err != nil {
+		t.Fatalf("LoadSetupConfig() error: %v", err)
+	}
+	if len(cfg.Copy) != 1 || len(cfg.Symlink) != 1 || len(cfg.Setup) != 1 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestSetupRunner_NoConfig(t *testing.T) {
+	r := NewSetupRunner(t.TempDir())
+	if r.Run("feature", t.TempDir()) {
+		t.Error("expected Run to report no setup")
+	}
+	if _, ok := r.Status("feature"); ok {
+		t.Error("expected no status")
+	}
+}
+
+func TestSetupRunner_Success(t *testing.T) {
+	mainDir := t.TempDir()
+	workDir := t.TempDir()
+
+	os.WriteFile(filepath.Join(mainDir, ".env"), []byte("SECRET=1"), 0600)
+	os.MkdirAll(filepath.Join(mainDir, "config", "nested"), 0755)
+	os.WriteFile(filepath.Join(mainDir, "config", "nested", "local.json"), []byte("{}"), 0644)
+	os.MkdirAll(filepath.Join(mainDir, "node_modules", "pkg"), 0755)
+	writeSetupConfig(t, mainDir, `{
+		"copy": [".env", "config", "missing.txt"],
+		"symlink": ["node_modules"],
+		"setup": ["echo hello", "echo oops >&2", "echo $POCKODE_WORKTREE > name.txt"]
+	}`)
+
+	listener := &recordingSetupListener{}
+	r := NewSetupRunner(mainDir)
+	r.SetListener(listener)
+
+	if !r.Run("feature", workDir) {
+		t.Fatal("expected setup to run")
+	}
+	status := waitForSetup(t, r, "feature")
+	if status.State != SetupSucceeded || status.FinishedAt.IsZero() {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(workDir, ".env")); err != nil || string(data) != "SECRET=1" {
+		t.Errorf(".env not copied: %q, %v", data, err)
+	}
+	if info, err := os.Stat(filepath.Join(workDir, ".env")); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected copied file to keep its mode, got %v", info.Mode())
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "config", "nested", "local.json")); err != nil {
+		t.Errorf("directory not copied: %v", err)
+	}
+	if target, err := os.Readlink(filepath.Join(workDir, "node_modules")); err != nil || target != filepath.Join(mainDir, "node_modules") {
+		t.Errorf("node_modules not symlinked: %q, %v", target, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workDir, "name.txt")); strings.TrimSpace(string(data)) != "feature" {
+		t.Errorf("expected POCKODE_WORKTREE in command env, got %q", data)
+	}
+
+	listener.mu.Lock()
+	defer listener.mu.Unlock()
+	output := strings.Join(listener.output, "")
+	if !strings.Contains(output, "stdout:hello\n") || !strings.Contains(output, "stderr:oops\n") {
+		t.Errorf("expected streamed output, got %q", output)
+	}
+	if len(listener.statuses) < 2 || listener.statuses[0].State != SetupRunning {
+		t.Errorf("expected running then finished statuses, got %+v", listener.statuses)
+	}
+}
+
+func TestSetupRunner_CommandFails(t *testing.T) {
+	mainDir := t.TempDir()
+	workDir := t.TempDir()
+	writeSetupConfig(t, mainDir, `{"setup": ["exit 3", "touch never"]}`)
+
+	r := NewSetupRunner(mainDir)
+	r.Run("feature", workDir)
+
+	status := waitForSetup(t, r, "feature")
+	if status.State != SetupFailed || status.Step != "exit 3" || status.Error == "" {
+		t.Errorf("unexpected status: %+v", status)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "never")); !os.IsNotExist(err) {
+		t.Error("expected later commands to be skipped")
+	}
+}
+
+func TestSetupRunner_Forget(t *testing.T) {
+	mainDir := t.TempDir()
+	writeSetupConfig(t, mainDir, `{"setup": ["sleep 10"]}`)
+
+	r := NewSetupRunner(mainDir)
+	r.Run("feature", t.TempDir())
+	r.Forget("feature")
+
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := r.Status("feature"); ok {
+		t.Error("expected status to be dropped")
+	}
+}
@@ -5,23 +5,37 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/pockode/server/contents"
+	"github.com/pockode/server/git"
 	"github.com/pockode/server/process"
 	"github.com/pockode/server/session"
+	"github.com/pockode/server/snapshot"
+	"github.com/pockode/server/snippet"
+	"github.com/pockode/server/task"
+	"github.com/pockode/server/terminal"
 	"github.com/pockode/server/watch"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
-// Worktree holds all resources (session store, watchers, processes) for a single worktree.
+// Worktree holds all resources (session store, watchers, processes, terminals, tasks) for a single worktree.
 type Worktree struct {
 	Name                string
 	WorkDir             string
 	SessionStore        session.Store
+	SnapshotStore       *snapshot.Store
+	SnippetStore        *snippet.Store
+	BlameCache          *git.BlameCache
+	FileIndex           *contents.FileIndex
 	FSWatcher           *watch.FSWatcher
 	GitWatcher          *watch.GitWatcher
 	GitDiffWatcher      *watch.GitDiffWatcher
 	SessionListWatcher  *watch.SessionListWatcher
 	ChatMessagesWatcher *watch.ChatMessagesWatcher
+	TerminalWatcher     *watch.TerminalWatcher
+	TaskWatcher         *watch.TaskWatcher
 	ProcessManager      *process.Manager
+	Terminals           *terminal.Manager
+	Tasks               *task.Runner
 
 	watchers []watch.Watcher // for unified lifecycle management
 
@@ -87,4 +101,6 @@ func (w *Worktree) Stop() {
 		watcher.Stop()
 	}
 	w.ProcessManager.Shutdown()
+	w.Terminals.Shutdown()
+	w.Tasks.Shutdown()
 }
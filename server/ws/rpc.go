@@ -13,9 +13,12 @@ import (
 	"github.com/coder/websocket"
 	"github.com/google/uuid"
 	"github.com/pockode/server/command"
+	"github.com/pockode/server/git"
 	"github.com/pockode/server/logger"
 	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/schedule"
 	"github.com/pockode/server/settings"
+	"github.com/pockode/server/snippet"
 	"github.com/pockode/server/watch"
 	"github.com/pockode/server/worktree"
 	"github.com/sourcegraph/jsonrpc2"
@@ -28,12 +31,17 @@ type RPCHandler struct {
 	devMode         bool
 	agentType       string
 	commandStore    *command.Store
+	snippetStore    *snippet.Store
 	worktreeManager *worktree.Manager
 	settingsStore   *settings.Store
+	scheduler       *schedule.Scheduler
 	settingsWatcher *watch.SettingsWatcher
+
+	previewOrigin string
+	previewPort   int
 }
 
-func NewRPCHandler(token, version string, devMode bool, agentType string, commandStore *command.Store, worktreeManager *worktree.Manager, settingsStore *settings.Store) *RPCHandler {
+func NewRPCHandler(token, version string, devMode bool, agentType string, commandStore *command.Store, snippetStore *snippet.Store, worktreeManager *worktree.Manager, settingsStore *settings.Store, scheduler *schedule.Scheduler) *RPCHandler {
 	settingsWatcher := watch.NewSettingsWatcher(settingsStore)
 	settingsWatcher.Start()
 
@@ -43,8 +51,10 @@ func NewRPCHandler(token, version string, devMode bool, agentType string, comman
 		devMode:         devMode,
 		agentType:       agentType,
 		commandStore:    commandStore,
+		snippetStore:    snippetStore,
 		worktreeManager: worktreeManager,
 		settingsStore:   settingsStore,
+		scheduler:       scheduler,
 		settingsWatcher: settingsWatcher,
 	}
 }
@@ -54,6 +64,13 @@ func (h *RPCHandler) Stop() {
 	h.settingsWatcher.Stop()
 }
 
+// SetPreviewOrigin tells clients that previews are served from a separate
+// origin, either origin or their own host on port. Must be called before serving.
+func (h *RPCHandler) SetPreviewOrigin(origin string, port int) {
+	h.previewOrigin = origin
+	h.previewPort = port
+}
+
 func (h *RPCHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
 		InsecureSkipVerify: h.devMode,
@@ -111,6 +128,7 @@ type rpcConnState struct {
 	conn     *jsonrpc2.Conn
 	log      *slog.Logger
 	worktree *worktree.Worktree // set after auth
+	searches map[string]context.CancelFunc
 }
 
 func (s *rpcConnState) getConnID() string {
@@ -123,6 +141,30 @@ func (s *rpcConnState) getWorktree() *worktree.Worktree {
 	return s.worktree
 }
 
+// startSearch registers a cancellable search and returns its context.
+func (s *rpcConnState) startSearch(id string) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	if s.searches == nil {
+		s.searches = make(map[string]context.CancelFunc)
+	}
+	s.searches[id] = cancel
+	s.mu.Unlock()
+	return ctx
+}
+
+// endSearch cancels a search and forgets it. Returns false if it was not running.
+func (s *rpcConnState) endSearch(id string) bool {
+	s.mu.Lock()
+	cancel, ok := s.searches[id]
+	delete(s.searches, id)
+	s.mu.Unlock()
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
 func (s *rpcConnState) setConn(conn *jsonrpc2.Conn) {
 	s.mu.Lock()
 	s.conn = conn
@@ -133,6 +175,11 @@ func (s *rpcConnState) cleanup(worktreeManager *worktree.Manager, settingsWatche
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	for _, cancel := range s.searches {
+		cancel()
+	}
+	s.searches = nil
+
 	// Cleanup manager-level watchers (not worktree-specific)
 	worktreeManager.WorktreeWatcher.CleanupConnection(s.connID)
 	settingsWatcher.CleanupConnection(s.connID)
@@ -187,6 +234,18 @@ func (h *rpcMethodHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req
 	case "worktree.delete":
 		h.handleWorktreeDelete(ctx, conn, req)
 		return
+	case "worktree.update":
+		h.handleWorktreeUpdate(ctx, conn, req)
+		return
+	case "worktree.diff":
+		h.handleWorktreeDiff(ctx, conn, req)
+		return
+	case "worktree.prune":
+		h.handleWorktreePrune(ctx, conn, req)
+		return
+	case "worktree.merge":
+		h.handleWorktreeMerge(ctx, conn, req)
+		return
 	case "worktree.switch":
 		h.handleWorktreeSwitch(ctx, conn, req)
 		return
@@ -199,6 +258,24 @@ func (h *rpcMethodHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req
 	case "command.list":
 		h.handleCommandList(ctx, conn, req)
 		return
+	case "schedule.list":
+		h.handleScheduleList(ctx, conn, req)
+		return
+	case "schedule.create":
+		h.handleScheduleCreate(ctx, conn, req)
+		return
+	case "schedule.pause":
+		h.handleSchedulePause(ctx, conn, req)
+		return
+	case "schedule.resume":
+		h.handleScheduleResume(ctx, conn, req)
+		return
+	case "schedule.delete":
+		h.handleScheduleDelete(ctx, conn, req)
+		return
+	case "schedule.history":
+		h.handleScheduleHistory(ctx, conn, req)
+		return
 	case "settings.subscribe":
 		h.handleSettingsSubscribe(ctx, conn, req)
 		return
@@ -247,6 +324,22 @@ func (h *rpcMethodHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req
 	// file namespace
 	case "file.get":
 		h.handleFileGet(ctx, conn, req)
+	case "file.find":
+		h.handleFileFind(ctx, conn, req)
+	case "file.search":
+		h.handleFileSearch(ctx, conn, req)
+	case "file.search.cancel":
+		h.handleFileSearchCancel(ctx, conn, req)
+	case "file.write":
+		h.handleFileWrite(ctx, conn, req)
+	case "file.create":
+		h.handleFileCreate(ctx, conn, req)
+	case "file.mkdir":
+		h.handleFileMkdir(ctx, conn, req)
+	case "file.rename":
+		h.handleFileRename(ctx, conn, req)
+	case "file.delete":
+		h.handleFileDelete(ctx, conn, req)
 	// git namespace
 	case "git.status":
 		h.handleGitStatus(ctx, conn, req)
@@ -262,11 +355,87 @@ func (h *rpcMethodHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req
 		h.handleGitAdd(ctx, conn, req)
 	case "git.reset":
 		h.handleGitReset(ctx, conn, req)
+	case "git.discard":
+		h.handleGitDiscard(ctx, conn, req)
+	case "git.discard.list":
+		h.handleGitDiscardList(ctx, conn, req)
+	case "git.discard.restore":
+		h.handleGitDiscardRestore(ctx, conn, req)
+	case "git.stash.push":
+		h.handleGitStashPush(ctx, conn, req)
+	case "git.stash.list":
+		h.handleGitStashList(ctx, conn, req)
+	case "git.stash.show":
+		h.handleGitStashShow(ctx, conn, req)
+	case "git.stash.apply":
+		h.handleGitStashApply(ctx, conn, req, false)
+	case "git.stash.pop":
+		h.handleGitStashApply(ctx, conn, req, true)
+	case "git.stash.drop":
+		h.handleGitStashDrop(ctx, conn, req)
+	case "git.blame":
+		h.handleGitBlame(ctx, conn, req)
+	case "git.conflict.get":
+		h.handleGitConflictGet(ctx, conn, req)
+	case "git.conflict.resolve":
+		h.handleGitConflictResolve(ctx, conn, req)
+	case "git.merge.continue":
+		h.handleGitOperationContinue(ctx, conn, req, git.OperationMerge)
+	case "git.merge.abort":
+		h.handleGitOperationAbort(ctx, conn, req, git.OperationMerge)
+	case "git.rebase.continue":
+		h.handleGitOperationContinue(ctx, conn, req, git.OperationRebase)
+	case "git.rebase.abort":
+		h.handleGitOperationAbort(ctx, conn, req, git.OperationRebase)
 	// fs namespace
 	case "fs.subscribe":
 		h.handleFSSubscribe(ctx, conn, req)
 	case "fs.unsubscribe":
 		h.handleWatcherUnsubscribe(ctx, conn, req, h.state.worktree.FSWatcher, "fs")
+	// terminal namespace
+	case "terminal.open":
+		h.handleTerminalOpen(ctx, conn, req)
+	case "terminal.attach":
+		h.handleTerminalAttach(ctx, conn, req)
+	case "terminal.detach":
+		h.handleWatcherUnsubscribe(ctx, conn, req, h.state.worktree.TerminalWatcher, "terminal")
+	case "terminal.input":
+		h.handleTerminalInput(ctx, conn, req)
+	case "terminal.resize":
+		h.handleTerminalResize(ctx, conn, req)
+	case "terminal.close":
+		h.handleTerminalClose(ctx, conn, req)
+	case "terminal.list":
+		h.handleTerminalList(ctx, conn, req)
+	// task namespace
+	case "task.list":
+		h.handleTaskList(ctx, conn, req)
+	case "task.run":
+		h.handleTaskRun(ctx, conn, req)
+	case "task.cancel":
+		h.handleTaskCancel(ctx, conn, req)
+	case "task.get":
+		h.handleTaskGet(ctx, conn, req)
+	case "task.history":
+		h.handleTaskHistory(ctx, conn, req)
+	case "task.subscribe":
+		h.handleTaskSubscribe(ctx, conn, req)
+	case "task.unsubscribe":
+		h.handleWatcherUnsubscribe(ctx, conn, req, h.state.worktree.TaskWatcher, "task")
+	// snippet namespace
+	case "snippet.list":
+		h.handleSnippetList(ctx, conn, req)
+	case "snippet.create":
+		h.handleSnippetCreate(ctx, conn, req)
+	case "snippet.update":
+		h.handleSnippetUpdate(ctx, conn, req)
+	case "snippet.delete":
+		h.handleSnippetDelete(ctx, conn, req)
+	case "snippet.expand":
+		h.handleSnippetExpand(ctx, conn, req)
+	// preview namespace
+	case "preview.list":
+		h.handlePreviewList(ctx, conn, req)
 	default:
 		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeMethodNotFound, "method not found: "+req.Method)
 	}
@@ -339,6 +508,23 @@ func (h *rpcMethodHandler) replyError(ctx context.Context, conn *jsonrpc2.Conn,
 	}
 }
 
+// replyConflict sends a CodeConflict error carrying the conflicted paths as error data.
+func (h *rpcMethodHandler) replyConflict(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, conflict *git.ConflictError) {
+	h.replyErrorWithData(ctx, conn, id, rpc.CodeConflict, conflict.Error(), conflict)
+}
+
+// replyErrorWithData replies with an error whose data field carries structured details.
+func (h *rpcMethodHandler) replyErrorWithData(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, code int64, message string, data any) {
+	err := &jsonrpc2.Error{
+		Code:    code,
+		Message: message,
+	}
+	err.SetError(data)
+	if replyErr := conn.ReplyWithError(ctx, id, err); replyErr != nil {
+		h.log.Error("failed to send error response", "error", replyErr)
+	}
+}
+
 func unmarshalParams(req *jsonrpc2.Request, v interface{}) error {
 	return json.Unmarshal(*req.Params, v)
 }
@@ -2,8 +2,7 @@ package ws
 
 import (
 	"context"
-	"fmt"
-	"log/slog"
+	"strings"
 	"unicode"
 
 	"github.com/pockode/server/agent"
@@ -62,27 +61,30 @@ func (h *rpcMethodHandler) handleMessage(ctx context.Context, conn *jsonrpc2.Con
 
 	log := h.log.With("sessionId", params.SessionID)
 
-	sess, err := h.getOrCreateProcess(ctx, log, params.SessionID)
-	if err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
-		return
+	if params.Snippet != nil {
+		content, missing, err := h.expandSnippet(params.Content, *params.Snippet)
+		if err != nil {
+			h.replySnippetError(ctx, conn, req.ID, err)
+			return
+		}
+		if len(missing) > 0 {
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "missing snippet variables: "+strings.Join(missing, ", "))
+			return
+		}
+		params.Content = content
 	}
 
 	h.recordCommandIfSlash(params.Content)
 
 	log.Info("received prompt", "length", len(params.Content))
 
-	// Persist user message to history
-	event := agent.MessageEvent{Content: params.Content}
-	if err := h.state.worktree.SessionStore.AppendToHistory(ctx, params.SessionID, agent.NewEventRecord(event)); err != nil {
-		log.Error("failed to append to history", "error", err)
-	}
-
-	// Send message to agent
-	if err := sess.SendMessage(params.Content); err != nil {
+	if err := h.state.worktree.SendMessage(ctx, params.SessionID, params.Content); err != nil {
 		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
 		return
 	}
+	if params.Snippet != nil {
+		h.recordSnippetUse(params.Snippet.ID)
+	}
 
 	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
 		log.Error("failed to send message response", "error", err)
@@ -98,7 +100,7 @@ func (h *rpcMethodHandler) handleInterrupt(ctx context.Context, conn *jsonrpc2.C
 
 	log := h.log.With("sessionId", params.SessionID)
 
-	sess, err := h.getOrCreateProcess(ctx, log, params.SessionID)
+	sess, err := h.state.worktree.AgentSession(ctx, params.SessionID)
 	if err != nil {
 		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
 		return
@@ -125,7 +127,7 @@ func (h *rpcMethodHandler) handlePermissionResponse(ctx context.Context, conn *j
 
 	log := h.log.With("sessionId", params.SessionID)
 
-	sess, err := h.getOrCreateProcess(ctx, log, params.SessionID)
+	sess, err := h.state.worktree.AgentSession(ctx, params.SessionID)
 	if err != nil {
 		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
 		return
@@ -166,7 +168,7 @@ func (h *rpcMethodHandler) handleQuestionResponse(ctx context.Context, conn *jso
 
 	log := h.log.With("sessionId", params.SessionID)
 
-	sess, err := h.getOrCreateProcess(ctx, log, params.SessionID)
+	sess, err := h.state.worktree.AgentSession(ctx, params.SessionID)
 	if err != nil {
 		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
 		return
@@ -228,32 +230,3 @@ func (h *rpcMethodHandler) recordCommandIfSlash(content string) {
 func isWhitespace(r rune) bool {
 	return unicode.IsSpace(r)
 }
-
-func (h *rpcMethodHandler) getOrCreateProcess(ctx context.Context, log *slog.Logger, sessionID string) (agent.Session, error) {
-	meta, found, err := h.state.worktree.SessionStore.Get(sessionID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get session: %w", err)
-	}
-	if !found {
-		return nil, fmt.Errorf("session not found: %s", sessionID)
-	}
-
-	resume := meta.Activated
-	proc, created, err := h.state.worktree.ProcessManager.GetOrCreateProcess(ctx, sessionID, resume, meta.Mode)
-	if err != nil {
-		return nil, err
-	}
-
-	// Mark as activated on first process creation
-	if created && !resume {
-		if err := h.state.worktree.SessionStore.Activate(ctx, sessionID); err != nil {
-			log.Error("failed to activate session", "error", err)
-		}
-	}
-
-	if created {
-		log.Info("process created", "resume", resume, "mode", meta.Mode)
-	}
-
-	return proc.AgentSession(), nil
-}
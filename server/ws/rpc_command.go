@@ -8,7 +8,12 @@ import (
 )
 
 func (h *rpcMethodHandler) handleCommandList(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
-	commands := h.commandStore.List()
+	// Project commands come from the bound worktree, which may have its own checkout of them
+	projectDir := h.worktreeManager.Registry().MainDir()
+	if wt := h.state.getWorktree(); wt != nil {
+		projectDir = wt.WorkDir
+	}
+	commands := h.commandStore.ListFor(projectDir)
 
 	result := rpc.CommandListResult{Commands: commands}
 
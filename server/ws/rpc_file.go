@@ -3,8 +3,11 @@ package ws
 import (
 	"context"
 	"errors"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/pockode/server/contents"
+	"github.com/pockode/server/logger"
 	"github.com/pockode/server/rpc"
 	"github.com/sourcegraph/jsonrpc2"
 )
@@ -16,9 +19,22 @@ func (h *rpcMethodHandler) handleFileGet(ctx context.Context, conn *jsonrpc2.Con
 		return
 	}
 
-	result, err := contents.GetContents(h.state.worktree.WorkDir, params.Path)
+	list := contents.ListOptions{Depth: params.Depth, ExcludeHidden: params.ExcludeHidden}
+	result, err := contents.GetContents(h.state.worktree.WorkDir, params.Path, list, contents.ReadOptions{
+		Offset:    params.Offset,
+		Length:    params.Length,
+		StartLine: params.StartLine,
+		EndLine:   params.EndLine,
+		Tail:      params.Tail,
+		MaxSize:   params.MaxSize,
+	})
 	if err != nil {
-		if errors.Is(err, contents.ErrNotFound) {
+		var tooLarge *contents.TooLargeError
+		if errors.As(err, &tooLarge) {
+			h.replyErrorWithData(ctx, conn, req.ID, rpc.CodeFileTooLarge, tooLarge.Error(), tooLarge)
+			return
+		}
+		if errors.Is(err, contents.ErrNotFound) || errors.Is(err, contents.ErrInvalidRange) {
 			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, err.Error())
 			return
 		}
@@ -33,8 +49,9 @@ func (h *rpcMethodHandler) handleFileGet(ctx context.Context, conn *jsonrpc2.Con
 	var response rpc.FileGetResult
 	if result.IsDir() {
 		response = rpc.FileGetResult{
-			Type:    "directory",
-			Entries: result.Entries,
+			Type:      "directory",
+			Entries:   result.Entries,
+			Truncated: result.Truncated,
 		}
 	} else {
 		response = rpc.FileGetResult{
@@ -47,3 +64,236 @@ func (h *rpcMethodHandler) handleFileGet(ctx context.Context, conn *jsonrpc2.Con
 		h.log.Error("failed to send file get response", "error", err)
 	}
 }
+
+func (h *rpcMethodHandler) handleFileFind(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.FileFindParams
+	if req.Params != nil {
+		if err := unmarshalParams(req, &params); err != nil {
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+			return
+		}
+	}
+
+	files, err := h.state.worktree.FileIndex.Files(ctx)
+	if err != nil {
+		h.log.Error("failed to list files", "error", err)
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, "failed to list files")
+		return
+	}
+
+	results, total := contents.Find(files, contents.FindOptions{Query: params.Query, Limit: params.Limit})
+	if err := conn.Reply(ctx, req.ID, rpc.FileFindResult{Results: results, Total: total}); err != nil {
+		h.log.Error("failed to send file find response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleFileSearch(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.FileSearchParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	searcher, err := contents.NewSearcher(contents.SearchOptions{
+		Query:         params.Query,
+		Regex:         params.Regex,
+		CaseSensitive: params.CaseSensitive,
+		Include:       params.Include,
+		Exclude:       params.Exclude,
+		MaxResults:    params.MaxResults,
+		ContextLines:  params.ContextLines,
+	})
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, err.Error())
+		return
+	}
+
+	id := uuid.Must(uuid.NewV7()).String()
+	searchCtx := h.state.startSearch(id)
+	workDir := h.state.worktree.WorkDir
+
+	// Reply before streaming so the client knows the ID of the first notification
+	if err := conn.Reply(ctx, req.ID, rpc.FileSearchResult{ID: id}); err != nil {
+		h.log.Error("failed to send file search response", "error", err)
+		h.state.endSearch(id)
+		return
+	}
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.LogPanic(r, "file search crashed", "searchId", id)
+			}
+		}()
+
+		start := time.Now()
+		summary, err := searcher.Run(searchCtx, workDir, func(matches []contents.SearchMatch) {
+			if err := conn.Notify(searchCtx, "file.search.result", rpc.FileSearchResultParams{ID: id, Matches: matches}); err != nil {
+				h.log.Debug("failed to send search results", "searchId", id, "error", err)
+			}
+		})
+
+		// endSearch returns false if the search was cancelled by the client or connection close
+		done := rpc.FileSearchDoneParams{ID: id, SearchSummary: summary}
+		if !h.state.endSearch(id) {
+			done.Cancelled = true
+		} else if err != nil {
+			done.Error = err.Error()
+		}
+		if err := conn.Notify(context.Background(), "file.search.done", done); err != nil {
+			h.log.Debug("failed to send search done", "searchId", id, "error", err)
+		}
+		h.log.Debug("file search finished", "searchId", id, "matches", summary.Matches, "files", summary.FilesSearched, "duration", time.Since(start))
+	}()
+}
+
+func (h *rpcMethodHandler) handleFileSearchCancel(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.FileSearchCancelParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	// Searches that already finished are not an error; the client may race with file.search.done
+	h.state.endSearch(params.ID)
+
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		h.log.Error("failed to send file search cancel response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleFileWrite(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.FileWriteParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	data, err := contents.DecodeContent(params.Content, params.Encoding)
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, err.Error())
+		return
+	}
+
+	pre := contents.Precondition{Hash: params.ExpectedHash}
+	if params.ExpectedMtime != nil {
+		pre.ModTime = *params.ExpectedMtime
+	}
+
+	result, err := contents.WriteFile(h.state.worktree.WorkDir, params.Path, data, pre)
+	if err != nil {
+		h.replyFileError(ctx, conn, req.ID, err)
+		return
+	}
+
+	h.state.worktree.FSWatcher.NotifyChanged(rpc.FSOpWrite, params.Path)
+	h.log.Info("file written", "path", params.Path, "size", result.Size)
+
+	if err := conn.Reply(ctx, req.ID, rpc.FileWriteResult{File: result}); err != nil {
+		h.log.Error("failed to send file write response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleFileCreate(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.FileCreateParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	data, err := contents.DecodeContent(params.Content, params.Encoding)
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, err.Error())
+		return
+	}
+
+	result, err := contents.CreateFile(h.state.worktree.WorkDir, params.Path, data)
+	if err != nil {
+		h.replyFileError(ctx, conn, req.ID, err)
+		return
+	}
+
+	h.state.worktree.FSWatcher.NotifyChanged(rpc.FSOpCreate, params.Path)
+	h.log.Info("file created", "path", params.Path)
+
+	if err := conn.Reply(ctx, req.ID, rpc.FileWriteResult{File: result}); err != nil {
+		h.log.Error("failed to send file create response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleFileMkdir(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.FileMkdirParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	if err := contents.Mkdir(h.state.worktree.WorkDir, params.Path); err != nil {
+		h.replyFileError(ctx, conn, req.ID, err)
+		return
+	}
+
+	h.state.worktree.FSWatcher.NotifyChanged(rpc.FSOpCreate, params.Path)
+	h.log.Info("directory created", "path", params.Path)
+
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		h.log.Error("failed to send file mkdir response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleFileRename(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.FileRenameParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	if err := contents.Rename(h.state.worktree.WorkDir, params.Path, params.NewPath); err != nil {
+		h.replyFileError(ctx, conn, req.ID, err)
+		return
+	}
+
+	h.state.worktree.FSWatcher.NotifyChanged(rpc.FSOpRename, params.Path)
+	h.state.worktree.FSWatcher.NotifyChanged(rpc.FSOpCreate, params.NewPath)
+	h.log.Info("file renamed", "path", params.Path, "newPath", params.NewPath)
+
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		h.log.Error("failed to send file rename response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleFileDelete(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.FileDeleteParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	if err := contents.Delete(h.state.worktree.WorkDir, params.Path, params.Recursive); err != nil {
+		h.replyFileError(ctx, conn, req.ID, err)
+		return
+	}
+
+	h.state.worktree.FSWatcher.NotifyChanged(rpc.FSOpRemove, params.Path)
+	h.log.Info("file deleted", "path", params.Path, "recursive", params.Recursive)
+
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		h.log.Error("failed to send file delete response", "error", err)
+	}
+}
+
+// replyFileError maps errors from the contents package to JSON-RPC errors.
+func (h *rpcMethodHandler) replyFileError(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, err error) {
+	var modified *contents.ModifiedError
+	switch {
+	case errors.As(err, &modified):
+		h.replyErrorWithData(ctx, conn, id, rpc.CodeFileModified, modified.Error(), modified)
+	case errors.Is(err, contents.ErrInvalidPath):
+		h.replyError(ctx, conn, id, jsonrpc2.CodeInvalidParams, "invalid path")
+	case errors.Is(err, contents.ErrProtectedPath), errors.Is(err, contents.ErrNotFound),
+		errors.Is(err, contents.ErrAlreadyExists), errors.Is(err, contents.ErrNotEmpty):
+		h.replyError(ctx, conn, id, jsonrpc2.CodeInvalidParams, err.Error())
+	default:
+		h.replyError(ctx, conn, id, jsonrpc2.CodeInternalError, err.Error())
+	}
+}
@@ -15,12 +15,12 @@ func (h *rpcMethodHandler) handleFSSubscribe(ctx context.Context, conn *jsonrpc2
 	}
 
 	connID := h.state.getConnID()
-	id, err := h.state.worktree.FSWatcher.Subscribe(params.Path, conn, connID)
+	id, err := h.state.worktree.FSWatcher.Subscribe(params.Path, params.Recursive, conn, connID)
 	if err != nil {
 		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, err.Error())
 		return
 	}
-	h.log.Debug("subscribed", "watcher", "fs", "watchId", id, "path", params.Path)
+	h.log.Debug("subscribed", "watcher", "fs", "watchId", id, "path", params.Path, "recursive", params.Recursive)
 
 	if err := conn.Reply(ctx, req.ID, rpc.FSSubscribeResult{ID: id}); err != nil {
 		h.log.Error("failed to send fs subscribe response", "error", err)
@@ -8,6 +8,7 @@ import (
 	"github.com/pockode/server/contents"
 	"github.com/pockode/server/git"
 	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/snapshot"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
@@ -149,3 +150,121 @@ func (h *rpcMethodHandler) handleGitReset(ctx context.Context, conn *jsonrpc2.Co
 		h.log.Error("failed to send git reset response", "error", err)
 	}
 }
+
+func (h *rpcMethodHandler) handleGitDiscard(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.GitDiscardParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	if len(params.Paths) == 0 {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "paths required")
+		return
+	}
+
+	workDir := h.state.worktree.WorkDir
+	for _, path := range params.Paths {
+		if err := contents.ValidatePath(workDir, path); err != nil {
+			if errors.Is(err, contents.ErrInvalidPath) {
+				h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid path: "+path)
+				return
+			}
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+			return
+		}
+	}
+
+	// Snapshot before touching anything so a partial failure is still recoverable
+	snap, err := h.state.worktree.SnapshotStore.Save(workDir, params.Paths, "discard")
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, "failed to snapshot files: "+err.Error())
+		return
+	}
+
+	for _, path := range params.Paths {
+		if err := git.Discard(workDir, path, params.Staged); err != nil {
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+			return
+		}
+	}
+
+	h.log.Info("discarded changes", "paths", len(params.Paths), "staged", params.Staged, "snapshotId", snap.ID)
+
+	result := rpc.GitDiscardResult{SnapshotID: snap.ID, ExpiresAt: snap.ExpiresAt}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		h.log.Error("failed to send git discard response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleGitDiscardList(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	snaps, err := h.state.worktree.SnapshotStore.List()
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, rpc.GitDiscardListResult{Snapshots: snaps}); err != nil {
+		h.log.Error("failed to send git discard list response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleGitDiscardRestore(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.GitDiscardRestoreParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	if params.SnapshotID == "" {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "snapshot_id required")
+		return
+	}
+
+	snap, err := h.state.worktree.SnapshotStore.Restore(h.state.worktree.WorkDir, params.SnapshotID)
+	if err != nil {
+		if errors.Is(err, snapshot.ErrSnapshotNotFound) {
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "snapshot not found")
+			return
+		}
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+		return
+	}
+
+	h.log.Info("restored discarded changes", "snapshotId", snap.ID, "paths", len(snap.Paths))
+
+	if err := conn.Reply(ctx, req.ID, rpc.GitDiscardRestoreResult{Paths: snap.Paths}); err != nil {
+		h.log.Error("failed to send git discard restore response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleGitBlame(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.GitBlameParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	if !h.validateGitPath(ctx, conn, req.ID, params.Path) {
+		return
+	}
+
+	opts := git.BlameOptions{
+		Rev:       params.Rev,
+		StartLine: params.StartLine,
+		EndLine:   params.EndLine,
+	}
+	lines, err := git.Blame(h.state.worktree.WorkDir, params.Path, opts, h.state.worktree.BlameCache)
+	if err != nil {
+		if errors.Is(err, git.ErrInvalidRevision) {
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, err.Error())
+			return
+		}
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, rpc.GitBlameResult{Path: params.Path, Lines: lines}); err != nil {
+		h.log.Error("failed to send git blame response", "error", err)
+	}
+}
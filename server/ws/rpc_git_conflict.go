@@ -0,0 +1,113 @@
+package ws
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pockode/server/contents"
+	"github.com/pockode/server/git"
+	"github.com/pockode/server/rpc"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *rpcMethodHandler) handleGitConflictGet(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.GitConflictGetParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	if !h.validateGitPath(ctx, conn, req.ID, params.Path) {
+		return
+	}
+
+	result, err := git.GetConflict(h.state.worktree.WorkDir, params.Path)
+	if err != nil {
+		h.replyConflictError(ctx, conn, req.ID, err)
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		h.log.Error("failed to send git conflict get response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleGitConflictResolve(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.GitConflictResolveParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	if !h.validateGitPath(ctx, conn, req.ID, params.Path) {
+		return
+	}
+
+	if err := git.ResolveConflict(h.state.worktree.WorkDir, params.Path, params.Resolution, params.Content); err != nil {
+		h.replyConflictError(ctx, conn, req.ID, err)
+		return
+	}
+
+	h.log.Info("conflict resolved", "path", params.Path, "resolution", params.Resolution)
+
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		h.log.Error("failed to send git conflict resolve response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleGitOperationContinue(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, op git.Operation) {
+	if err := git.ContinueOperation(h.state.worktree.WorkDir, op); err != nil {
+		h.replyConflictError(ctx, conn, req.ID, err)
+		return
+	}
+
+	h.log.Info("git operation continued", "operation", op)
+
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		h.log.Error("failed to send git "+string(op)+" continue response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleGitOperationAbort(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, op git.Operation) {
+	if err := git.AbortOperation(h.state.worktree.WorkDir, op); err != nil {
+		h.replyConflictError(ctx, conn, req.ID, err)
+		return
+	}
+
+	h.log.Info("git operation aborted", "operation", op)
+
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		h.log.Error("failed to send git "+string(op)+" abort response", "error", err)
+	}
+}
+
+// validateGitPath replies with an error and returns false if path is empty or invalid.
+func (h *rpcMethodHandler) validateGitPath(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, path string) bool {
+	if path == "" {
+		h.replyError(ctx, conn, id, jsonrpc2.CodeInvalidParams, "path required")
+		return false
+	}
+	if err := contents.ValidatePath(h.state.worktree.WorkDir, path); err != nil {
+		if errors.Is(err, contents.ErrInvalidPath) {
+			h.replyError(ctx, conn, id, jsonrpc2.CodeInvalidParams, "invalid path")
+			return false
+		}
+		h.replyError(ctx, conn, id, jsonrpc2.CodeInternalError, err.Error())
+		return false
+	}
+	return true
+}
+
+func (h *rpcMethodHandler) replyConflictError(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, err error) {
+	var conflict *git.ConflictError
+	switch {
+	case errors.As(err, &conflict):
+		h.replyConflict(ctx, conn, id, conflict)
+	case errors.Is(err, git.ErrNotConflicted),
+		errors.Is(err, git.ErrInvalidResolution),
+		errors.Is(err, git.ErrNoOperationInProgress):
+		h.replyError(ctx, conn, id, jsonrpc2.CodeInvalidParams, err.Error())
+	default:
+		h.replyError(ctx, conn, id, jsonrpc2.CodeInternalError, err.Error())
+	}
+}
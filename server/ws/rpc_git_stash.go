@@ -0,0 +1,127 @@
+package ws
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pockode/server/contents"
+	"github.com/pockode/server/git"
+	"github.com/pockode/server/rpc"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *rpcMethodHandler) handleGitStashPush(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.GitStashPushParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	workDir := h.state.worktree.WorkDir
+	for _, path := range params.Paths {
+		if err := contents.ValidatePath(workDir, path); err != nil {
+			if errors.Is(err, contents.ErrInvalidPath) {
+				h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid path: "+path)
+				return
+			}
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+			return
+		}
+	}
+
+	err := git.StashPush(workDir, git.StashPushOptions{
+		Message:          params.Message,
+		IncludeUntracked: params.IncludeUntracked,
+		Paths:            params.Paths,
+	})
+	if err != nil {
+		if errors.Is(err, git.ErrNothingToStash) {
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidRequest, err.Error())
+			return
+		}
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+		return
+	}
+
+	h.log.Info("stash pushed", "paths", len(params.Paths), "includeUntracked", params.IncludeUntracked)
+
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		h.log.Error("failed to send git stash push response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleGitStashList(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	stashes, err := git.StashList(h.state.worktree.WorkDir)
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, rpc.GitStashListResult{Stashes: stashes}); err != nil {
+		h.log.Error("failed to send git stash list response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleGitStashShow(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.GitStashParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	detail, err := git.StashShow(h.state.worktree.WorkDir, params.Index)
+	if err != nil {
+		h.replyStashError(ctx, conn, req.ID, err)
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, detail); err != nil {
+		h.log.Error("failed to send git stash show response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleGitStashApply(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, pop bool) {
+	var params rpc.GitStashParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	if err := git.StashApply(h.state.worktree.WorkDir, params.Index, pop); err != nil {
+		h.replyStashError(ctx, conn, req.ID, err)
+		return
+	}
+
+	h.log.Info("stash applied", "index", params.Index, "pop", pop)
+
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		h.log.Error("failed to send git stash apply response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleGitStashDrop(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.GitStashParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	if err := git.StashDrop(h.state.worktree.WorkDir, params.Index); err != nil {
+		h.replyStashError(ctx, conn, req.ID, err)
+		return
+	}
+
+	h.log.Info("stash dropped", "index", params.Index)
+
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		h.log.Error("failed to send git stash drop response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) replyStashError(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, err error) {
+	if errors.Is(err, git.ErrStashNotFound) {
+		h.replyError(ctx, conn, id, jsonrpc2.CodeInvalidParams, "stash not found")
+		return
+	}
+	h.replyConflictError(ctx, conn, id, err)
+}
@@ -0,0 +1,22 @@
+package ws
+
+import (
+	"context"
+
+	"github.com/pockode/server/preview"
+	"github.com/pockode/server/rpc"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *rpcMethodHandler) handlePreviewList(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	detected := preview.DetectPorts(h.state.worktree)
+	ports := make([]rpc.PreviewPort, len(detected))
+	for i, p := range detected {
+		ports[i] = rpc.PreviewPort{Port: p.Port, Source: string(p.Source), Path: p.Path}
+	}
+
+	result := rpc.PreviewListResult{Ports: ports, Origin: h.previewOrigin, ServerPort: h.previewPort}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		h.log.Error("failed to send preview list response", "error", err)
+	}
+}
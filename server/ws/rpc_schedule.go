@@ -0,0 +1,121 @@
+package ws
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/schedule"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *rpcMethodHandler) handleScheduleList(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if err := conn.Reply(ctx, req.ID, rpc.ScheduleListResult{Jobs: h.scheduler.List()}); err != nil {
+		h.log.Error("failed to send schedule list response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleScheduleCreate(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.ScheduleCreateParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	job, err := h.scheduler.Create(schedule.NewJob{
+		Name:         params.Name,
+		Spec:         params.Spec,
+		Worktree:     params.Worktree,
+		Prompt:       params.Prompt,
+		Mode:         params.Mode,
+		ReuseSession: params.ReuseSession,
+	})
+	if err != nil {
+		h.replyScheduleError(ctx, conn, req.ID, err)
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, job); err != nil {
+		h.log.Error("failed to send schedule create response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleSchedulePause(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.SchedulePauseParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+	h.setSchedulePaused(ctx, conn, req, params.ID, true)
+}
+
+func (h *rpcMethodHandler) handleScheduleResume(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.ScheduleResumeParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+	h.setSchedulePaused(ctx, conn, req, params.ID, false)
+}
+
+func (h *rpcMethodHandler) setSchedulePaused(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, id string, paused bool) {
+	job, err := h.scheduler.SetPaused(id, paused)
+	if err != nil {
+		h.replyScheduleError(ctx, conn, req.ID, err)
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, job); err != nil {
+		h.log.Error("failed to send schedule pause response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleScheduleDelete(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.ScheduleDeleteParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	if err := h.scheduler.Delete(params.ID); err != nil {
+		h.replyScheduleError(ctx, conn, req.ID, err)
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		h.log.Error("failed to send schedule delete response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleScheduleHistory(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.ScheduleHistoryParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	runs, err := h.scheduler.History(params.ID)
+	if err != nil {
+		h.replyScheduleError(ctx, conn, req.ID, err)
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, rpc.ScheduleHistoryResult{Runs: runs}); err != nil {
+		h.log.Error("failed to send schedule history response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) replyScheduleError(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, err error) {
+	switch {
+	case errors.Is(err, schedule.ErrNotFound),
+		errors.Is(err, schedule.ErrInvalidSpec),
+		errors.Is(err, schedule.ErrNameRequired),
+		errors.Is(err, schedule.ErrPromptRequired),
+		errors.Is(err, schedule.ErrInvalidMode),
+		errors.Is(err, schedule.ErrWorktreeNotFound):
+		h.replyError(ctx, conn, id, jsonrpc2.CodeInvalidParams, err.Error())
+	default:
+		h.log.Error("schedule error", "error", err)
+		h.replyError(ctx, conn, id, jsonrpc2.CodeInternalError, err.Error())
+	}
+}
@@ -7,12 +7,26 @@ import (
 	"github.com/google/uuid"
 	"github.com/pockode/server/rpc"
 	"github.com/pockode/server/session"
+	"github.com/pockode/server/worktree"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
 func (h *rpcMethodHandler) handleSessionCreate(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.SessionCreateParams
+	if req.Params != nil {
+		if err := unmarshalParams(req, &params); err != nil {
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+			return
+		}
+	}
+
 	sessionID := uuid.Must(uuid.NewV7()).String()
 
+	if params.Worktree {
+		h.createSessionWorktree(ctx, conn, req, sessionID, params.BaseBranch)
+		return
+	}
+
 	sess, err := h.state.worktree.SessionStore.Create(ctx, sessionID)
 	if err != nil {
 		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, "failed to create session")
@@ -21,7 +35,26 @@ func (h *rpcMethodHandler) handleSessionCreate(ctx context.Context, conn *jsonrp
 
 	h.log.Info("session created", "sessionId", sessionID)
 
-	if err := conn.Reply(ctx, req.ID, sess); err != nil {
+	if err := conn.Reply(ctx, req.ID, rpc.SessionCreateResult{SessionMeta: sess}); err != nil {
+		h.log.Error("failed to send session create response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) createSessionWorktree(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, sessionID, baseBranch string) {
+	info, sess, err := h.worktreeManager.CreateSessionWorktree(ctx, sessionID, baseBranch)
+	if err != nil {
+		if errors.Is(err, worktree.ErrNotGitRepo) {
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidRequest, "not a git repository")
+			return
+		}
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+		return
+	}
+
+	h.log.Info("session created", "sessionId", sessionID, "worktree", info.Name, "branch", info.Branch)
+
+	wt := h.worktreeManager.Describe(info)
+	if err := conn.Reply(ctx, req.ID, rpc.SessionCreateResult{SessionMeta: sess, Worktree: &wt}); err != nil {
 		h.log.Error("failed to send session create response", "error", err)
 	}
 }
@@ -41,9 +74,14 @@ func (h *rpcMethodHandler) handleSessionDelete(ctx context.Context, conn *jsonrp
 
 	h.log.Info("session deleted", "sessionId", params.SessionID)
 
+	// The connection may be closed, clearing its worktree, once replied to
+	worktreeName := h.state.getWorktree().Name
 	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
 		h.log.Error("failed to send session delete response", "error", err)
 	}
+
+	// Removing the worktree notifies its clients, including this one, so do it after replying
+	go h.worktreeManager.CleanupSessionWorktree(worktreeName, params.SessionID)
 }
 
 func (h *rpcMethodHandler) handleSessionUpdateTitle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
@@ -0,0 +1,185 @@
+package ws
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pockode/server/git"
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/snippet"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *rpcMethodHandler) handleSnippetList(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	snippets := append(h.snippetStore.List(), h.state.worktree.SnippetStore.List()...)
+	snippet.SortByUsage(snippets)
+
+	if err := conn.Reply(ctx, req.ID, rpc.SnippetListResult{Snippets: snippets}); err != nil {
+		h.log.Error("failed to send snippet list response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleSnippetCreate(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.SnippetCreateParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	store := h.snippetStore
+	switch params.Scope {
+	case "", snippet.ScopeGlobal:
+	case snippet.ScopeWorktree:
+		store = h.state.worktree.SnippetStore
+	default:
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, snippet.ErrInvalidScope.Error())
+		return
+	}
+
+	created, err := store.Create(params.Name, params.Description, params.Content)
+	if err != nil {
+		h.replySnippetError(ctx, conn, req.ID, err)
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, created); err != nil {
+		h.log.Error("failed to send snippet create response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleSnippetUpdate(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.SnippetUpdateParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	_, store, err := h.findSnippet(params.ID)
+	if err != nil {
+		h.replySnippetError(ctx, conn, req.ID, err)
+		return
+	}
+	updated, err := store.Update(params.ID, snippet.Update{
+		Name:        params.Name,
+		Description: params.Description,
+		Content:     params.Content,
+	})
+	if err != nil {
+		h.replySnippetError(ctx, conn, req.ID, err)
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, updated); err != nil {
+		h.log.Error("failed to send snippet update response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleSnippetDelete(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.SnippetDeleteParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	_, store, err := h.findSnippet(params.ID)
+	if err == nil {
+		err = store.Delete(params.ID)
+	}
+	if err != nil {
+		h.replySnippetError(ctx, conn, req.ID, err)
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		h.log.Error("failed to send snippet delete response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleSnippetExpand(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.SnippetExpandParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	content, missing, err := h.expandSnippet(params.Content, params.SnippetUse)
+	if err != nil {
+		h.replySnippetError(ctx, conn, req.ID, err)
+		return
+	}
+	if missing == nil {
+		missing = []string{}
+	}
+
+	if err := conn.Reply(ctx, req.ID, rpc.SnippetExpandResult{Content: content, Missing: missing}); err != nil {
+		h.log.Error("failed to send snippet expand response", "error", err)
+	}
+}
+
+// expandSnippet expands template, or the saved content of the snippet if template is empty.
+func (h *rpcMethodHandler) expandSnippet(template string, use rpc.SnippetUse) (string, []string, error) {
+	saved, _, err := h.findSnippet(use.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	if template == "" {
+		template = saved.Content
+	}
+
+	wt := h.state.worktree
+	content, missing := snippet.Expand(template, func(name string) (string, bool) {
+		if value, ok := use.Variables[name]; ok {
+			return value, true
+		}
+		switch name {
+		case snippet.VarBranch:
+			return git.CurrentBranch(wt.WorkDir), true
+		case snippet.VarWorktree:
+			return wt.Name, true
+		case snippet.VarSelectedFile:
+			return use.SelectedFile, use.SelectedFile != ""
+		case snippet.VarDiffSummary:
+			summary, err := git.DiffSummary(wt.WorkDir)
+			if err != nil {
+				h.log.Debug("failed to summarize diff for snippet", "error", err)
+			}
+			return summary, true
+		}
+		return "", false
+	})
+	return content, missing, nil
+}
+
+// findSnippet looks a snippet up in the global store, then in the worktree's.
+func (h *rpcMethodHandler) findSnippet(id string) (snippet.Snippet, *snippet.Store, error) {
+	for _, store := range []*snippet.Store{h.snippetStore, h.state.worktree.SnippetStore} {
+		if s, err := store.Get(id); err == nil {
+			return s, store, nil
+		}
+	}
+	return snippet.Snippet{}, nil, snippet.ErrNotFound
+}
+
+// recordSnippetUse counts a sent snippet, for ordering by usage.
+func (h *rpcMethodHandler) recordSnippetUse(id string) {
+	_, store, err := h.findSnippet(id)
+	if err == nil {
+		err = store.Use(id)
+	}
+	if err != nil {
+		h.log.Error("failed to record snippet usage", "snippetId", id, "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) replySnippetError(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, err error) {
+	switch {
+	case errors.Is(err, snippet.ErrNotFound),
+		errors.Is(err, snippet.ErrNameRequired),
+		errors.Is(err, snippet.ErrContentRequired),
+		errors.Is(err, snippet.ErrDuplicateName):
+		h.replyError(ctx, conn, id, jsonrpc2.CodeInvalidParams, err.Error())
+	default:
+		h.log.Error("snippet error", "error", err)
+		h.replyError(ctx, conn, id, jsonrpc2.CodeInternalError, err.Error())
+	}
+}
@@ -0,0 +1,103 @@
+package ws
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/task"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *rpcMethodHandler) handleTaskList(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	tasks, err := h.state.worktree.Tasks.Tasks()
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, rpc.TaskListResult{Tasks: tasks}); err != nil {
+		h.log.Error("failed to send task list response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleTaskRun(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.TaskRunParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	run, err := h.state.worktree.Tasks.Run(params.Name)
+	if err != nil {
+		h.replyTaskError(ctx, conn, req.ID, err)
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, rpc.TaskRunResult{RunID: run.ID}); err != nil {
+		h.log.Error("failed to send task run response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleTaskCancel(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.TaskCancelParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	if err := h.state.worktree.Tasks.Cancel(params.RunID); err != nil {
+		h.replyTaskError(ctx, conn, req.ID, err)
+		return
+	}
+	h.log.Info("task cancelled", "runId", params.RunID)
+
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		h.log.Error("failed to send task cancel response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleTaskGet(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.TaskGetParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	run, output, err := h.state.worktree.Tasks.Get(params.RunID)
+	if err != nil {
+		h.replyTaskError(ctx, conn, req.ID, err)
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, rpc.TaskGetResult{Run: run, Output: output}); err != nil {
+		h.log.Error("failed to send task get response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleTaskHistory(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	runs := h.state.worktree.Tasks.History()
+	if err := conn.Reply(ctx, req.ID, rpc.TaskHistoryResult{Runs: runs}); err != nil {
+		h.log.Error("failed to send task history response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleTaskSubscribe(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	id, runs := h.state.worktree.TaskWatcher.Subscribe(conn, h.state.getConnID())
+	h.log.Debug("subscribed", "watcher", "task", "watchId", id)
+
+	if err := conn.Reply(ctx, req.ID, rpc.TaskSubscribeResult{ID: id, Runs: runs}); err != nil {
+		h.log.Error("failed to send task subscribe response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) replyTaskError(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, err error) {
+	switch {
+	case errors.Is(err, task.ErrTaskNotFound), errors.Is(err, task.ErrRunNotFound):
+		h.replyError(ctx, conn, id, jsonrpc2.CodeInvalidParams, err.Error())
+	case errors.Is(err, task.ErrRunFinished):
+		h.replyError(ctx, conn, id, jsonrpc2.CodeInvalidRequest, err.Error())
+	default:
+		h.replyError(ctx, conn, id, jsonrpc2.CodeInternalError, err.Error())
+	}
+}
@@ -0,0 +1,162 @@
+package ws
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/terminal"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *rpcMethodHandler) handleTerminalOpen(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.TerminalOpenParams
+	if req.Params != nil {
+		if err := unmarshalParams(req, &params); err != nil {
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+			return
+		}
+	}
+
+	term, err := h.state.worktree.Terminals.Open(terminal.OpenOptions{
+		Cols:  params.Cols,
+		Rows:  params.Rows,
+		Shell: params.Shell,
+	})
+	if err != nil {
+		h.replyTerminalError(ctx, conn, req.ID, err)
+		return
+	}
+	h.attachTerminal(ctx, conn, req, term)
+}
+
+func (h *rpcMethodHandler) handleTerminalAttach(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.TerminalAttachParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	term, err := h.state.worktree.Terminals.Get(params.TerminalID)
+	if err != nil {
+		h.replyTerminalError(ctx, conn, req.ID, err)
+		return
+	}
+
+	h.attachTerminal(ctx, conn, req, term)
+}
+
+func (h *rpcMethodHandler) attachTerminal(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, term *terminal.Terminal) {
+	var result rpc.TerminalAttachResult
+	term.Attach(func(info terminal.Info, scrollback string) {
+		if !info.Exited {
+			result.ID = h.state.worktree.TerminalWatcher.Subscribe(conn, h.state.getConnID(), info.ID)
+		}
+		result.Terminal = terminalInfoToRPC(info)
+		result.Scrollback = scrollback
+	})
+	h.log.Debug("subscribed", "watcher", "terminal", "watchId", result.ID, "terminalId", term.ID())
+
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		h.log.Error("failed to send terminal attach response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleTerminalInput(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.TerminalInputParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	term, err := h.state.worktree.Terminals.Get(params.TerminalID)
+	if err == nil {
+		err = term.Write(params.Data)
+	}
+	if err != nil {
+		h.replyTerminalError(ctx, conn, req.ID, err)
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		h.log.Error("failed to send terminal input response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleTerminalResize(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.TerminalResizeParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	term, err := h.state.worktree.Terminals.Get(params.TerminalID)
+	if err == nil {
+		err = term.Resize(params.Cols, params.Rows)
+	}
+	if err != nil {
+		h.replyTerminalError(ctx, conn, req.ID, err)
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		h.log.Error("failed to send terminal resize response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleTerminalClose(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.TerminalCloseParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	if err := h.state.worktree.Terminals.Close(params.TerminalID); err != nil {
+		h.replyTerminalError(ctx, conn, req.ID, err)
+		return
+	}
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		h.log.Error("failed to send terminal close response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleTerminalList(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	infos := h.state.worktree.Terminals.List()
+	terminals := make([]rpc.TerminalInfo, len(infos))
+	for i, info := range infos {
+		terminals[i] = terminalInfoToRPC(info)
+	}
+
+	if err := conn.Reply(ctx, req.ID, rpc.TerminalListResult{Terminals: terminals}); err != nil {
+		h.log.Error("failed to send terminal list response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) replyTerminalError(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, err error) {
+	switch {
+	case errors.Is(err, terminal.ErrNotFound):
+		h.replyError(ctx, conn, id, jsonrpc2.CodeInvalidParams, "terminal not found")
+	case errors.Is(err, terminal.ErrInvalidSize), errors.Is(err, terminal.ErrShellNotFound):
+		h.replyError(ctx, conn, id, jsonrpc2.CodeInvalidParams, err.Error())
+	case errors.Is(err, terminal.ErrExited), errors.Is(err, terminal.ErrTooManyTerminals):
+		h.replyError(ctx, conn, id, jsonrpc2.CodeInvalidRequest, err.Error())
+	default:
+		h.log.Error("terminal error", "error", err)
+		h.replyError(ctx, conn, id, jsonrpc2.CodeInternalError, err.Error())
+	}
+}
+
+func terminalInfoToRPC(info terminal.Info) rpc.TerminalInfo {
+	result := rpc.TerminalInfo{
+		ID:        info.ID,
+		Shell:     info.Shell,
+		Cols:      info.Cols,
+		Rows:      info.Rows,
+		CreatedAt: info.CreatedAt,
+		Exited:    info.Exited,
+	}
+	if info.Exited {
+		result.ExitCode = &info.ExitCode
+	}
+	return result
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -15,9 +16,14 @@ import (
 	"github.com/coder/websocket"
 	"github.com/pockode/server/agent"
 	"github.com/pockode/server/command"
+	"github.com/pockode/server/contents"
+	"github.com/pockode/server/git"
 	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/schedule"
 	"github.com/pockode/server/session"
 	"github.com/pockode/server/settings"
+	"github.com/pockode/server/snippet"
+	"github.com/pockode/server/task"
 	"github.com/pockode/server/worktree"
 	"github.com/sourcegraph/jsonrpc2"
 )
@@ -46,6 +52,10 @@ func newTestEnvWithWorkDir(t *testing.T, mock *mockAgent, workDir string) *testE
 	if err != nil {
 		t.Fatalf("failed to create command store: %v", err)
 	}
+	snippetStore, err := snippet.NewStore(dataDir, snippet.ScopeGlobal)
+	if err != nil {
+		t.Fatalf("failed to create snippet store: %v", err)
+	}
 	settingsStore, err := settings.NewStore(dataDir)
 	if err != nil {
 		t.Fatalf("failed to create settings store: %v", err)
@@ -54,7 +64,11 @@ func newTestEnvWithWorkDir(t *testing.T, mock *mockAgent, workDir string) *testE
 	registry := worktree.NewRegistry(workDir)
 	worktreeManager := worktree.NewManager(registry, mock, dataDir, 10*time.Minute)
 
-	h := NewRPCHandler("test-token", "test", true, "claude", cmdStore, worktreeManager, settingsStore)
+	scheduler, err := schedule.NewScheduler(dataDir, worktreeManager, nil)
+	if err != nil {
+		t.Fatalf("failed to create scheduler: %v", err)
+	}
+	h := NewRPCHandler("test-token", "test", true, "claude", cmdStore, snippetStore, worktreeManager, settingsStore, scheduler)
 	server := httptest.NewServer(h)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -206,12 +220,14 @@ func TestHandler_Auth_InvalidToken(t *testing.T) {
 	dataDir := t.TempDir()
 	workDir := t.TempDir()
 	cmdStore, _ := command.NewStore(dataDir)
+	snippetStore, _ := snippet.NewStore(dataDir, snippet.ScopeGlobal)
 	settingsStore, _ := settings.NewStore(dataDir)
 	registry := worktree.NewRegistry(workDir)
 	worktreeManager := worktree.NewManager(registry, &mockAgent{}, dataDir, 10*time.Minute)
 	defer worktreeManager.Shutdown()
 
-	h := NewRPCHandler("secret-token", "test", true, "claude", cmdStore, worktreeManager, settingsStore)
+	scheduler, _ := schedule.NewScheduler(dataDir, worktreeManager, nil)
+	h := NewRPCHandler("secret-token", "test", true, "claude", cmdStore, snippetStore, worktreeManager, settingsStore, scheduler)
 	server := httptest.NewServer(h)
 	defer server.Close()
 
@@ -253,12 +269,14 @@ func TestHandler_Auth_FirstMessageMustBeAuth(t *testing.T) {
 	dataDir := t.TempDir()
 	workDir := t.TempDir()
 	cmdStore, _ := command.NewStore(dataDir)
+	snippetStore, _ := snippet.NewStore(dataDir, snippet.ScopeGlobal)
 	settingsStore, _ := settings.NewStore(dataDir)
 	registry := worktree.NewRegistry(workDir)
 	worktreeManager := worktree.NewManager(registry, &mockAgent{}, dataDir, 10*time.Minute)
 	defer worktreeManager.Shutdown()
 
-	h := NewRPCHandler("test-token", "test", true, "claude", cmdStore, worktreeManager, settingsStore)
+	scheduler, _ := schedule.NewScheduler(dataDir, worktreeManager, nil)
+	h := NewRPCHandler("test-token", "test", true, "claude", cmdStore, snippetStore, worktreeManager, settingsStore, scheduler)
 	server := httptest.NewServer(h)
 	defer server.Close()
 
@@ -663,6 +681,36 @@ func TestHandler_SessionCreate(t *testing.T) {
 	}
 }
 
+func TestHandler_SessionCreate_DedicatedWorktree(t *testing.T) {
+	dir := setupGitRepo(t)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Test"), 0644)
+	runGitIn(t, dir, "add", ".")
+	runGitIn(t, dir, "commit", "-m", "initial")
+
+	env := newWorkDirTestEnv(t, dir)
+
+	resp := env.call("session.create", rpc.SessionCreateParams{Worktree: true})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+
+	var result rpc.SessionCreateResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("failed to unmarshal result: %v", err)
+	}
+	if result.ID == "" || result.Worktree == nil {
+		t.Fatalf("expected session with worktree, got %+v", result)
+	}
+	if result.Worktree.Session != result.ID || result.Worktree.SessionCount != 1 {
+		t.Errorf("expected worktree bound to session, got %+v", result.Worktree)
+	}
+
+	// The session lives in the new worktree, not the one the connection is on
+	if _, found, _ := env.getMainWorktree().SessionStore.Get(result.ID); found {
+		t.Error("session should not be created in the main worktree")
+	}
+}
+
 func TestHandler_SessionDelete(t *testing.T) {
 	env := newTestEnv(t, &mockAgent{})
 	store := env.getMainWorktree().SessionStore
@@ -846,6 +894,36 @@ func TestHandler_FileGet_ReadFile(t *testing.T) {
 	}
 }
 
+func TestHandler_FileGet_TooLargeAndTail(t *testing.T) {
+	workDir := t.TempDir()
+	env := newWorkDirTestEnv(t, workDir)
+	os.WriteFile(filepath.Join(workDir, "app.log"), []byte("first\nsecond\nthird\n"), 0644)
+
+	resp := env.call("file.get", rpc.FileGetParams{Path: "app.log", MaxSize: 8})
+	if resp.Error == nil || resp.Error.Code != rpc.CodeFileTooLarge {
+		t.Fatalf("expected CodeFileTooLarge, got %+v", resp.Error)
+	}
+	var tooLarge contents.TooLargeError
+	if resp.Error.Data == nil || json.Unmarshal(*resp.Error.Data, &tooLarge) != nil || tooLarge.Size != 19 {
+		t.Errorf("expected size in error data, got %+v", resp.Error.Data)
+	}
+
+	resp = env.call("file.get", rpc.FileGetParams{Path: "app.log", Tail: 1, MaxSize: 8})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	var result rpc.FileGetResult
+	json.Unmarshal(resp.Result, &result)
+	if result.File.Content != "third\n" || result.File.Size != 19 || result.File.Range == nil {
+		t.Errorf("unexpected tail: %+v", result.File)
+	}
+
+	resp = env.call("file.get", rpc.FileGetParams{Path: "app.log", Tail: 1, StartLine: 1})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("expected invalid params for conflicting ranges, got %+v", resp.Error)
+	}
+}
+
 func TestHandler_FileGet_NotFound(t *testing.T) {
 	env := newWorkDirTestEnv(t, t.TempDir())
 
@@ -872,6 +950,238 @@ func TestHandler_FileGet_InvalidPath(t *testing.T) {
 	}
 }
 
+func TestHandler_FileWrite(t *testing.T) {
+	workDir := t.TempDir()
+	env := newWorkDirTestEnv(t, workDir)
+	os.WriteFile(filepath.Join(workDir, "file.txt"), []byte("helo"), 0644)
+
+	getResp := env.call("file.get", rpc.FileGetParams{Path: "file.txt"})
+	var getResult rpc.FileGetResult
+	json.Unmarshal(getResp.Result, &getResult)
+
+	resp := env.call("file.write", rpc.FileWriteParams{Path: "file.txt", Content: "hello", ExpectedHash: getResult.File.Hash})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	var result rpc.FileWriteResult
+	json.Unmarshal(resp.Result, &result)
+	if result.File.Size != 5 || result.File.Hash == getResult.File.Hash {
+		t.Errorf("unexpected result: %+v", result.File)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workDir, "file.txt")); string(data) != "hello" {
+		t.Errorf("file content = %q, want hello", data)
+	}
+
+	// A second write based on the stale hash is refused
+	resp = env.call("file.write", rpc.FileWriteParams{Path: "file.txt", Content: "stale", ExpectedHash: getResult.File.Hash})
+	if resp.Error == nil || resp.Error.Code != rpc.CodeFileModified {
+		t.Fatalf("expected CodeFileModified, got %+v", resp.Error)
+	}
+	var current contents.ModifiedError
+	json.Unmarshal(*resp.Error.Data, &current)
+	if current.Hash != result.File.Hash {
+		t.Errorf("error data hash = %q, want current hash %q", current.Hash, result.File.Hash)
+	}
+
+	resp = env.call("file.write", rpc.FileWriteParams{Path: "missing.txt", Content: "x"})
+	if resp.Error == nil || !strings.Contains(resp.Error.Message, "not found") {
+		t.Errorf("expected not found error, got %+v", resp.Error)
+	}
+}
+
+func TestHandler_FileCreateRenameDelete(t *testing.T) {
+	workDir := t.TempDir()
+	env := newWorkDirTestEnv(t, workDir)
+
+	resp := env.call("file.create", rpc.FileCreateParams{Path: "src/new.txt", Content: "new"})
+	if resp.Error != nil {
+		t.Fatalf("create failed: %s", resp.Error.Message)
+	}
+	resp = env.call("file.create", rpc.FileCreateParams{Path: "src/new.txt", Content: "again"})
+	if resp.Error == nil || !strings.Contains(resp.Error.Message, "already exists") {
+		t.Errorf("expected already exists error, got %+v", resp.Error)
+	}
+
+	resp = env.call("file.mkdir", rpc.FileMkdirParams{Path: "docs"})
+	if resp.Error != nil {
+		t.Fatalf("mkdir failed: %s", resp.Error.Message)
+	}
+
+	resp = env.call("file.rename", rpc.FileRenameParams{Path: "src/new.txt", NewPath: "docs/renamed.txt"})
+	if resp.Error != nil {
+		t.Fatalf("rename failed: %s", resp.Error.Message)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workDir, "docs", "renamed.txt")); string(data) != "new" {
+		t.Errorf("renamed file content = %q, want new", data)
+	}
+
+	resp = env.call("file.delete", rpc.FileDeleteParams{Path: "docs"})
+	if resp.Error == nil || !strings.Contains(resp.Error.Message, "not empty") {
+		t.Errorf("expected not empty error, got %+v", resp.Error)
+	}
+	resp = env.call("file.delete", rpc.FileDeleteParams{Path: "docs", Recursive: true})
+	if resp.Error != nil {
+		t.Fatalf("delete failed: %s", resp.Error.Message)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "docs")); !os.IsNotExist(err) {
+		t.Error("directory still exists after delete")
+	}
+}
+
+func TestHandler_FileMutations_RejectUnsafePaths(t *testing.T) {
+	workDir := t.TempDir()
+	env := newWorkDirTestEnv(t, workDir)
+	os.MkdirAll(filepath.Join(workDir, ".git"), 0755)
+	os.WriteFile(filepath.Join(workDir, "file.txt"), []byte("x"), 0644)
+	os.Symlink(t.TempDir(), filepath.Join(workDir, "outside"))
+
+	tests := []struct {
+		method string
+		params any
+	}{
+		{"file.create", rpc.FileCreateParams{Path: ".git/hooks/pre-commit", Content: "x"}},
+		{"file.create", rpc.FileCreateParams{Path: "../escape.txt", Content: "x"}},
+		{"file.create", rpc.FileCreateParams{Path: "outside/escape.txt", Content: "x"}},
+		{"file.mkdir", rpc.FileMkdirParams{Path: ".git/objects"}},
+		{"file.rename", rpc.FileRenameParams{Path: "file.txt", NewPath: ".git/file.txt"}},
+		{"file.delete", rpc.FileDeleteParams{Path: ".git", Recursive: true}},
+		{"file.delete", rpc.FileDeleteParams{Path: ""}},
+	}
+	for _, tt := range tests {
+		resp := env.call(tt.method, tt.params)
+		if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+			t.Errorf("%s %+v: expected invalid params error, got %+v", tt.method, tt.params, resp.Error)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(workDir, ".git")); err != nil {
+		t.Error(".git was modified")
+	}
+}
+
+func TestHandler_FileCreate_NotifiesFSSubscribers(t *testing.T) {
+	workDir := t.TempDir()
+	env := newWorkDirTestEnv(t, workDir)
+
+	subResp := env.call("fs.subscribe", rpc.FSSubscribeParams{Path: ""})
+	if subResp.Error != nil {
+		t.Fatalf("subscribe failed: %s", subResp.Error.Message)
+	}
+
+	resp := env.call("file.create", rpc.FileCreateParams{Path: "new.txt", Content: "x"})
+	if resp.Error != nil {
+		t.Fatalf("create failed: %s", resp.Error.Message)
+	}
+
+	notif := env.readNotification()
+	if notif.Method != "fs.changed" {
+		t.Fatalf("expected fs.changed notification, got %s", notif.Method)
+	}
+	var params rpc.FSChangedParams
+	json.Unmarshal(notif.Params, &params)
+	if len(params.Changes) != 1 || params.Changes[0] != (rpc.FSChange{Path: "new.txt", Op: rpc.FSOpCreate}) {
+		t.Errorf("unexpected changes: %+v", params.Changes)
+	}
+}
+
+func TestHandler_FileFind(t *testing.T) {
+	dir := setupGitRepo(t)
+	os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("build/\n"), 0644)
+	os.MkdirAll(filepath.Join(dir, "src", "components"), 0755)
+	os.WriteFile(filepath.Join(dir, "src", "components", "Button.tsx"), []byte(""), 0644)
+	os.MkdirAll(filepath.Join(dir, "build"), 0755)
+	os.WriteFile(filepath.Join(dir, "build", "Button.js"), []byte(""), 0644)
+	env := newWorkDirTestEnv(t, dir)
+
+	find := func(query string) rpc.FileFindResult {
+		t.Helper()
+		resp := env.call("file.find", rpc.FileFindParams{Query: query})
+		if resp.Error != nil {
+			t.Fatalf("find failed: %s", resp.Error.Message)
+		}
+		var result rpc.FileFindResult
+		json.Unmarshal(resp.Result, &result)
+		return result
+	}
+
+	result := find("button")
+	if result.Total != 1 || result.Results[0].Path != "src/components/Button.tsx" {
+		t.Fatalf("expected only the non-ignored file, got %+v", result)
+	}
+	if len(result.Results[0].Positions) != 6 {
+		t.Errorf("expected 6 highlighted positions, got %v", result.Results[0].Positions)
+	}
+
+	// Files created through the server are found without waiting for fsnotify
+	resp := env.call("file.create", rpc.FileCreateParams{Path: "src/components/ButtonGroup.tsx"})
+	if resp.Error != nil {
+		t.Fatalf("create failed: %s", resp.Error.Message)
+	}
+	result = find("button")
+	if result.Total != 2 || result.Results[0].Path != "src/components/Button.tsx" {
+		t.Errorf("expected the new file to be indexed, got %+v", result)
+	}
+}
+
+func TestHandler_FileSearch(t *testing.T) {
+	dir := setupGitRepo(t)
+	os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("build/\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+	os.MkdirAll(filepath.Join(dir, "build"), 0755)
+	os.WriteFile(filepath.Join(dir, "build", "out.go"), []byte("func main() {}\n"), 0644)
+	env := newWorkDirTestEnv(t, dir)
+
+	resp := env.call("file.search", rpc.FileSearchParams{Query: "func main", ContextLines: 1})
+	if resp.Error != nil {
+		t.Fatalf("search failed: %s", resp.Error.Message)
+	}
+	var result rpc.FileSearchResult
+	json.Unmarshal(resp.Result, &result)
+	if result.ID == "" {
+		t.Fatal("expected search ID")
+	}
+
+	var matches []contents.SearchMatch
+	for {
+		notif := env.readNotification()
+		if notif.Method == "file.search.result" {
+			var params rpc.FileSearchResultParams
+			json.Unmarshal(notif.Params, &params)
+			if params.ID != result.ID {
+				t.Errorf("result ID = %q, want %q", params.ID, result.ID)
+			}
+			matches = append(matches, params.Matches...)
+			continue
+		}
+		if notif.Method != "file.search.done" {
+			t.Fatalf("unexpected notification %s", notif.Method)
+		}
+		var done rpc.FileSearchDoneParams
+		json.Unmarshal(notif.Params, &done)
+		if done.Cancelled || done.Error != "" || done.Matches != 1 {
+			t.Errorf("unexpected done: %+v", done)
+		}
+		break
+	}
+
+	if len(matches) != 1 {
+		t.Fatalf("expected 1 match (ignored file skipped), got %+v", matches)
+	}
+	m := matches[0]
+	if m.Path != "main.go" || m.Line != 3 || m.Column != 1 || len(m.Before) != 1 {
+		t.Errorf("unexpected match: %+v", m)
+	}
+
+	resp = env.call("file.search", rpc.FileSearchParams{Query: "(", Regex: true})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("expected invalid params for bad regex, got %+v", resp.Error)
+	}
+
+	resp = env.call("file.search.cancel", rpc.FileSearchCancelParams{ID: "unknown"})
+	if resp.Error != nil {
+		t.Errorf("cancel of unknown search should succeed: %s", resp.Error.Message)
+	}
+}
+
 // Git RPC tests
 
 func setupGitRepo(t *testing.T) string {
@@ -1030,6 +1340,194 @@ func TestHandler_GitDiffSubscribe_InvalidPath(t *testing.T) {
 	}
 }
 
+func TestHandler_GitDiscardAndRestore(t *testing.T) {
+	dir := setupGitRepo(t)
+	testFile := filepath.Join(dir, "test.txt")
+	os.WriteFile(testFile, []byte("original"), 0644)
+	runGitIn(t, dir, "add", "test.txt")
+	runGitIn(t, dir, "commit", "-m", "initial")
+	os.WriteFile(testFile, []byte("agent edit"), 0644)
+
+	env := newWorkDirTestEnv(t, dir)
+
+	resp := env.call("git.discard", rpc.GitDiscardParams{Paths: []string{"test.txt"}})
+	if resp.Error != nil {
+		t.Fatalf("discard failed: %s", resp.Error.Message)
+	}
+	var discardResult rpc.GitDiscardResult
+	json.Unmarshal(resp.Result, &discardResult)
+	if discardResult.SnapshotID == "" {
+		t.Fatal("expected snapshot ID")
+	}
+
+	if content, _ := os.ReadFile(testFile); string(content) != "original" {
+		t.Errorf("expected discarded content 'original', got %q", content)
+	}
+
+	resp = env.call("git.discard.list", nil)
+	var listResult rpc.GitDiscardListResult
+	json.Unmarshal(resp.Result, &listResult)
+	if len(listResult.Snapshots) != 1 || listResult.Snapshots[0].ID != discardResult.SnapshotID {
+		t.Errorf("expected discard snapshot in list, got %+v", listResult.Snapshots)
+	}
+
+	resp = env.call("git.discard.restore", rpc.GitDiscardRestoreParams{SnapshotID: discardResult.SnapshotID})
+	if resp.Error != nil {
+		t.Fatalf("restore failed: %s", resp.Error.Message)
+	}
+
+	if content, _ := os.ReadFile(testFile); string(content) != "agent edit" {
+		t.Errorf("expected restored content 'agent edit', got %q", content)
+	}
+}
+
+func TestHandler_GitDiscard_InvalidPath(t *testing.T) {
+	dir := setupGitRepo(t)
+	env := newWorkDirTestEnv(t, dir)
+
+	resp := env.call("git.discard", rpc.GitDiscardParams{Paths: []string{"../etc/passwd"}})
+	if resp.Error == nil || !strings.Contains(resp.Error.Message, "invalid path") {
+		t.Errorf("expected 'invalid path' error, got %+v", resp)
+	}
+}
+
+func TestHandler_GitStashApply_ConflictIsStructured(t *testing.T) {
+	dir := setupGitRepo(t)
+	testFile := filepath.Join(dir, "test.txt")
+	os.WriteFile(testFile, []byte("original\n"), 0644)
+	runGitIn(t, dir, "add", "test.txt")
+	runGitIn(t, dir, "commit", "-m", "initial")
+
+	env := newWorkDirTestEnv(t, dir)
+
+	os.WriteFile(testFile, []byte("stashed\n"), 0644)
+	resp := env.call("git.stash.push", rpc.GitStashPushParams{Message: "wip"})
+	if resp.Error != nil {
+		t.Fatalf("stash push failed: %s", resp.Error.Message)
+	}
+
+	resp = env.call("git.stash.list", nil)
+	var listResult rpc.GitStashListResult
+	json.Unmarshal(resp.Result, &listResult)
+	if len(listResult.Stashes) != 1 {
+		t.Fatalf("expected 1 stash, got %+v", listResult.Stashes)
+	}
+
+	os.WriteFile(testFile, []byte("committed\n"), 0644)
+	runGitIn(t, dir, "commit", "-am", "conflicting")
+
+	resp = env.call("git.stash.apply", rpc.GitStashParams{Index: 0})
+	if resp.Error == nil {
+		t.Fatal("expected conflict error")
+	}
+	if resp.Error.Code != rpc.CodeConflict {
+		t.Errorf("expected code %d, got %d", rpc.CodeConflict, resp.Error.Code)
+	}
+	if resp.Error.Data == nil {
+		t.Fatal("expected conflict data")
+	}
+	var conflict git.ConflictError
+	json.Unmarshal(*resp.Error.Data, &conflict)
+	if len(conflict.Paths) != 1 || conflict.Paths[0] != "test.txt" {
+		t.Errorf("expected conflict in test.txt, got %+v", conflict)
+	}
+}
+
+func TestHandler_GitStashDrop_NotFound(t *testing.T) {
+	dir := setupGitRepo(t)
+	env := newWorkDirTestEnv(t, dir)
+
+	resp := env.call("git.stash.drop", rpc.GitStashParams{Index: 0})
+	if resp.Error == nil || !strings.Contains(resp.Error.Message, "stash not found") {
+		t.Errorf("expected 'stash not found' error, got %+v", resp)
+	}
+}
+
+func TestHandler_GitConflictResolveAndMergeContinue(t *testing.T) {
+	dir := setupGitRepo(t)
+	testFile := filepath.Join(dir, "test.txt")
+	os.WriteFile(testFile, []byte("base\n"), 0644)
+	runGitIn(t, dir, "add", "test.txt")
+	runGitIn(t, dir, "commit", "-m", "base")
+	runGitIn(t, dir, "branch", "feature")
+	os.WriteFile(testFile, []byte("ours\n"), 0644)
+	runGitIn(t, dir, "commit", "-am", "ours")
+	runGitIn(t, dir, "checkout", "feature")
+	os.WriteFile(testFile, []byte("theirs\n"), 0644)
+	runGitIn(t, dir, "commit", "-am", "theirs")
+	runGitIn(t, dir, "checkout", "-")
+	exec.Command("git", "-C", dir, "merge", "feature").Run()
+
+	env := newWorkDirTestEnv(t, dir)
+
+	resp := env.call("git.status", nil)
+	var status rpc.GitStatusResult
+	json.Unmarshal(resp.Result, &status)
+	if len(status.Conflicted) != 1 || status.Operation != git.OperationMerge {
+		t.Fatalf("expected conflicted merge, got %+v", status)
+	}
+
+	resp = env.call("git.conflict.get", rpc.GitConflictGetParams{Path: "test.txt"})
+	if resp.Error != nil {
+		t.Fatalf("conflict get failed: %s", resp.Error.Message)
+	}
+	var conflict rpc.GitConflictGetResult
+	json.Unmarshal(resp.Result, &conflict)
+	if conflict.Ours != "ours\n" || conflict.Theirs != "theirs\n" {
+		t.Errorf("unexpected conflict content: %+v", conflict)
+	}
+
+	resp = env.call("git.merge.continue", nil)
+	if resp.Error == nil || resp.Error.Code != rpc.CodeConflict {
+		t.Fatalf("expected conflict error before resolving, got %+v", resp)
+	}
+
+	resp = env.call("git.conflict.resolve", rpc.GitConflictResolveParams{
+		Path:       "test.txt",
+		Resolution: git.ResolutionCustom,
+		Content:    "merged\n",
+	})
+	if resp.Error != nil {
+		t.Fatalf("conflict resolve failed: %s", resp.Error.Message)
+	}
+
+	resp = env.call("git.merge.continue", nil)
+	if resp.Error != nil {
+		t.Fatalf("merge continue failed: %s", resp.Error.Message)
+	}
+
+	if content, _ := os.ReadFile(testFile); string(content) != "merged\n" {
+		t.Errorf("expected merged content, got %q", content)
+	}
+}
+
+func TestHandler_GitBlame(t *testing.T) {
+	dir := setupGitRepo(t)
+	os.WriteFile(filepath.Join(dir, "test.txt"), []byte("a\nb\nc\n"), 0644)
+	runGitIn(t, dir, "add", "test.txt")
+	runGitIn(t, dir, "commit", "-m", "initial")
+
+	env := newWorkDirTestEnv(t, dir)
+
+	resp := env.call("git.blame", rpc.GitBlameParams{Path: "test.txt", StartLine: 2, EndLine: 3})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+
+	var result rpc.GitBlameResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("failed to unmarshal result: %v", err)
+	}
+	if len(result.Lines) != 2 || result.Lines[0].Content != "b" || result.Lines[0].Summary != "initial" {
+		t.Errorf("unexpected blame result: %+v", result.Lines)
+	}
+
+	resp = env.call("git.blame", rpc.GitBlameParams{Path: "test.txt", Rev: "--bogus"})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("expected invalid params error for bad revision, got %+v", resp.Error)
+	}
+}
+
 // Worktree RPC tests
 // Unit tests for worktree logic are in worktree/registry_test.go.
 // These integration tests verify RPC layer behavior only.
@@ -1146,7 +1644,7 @@ func TestHandler_WorktreeCreateAndDelete_E2E(t *testing.T) {
 	}
 }
 
-func TestHandler_WorktreeSwitch(t *testing.T) {
+func TestHandler_WorktreeUpdate(t *testing.T) {
 	dir := setupGitRepo(t)
 	os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Test"), 0644)
 	runGitIn(t, dir, "add", ".")
@@ -1154,34 +1652,292 @@ func TestHandler_WorktreeSwitch(t *testing.T) {
 
 	env := newWorkDirTestEnv(t, dir)
 
-	// Create a worktree to switch to
 	createResp := env.call("worktree.create", rpc.WorktreeCreateParams{
-		Name:   "feature",
-		Branch: "feature-branch",
+		Name:        "feature",
+		Branch:      "feature-branch",
+		Description: "first draft",
 	})
 	if createResp.Error != nil {
 		t.Fatalf("create failed: %s", createResp.Error.Message)
 	}
-
-	// Switch to the new worktree
-	switchResp := env.call("worktree.switch", rpc.WorktreeSwitchParams{Name: "feature"})
-	if switchResp.Error != nil {
-		t.Fatalf("switch failed: %s", switchResp.Error.Message)
+	var createResult rpc.WorktreeCreateResult
+	json.Unmarshal(createResp.Result, &createResult)
+	if createResult.Worktree.Description != "first draft" || createResult.Worktree.CreatedBy != "user" {
+		t.Errorf("unexpected metadata: %+v", createResult.Worktree)
 	}
 
-	var switchResult rpc.WorktreeSwitchResult
-	json.Unmarshal(switchResp.Result, &switchResult)
-
-	if switchResult.WorktreeName != "feature" {
-		t.Errorf("expected worktree_name 'feature', got %q", switchResult.WorktreeName)
-	}
-	if !strings.Contains(switchResult.WorkDir, "feature") {
-		t.Errorf("expected work_dir to contain 'feature', got %q", switchResult.WorkDir)
+	updateResp := env.call("worktree.update", rpc.WorktreeUpdateParams{Name: "feature", Description: "login form"})
+	if updateResp.Error != nil {
+		t.Fatalf("update failed: %s", updateResp.Error.Message)
 	}
 
-	// Switch back to main (empty name)
-	switchResp = env.call("worktree.switch", rpc.WorktreeSwitchParams{Name: ""})
-	if switchResp.Error != nil {
+	listResp := env.call("worktree.list", nil)
+	var listResult rpc.WorktreeListResult
+	json.Unmarshal(listResp.Result, &listResult)
+	var found bool
+	for _, wt := range listResult.Worktrees {
+		if wt.Name == "feature" {
+			found = true
+			if wt.Description != "login form" {
+				t.Errorf("Description = %q, want %q", wt.Description, "login form")
+			}
+			if wt.BaseBranch == "" || wt.CreatedAt == nil {
+				t.Errorf("expected base branch and creation time, got %+v", wt)
+			}
+		}
+	}
+	if !found {
+		t.Error("created worktree not found in list")
+	}
+
+	resp := env.call("worktree.update", rpc.WorktreeUpdateParams{Name: "missing", Description: "x"})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("expected invalid params for unknown worktree, got %+v", resp.Error)
+	}
+}
+
+func TestHandler_WorktreeDiff(t *testing.T) {
+	dir := setupGitRepo(t)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Test"), 0644)
+	runGitIn(t, dir, "add", ".")
+	runGitIn(t, dir, "commit", "-m", "initial")
+
+	env := newWorkDirTestEnv(t, dir)
+
+	createResp := env.call("worktree.create", rpc.WorktreeCreateParams{Name: "feature", Branch: "feature-branch"})
+	if createResp.Error != nil {
+		t.Fatalf("create failed: %s", createResp.Error.Message)
+	}
+	var createResult rpc.WorktreeCreateResult
+	json.Unmarshal(createResp.Result, &createResult)
+	os.WriteFile(filepath.Join(createResult.Worktree.Path, "README.md"), []byte("# Changed"), 0644)
+
+	resp := env.call("worktree.diff", rpc.WorktreeDiffParams{Name: "feature", Path: "README.md"})
+	if resp.Error != nil {
+		t.Fatalf("diff failed: %s", resp.Error.Message)
+	}
+	var result rpc.WorktreeDiffResult
+	json.Unmarshal(resp.Result, &result)
+	if len(result.Files) != 1 || result.Files[0].Path != "README.md" || result.Files[0].Status != "M" {
+		t.Errorf("unexpected files: %+v", result.Files)
+	}
+	if result.OldContent != "# Test" || result.NewContent != "# Changed" || result.Diff == "" {
+		t.Errorf("unexpected file diff: %+v", result)
+	}
+
+	main := ""
+	resp = env.call("worktree.diff", rpc.WorktreeDiffParams{Name: "feature", Against: &main})
+	if resp.Error != nil {
+		t.Fatalf("diff against main failed: %s", resp.Error.Message)
+	}
+	result = rpc.WorktreeDiffResult{}
+	json.Unmarshal(resp.Result, &result)
+	if len(result.Files) != 1 || result.Base != "" {
+		t.Errorf("unexpected result against main: %+v", result)
+	}
+
+	resp = env.call("worktree.diff", rpc.WorktreeDiffParams{Name: "feature", Path: "../secret"})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("expected invalid params for path traversal, got %+v", resp.Error)
+	}
+}
+
+func TestHandler_WorktreePrune(t *testing.T) {
+	dir := setupGitRepo(t)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Test"), 0644)
+	runGitIn(t, dir, "add", ".")
+	runGitIn(t, dir, "commit", "-m", "initial")
+
+	env := newWorkDirTestEnv(t, dir)
+
+	createResp := env.call("worktree.create", rpc.WorktreeCreateParams{Name: "gone", Branch: "gone-branch"})
+	if createResp.Error != nil {
+		t.Fatalf("create failed: %s", createResp.Error.Message)
+	}
+	var createResult rpc.WorktreeCreateResult
+	json.Unmarshal(createResp.Result, &createResult)
+	os.RemoveAll(createResult.Worktree.Path)
+
+	resp := env.call("worktree.prune", nil)
+	if resp.Error != nil {
+		t.Fatalf("prune failed: %s", resp.Error.Message)
+	}
+	var report worktree.PruneReport
+	json.Unmarshal(resp.Result, &report)
+	if len(report.Pruned) != 1 || report.Pruned[0].Name != "gone" {
+		t.Errorf("Pruned = %+v, want gone", report.Pruned)
+	}
+
+	listResp := env.call("worktree.list", nil)
+	var listResult rpc.WorktreeListResult
+	json.Unmarshal(listResp.Result, &listResult)
+	for _, wt := range listResult.Worktrees {
+		if wt.Name == "gone" {
+			t.Error("pruned worktree still in list")
+		}
+	}
+}
+
+func TestHandler_WorktreeCreate_RunsSetup(t *testing.T) {
+	dir := setupGitRepo(t)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Test"), 0644)
+	runGitIn(t, dir, "add", ".")
+	runGitIn(t, dir, "commit", "-m", "initial")
+
+	os.WriteFile(filepath.Join(dir, ".env"), []byte("KEY=1"), 0644)
+	os.MkdirAll(filepath.Join(dir, ".pockode"), 0755)
+	os.WriteFile(filepath.Join(dir, ".pockode", "worktree.json"), []byte(`{"copy": [".env"], "setup": ["echo done"]}`), 0644)
+
+	env := newWorkDirTestEnv(t, dir)
+
+	createResp := env.call("worktree.create", rpc.WorktreeCreateParams{Name: "feature", Branch: "feature-branch"})
+	if createResp.Error != nil {
+		t.Fatalf("create failed: %s", createResp.Error.Message)
+	}
+	var createResult rpc.WorktreeCreateResult
+	json.Unmarshal(createResp.Result, &createResult)
+	if createResult.Worktree.Setup == nil {
+		t.Fatal("expected setup status in create result")
+	}
+
+	var setup *rpc.WorktreeSetupStatus
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var listResult rpc.WorktreeListResult
+		json.Unmarshal(env.call("worktree.list", nil).Result, &listResult)
+		for _, wt := range listResult.Worktrees {
+			if wt.Name == "feature" {
+				setup = wt.Setup
+			}
+		}
+		if setup != nil && setup.State != "running" {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if setup == nil || setup.State != "succeeded" {
+		t.Fatalf("expected succeeded setup, got %+v", setup)
+	}
+
+	data, err := os.ReadFile(filepath.Join(createResult.Worktree.Path, ".env"))
+	if err != nil || string(data) != "KEY=1" {
+		t.Errorf("expected .env copied into worktree, got %q, %v", data, err)
+	}
+}
+
+func TestHandler_WorktreeDelete_RefusesDirtyWorktree(t *testing.T) {
+	dir := setupGitRepo(t)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Test"), 0644)
+	runGitIn(t, dir, "add", ".")
+	runGitIn(t, dir, "commit", "-m", "initial")
+
+	env := newWorkDirTestEnv(t, dir)
+
+	createResp := env.call("worktree.create", rpc.WorktreeCreateParams{Name: "feature", Branch: "feature-branch"})
+	if createResp.Error != nil {
+		t.Fatalf("create failed: %s", createResp.Error.Message)
+	}
+	var createResult rpc.WorktreeCreateResult
+	json.Unmarshal(createResp.Result, &createResult)
+	os.WriteFile(filepath.Join(createResult.Worktree.Path, "README.md"), []byte("changed"), 0644)
+
+	resp := env.call("worktree.delete", rpc.WorktreeDeleteParams{Name: "feature"})
+	if resp.Error == nil || resp.Error.Code != rpc.CodeWorkWouldBeLost {
+		t.Fatalf("expected work-would-be-lost error, got %+v", resp.Error)
+	}
+	var report worktree.DeleteReport
+	if resp.Error.Data == nil || json.Unmarshal(*resp.Error.Data, &report) != nil {
+		t.Fatalf("expected report in error data, got %+v", resp.Error)
+	}
+	if len(report.Uncommitted) != 1 || report.Uncommitted[0].Path != "README.md" {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	resp = env.call("worktree.delete", rpc.WorktreeDeleteParams{Name: "feature", Force: true, DeleteBranch: true})
+	if resp.Error != nil {
+		t.Fatalf("forced delete failed: %s", resp.Error.Message)
+	}
+	var result rpc.WorktreeDeleteResult
+	json.Unmarshal(resp.Result, &result)
+	if !result.BranchDeleted {
+		t.Errorf("expected branch to be deleted, got %+v", result)
+	}
+}
+
+func TestHandler_WorktreeMerge(t *testing.T) {
+	dir := setupGitRepo(t)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Test"), 0644)
+	runGitIn(t, dir, "add", ".")
+	runGitIn(t, dir, "commit", "-m", "initial")
+
+	env := newWorkDirTestEnv(t, dir)
+
+	createResp := env.call("worktree.create", rpc.WorktreeCreateParams{Name: "feature", Branch: "feature-branch"})
+	if createResp.Error != nil {
+		t.Fatalf("create failed: %s", createResp.Error.Message)
+	}
+	var createResult rpc.WorktreeCreateResult
+	json.Unmarshal(createResp.Result, &createResult)
+	wtPath := createResult.Worktree.Path
+	os.WriteFile(filepath.Join(wtPath, "feature.txt"), []byte("feature"), 0644)
+	runGitIn(t, wtPath, "add", "feature.txt")
+	runGitIn(t, wtPath, "commit", "-m", "feature")
+
+	resp := env.call("worktree.merge", rpc.WorktreeMergeParams{Name: "feature", Strategy: "bogus"})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("expected invalid params for unknown strategy, got %+v", resp.Error)
+	}
+
+	resp = env.call("worktree.merge", rpc.WorktreeMergeParams{Name: "feature", DeleteWorktree: true, DeleteBranch: true})
+	if resp.Error != nil {
+		t.Fatalf("merge failed: %s", resp.Error.Message)
+	}
+	var result rpc.WorktreeMergeResult
+	json.Unmarshal(resp.Result, &result)
+	if result.Commit == "" || !result.Deleted || !result.BranchDeleted {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "feature.txt")); err != nil {
+		t.Error("expected merged file in main worktree")
+	}
+}
+
+func TestHandler_WorktreeSwitch(t *testing.T) {
+	dir := setupGitRepo(t)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Test"), 0644)
+	runGitIn(t, dir, "add", ".")
+	runGitIn(t, dir, "commit", "-m", "initial")
+
+	env := newWorkDirTestEnv(t, dir)
+
+	// Create a worktree to switch to
+	createResp := env.call("worktree.create", rpc.WorktreeCreateParams{
+		Name:   "feature",
+		Branch: "feature-branch",
+	})
+	if createResp.Error != nil {
+		t.Fatalf("create failed: %s", createResp.Error.Message)
+	}
+
+	// Switch to the new worktree
+	switchResp := env.call("worktree.switch", rpc.WorktreeSwitchParams{Name: "feature"})
+	if switchResp.Error != nil {
+		t.Fatalf("switch failed: %s", switchResp.Error.Message)
+	}
+
+	var switchResult rpc.WorktreeSwitchResult
+	json.Unmarshal(switchResp.Result, &switchResult)
+
+	if switchResult.WorktreeName != "feature" {
+		t.Errorf("expected worktree_name 'feature', got %q", switchResult.WorktreeName)
+	}
+	if !strings.Contains(switchResult.WorkDir, "feature") {
+		t.Errorf("expected work_dir to contain 'feature', got %q", switchResult.WorkDir)
+	}
+
+	// Switch back to main (empty name)
+	switchResp = env.call("worktree.switch", rpc.WorktreeSwitchParams{Name: ""})
+	if switchResp.Error != nil {
 		t.Fatalf("switch to main failed: %s", switchResp.Error.Message)
 	}
 
@@ -1221,3 +1977,436 @@ func TestHandler_WorktreeSwitch_NotFound(t *testing.T) {
 		t.Errorf("expected 'worktree not found' error, got %q", resp.Error.Message)
 	}
 }
+
+// callAsync sends a request and returns its response along with the notifications
+// received before it, for methods whose notifications may overtake the response.
+func (e *testEnv) callAsync(method string, params interface{}) (rpcResponse, []rpcNotification) {
+	id := e.nextID()
+	data, _ := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: id, Method: method, Params: params})
+	if err := e.conn.Write(e.ctx, websocket.MessageText, data); err != nil {
+		e.t.Fatalf("failed to send: %v", err)
+	}
+
+	var notifs []rpcNotification
+	for {
+		_, msg, err := e.conn.Read(e.ctx)
+		if err != nil {
+			e.t.Fatalf("failed to read: %v", err)
+		}
+		var resp rpcResponse
+		if err := json.Unmarshal(msg, &resp); err == nil && resp.ID == id {
+			return resp, notifs
+		}
+		var notif rpcNotification
+		json.Unmarshal(msg, &notif)
+		notifs = append(notifs, notif)
+	}
+}
+
+// readTerminalOutput reads notifications until the terminal output contains want.
+func (e *testEnv) readTerminalOutput(notifs []rpcNotification, want string) {
+	var output strings.Builder
+	for {
+		for _, notif := range notifs {
+			if notif.Method != "terminal.output" {
+				continue
+			}
+			var params rpc.TerminalOutputParams
+			json.Unmarshal(notif.Params, &params)
+			output.WriteString(params.Data)
+		}
+		if strings.Contains(output.String(), want) {
+			return
+		}
+		notifs = []rpcNotification{e.readNotification()}
+	}
+}
+
+func TestHandler_Terminal(t *testing.T) {
+	workDir := t.TempDir()
+	env := newWorkDirTestEnv(t, workDir)
+
+	resp, notifs := env.callAsync("terminal.open", rpc.TerminalOpenParams{Cols: 90, Rows: 20, Shell: "/bin/sh"})
+	if resp.Error != nil {
+		t.Skipf("terminal unavailable: %s", resp.Error.Message)
+	}
+	var opened rpc.TerminalAttachResult
+	json.Unmarshal(resp.Result, &opened)
+	if opened.ID == "" || opened.Terminal.Cols != 90 || opened.Terminal.Rows != 20 || opened.Terminal.Exited {
+		t.Fatalf("unexpected open result: %+v", opened)
+	}
+	terminalID := opened.Terminal.ID
+
+	// Wait for the prompt: input typed before the shell sets up the terminal may be discarded
+	for len(notifs) == 0 {
+		notifs = append(notifs, env.readNotification())
+	}
+
+	resp, more := env.callAsync("terminal.input", rpc.TerminalInputParams{TerminalID: terminalID, Data: "echo hi$((1+1)); pwd\n"})
+	if resp.Error != nil {
+		t.Fatalf("input failed: %s", resp.Error.Message)
+	}
+	env.readTerminalOutput(more, workDir)
+
+	resp, _ = env.callAsync("terminal.list", nil)
+	var list rpc.TerminalListResult
+	json.Unmarshal(resp.Result, &list)
+	if len(list.Terminals) != 1 || list.Terminals[0].ID != terminalID {
+		t.Fatalf("unexpected terminal list: %+v", list)
+	}
+
+	// Reattaching (as after a reconnect) restores the output so far
+	resp, _ = env.callAsync("terminal.detach", rpc.TerminalDetachParams{ID: opened.ID})
+	if resp.Error != nil {
+		t.Fatalf("detach failed: %s", resp.Error.Message)
+	}
+	resp, _ = env.callAsync("terminal.attach", rpc.TerminalAttachParams{TerminalID: terminalID})
+	if resp.Error != nil {
+		t.Fatalf("attach failed: %s", resp.Error.Message)
+	}
+	var attached rpc.TerminalAttachResult
+	json.Unmarshal(resp.Result, &attached)
+	if attached.ID == "" || !strings.Contains(attached.Scrollback, "hi2") {
+		t.Errorf("expected scrollback with earlier output, got %+v", attached)
+	}
+
+	resp, _ = env.callAsync("terminal.resize", rpc.TerminalResizeParams{TerminalID: terminalID, Cols: 0, Rows: 10})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("expected invalid params for zero size, got %+v", resp.Error)
+	}
+
+	resp, notifs = env.callAsync("terminal.input", rpc.TerminalInputParams{TerminalID: terminalID, Data: "exit 7\n"})
+	if resp.Error != nil {
+		t.Fatalf("input failed: %s", resp.Error.Message)
+	}
+	for i := 0; ; i++ {
+		if i == len(notifs) {
+			notifs = append(notifs, env.readNotification())
+		}
+		if notifs[i].Method != "terminal.exited" {
+			continue
+		}
+		var params rpc.TerminalExitedParams
+		json.Unmarshal(notifs[i].Params, &params)
+		if params.ID != attached.ID || params.ExitCode != 7 {
+			t.Errorf("unexpected exit notification: %+v", params)
+		}
+		break
+	}
+
+	resp, _ = env.callAsync("terminal.close", rpc.TerminalCloseParams{TerminalID: terminalID})
+	if resp.Error != nil {
+		t.Fatalf("close failed: %s", resp.Error.Message)
+	}
+	resp = env.call("terminal.input", rpc.TerminalInputParams{TerminalID: terminalID, Data: "x"})
+	if resp.Error == nil || resp.Error.Message != "terminal not found" {
+		t.Errorf("expected terminal not found after close, got %+v", resp.Error)
+	}
+}
+
+func TestHandler_Task(t *testing.T) {
+	workDir := t.TempDir()
+	os.MkdirAll(filepath.Join(workDir, ".pockode"), 0755)
+	os.WriteFile(filepath.Join(workDir, ".pockode", "tasks.json"), []byte(`{"tasks": [
+		{"name": "greet", "command": "echo hello", "description": "Say hello"}
+	]}`), 0644)
+	env := newWorkDirTestEnv(t, workDir)
+
+	resp := env.call("task.list", nil)
+	var list rpc.TaskListResult
+	json.Unmarshal(resp.Result, &list)
+	if len(list.Tasks) != 1 || list.Tasks[0].Name != "greet" || list.Tasks[0].Description != "Say hello" {
+		t.Fatalf("unexpected task list: %+v", list)
+	}
+
+	resp = env.call("task.subscribe", nil)
+	var sub rpc.TaskSubscribeResult
+	json.Unmarshal(resp.Result, &sub)
+	if sub.ID == "" || len(sub.Runs) != 0 {
+		t.Fatalf("unexpected subscribe result: %+v", sub)
+	}
+
+	resp, notifs := env.callAsync("task.run", rpc.TaskRunParams{Name: "greet"})
+	if resp.Error != nil {
+		t.Fatalf("run failed: %s", resp.Error.Message)
+	}
+	var run rpc.TaskRunResult
+	json.Unmarshal(resp.Result, &run)
+
+	var output string
+	for i := 0; ; i++ {
+		if i == len(notifs) {
+			notifs = append(notifs, env.readNotification())
+		}
+		switch notifs[i].Method {
+		case "task.output":
+			var params rpc.TaskOutputParams
+			json.Unmarshal(notifs[i].Params, &params)
+			if params.RunID != run.RunID || params.Stream != "stdout" {
+				t.Errorf("unexpected output notification: %+v", params)
+			}
+			output += params.Data
+			continue
+		case "task.status":
+			var params rpc.TaskStatusParams
+			json.Unmarshal(notifs[i].Params, &params)
+			if params.Run.ID != run.RunID {
+				t.Errorf("unexpected status notification: %+v", params)
+			}
+			if params.Run.State == task.StateRunning {
+				continue
+			}
+			if params.Run.State != task.StateSucceeded || params.Run.ExitCode == nil || *params.Run.ExitCode != 0 {
+				t.Errorf("unexpected final status: %+v", params.Run)
+			}
+		}
+		break
+	}
+	if output != "hello\n" {
+		t.Errorf("output = %q, want %q", output, "hello\n")
+	}
+
+	resp = env.call("task.history", nil)
+	var history rpc.TaskHistoryResult
+	json.Unmarshal(resp.Result, &history)
+	if len(history.Runs) != 1 || history.Runs[0].ID != run.RunID {
+		t.Errorf("unexpected history: %+v", history)
+	}
+
+	resp = env.call("task.get", rpc.TaskGetParams{RunID: run.RunID})
+	var got rpc.TaskGetResult
+	json.Unmarshal(resp.Result, &got)
+	if got.Output != "hello\n" {
+		t.Errorf("retained output = %q", got.Output)
+	}
+
+	resp = env.call("task.cancel", rpc.TaskCancelParams{RunID: run.RunID})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidRequest {
+		t.Errorf("expected invalid request when cancelling a finished run, got %+v", resp.Error)
+	}
+	resp = env.call("task.run", rpc.TaskRunParams{Name: "missing"})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("expected invalid params for an unknown task, got %+v", resp.Error)
+	}
+}
+
+func TestHandler_PreviewList(t *testing.T) {
+	env := newWorkDirTestEnv(t, t.TempDir())
+
+	resp := env.call("preview.list", nil)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	var result rpc.PreviewListResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("failed to unmarshal result: %v", err)
+	}
+	if result.Ports == nil || len(result.Ports) != 0 {
+		t.Errorf("expected no detected ports, got %v", result.Ports)
+	}
+}
+
+func TestHandler_Snippet(t *testing.T) {
+	mock := &mockAgent{events: []agent.AgentEvent{agent.DoneEvent{}}}
+	env := newTestEnv(t, mock)
+	env.getMainWorktree().SessionStore.Create(bgCtx, "sess")
+
+	resp := env.call("snippet.create", rpc.SnippetCreateParams{Name: "tests", Content: "Write tests for {{selected_file}} in {{worktree}}{{module}}"})
+	if resp.Error != nil {
+		t.Fatalf("create failed: %s", resp.Error.Message)
+	}
+	var global snippet.Snippet
+	json.Unmarshal(resp.Result, &global)
+
+	resp = env.call("snippet.create", rpc.SnippetCreateParams{Name: "local", Content: "local", Scope: snippet.ScopeWorktree})
+	var local snippet.Snippet
+	json.Unmarshal(resp.Result, &local)
+	if resp.Error != nil || local.Scope != snippet.ScopeWorktree {
+		t.Fatalf("unexpected worktree snippet: %+v, %+v", local, resp.Error)
+	}
+
+	resp = env.call("snippet.create", rpc.SnippetCreateParams{Name: "bad", Content: "x", Scope: "team"})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("expected invalid scope error, got %+v", resp.Error)
+	}
+
+	resp = env.call("snippet.expand", rpc.SnippetExpandParams{SnippetUse: rpc.SnippetUse{ID: global.ID}})
+	var expanded rpc.SnippetExpandResult
+	json.Unmarshal(resp.Result, &expanded)
+	if expanded.Content != "Write tests for {{selected_file}} in {{module}}" || len(expanded.Missing) != 2 {
+		t.Errorf("unexpected expansion: %+v", expanded)
+	}
+
+	// Sending refuses to leave placeholders unexpanded
+	resp = env.call("chat.message", rpc.MessageParams{SessionID: "sess", Snippet: &rpc.SnippetUse{ID: global.ID}})
+	if resp.Error == nil || !strings.Contains(resp.Error.Message, "selected_file, module") {
+		t.Fatalf("expected missing variables error, got %+v", resp.Error)
+	}
+
+	// The main worktree's name is empty
+	resp = env.call("chat.message", rpc.MessageParams{SessionID: "sess", Snippet: &rpc.SnippetUse{
+		ID:           global.ID,
+		SelectedFile: "main.go",
+		Variables:    map[string]string{"module": "the server"},
+	}})
+	if resp.Error != nil {
+		t.Fatalf("message failed: %s", resp.Error.Message)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mock.mu.Lock()
+		messages := slices.Clone(mock.messages)
+		mock.mu.Unlock()
+		if len(messages) > 0 {
+			if messages[0] != "Write tests for main.go in the server" {
+				t.Errorf("sent %q", messages[0])
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("message was not sent")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	name := "renamed"
+	resp = env.call("snippet.update", rpc.SnippetUpdateParams{ID: local.ID, Name: &name})
+	if resp.Error != nil {
+		t.Fatalf("update failed: %s", resp.Error.Message)
+	}
+
+	resp = env.call("snippet.list", nil)
+	var list rpc.SnippetListResult
+	json.Unmarshal(resp.Result, &list)
+	if len(list.Snippets) != 2 || list.Snippets[0].ID != global.ID || list.Snippets[0].UseCount != 1 || list.Snippets[1].Name != "renamed" {
+		t.Fatalf("unexpected snippet list: %+v", list.Snippets)
+	}
+
+	resp = env.call("snippet.delete", rpc.SnippetDeleteParams{ID: local.ID})
+	if resp.Error != nil {
+		t.Fatalf("delete failed: %s", resp.Error.Message)
+	}
+	resp = env.call("snippet.delete", rpc.SnippetDeleteParams{ID: local.ID})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("expected not found error, got %+v", resp.Error)
+	}
+}
+
+func TestHandler_Schedule(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	resp := env.call("schedule.create", rpc.ScheduleCreateParams{Name: "nightly", Spec: "0 8 * * *", Prompt: "Run the tests", ReuseSession: true})
+	if resp.Error != nil {
+		t.Fatalf("create failed: %s", resp.Error.Message)
+	}
+	var job schedule.Job
+	json.Unmarshal(resp.Result, &job)
+	if job.ID == "" || job.Mode != session.ModeDefault || job.NextRunAt == nil {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+
+	resp = env.call("schedule.create", rpc.ScheduleCreateParams{Name: "bad", Spec: "every day", Prompt: "x"})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("expected invalid spec error, got %+v", resp.Error)
+	}
+
+	resp = env.call("schedule.pause", rpc.SchedulePauseParams{ID: job.ID})
+	var paused schedule.Job
+	json.Unmarshal(resp.Result, &paused)
+	if resp.Error != nil || !paused.Paused || paused.NextRunAt != nil {
+		t.Errorf("unexpected paused job: %+v, %+v", paused, resp.Error)
+	}
+
+	resp = env.call("schedule.resume", rpc.ScheduleResumeParams{ID: job.ID})
+	var resumed schedule.Job
+	json.Unmarshal(resp.Result, &resumed)
+	if resp.Error != nil || resumed.Paused || resumed.NextRunAt == nil {
+		t.Errorf("unexpected resumed job: %+v, %+v", resumed, resp.Error)
+	}
+
+	resp = env.call("schedule.list", nil)
+	var list rpc.ScheduleListResult
+	json.Unmarshal(resp.Result, &list)
+	if len(list.Jobs) != 1 || list.Jobs[0].ID != job.ID {
+		t.Fatalf("unexpected schedule list: %+v", list.Jobs)
+	}
+
+	resp = env.call("schedule.history", rpc.ScheduleHistoryParams{ID: job.ID})
+	var history rpc.ScheduleHistoryResult
+	json.Unmarshal(resp.Result, &history)
+	if resp.Error != nil || history.Runs == nil || len(history.Runs) != 0 {
+		t.Errorf("unexpected history: %+v, %+v", history, resp.Error)
+	}
+
+	resp = env.call("schedule.delete", rpc.ScheduleDeleteParams{ID: job.ID})
+	if resp.Error != nil {
+		t.Fatalf("delete failed: %s", resp.Error.Message)
+	}
+	resp = env.call("schedule.history", rpc.ScheduleHistoryParams{ID: job.ID})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("expected not found error, got %+v", resp.Error)
+	}
+}
+
+func TestWorktreeManager_SendPrompt(t *testing.T) {
+	mock := &mockAgent{events: []agent.AgentEvent{agent.DoneEvent{}}}
+	env := newTestEnv(t, mock)
+
+	sessionID, err := env.worktreeManager.SendPrompt(bgCtx, "", "", "nightly", "Run the tests", session.ModeYolo)
+	if err != nil {
+		t.Fatalf("SendPrompt() error: %v", err)
+	}
+
+	wt := env.getMainWorktree()
+	meta, found, _ := wt.SessionStore.Get(sessionID)
+	if !found || meta.Title != "nightly" || meta.Mode != session.ModeYolo || !meta.Activated {
+		t.Errorf("unexpected session: %+v", meta)
+	}
+
+	// A session that still exists is reused
+	again, err := env.worktreeManager.SendPrompt(bgCtx, "", sessionID, "nightly", "Run them again", session.ModeYolo)
+	if err != nil || again != sessionID {
+		t.Fatalf("SendPrompt() with session = %q, %v", again, err)
+	}
+
+	// A session the user switched to another mode is left alone
+	if err := wt.SessionStore.SetMode(bgCtx, sessionID, session.ModeDefault); err != nil {
+		t.Fatal(err)
+	}
+	other, err := env.worktreeManager.SendPrompt(bgCtx, "", sessionID, "nightly", "Run them once more", session.ModeYolo)
+	if err != nil || other == sessionID {
+		t.Fatalf("SendPrompt() after a mode change = %q, %v", other, err)
+	}
+	if meta, _, _ := wt.SessionStore.Get(sessionID); meta.Mode != session.ModeDefault {
+		t.Errorf("mode of the user's session changed to %q", meta.Mode)
+	}
+	if meta, _, _ := wt.SessionStore.Get(other); meta.Mode != session.ModeYolo || meta.Title != "nightly" {
+		t.Errorf("unexpected new session: %+v", meta)
+	}
+
+	var messages []string
+	var starts []startCall
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mock.mu.Lock()
+		messages = slices.Clone(mock.messages)
+		starts = slices.Clone(mock.startCalls)
+		mock.mu.Unlock()
+		if len(messages) >= 3 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Sessions deliver independently, so messages are compared regardless of order
+	slices.Sort(messages)
+	if !slices.Equal(messages, []string{"Run the tests", "Run them again", "Run them once more"}) {
+		t.Errorf("sent %q", messages)
+	}
+	if len(starts) != 2 || starts[0].mode != session.ModeYolo || starts[1].mode != session.ModeYolo {
+		t.Errorf("unexpected agent starts: %+v", starts)
+	}
+
+	if _, err := env.worktreeManager.SendPrompt(bgCtx, "missing", "", "t", "p", session.ModeDefault); err == nil {
+		t.Error("SendPrompt() to a missing worktree succeeded")
+	}
+}
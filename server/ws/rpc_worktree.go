@@ -3,26 +3,18 @@ package ws
 import (
 	"context"
 	"errors"
+	"path/filepath"
+	"time"
 
+	"github.com/pockode/server/git"
 	"github.com/pockode/server/rpc"
 	"github.com/pockode/server/worktree"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
 func (h *rpcMethodHandler) handleWorktreeList(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
-	registry := h.worktreeManager.Registry()
-	worktrees := registry.List()
-
 	result := rpc.WorktreeListResult{
-		Worktrees: make([]rpc.WorktreeInfo, len(worktrees)),
-	}
-	for i, wt := range worktrees {
-		result.Worktrees[i] = rpc.WorktreeInfo{
-			Name:   wt.Name,
-			Path:   wt.Path,
-			Branch: wt.Branch,
-			IsMain: wt.IsMain,
-		}
+		Worktrees: h.worktreeManager.List(),
 	}
 
 	if err := conn.Reply(ctx, req.ID, result); err != nil {
@@ -46,8 +38,18 @@ func (h *rpcMethodHandler) handleWorktreeCreate(ctx context.Context, conn *jsonr
 		return
 	}
 
-	registry := h.worktreeManager.Registry()
-	info, err := registry.Create(params.Name, params.Branch, params.BaseBranch)
+	createdBy := params.CreatedBy
+	if createdBy == "" {
+		createdBy = "user"
+	}
+
+	info, err := h.worktreeManager.Create(worktree.CreateOptions{
+		Name:        params.Name,
+		Branch:      params.Branch,
+		BaseBranch:  params.BaseBranch,
+		Description: params.Description,
+		CreatedBy:   createdBy,
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, worktree.ErrNotGitRepo):
@@ -63,18 +65,47 @@ func (h *rpcMethodHandler) handleWorktreeCreate(ctx context.Context, conn *jsonr
 	h.log.Info("worktree created", "name", info.Name, "branch", info.Branch)
 
 	result := rpc.WorktreeCreateResult{
-		Worktree: rpc.WorktreeInfo{
-			Name:   info.Name,
-			Path:   info.Path,
-			Branch: info.Branch,
-			IsMain: info.IsMain,
-		},
+		Worktree: h.worktreeManager.Describe(info),
 	}
 	if err := conn.Reply(ctx, req.ID, result); err != nil {
 		h.log.Error("failed to send worktree create response", "error", err)
 	}
 }
 
+func (h *rpcMethodHandler) handleWorktreeUpdate(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorktreeUpdateParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	if params.Name == "" {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "cannot update main worktree")
+		return
+	}
+
+	if err := h.worktreeManager.UpdateDescription(params.Name, params.Description); err != nil {
+		switch {
+		case errors.Is(err, worktree.ErrNotGitRepo):
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidRequest, "not a git repository")
+		case errors.Is(err, worktree.ErrWorktreeNotFound):
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "worktree not found")
+		default:
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+		}
+		return
+	}
+
+	info, err := h.worktreeManager.Registry().Get(params.Name)
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "worktree not found")
+		return
+	}
+	if err := conn.Reply(ctx, req.ID, rpc.WorktreeCreateResult{Worktree: h.worktreeManager.Describe(info)}); err != nil {
+		h.log.Error("failed to send worktree update response", "error", err)
+	}
+}
+
 func (h *rpcMethodHandler) handleWorktreeDelete(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	var params rpc.WorktreeDeleteParams
 	if err := unmarshalParams(req, &params); err != nil {
@@ -87,9 +118,13 @@ func (h *rpcMethodHandler) handleWorktreeDelete(ctx context.Context, conn *jsonr
 		return
 	}
 
-	registry := h.worktreeManager.Registry()
-	if err := registry.Delete(params.Name); err != nil {
+	opts := worktree.DeleteOptions{Force: params.Force, DeleteBranch: params.DeleteBranch}
+	deleted, err := h.worktreeManager.Delete(params.Name, opts)
+	if err != nil {
+		var unsafe *worktree.UnsafeDeleteError
 		switch {
+		case errors.As(err, &unsafe):
+			h.replyErrorWithData(ctx, conn, req.ID, rpc.CodeWorkWouldBeLost, unsafe.Error(), unsafe.Report)
 		case errors.Is(err, worktree.ErrNotGitRepo):
 			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidRequest, "not a git repository")
 		case errors.Is(err, worktree.ErrWorktreeNotFound):
@@ -100,16 +135,150 @@ func (h *rpcMethodHandler) handleWorktreeDelete(ctx context.Context, conn *jsonr
 		return
 	}
 
-	h.log.Info("worktree deleted", "name", params.Name)
-
-	// Force shutdown the worktree (notifies subscribers internally)
-	h.worktreeManager.ForceShutdown(params.Name)
+	h.log.Info("worktree deleted", "name", params.Name, "force", params.Force, "branchDeleted", deleted.BranchDeleted)
 
-	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+	result := rpc.WorktreeDeleteResult{
+		BranchDeleted: deleted.BranchDeleted,
+		BranchError:   deleted.BranchError,
+	}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
 		h.log.Error("failed to send worktree delete response", "error", err)
 	}
 }
 
+func (h *rpcMethodHandler) handleWorktreeMerge(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorktreeMergeParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	if params.Name == "" {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "name required")
+		return
+	}
+
+	merged, err := h.worktreeManager.Merge(params.Name, worktree.MergeOptions{
+		Target:         params.Target,
+		Strategy:       params.Strategy,
+		Message:        params.Message,
+		DeleteWorktree: params.DeleteWorktree,
+		DeleteBranch:   params.DeleteBranch,
+	})
+	if err != nil {
+		var conflict *git.ConflictError
+		var unsafe *worktree.UnsafeDeleteError
+		switch {
+		case errors.As(err, &conflict):
+			h.replyConflict(ctx, conn, req.ID, conflict)
+		case errors.As(err, &unsafe):
+			h.replyErrorWithData(ctx, conn, req.ID, rpc.CodeWorkWouldBeLost, unsafe.Error(), unsafe.Report)
+		case errors.Is(err, worktree.ErrNotGitRepo):
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidRequest, "not a git repository")
+		case errors.Is(err, worktree.ErrWorktreeNotFound):
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "worktree not found")
+		case errors.Is(err, git.ErrInvalidStrategy), errors.Is(err, git.ErrInvalidRevision), errors.Is(err, worktree.ErrInvalidMergeTarget):
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, err.Error())
+		case errors.Is(err, git.ErrUncommittedChanges), errors.Is(err, worktree.ErrNoBranch):
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidRequest, err.Error())
+		default:
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+		}
+		return
+	}
+
+	h.log.Info("worktree merged", "name", params.Name, "target", merged.Target, "strategy", params.Strategy, "deleted", merged.Deleted)
+
+	result := rpc.WorktreeMergeResult{
+		Target:        merged.Target,
+		Commit:        merged.Commit,
+		UpToDate:      merged.UpToDate,
+		Deleted:       merged.Deleted,
+		BranchDeleted: merged.BranchDeleted,
+		BranchError:   merged.BranchError,
+	}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		h.log.Error("failed to send worktree merge response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleWorktreeDiff(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorktreeDiffParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		return
+	}
+
+	if params.Path != "" && !filepath.IsLocal(params.Path) {
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid path")
+		return
+	}
+
+	diff, err := h.worktreeManager.Diff(params.Name, worktree.DiffOptions{
+		Against: params.Against,
+		Path:    params.Path,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, worktree.ErrNotGitRepo):
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidRequest, "not a git repository")
+		case errors.Is(err, worktree.ErrWorktreeNotFound):
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "worktree not found")
+		case errors.Is(err, worktree.ErrNoBaseBranch):
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidRequest, err.Error())
+		case errors.Is(err, git.ErrInvalidRevision):
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, err.Error())
+		default:
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+		}
+		return
+	}
+
+	result := rpc.WorktreeDiffResult{
+		Base:  diff.Base,
+		Files: diff.Files,
+	}
+	if diff.File != nil {
+		result.Diff = diff.File.Diff
+		result.OldContent = diff.File.OldContent
+		result.NewContent = diff.File.NewContent
+	}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		h.log.Error("failed to send worktree diff response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleWorktreePrune(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorktreePruneParams
+	if req.Params != nil {
+		if err := unmarshalParams(req, &params); err != nil {
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+			return
+		}
+	}
+
+	idleAfter := worktree.DefaultMergedIdleAfter
+	if params.IdleDays > 0 {
+		idleAfter = time.Duration(params.IdleDays) * 24 * time.Hour
+	}
+
+	report, err := h.worktreeManager.Prune(idleAfter, true)
+	if err != nil {
+		if errors.Is(err, worktree.ErrNotGitRepo) {
+			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidRequest, "not a git repository")
+			return
+		}
+		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+		return
+	}
+
+	h.log.Info("worktrees pruned", "pruned", len(report.Pruned), "orphanedData", len(report.OrphanedData), "mergedIdle", len(report.MergedIdle))
+
+	if err := conn.Reply(ctx, req.ID, report); err != nil {
+		h.log.Error("failed to send worktree prune response", "error", err)
+	}
+}
+
 func (h *rpcMethodHandler) handleWorktreeSwitch(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	var params rpc.WorktreeSwitchParams
 	if err := unmarshalParams(req, &params); err != nil {